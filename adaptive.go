@@ -0,0 +1,93 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// An AdaptiveTauEstimator learns the search radius tau, i.e. the distance to
+// the k-th nearest neighbour, from past queries. Searches that start out
+// with a good estimate of tau can prune the tree before they have found k
+// candidates. An AdaptiveTauEstimator is safe for concurrent use.
+type AdaptiveTauEstimator struct {
+	mu       sync.Mutex
+	window   []float64
+	next     int
+	full     bool
+	quantile float64
+}
+
+// NewAdaptiveTauEstimator creates an AdaptiveTauEstimator that remembers the
+// tau of the last n queries and estimates tau as the given quantile of
+// those. Higher quantiles make the estimate too small less often.
+func NewAdaptiveTauEstimator(n int, quantile float64) *AdaptiveTauEstimator {
+	return &AdaptiveTauEstimator{
+		window:   make([]float64, max(n, 1)),
+		quantile: math.Max(0, math.Min(1, quantile)),
+	}
+}
+
+// Observe records the tau of a query.
+func (e *AdaptiveTauEstimator) Observe(tau float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.window[e.next] = tau
+	e.next++
+	if e.next == len(e.window) {
+		e.next, e.full = 0, true
+	}
+}
+
+// Estimate returns the estimated tau for a query for target. The current
+// implementation doesn't look at target. It returns +Inf until a tau has
+// been observed.
+func (e *AdaptiveTauEstimator) Estimate(target interface{}) float64 {
+	e.mu.Lock()
+	n := e.next
+	if e.full {
+		n = len(e.window)
+	}
+	taus := append([]float64(nil), e.window[:n]...)
+	e.mu.Unlock()
+
+	if len(taus) == 0 {
+		return math.Inf(1)
+	}
+
+	sort.Float64s(taus)
+	return taus[int(e.quantile*float64(len(taus)-1))]
+}
+
+// AdaptiveSearch is like Search, but starts with the tau estimated by
+// estimator and reports the resulting tau back to it. If the estimate turns
+// out to be too small to find k neighbours, the search is repeated without
+// it, so the results are always the same as those of Search.
+func (vp *VPTree) AdaptiveSearch(estimator *AdaptiveTauEstimator, target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	q := vp.getKNNQuery(target, k)
+	defer func() { putKNNQuery(q) }()
+	if tau := estimator.Estimate(target); tau < q.tau {
+		// Only items closer than tau are candidates, so nudge it up to
+		// include an item at exactly the estimated distance
+		q.tau = math.Nextafter(tau, math.Inf(1))
+	}
+	vp.runKNN(q)
+
+	if q.len() < k {
+		// The estimate was too small
+		putKNNQuery(q)
+		q = vp.getKNNQuery(target, k)
+		vp.runKNN(q)
+	}
+
+	if q.len() == k {
+		estimator.Observe(q.tau)
+	}
+
+	return itemsAndDistances(q.results())
+}
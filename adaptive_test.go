@@ -0,0 +1,87 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure AdaptiveSearch returns the same results as Search, and
+// that a perfect estimate never costs more metric calls than Search
+func TestAdaptiveSearch(t *testing.T) {
+	_, items := randomCoordinates(10000)
+
+	calls := 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	vp := New(counting, items)
+	estimator := NewAdaptiveTauEstimator(50, 0.9)
+
+	for i := 0; i < 100; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		expectedResults, expectedDistances := vp.Search(q, 10)
+
+		results, distances := vp.AdaptiveSearch(estimator, q, 10)
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+	}
+
+	for i := 0; i < 100; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		calls = 0
+		expectedResults, expectedDistances := vp.Search(q, 10)
+		plainCalls := calls
+
+		perfect := NewAdaptiveTauEstimator(1, 0.5)
+		perfect.Observe(expectedDistances[9])
+
+		calls = 0
+		results, distances := vp.AdaptiveSearch(perfect, q, 10)
+
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+		if calls > plainCalls {
+			t.Errorf("Expected at most the %v metric calls of Search, got %v", plainCalls, calls)
+		}
+	}
+}
+
+// This test makes sure a too small estimate doesn't lose results
+func TestAdaptiveSearchFallback(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	estimator := NewAdaptiveTauEstimator(1, 0.5)
+	estimator.Observe(1e-9)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	expectedResults, expectedDistances := vp.Search(q, 5)
+	results, distances := vp.AdaptiveSearch(estimator, q, 5)
+
+	compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+	if tau := estimator.Estimate(q); tau != distances[4] {
+		t.Errorf("Expected the estimator to learn tau %v, got %v", distances[4], tau)
+	}
+}
+
+// This test checks the estimator's quantiles
+func TestAdaptiveTauEstimator(t *testing.T) {
+	e := NewAdaptiveTauEstimator(5, 0.5)
+
+	if tau := e.Estimate(nil); !math.IsInf(tau, 1) {
+		t.Errorf("Expected +Inf before any observations, got %v", tau)
+	}
+
+	for _, tau := range []float64{9, 1, 2, 3, 4, 5} {
+		e.Observe(tau)
+	}
+
+	// 9 has dropped out of the window
+	if tau := e.Estimate(nil); tau != 3 {
+		t.Errorf("Expected the median 3, got %v", tau)
+	}
+}
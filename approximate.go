@@ -0,0 +1,36 @@
+package vptree
+
+import (
+	"math/rand"
+)
+
+// SearchSampled is an approximate version of Search. Every subtree the
+// search would descend into is skipped with probability 1-sampleFraction,
+// so a sampleFraction of 1 gives the same results as Search, and smaller
+// fractions trade recall for speed.
+func (vp *VPTree) SearchSampled(target interface{}, k int, sampleFraction float64) (results []interface{}, distances []float64) {
+	rng := rand.New(rand.NewSource(rand.Int63()))
+
+	skip := func(n *node) bool {
+		return n != vp.root && rng.Float64() >= sampleFraction
+	}
+
+	return itemsAndDistances(vp.nearestSkipping(target, k, skip))
+}
+
+// SearchTruncated is an approximate version of Search that does not descend
+// into subtrees containing fewer than minSubtreeSize items. The returned bool
+// reports whether any subtree was skipped, that is, whether the results may
+// differ from those of Search.
+func (vp *VPTree) SearchTruncated(target interface{}, k int, minSubtreeSize int) (results []interface{}, distances []float64, truncated bool) {
+	skip := func(n *node) bool {
+		if n != vp.root && n.Size < minSubtreeSize {
+			truncated = true
+			return true
+		}
+		return false
+	}
+
+	results, distances = itemsAndDistances(vp.nearestSkipping(target, k, skip))
+	return
+}
@@ -0,0 +1,110 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomCoordinates(n int) (items []Coordinate, vpitems []interface{}) {
+	for i := 0; i < n; i++ {
+		c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		items = append(items, c)
+		vpitems = append(vpitems, c)
+	}
+	return
+}
+
+// This test makes sure SearchSampled with a sample fraction of 1 is exact
+func TestSearchSampledExact(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	coords1, distances1 := vp.SearchSampled(q, 10, 1)
+	coords2, distances2 := nearestNeighbours(q, items, 10)
+
+	compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+}
+
+// This test makes sure SearchSampled returns a subset of the items, ordered
+// by distance, and that sampling less of the tree does not improve recall
+func TestSearchSampledRecall(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	recall := func(fraction float64) (hits int) {
+		for i := 0; i < 100; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			coords, distances := vp.SearchSampled(q, 10, fraction)
+			expected, _ := nearestNeighbours(q, items, 10)
+
+			for j := 1; j < len(distances); j++ {
+				if distances[j-1] > distances[j] {
+					t.Fatalf("Distances are not sorted: %v", distances)
+				}
+			}
+
+			for _, c := range coords {
+				for _, e := range expected {
+					if c == e {
+						hits++
+					}
+				}
+			}
+		}
+		return
+	}
+
+	if full, low := recall(1), recall(0.2); low > full || full != 1000 {
+		t.Errorf("Expected recall to drop from 1000 hits at fraction 1, got %v at fraction 1 and %v at fraction 0.2", full, low)
+	}
+}
+
+// This test makes sure subtree sizes are tracked correctly
+func TestSubtreeSizes(t *testing.T) {
+	_, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	var check func(n *node) int
+	check = func(n *node) int {
+		if n == nil {
+			return 0
+		}
+		size := 1 + check(n.Left) + check(n.Right)
+		if n.Size != size {
+			t.Errorf("Expected node size %v, got %v", size, n.Size)
+		}
+		return size
+	}
+
+	if size := check(vp.root); size != 1000 {
+		t.Errorf("Expected tree size 1000, got %v", size)
+	}
+}
+
+// This test makes sure SearchTruncated is exact and reports no truncation
+// when nothing is skipped, and reports truncation otherwise
+func TestSearchTruncated(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	coords1, distances1, truncated := vp.SearchTruncated(q, 10, 0)
+	coords2, distances2 := nearestNeighbours(q, items, 10)
+
+	if truncated {
+		t.Error("Expected no truncation with minSubtreeSize 0")
+	}
+	compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+
+	coords, _, truncated := vp.SearchTruncated(q, 10, 100)
+	if !truncated {
+		t.Error("Expected truncation with minSubtreeSize 100")
+	}
+	if len(coords) == 0 {
+		t.Error("Expected at least the root item to be returned")
+	}
+}
@@ -0,0 +1,148 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// A TuneReport holds the measurements AutoTune based its choice on.
+type TuneReport struct {
+	// SampleSize is the number of items the trees were built over.
+	SampleSize int
+
+	// Candidates holds the measurements for each option set tried, in the
+	// order they were tried. Candidates that did not fit into the time
+	// budget are missing.
+	Candidates []TuneCandidate
+
+	// Best is the index of the chosen candidate.
+	Best int
+}
+
+// A TuneCandidate holds the measurements for one option set.
+type TuneCandidate struct {
+	// LeafSize is the leaf size the tree was built with. See WithLeafSize.
+	LeafSize int
+
+	// BuildMetricCalls and BuildTime are the cost of building the tree.
+	BuildMetricCalls int
+	BuildTime        time.Duration
+
+	// QueryMetricCalls and QueryLatency are the mean cost of a query.
+	QueryMetricCalls float64
+	QueryLatency     time.Duration
+
+	// Cost is the number of metric calls needed to build the tree and run
+	// the sample queries, divided by the number of queries. The candidate
+	// with the lowest cost wins.
+	Cost float64
+}
+
+// Options returns the options to build a VP-tree like the candidate's.
+func (c TuneCandidate) Options() []Option {
+	return []Option{WithLeafSize(c.LeafSize)}
+}
+
+// A TuneOption configures AutoTune.
+type TuneOption func(*tuneConfig)
+
+type tuneConfig struct {
+	seed int64
+	k    int
+}
+
+// defaultTuneK is the number of neighbours AutoTune searches for by default.
+const defaultTuneK = 10
+
+// maxTuneSample is the largest number of items AutoTune builds trees over.
+const maxTuneSample = 4096
+
+// tuneLeafSizes are the leaf sizes AutoTune tries, the default first.
+var tuneLeafSizes = []int{1, 2, 4, 8, 16, 32, 64}
+
+// WithTuneSeed seeds the sample of items and the choice of vantage points,
+// which makes AutoTune's choice reproducible.
+func WithTuneSeed(seed int64) TuneOption {
+	return func(c *tuneConfig) {
+		c.seed = seed
+	}
+}
+
+// WithTuneK sets the number of neighbours the sample queries search for.
+// The default is 10.
+func WithTuneK(k int) TuneOption {
+	return func(c *tuneConfig) {
+		c.k = k
+	}
+}
+
+// AutoTune picks build options for a VP-tree over items. It builds trees
+// over a random sample of at most 4096 items with each candidate option
+// set, runs a k-nearest-neighbour search for each of sampleQueries on them,
+// and returns the options of the candidate with the lowest cost, together
+// with the measurements. The options can be passed to New as they are.
+//
+// The cost counts metric calls rather than time, so that the choice only
+// depends on the seed. It includes the calls made by the build, spread over
+// the sample queries, so the number of sample queries should reflect how
+// many queries a tree serves relative to its size.
+//
+// Candidates are tried until budget is used up; the default options are
+// always tried first.
+func AutoTune(metric Metric, items []interface{}, sampleQueries []interface{}, budget time.Duration, opts ...TuneOption) ([]Option, TuneReport) {
+	cfg := tuneConfig{k: defaultTuneK}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	sample := items
+	if len(items) > maxTuneSample {
+		rng := rand.New(rand.NewSource(cfg.seed))
+		sample = make([]interface{}, maxTuneSample)
+		for i, j := range rng.Perm(len(items))[:maxTuneSample] {
+			sample[i] = items[j]
+		}
+	}
+
+	report := TuneReport{SampleSize: len(sample)}
+
+	calls := 0
+	counted := func(a, b interface{}) float64 {
+		calls++
+		return metric(a, b)
+	}
+
+	start := time.Now()
+	for _, leafSize := range tuneLeafSizes {
+		if len(report.Candidates) > 0 && (time.Since(start) > budget || leafSize > max(1, len(sample))) {
+			break
+		}
+
+		// Every candidate picks the same vantage points where it can
+		rng := rand.New(rand.NewSource(cfg.seed))
+		selector := func(items []interface{}, depth int) int {
+			return rng.Intn(len(items))
+		}
+
+		c := TuneCandidate{LeafSize: leafSize}
+
+		calls = 0
+		buildStart := time.Now()
+		tree := NewHierarchical(counted, sample, selector, WithLeafSize(leafSize))
+		c.BuildTime = time.Since(buildStart)
+		c.BuildMetricCalls = calls
+
+		cost := crossoverCost(&calls, sampleQueries, func(q interface{}) { tree.Search(q, cfg.k) })
+		c.QueryMetricCalls, c.QueryLatency = cost.MetricCalls, cost.Latency
+
+		c.Cost = c.QueryMetricCalls + float64(c.BuildMetricCalls)/math.Max(1, float64(len(sampleQueries)))
+
+		report.Candidates = append(report.Candidates, c)
+		if c.Cost < report.Candidates[report.Best].Cost {
+			report.Best = len(report.Candidates) - 1
+		}
+	}
+
+	return report.Candidates[report.Best].Options(), report
+}
@@ -0,0 +1,71 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// clusteredCoordinates returns n coordinates in tight clusters of the given
+// size, scattered over the unit square
+func clusteredCoordinates(rng *rand.Rand, n, size int) []interface{} {
+	var items []interface{}
+	var center Coordinate
+	for i := 0; i < n; i++ {
+		if i%size == 0 {
+			center = Coordinate{X: rng.Float64(), Y: rng.Float64()}
+		}
+		items = append(items, Coordinate{X: center.X + 1e-6*rng.Float64(), Y: center.Y + 1e-6*rng.Float64()})
+	}
+	return items
+}
+
+// This test tunes a tree for tight clusters, where the items of a cluster
+// can't be told apart by their distances, so partitioning them is wasted
+// effort and a larger leaf size should win
+func TestAutoTune(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := clusteredCoordinates(rng, 2000, 50)
+	var queries []interface{}
+	for i := 0; i < 20; i++ {
+		queries = append(queries, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	opts, report := AutoTune(CoordinateMetric, items, queries, time.Minute, WithTuneSeed(1))
+
+	if report.SampleSize != len(items) {
+		t.Errorf("Expected a sample of %v items, got %v", len(items), report.SampleSize)
+	}
+	if len(report.Candidates) != len(tuneLeafSizes) {
+		t.Fatalf("Expected %v candidates, got %v", len(tuneLeafSizes), len(report.Candidates))
+	}
+
+	best := report.Candidates[report.Best]
+	if best.LeafSize <= 1 {
+		t.Errorf("Expected a leaf size above 1 to win on clustered data, got %+v", report.Candidates)
+	}
+	for _, c := range report.Candidates {
+		if c.Cost < best.Cost {
+			t.Errorf("Expected the best candidate to have the lowest cost, got %v and %v", best.Cost, c.Cost)
+		}
+		if c.LeafSize > 1 && c.BuildMetricCalls >= report.Candidates[0].BuildMetricCalls {
+			t.Errorf("Expected leaf size %v to save metric calls during the build", c.LeafSize)
+		}
+	}
+
+	// The options can be used as they are, and the choice is reproducible
+	vp := New(CoordinateMetric, items, opts...)
+	if vp.leafSize != best.LeafSize {
+		t.Errorf("Expected the options to set leaf size %v, got %v", best.LeafSize, vp.leafSize)
+	}
+
+	_, again := AutoTune(CoordinateMetric, items, queries, time.Minute, WithTuneSeed(1))
+	if again.Best != report.Best {
+		t.Errorf("Expected the same choice for the same seed, got %v and %v", report.Best, again.Best)
+	}
+
+	// With no budget, only the default is tried
+	if _, short := AutoTune(CoordinateMetric, items, queries, 0); len(short.Candidates) != 1 || short.Candidates[0].LeafSize != 1 {
+		t.Errorf("Expected only the default candidate with no budget, got %+v", short.Candidates)
+	}
+}
@@ -0,0 +1,27 @@
+package vptree
+
+import "sort"
+
+// SearchBanded returns the items around target grouped into bands of
+// distance, with a single range search. The bands are given by their upper
+// bounds in increasing order: with bands [1, 5, 25], bucket 0 holds the
+// items at distances below 1, bucket 1 those from 1 to below 5, and bucket
+// 2 those from 5 to below 25. Within a bucket, the items are ordered by
+// increasing distance.
+func (vp *VPTree) SearchBanded(target interface{}, bands []float64) [][]interface{} {
+	buckets := make([][]interface{}, len(bands))
+	if len(bands) == 0 {
+		return buckets
+	}
+
+	vp.withinRadius(target, bands[len(bands)-1], func(found []heapItem) {
+		for _, hi := range found {
+			// The first band whose bound is above the distance
+			if b := sort.Search(len(bands), func(i int) bool { return hi.Dist < bands[i] }); b < len(bands) {
+				buckets[b] = append(buckets[b], hi.Item)
+			}
+		}
+	})
+
+	return buckets
+}
@@ -0,0 +1,61 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test compares the bands against brute force, including items exactly
+// on a band boundary
+func TestSearchBanded(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+	bands := []float64{0.1, 0.2, 0.4}
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		buckets := vp.SearchBanded(q, bands)
+
+		if len(buckets) != len(bands) {
+			t.Fatalf("Expected %v buckets, got %v", len(bands), len(buckets))
+		}
+
+		for b, bucket := range buckets {
+			lower := 0.0
+			if b > 0 {
+				lower = bands[b-1]
+			}
+
+			expected := 0
+			for _, c := range coords {
+				if d := CoordinateMetric(c, q); d >= lower && d < bands[b] {
+					expected++
+				}
+			}
+
+			if len(bucket) != expected {
+				t.Errorf("Expected %v items in bucket %v, got %v", expected, b, len(bucket))
+			}
+
+			prev := lower
+			for _, item := range bucket {
+				d := CoordinateMetric(item, q)
+				if d < prev || d >= bands[b] {
+					t.Errorf("Expected distances in [%v, %v) in increasing order, got %v after %v", lower, bands[b], d, prev)
+				}
+				prev = d
+			}
+		}
+	}
+
+	// Items exactly on a boundary belong to the band above it
+	line := New(CoordinateMetric, []interface{}{Coordinate{0, 0}, Coordinate{1, 0}, Coordinate{5, 0}})
+	buckets := line.SearchBanded(Coordinate{0, 0}, []float64{1, 5})
+	if len(buckets[0]) != 1 || len(buckets[1]) != 1 {
+		t.Errorf("Expected one item in each band, got %v", buckets)
+	}
+
+	if buckets := vp.SearchBanded(Coordinate{}, nil); len(buckets) != 0 {
+		t.Errorf("Expected no buckets without bands, got %v", buckets)
+	}
+}
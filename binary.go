@@ -0,0 +1,538 @@
+package vptree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// binaryMagic starts every VP-tree in the binary format.
+const binaryMagic = "VPT\x00"
+
+// binaryVersion is the version of the binary format written by
+// MarshalBinary.
+const binaryVersion = 1
+
+// The flags of the binary format's header say which optional fields are
+// present.
+const (
+	// flagFloat32 marks thresholds stored as float32 rather than float64,
+	// which is only done if that loses no precision.
+	flagFloat32 = 1 << iota
+
+	// flagSpill marks the overlap factor of a spill tree, stored as a
+	// float64 after the header.
+	flagSpill
+
+	// flagDeleted marks the indices of the items Upsert replaced, stored
+	// after the overlap factor.
+	flagDeleted
+
+	knownFlags = flagFloat32 | flagSpill | flagDeleted
+)
+
+// minBinaryNodeSize is the fewest bytes a node takes up in the binary
+// format: one byte each for its index, its children and the length of its
+// item, and a float32 threshold.
+const minBinaryNodeSize = 7
+
+var (
+	// ErrNoItemCodec is returned by MarshalBinary, UnmarshalBinary,
+	// WriteTo and ReadFrom if the VP-tree has no ItemCodec.
+	ErrNoItemCodec = errors.New("vptree: no ItemCodec set")
+
+	// ErrBinaryFormat is returned by UnmarshalBinary and ReadFrom if the
+	// data is not a VP-tree in the binary format, or is corrupt or
+	// truncated.
+	ErrBinaryFormat = errors.New("vptree: malformed binary VP-tree")
+
+	// ErrBinaryVersion is returned by UnmarshalBinary and ReadFrom if the
+	// data was written in a version of the binary format they don't know.
+	ErrBinaryVersion = errors.New("vptree: unsupported binary format version")
+)
+
+// An ItemCodec encodes and decodes the items of a VP-tree for the binary
+// format. AppendItem appends the encoding of item to buf and returns the
+// extended buffer, like append. DecodeItem decodes an item from exactly the
+// bytes AppendItem appended for it; it must not retain data, which is reused
+// for the next item.
+type ItemCodec interface {
+	AppendItem(buf []byte, item interface{}) ([]byte, error)
+	DecodeItem(data []byte) (interface{}, error)
+}
+
+// WithItemCodec sets the ItemCodec MarshalBinary, UnmarshalBinary, WriteTo
+// and ReadFrom encode and decode the items with.
+func WithItemCodec(codec ItemCodec) Option {
+	return func(vp *VPTree) {
+		vp.itemCodec = codec
+	}
+}
+
+// MarshalBinary encodes the VP-tree in a compact binary format, using the
+// ItemCodec set with WithItemCodec. The format only uses fixed-width
+// little-endian integers, IEEE 754 floats and unsigned LEB128 varints (as
+// in Protocol Buffers), so that it can be read in any language; the items
+// are opaque byte strings that the reader decodes on its own. The layout
+// is:
+//
+//	magic      4 bytes   "VPT\x00"
+//	version    1 byte    1
+//	flags      1 byte    bit 0: thresholds are float32, bit 1: spill tree,
+//	                     bit 2: deleted items; the other bits are 0
+//	spill      float64   only if bit 1 of flags is set: the overlap factor
+//	deleted    only if bit 2 of flags is set:
+//	  count      varint    the number of deleted items
+//	  indices    count varints, the indices of the items Upsert replaced,
+//	             in increasing order
+//	count      varint    the number of nodes
+//	nodes      count times, in preorder:
+//	  index      varint    the index of the node's item in the items slice
+//	  children   varint    bit 0: the node has a left child; the bits
+//	                       above: the distance in nodes from this node to
+//	                       its right child, or 0 if it has none
+//	  threshold  float32 or float64, depending on bit 0 of flags
+//	  length     varint    the length of the encoded item
+//	  item       length bytes from ItemCodec.AppendItem
+//
+// A node's left child directly follows it, and its right child follows
+// its left subtree, so the distance to the right child is one more than
+// the number of nodes in the left subtree. The items in a node's left
+// subtree are at most the threshold away from the node's item, and those
+// in its right subtree at least the threshold; nodes of leaves made by
+// WithLeafSize chain their items through left children with an infinite
+// threshold. Thresholds are stored as float32 if none of them loses
+// precision that way, which is the case for metrics with integer
+// distances, for example. The nodes of deleted items stay in the tree, as
+// they split their subtrees, and are marked as deleted again on decoding.
+// Test vectors of canned trees, with their encodings and decoded nodes, are
+// in testdata/binary.
+//
+// MarshalBinary holds the whole encoding in memory; WriteTo streams it.
+func (vp *VPTree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := vp.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// binaryBufferSize is the size of the buffers WriteTo and ReadFrom use.
+const binaryBufferSize = 64 << 10
+
+// WriteTo writes the VP-tree to w in the binary format of MarshalBinary and
+// returns the number of bytes written. It encodes the tree node by node
+// through a buffer of fixed size, so that its memory use doesn't depend on
+// the size of the tree. Errors of w are returned with the node that was
+// being written.
+func (vp *VPTree) WriteTo(w io.Writer) (int64, error) {
+	if vp.itemCodec == nil {
+		return 0, ErrNoItemCodec
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriterSize(cw, binaryBufferSize)
+
+	flags := byte(flagFloat32)
+	vp.Walk(func(n NodeInfo) bool {
+		if float64(float32(n.Threshold)) != n.Threshold {
+			flags &^= flagFloat32
+			return false
+		}
+		return true
+	})
+	if vp.spill != 0 {
+		flags |= flagSpill
+	}
+	if len(vp.deleted) > 0 {
+		flags |= flagDeleted
+	}
+
+	buf := append([]byte(binaryMagic), binaryVersion, flags)
+	if flags&flagSpill != 0 {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(vp.spill))
+	}
+	if flags&flagDeleted != 0 {
+		deleted := vp.deletedIndices()
+		buf = binary.AppendUvarint(buf, uint64(len(deleted)))
+		for _, index := range deleted {
+			buf = binary.AppendUvarint(buf, uint64(index))
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(vp.root.size()))
+	if _, err := bw.Write(buf); err != nil {
+		return cw.n, fmt.Errorf("vptree: writing header: %w", err)
+	}
+
+	e := binaryEncoder{bw: bw, codec: vp.itemCodec, float32: flags&flagFloat32 != 0}
+	if err := e.encode(vp.root); err != nil {
+		return cw.n, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return cw.n, fmt.Errorf("vptree: writing nodes: %w", err)
+	}
+
+	return cw.n, nil
+}
+
+// A binaryEncoder writes nodes in the binary format.
+type binaryEncoder struct {
+	bw      *bufio.Writer
+	codec   ItemCodec
+	float32 bool
+
+	// buf and item are reused for every node
+	buf, item []byte
+}
+
+// encode writes the subtree rooted at n in preorder. The size of a node is
+// the number of nodes in its subtree, so the right child's offset is one
+// more than the size of the left subtree.
+func (e *binaryEncoder) encode(n *node) error {
+	if n == nil {
+		return nil
+	}
+
+	buf := binary.AppendUvarint(e.buf[:0], uint64(n.Index))
+
+	children := uint64(0)
+	if n.Left != nil {
+		children = 1
+	}
+	if n.Right != nil {
+		children |= uint64(1+n.Left.size()) << 1
+	}
+	buf = binary.AppendUvarint(buf, children)
+
+	if e.float32 {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(n.Threshold)))
+	} else {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(n.Threshold))
+	}
+
+	var err error
+	if e.item, err = e.codec.AppendItem(e.item[:0], n.Item); err != nil {
+		return fmt.Errorf("vptree: encoding item %v: %w", n.Index, err)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(e.item)))
+	e.buf = buf
+
+	if _, err := e.bw.Write(buf); err != nil {
+		return fmt.Errorf("vptree: writing item %v: %w", n.Index, err)
+	}
+	if _, err := e.bw.Write(e.item); err != nil {
+		return fmt.Errorf("vptree: writing item %v: %w", n.Index, err)
+	}
+
+	if err := e.encode(n.Left); err != nil {
+		return err
+	}
+	return e.encode(n.Right)
+}
+
+// A countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// UnmarshalBinary replaces the VP-tree with one decoded from data, which
+// MarshalBinary has written, using the tree's ItemCodec for the items. The
+// tree has to have been created with the metric the encoded tree was built
+// with, e.g. with New(metric, nil, WithItemCodec(codec)). Data that is not
+// in the binary format, or that is corrupt or truncated, returns an error
+// wrapping ErrBinaryFormat, and a version of the format UnmarshalBinary
+// doesn't know returns ErrBinaryVersion. The tree is only modified if
+// decoding succeeds.
+func (vp *VPTree) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	if _, err := vp.readFrom(r, len(data)); err != nil {
+		return err
+	}
+
+	if r.Len() > 0 {
+		return fmt.Errorf("%w: %v bytes of trailing data", ErrBinaryFormat, r.Len())
+	}
+
+	return nil
+}
+
+// ReadFrom replaces the VP-tree with one read from r in the binary format,
+// like UnmarshalBinary, and returns the number of bytes read. It decodes the
+// tree node by node, so that apart from the tree itself, its memory use
+// doesn't depend on the size of the tree. Unless r is an io.ByteReader,
+// ReadFrom reads through a buffer and may consume data after the end of the
+// tree. Errors of r other than io.EOF are returned with the node that was
+// being read.
+func (vp *VPTree) ReadFrom(r io.Reader) (int64, error) {
+	br, ok := r.(binaryReader)
+	if !ok {
+		br = bufio.NewReaderSize(r, binaryBufferSize)
+	}
+
+	return vp.readFrom(br, -1)
+}
+
+// A binaryReader is what ReadFrom reads the binary format from.
+type binaryReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// readFrom decodes a VP-tree from r. If limit is not negative, it is the
+// number of bytes r holds, which bounds the number of nodes it can hold.
+func (vp *VPTree) readFrom(r binaryReader, limit int) (int64, error) {
+	if vp.itemCodec == nil {
+		return 0, ErrNoItemCodec
+	}
+
+	d := binaryDecoder{r: r}
+
+	var header [len(binaryMagic) + 2]byte
+	if err := d.full(header[:]); err != nil {
+		return d.n, fmt.Errorf("vptree: reading header: %w", err)
+	}
+	if string(header[:len(binaryMagic)]) != binaryMagic {
+		return d.n, ErrBinaryFormat
+	}
+	if header[len(binaryMagic)] != binaryVersion {
+		return d.n, ErrBinaryVersion
+	}
+
+	flags := header[len(binaryMagic)+1]
+	if flags&^knownFlags != 0 {
+		return d.n, fmt.Errorf("%w: unknown flags %#x", ErrBinaryFormat, flags)
+	}
+
+	spill := 0.0
+	if flags&flagSpill != 0 {
+		spill = math.Float64frombits(d.uint64())
+	}
+
+	var deleted map[int]bool
+	if flags&flagDeleted != 0 {
+		deleted = make(map[int]bool)
+		last := -1
+		for i, n := uint64(0), d.uvarint(); i < n && d.err == nil; i++ {
+			index := d.uvarint()
+			if d.err == nil && (index > math.MaxInt || int(index) <= last) {
+				return d.n, fmt.Errorf("%w: deleted items out of order", ErrBinaryFormat)
+			}
+			last = int(index)
+			deleted[last] = true
+		}
+	}
+
+	count := d.uvarint()
+	if d.err != nil {
+		return d.n, fmt.Errorf("vptree: reading header: %w", d.err)
+	}
+
+	// Every node takes up a few bytes, which bounds the number of nodes
+	// the data can hold before anything is allocated for them. Streams
+	// have no known length, so their arena only grows as nodes arrive.
+	capacity := uint64(binaryBufferSize)
+	if limit >= 0 {
+		if count > uint64(limit/minBinaryNodeSize) {
+			return d.n, fmt.Errorf("%w: %v nodes do not fit into %v bytes", ErrBinaryFormat, count, limit)
+		}
+		capacity = count
+	}
+	arena := make([]node, 0, min(count, capacity))
+
+	// A node's left child comes right after it, and its right child after
+	// its left subtree. leftOf says whether the node before has a left
+	// child, and rights holds the positions of the right children that are
+	// still to come. The links are made once all nodes are in the arena,
+	// which may move while it grows, so until then, the size of every node
+	// holds its children as encoded.
+	leftOf := false
+	var rights []uint64
+	var item []byte
+	maxIndex := -1
+
+	for i := uint64(0); i < count; i++ {
+		switch {
+		case i == 0:
+		case leftOf:
+			leftOf = false
+		case len(rights) > 0 && rights[len(rights)-1] == i:
+			rights = rights[:len(rights)-1]
+		default:
+			return d.n, fmt.Errorf("%w: node %v is no child of an earlier node", ErrBinaryFormat, i)
+		}
+
+		var n node
+		index := d.uvarint()
+		children := d.uvarint()
+		if flags&flagFloat32 != 0 {
+			n.Threshold = float64(math.Float32frombits(d.uint32()))
+		} else {
+			n.Threshold = math.Float64frombits(d.uint64())
+		}
+		item = d.bytes(item, d.uvarint())
+		if d.err != nil {
+			return d.n, fmt.Errorf("vptree: reading node %v: %w", i, d.err)
+		}
+
+		if index > math.MaxInt {
+			return d.n, fmt.Errorf("%w: node %v has index %v", ErrBinaryFormat, i, index)
+		}
+		n.Index = int(index)
+		maxIndex = max(maxIndex, n.Index)
+
+		leftOf = children&1 != 0
+		if offset := children >> 1; offset > 0 {
+			if offset >= count-i {
+				return d.n, fmt.Errorf("%w: node %v has its right child out of range", ErrBinaryFormat, i)
+			}
+			rights = append(rights, i+offset)
+		}
+		n.Size = int(min(children, math.MaxInt))
+
+		var err error
+		if n.Item, err = vp.itemCodec.DecodeItem(item); err != nil {
+			return d.n, fmt.Errorf("vptree: decoding item %v: %w", n.Index, err)
+		}
+
+		arena = append(arena, n)
+	}
+
+	if leftOf || len(rights) > 0 {
+		return d.n, fmt.Errorf("%w: nodes missing", ErrBinaryFormat)
+	}
+	for index := range deleted {
+		if index > maxIndex {
+			return d.n, fmt.Errorf("%w: deleted item %v out of range", ErrBinaryFormat, index)
+		}
+	}
+
+	// Children come after their parents, so the links and sizes can be
+	// filled in backwards
+	for i := len(arena) - 1; i >= 0; i-- {
+		n := &arena[i]
+		children := uint64(n.Size)
+		if children&1 != 0 {
+			n.Left = &arena[i+1]
+		}
+		if offset := children >> 1; offset > 0 {
+			n.Right = &arena[i+int(offset)]
+		}
+		n.Size = 1 + n.Left.size() + n.Right.size()
+	}
+
+	if vp.nodePool != nil && cap(vp.arena) > 0 {
+		vp.nodePool.put(vp.arena)
+	}
+	vp.arena, vp.root, vp.spill = arena, nil, spill
+	vp.deleted, vp.nextIndex, vp.upserts = deleted, 0, 0
+	if len(arena) > 0 {
+		vp.root = &arena[0]
+	}
+	vp.mutated("ReadFrom")
+
+	return d.n, nil
+}
+
+// A binaryDecoder reads the fields of the binary format from r, counting the
+// bytes read and recording the first error instead of returning it, so that
+// a sequence of reads can be checked once.
+type binaryDecoder struct {
+	r   binaryReader
+	n   int64
+	err error
+
+	// buf holds fixed-size fields
+	buf [8]byte
+}
+
+// fail records err, turning the end of the data into an error of the format.
+func (d *binaryDecoder) fail(err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: %w", ErrBinaryFormat, io.ErrUnexpectedEOF)
+	}
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// full reads exactly len(p) bytes into p and returns the error, if any.
+func (d *binaryDecoder) full(p []byte) error {
+	if d.err != nil {
+		return d.err
+	}
+	n, err := io.ReadFull(d.r, p)
+	d.n += int64(n)
+	if err != nil {
+		d.fail(err)
+	}
+	return d.err
+}
+
+func (d *binaryDecoder) uint32() uint32 {
+	if d.full(d.buf[:4]) != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(d.buf[:4])
+}
+
+func (d *binaryDecoder) uint64() uint64 {
+	if d.full(d.buf[:8]) != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(d.buf[:8])
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(byteCounter{d})
+	if err != nil && d.err == nil {
+		// byteCounter records the errors of the reader, so this is an
+		// overlong varint
+		d.fail(fmt.Errorf("%w: %w", ErrBinaryFormat, err))
+	}
+	return v
+}
+
+// bytes reads n bytes into buf, growing it if needed, and returns them. The
+// buffer only grows as the bytes arrive, so that a corrupt length can't
+// make it allocate more memory than the data holds.
+func (d *binaryDecoder) bytes(buf []byte, n uint64) []byte {
+	buf = buf[:0]
+	for d.err == nil && uint64(len(buf)) < n {
+		chunk := min(n-uint64(len(buf)), binaryBufferSize)
+		buf = slices.Grow(buf, int(chunk))[:len(buf)+int(chunk)]
+		d.full(buf[uint64(len(buf))-chunk:])
+	}
+	return buf
+}
+
+// A byteCounter reads single bytes for binary.ReadUvarint, counting them and
+// recording the errors of the reader.
+type byteCounter struct {
+	d *binaryDecoder
+}
+
+func (bc byteCounter) ReadByte() (byte, error) {
+	b, err := bc.d.r.ReadByte()
+	if err != nil {
+		bc.d.fail(err)
+	} else {
+		bc.d.n++
+	}
+	return b, err
+}
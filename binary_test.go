@@ -0,0 +1,529 @@
+package vptree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// coordinateCodec encodes Coordinates as two little-endian float64s.
+type coordinateCodec struct{}
+
+func (coordinateCodec) AppendItem(buf []byte, item interface{}) ([]byte, error) {
+	c := item.(Coordinate)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.X))
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.Y)), nil
+}
+
+func (coordinateCodec) DecodeItem(data []byte) (interface{}, error) {
+	if len(data) != 16 {
+		return nil, errors.New("expected 16 bytes")
+	}
+	return Coordinate{
+		X: math.Float64frombits(binary.LittleEndian.Uint64(data)),
+		Y: math.Float64frombits(binary.LittleEndian.Uint64(data[8:])),
+	}, nil
+}
+
+// This test round-trips trees through MarshalBinary and UnmarshalBinary
+func TestBinaryRoundTrip(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+
+	// Integer coordinates under the Manhattan distance give thresholds
+	// that fit into a float32
+	var grid []interface{}
+	for i := 0; i < 200; i++ {
+		grid = append(grid, Coordinate{float64(rand.Intn(100)), float64(rand.Intn(100))})
+	}
+	manhattan := func(a, b interface{}) float64 {
+		p, q := a.(Coordinate), b.(Coordinate)
+		return math.Abs(p.X-q.X) + math.Abs(p.Y-q.Y)
+	}
+
+	codec := WithItemCodec(coordinateCodec{})
+
+	for name, vp := range map[string]*VPTree{
+		"Tree":  New(CoordinateMetric, vpitems, codec),
+		"Leaf":  New(CoordinateMetric, vpitems, codec, WithLeafSize(8)),
+		"Spill": NewSpillTree(CoordinateMetric, vpitems, 0.1),
+		"Grid":  New(manhattan, grid, codec),
+		"Empty": New(CoordinateMetric, nil, codec),
+	} {
+		vp.itemCodec = coordinateCodec{}
+
+		data, err := vp.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		if float32s := data[len(binaryMagic)+1]&flagFloat32 != 0; float32s != (name == "Grid" || name == "Empty") {
+			t.Errorf("%v: expected float32 thresholds only for integer distances, got %v", name, float32s)
+		}
+
+		loaded := New(vp.distanceMetric, nil, codec)
+		if err := loaded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(walkInfos(loaded), walkInfos(vp)) || loaded.spill != vp.spill || loaded.Len() != vp.Len() {
+			t.Errorf("%v: the decoded tree differs from the original", name)
+		}
+
+		if name == "Tree" || name == "Leaf" {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			coords1, distances1 := loaded.Search(q, 10)
+			coords2, distances2 := nearestNeighbours(q, items, 10)
+
+			compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+		}
+	}
+}
+
+// This test makes sure the items Upsert replaced stay deleted after a round
+// trip through MarshalBinary and UnmarshalBinary, and that corrupt indices of
+// deleted items fail cleanly
+func TestBinaryUpsert(t *testing.T) {
+	_, vpitems := randomCoordinates(50)
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}))
+
+	old := vpitems[0]
+	vp.Upsert(old, Coordinate{X: 2, Y: 2})
+	vp.Upsert(vpitems[1], Coordinate{X: 3, Y: 3})
+
+	data, err := vp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{}))
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != 50 {
+		t.Errorf("Expected 50 items, got %v", loaded.Len())
+	}
+	if results, _ := loaded.SearchRadius(old, 0); len(results) != 0 {
+		t.Errorf("Expected the replaced item to stay deleted, got %v", results)
+	}
+	if results, _ := loaded.SearchRadius(Coordinate{X: 2, Y: 2}, 0); len(results) != 1 {
+		t.Errorf("Expected to find the new item, got %v", results)
+	}
+
+	// The deleted items follow the flags: a count of 2 and the indices 0
+	// and 1
+	header := len(binaryMagic) + 2
+	if !bytes.Equal(data[header:header+3], []byte{2, 0, 1}) {
+		t.Fatalf("Expected the deleted items 0 and 1, got %v", data[header:header+3])
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[header+1] = 127
+	if err := loaded.UnmarshalBinary(corrupt); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("Expected ErrBinaryFormat for a deleted item out of range, got %v", err)
+	}
+
+	corrupt = bytes.Clone(data)
+	corrupt[header+1], corrupt[header+2] = 1, 0
+	if err := loaded.UnmarshalBinary(corrupt); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("Expected ErrBinaryFormat for deleted items out of order, got %v", err)
+	}
+}
+
+// This test truncates an encoded tree and flips each of its bytes in turn,
+// and makes sure UnmarshalBinary fails cleanly rather than panicking
+func TestBinaryCorruption(t *testing.T) {
+	_, vpitems := randomCoordinates(20)
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}))
+
+	data, err := vp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(data); i++ {
+		loaded := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{}))
+		if err := loaded.UnmarshalBinary(data[:i]); err == nil {
+			t.Fatalf("Expected an error for data truncated to %v bytes", i)
+		}
+		if loaded.Len() != 0 {
+			t.Fatalf("Expected a failed UnmarshalBinary to leave the tree alone")
+		}
+	}
+
+	corrupt := make([]byte, len(data))
+	for i := range data {
+		copy(corrupt, data)
+		corrupt[i] ^= 0xff
+
+		// Flipping the bytes of a threshold or an item may still give a
+		// valid tree, but decoding must never panic
+		loaded := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{}))
+		if err := loaded.UnmarshalBinary(corrupt); err == nil {
+			loaded.Search(Coordinate{}, 5)
+		}
+	}
+
+	copy(corrupt, data)
+	corrupt[len(binaryMagic)]++
+	if err := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{})).UnmarshalBinary(corrupt); !errors.Is(err, ErrBinaryVersion) {
+		t.Errorf("Expected ErrBinaryVersion, got %v", err)
+	}
+
+	if err := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{})).UnmarshalBinary([]byte("not a tree")); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("Expected ErrBinaryFormat, got %v", err)
+	}
+
+	if _, err := New(CoordinateMetric, vpitems).MarshalBinary(); !errors.Is(err, ErrNoItemCodec) {
+		t.Errorf("Expected ErrNoItemCodec, got %v", err)
+	}
+}
+
+// gobNode is the node of a VP-tree encoded with gob, for comparison.
+type gobNode struct {
+	Index       int
+	Threshold   float64
+	Left, Right int
+	Item        Coordinate
+}
+
+func BenchmarkBinary(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+	vp := New(CoordinateMetric, items, WithItemCodec(coordinateCodec{}))
+
+	b.Run("Binary", func(b *testing.B) {
+		size := 0
+		for i := 0; i < b.N; i++ {
+			data, err := vp.MarshalBinary()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{})).UnmarshalBinary(data); err != nil {
+				b.Fatal(err)
+			}
+			size = len(data)
+		}
+		b.ReportMetric(float64(size), "bytes")
+	})
+
+	b.Run("Gob", func(b *testing.B) {
+		var nodes []*node
+		vp.collectNodes(vp.root, &nodes)
+		pos := make(map[*node]int, len(nodes))
+		for i, n := range nodes {
+			pos[n] = i
+		}
+		position := func(n *node) int {
+			if n == nil {
+				return -1
+			}
+			return pos[n]
+		}
+
+		size := 0
+		for i := 0; i < b.N; i++ {
+			gobNodes := make([]gobNode, len(nodes))
+			for j, n := range nodes {
+				gobNodes[j] = gobNode{n.Index, n.Threshold, position(n.Left), position(n.Right), n.Item.(Coordinate)}
+			}
+
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(gobNodes); err != nil {
+				b.Fatal(err)
+			}
+			size = buf.Len()
+
+			var decoded []gobNode
+			if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(size), "bytes")
+	})
+}
+
+// limitedWriter fails once more than limit bytes have been written.
+type limitedWriter struct {
+	limit int
+	short bool
+}
+
+var errLimit = errors.New("limit reached")
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) <= lw.limit {
+		lw.limit -= len(p)
+		return len(p), nil
+	}
+
+	n := lw.limit
+	lw.limit = 0
+	if lw.short {
+		return n, nil
+	}
+	return n, errLimit
+}
+
+// failingReader returns err once the data is used up.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	if len(fr.data) == 0 {
+		return 0, fr.err
+	}
+	n := copy(p, fr.data)
+	fr.data = fr.data[n:]
+	return n, nil
+}
+
+// This test streams trees through WriteTo and ReadFrom and compares the
+// stream with the output of MarshalBinary
+func TestBinaryStreaming(t *testing.T) {
+	_, vpitems := randomCoordinates(20000)
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}))
+
+	data, err := vp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if n, err := vp.WriteTo(&buf); err != nil || n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("Expected WriteTo to write the %v bytes of MarshalBinary, wrote %v with error %v", len(data), n, err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := vp.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+
+	loaded := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{}))
+	if n, err := loaded.ReadFrom(pr); err != nil || n != int64(len(data)) {
+		t.Fatalf("Expected ReadFrom to read %v bytes, read %v with error %v", len(data), n, err)
+	}
+	if !reflect.DeepEqual(walkInfos(loaded), walkInfos(vp)) {
+		t.Errorf("The streamed tree differs from the original")
+	}
+
+	// Encoding a tree needs the same few buffers whatever its size
+	if allocs := testing.AllocsPerRun(1, func() { vp.WriteTo(io.Discard) }); allocs > 10 {
+		t.Errorf("Expected WriteTo to allocate a few buffers, got %v allocations", allocs)
+	}
+}
+
+// This test makes sure WriteTo and ReadFrom report the errors of the
+// underlying writer and reader, and truncated streams
+func TestBinaryStreamingErrors(t *testing.T) {
+	_, vpitems := randomCoordinates(20000)
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}))
+
+	data, err := vp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := vp.WriteTo(&limitedWriter{limit: len(data) / 2}); !errors.Is(err, errLimit) || n != int64(len(data)/2) {
+		t.Errorf("Expected the writer's error after %v bytes, got %v after %v", len(data)/2, err, n)
+	}
+	if _, err := vp.WriteTo(&limitedWriter{limit: len(data) / 2, short: true}); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Expected io.ErrShortWrite, got %v", err)
+	}
+
+	errBroken := errors.New("broken")
+	loaded := New(CoordinateMetric, nil, WithItemCodec(coordinateCodec{}))
+	if _, err := loaded.ReadFrom(&failingReader{data[:len(data)/2], errBroken}); !errors.Is(err, errBroken) || errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("Expected the reader's error, got %v", err)
+	}
+	if _, err := loaded.ReadFrom(&failingReader{data[:len(data)/2], io.EOF}); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("Expected ErrBinaryFormat for a truncated stream, got %v", err)
+	}
+	if loaded.Len() != 0 {
+		t.Errorf("Expected a failed ReadFrom to leave the tree alone")
+	}
+}
+
+// intCodec encodes ints as little-endian int64s.
+type intCodec struct{}
+
+func (intCodec) AppendItem(buf []byte, item interface{}) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(buf, uint64(item.(int))), nil
+}
+
+func (intCodec) DecodeItem(data []byte) (interface{}, error) {
+	if len(data) != 8 {
+		return nil, errors.New("expected 8 bytes")
+	}
+	return int(binary.LittleEndian.Uint64(data)), nil
+}
+
+// A binaryVector describes a test vector of the binary format for
+// implementations in other languages: the file holding the encoding, the
+// header fields and the nodes in preorder, with their children given by
+// their position in preorder, or -1, and their items as hex.
+type binaryVector struct {
+	Name    string             `json:"name"`
+	File    string             `json:"file"`
+	Float32 bool               `json:"float32"`
+	Spill   float64            `json:"spill"`
+	Nodes   []binaryVectorNode `json:"nodes"`
+}
+
+type binaryVectorNode struct {
+	Index     int    `json:"index"`
+	Threshold string `json:"threshold"`
+	Left      int    `json:"left"`
+	Right     int    `json:"right"`
+	Item      string `json:"item"`
+}
+
+// describeVector appends the subtree rooted at n to v.Nodes in preorder and
+// returns the position of n, or -1 if n is nil.
+func describeVector(v *binaryVector, codec ItemCodec, n *node) int {
+	if n == nil {
+		return -1
+	}
+
+	item, _ := codec.AppendItem(nil, n.Item)
+	pos := len(v.Nodes)
+	v.Nodes = append(v.Nodes, binaryVectorNode{
+		Index:     n.Index,
+		Threshold: strconv.FormatFloat(n.Threshold, 'g', -1, 64),
+		Item:      hex.EncodeToString(item),
+	})
+
+	left := describeVector(v, codec, n.Left)
+	right := describeVector(v, codec, n.Right)
+	v.Nodes[pos].Left, v.Nodes[pos].Right = left, right
+
+	return pos
+}
+
+// This test checks the canned trees of testdata/binary against their
+// encodings and descriptions, which implementations of the binary format in
+// other languages can validate against. Run with -update to regenerate
+// them.
+func TestBinaryVectors(t *testing.T) {
+	first := WithVantagePointSelector(func(items []interface{}, depth int) int { return 0 })
+	line := func(a, b interface{}) float64 {
+		return math.Abs(float64(a.(int) - b.(int)))
+	}
+	ints := []interface{}{7, 1, 12, 5, 9, 3, 20, 14, 2}
+	coords := []interface{}{
+		Coordinate{0, 0}, Coordinate{1, 0}, Coordinate{0, 2}, Coordinate{3, 3},
+		Coordinate{5, 1}, Coordinate{2, 7}, Coordinate{4, 4},
+	}
+
+	vectors := []struct {
+		name  string
+		codec ItemCodec
+		build func() *VPTree
+
+		// Spill trees are built randomly, so only their round trip is
+		// checked
+		random bool
+	}{
+		{"empty", intCodec{}, func() *VPTree { return New(line, nil, first) }, false},
+		{"single", intCodec{}, func() *VPTree { return New(line, ints[:1], first) }, false},
+		{"float32", intCodec{}, func() *VPTree { return New(line, ints, first) }, false},
+		{"float64", coordinateCodec{}, func() *VPTree { return New(CoordinateMetric, coords, first) }, false},
+		{"leaf", intCodec{}, func() *VPTree { return New(line, ints, first, WithLeafSize(3)) }, false},
+		{"spill", intCodec{}, func() *VPTree { return NewSpillTree(line, ints, 0.25) }, true},
+	}
+
+	dir := filepath.Join("testdata", "binary")
+	manifest := filepath.Join(dir, "vectors.json")
+
+	var descriptions []binaryVector
+	for _, v := range vectors {
+		file := filepath.Join(dir, v.name+".vpt")
+
+		var data []byte
+		if *updateGolden {
+			vp := v.build()
+			vp.itemCodec = v.codec
+
+			var err error
+			if data, err = vp.MarshalBinary(); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := New(nil, nil, WithItemCodec(v.codec))
+		if err := loaded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v: %v", v.name, err)
+		}
+
+		reencoded, err := loaded.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reencoded, data) {
+			t.Errorf("%v: the decoded tree encodes to different bytes", v.name)
+		}
+
+		if !v.random {
+			vp := v.build()
+			vp.itemCodec = v.codec
+			if encoded, _ := vp.MarshalBinary(); !bytes.Equal(encoded, data) {
+				t.Errorf("%v: expected the encoding\n%x\ngot\n%x", v.name, data, encoded)
+			}
+		}
+
+		d := binaryVector{
+			Name:    v.name,
+			File:    v.name + ".vpt",
+			Float32: data[len(binaryMagic)+1]&flagFloat32 != 0,
+			Spill:   loaded.spill,
+		}
+		describeVector(&d, v.codec, loaded.root)
+		descriptions = append(descriptions, d)
+	}
+
+	described, err := json.MarshalIndent(descriptions, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	described = append(described, '\n')
+
+	if *updateGolden {
+		if err := os.WriteFile(manifest, described, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(described, expected) {
+		t.Errorf("Expected the descriptions\n%s\ngot\n%s", expected, described)
+	}
+}
@@ -0,0 +1,208 @@
+package vptree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ErrNonIntegerDistance is returned by BKTree when the metric returns a
+// distance that is not a non-negative integer.
+var ErrNonIntegerDistance = errors.New("vptree: distance is not a non-negative integer")
+
+// A BKTree is a Burkhard-Keller tree, an alternative to a VP-tree for
+// metrics that only take small integer values, such as the Hamming or the
+// Levenshtein distance. Every node buckets its children by their exact
+// distance to its item, so a search for the items within radius r of a
+// target at distance d of a node only descends into the buckets from d-r to
+// d+r. Unlike a VPTree, a BKTree is built by inserting the items one at a
+// time, without partitioning them.
+//
+// The metric must return non-negative integers, see ErrNonIntegerDistance.
+type BKTree struct {
+	root           *bkNode
+	distanceMetric Metric
+	size           int
+}
+
+type bkNode struct {
+	Item  interface{}
+	Index int
+
+	// Dist is the distance to the parent's item, and Children are sorted
+	// by theirs. Equal items are children at distance 0.
+	Dist     int
+	Children []*bkNode
+}
+
+// NewBK creates a new BKTree using the metric and items provided, inserting
+// the items in order. It returns an ErrNonIntegerDistance error if the
+// metric returns a distance that is not a non-negative integer.
+func NewBK(metric Metric, items []interface{}) (*BKTree, error) {
+	t := &BKTree{distanceMetric: metric}
+
+	for _, item := range items {
+		if _, err := t.Insert(item); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Insert adds item to the BKTree and returns its index, which counts the
+// items in the order they were inserted. It returns an ErrNonIntegerDistance
+// error, and leaves the BKTree unchanged, if the metric returns a distance
+// that is not a non-negative integer. Insert must not be called
+// concurrently with other methods of the BKTree.
+func (t *BKTree) Insert(item interface{}) (int, error) {
+	leaf := &bkNode{Item: item, Index: t.size}
+
+	if t.root == nil {
+		t.root = leaf
+		t.size++
+		return leaf.Index, nil
+	}
+
+	n := t.root
+	for {
+		d, err := t.distance(n.Item, item)
+		if err != nil {
+			return 0, err
+		}
+
+		i := sort.Search(len(n.Children), func(i int) bool {
+			return n.Children[i].Dist >= d
+		})
+		if i < len(n.Children) && n.Children[i].Dist == d {
+			n = n.Children[i]
+			continue
+		}
+
+		leaf.Dist = d
+		n.Children = append(n.Children, nil)
+		copy(n.Children[i+1:], n.Children[i:])
+		n.Children[i] = leaf
+
+		t.size++
+		return leaf.Index, nil
+	}
+}
+
+// distance returns the distance between a and b as an int.
+func (t *BKTree) distance(a, b interface{}) (int, error) {
+	d := t.distanceMetric(a, b)
+	if !(d >= 0 && d <= math.MaxInt32 && d == math.Trunc(d)) {
+		return 0, fmt.Errorf("%w: %v", ErrNonIntegerDistance, d)
+	}
+	return int(d), nil
+}
+
+// Len returns the number of items in the BKTree.
+func (t *BKTree) Len() int {
+	return t.size
+}
+
+// Search searches the BKTree for the k nearest neighbours of target. It
+// returns the up to k nearest neighbours and the corresponding distances in
+// order of least distance to largest distance.
+func (t *BKTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	q := getKNNQuery(target, k, t.size)
+	defer putKNNQuery(q)
+	t.search(q, t.root)
+
+	return itemsAndDistances(q.results())
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (t *BKTree) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	q := getKNNQuery(target, k, t.size)
+	defer putKNNQuery(q)
+	t.search(q, t.root)
+
+	for _, hi := range q.results() {
+		indices = append(indices, hi.Index)
+		distances = append(distances, hi.Dist)
+	}
+	return
+}
+
+// search is a search for the items within tau, where tau shrinks to the
+// distance of the k-th nearest neighbour found so far.
+func (t *BKTree) search(q *knnQuery, n *bkNode) {
+	if n == nil {
+		return
+	}
+
+	dist := t.distanceMetric(n.Item, q.target)
+	if dist < q.tau {
+		q.add(heapItem{n.Item, n.Index, dist})
+	}
+
+	// Search the buckets from the one at dist outwards, so that tau
+	// shrinks early. Tau may shrink in between, so every bucket is checked
+	// right before it is searched.
+	hi := sort.Search(len(n.Children), func(i int) bool {
+		return float64(n.Children[i].Dist) >= dist
+	})
+	lo := hi - 1
+
+	for lo >= 0 || hi < len(n.Children) {
+		var c *bkNode
+		if hi == len(n.Children) || lo >= 0 && dist-float64(n.Children[lo].Dist) < float64(n.Children[hi].Dist)-dist {
+			c = n.Children[lo]
+			lo--
+		} else {
+			c = n.Children[hi]
+			hi++
+		}
+
+		// Buckets farther away from dist are out of reach as well
+		if math.Abs(float64(c.Dist)-dist) > q.tau {
+			break
+		}
+		t.search(q, c)
+	}
+}
+
+// SearchRadius searches the BKTree for all items within distance radius of
+// target. It returns the items and the corresponding distances in order of
+// least distance to largest distance.
+func (t *BKTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	var found []heapItem
+	t.searchRadius(t.root, target, radius, &found)
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Dist < found[j].Dist
+	})
+
+	return itemsAndDistances(found)
+}
+
+func (t *BKTree) searchRadius(n *bkNode, target interface{}, radius float64, found *[]heapItem) {
+	if n == nil {
+		return
+	}
+
+	dist := t.distanceMetric(n.Item, target)
+	if dist <= radius {
+		*found = append(*found, heapItem{n.Item, n.Index, dist})
+	}
+
+	i := sort.Search(len(n.Children), func(i int) bool {
+		return float64(n.Children[i].Dist) >= dist-radius
+	})
+	for ; i < len(n.Children) && float64(n.Children[i].Dist) <= dist+radius; i++ {
+		t.searchRadius(n.Children[i], target, radius, found)
+	}
+}
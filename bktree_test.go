@@ -0,0 +1,90 @@
+package vptree_test
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+	"github.com/DataWraith/vptree/metrics"
+)
+
+// This test checks a BKTree over a dictionary of 100,000 misspelled words
+// against a linear scan, and compares its metric calls with a VPTree's
+func TestBKTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	bases := baseWords(rng, 10000)
+	words := misspelledWords(rng, bases, 100000)
+	queries := misspelledWords(rng, bases, 20)
+
+	metric, calls := countingLevenshtein()
+	bk, err := vptree.NewBK(metric, words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp := vptree.New(metric, words)
+	linear := vptree.NewLinearIndex(metrics.Levenshtein, words)
+
+	if bk.Len() != len(words) {
+		t.Errorf("Expected %v items, got %v", len(words), bk.Len())
+	}
+
+	var bkCalls, vpCalls int64
+	for _, q := range queries {
+		k := rng.Intn(10) + 1
+		_, expected := linear.Search(q, k)
+
+		calls.Store(0)
+		_, distances := bk.Search(q, k)
+		bkCalls += calls.Load()
+		if !slices.Equal(distances, expected) {
+			t.Errorf("Expected distances %v for %q, got %v", expected, q, distances)
+		}
+
+		calls.Store(0)
+		vp.Search(q, k)
+		vpCalls += calls.Load()
+
+		radius := float64(rng.Intn(4))
+		_, expected = linear.SearchRadius(q, radius)
+		_, distances = bk.SearchRadius(q, radius)
+		if !slices.Equal(distances, expected) {
+			t.Errorf("Expected distances %v within %v of %q, got %v", expected, radius, q, distances)
+		}
+	}
+
+	t.Logf("Metric calls per search: BKTree %v, VPTree %v", bkCalls/int64(len(queries)), vpCalls/int64(len(queries)))
+	if bkCalls >= vpCalls {
+		t.Errorf("Expected the BKTree to call the metric less often than the VPTree, got %v >= %v", bkCalls, vpCalls)
+	}
+}
+
+// This test makes sure a BKTree rejects distances that are not non-negative
+// integers, and stays unchanged when it does
+func TestBKTreeNonInteger(t *testing.T) {
+	if _, err := vptree.NewBK(pointMetric, []interface{}{point{0, 0}, point{1, 1}}); !errors.Is(err, vptree.ErrNonIntegerDistance) {
+		t.Errorf("Expected ErrNonIntegerDistance, got %v", err)
+	}
+
+	bk, err := vptree.NewBK(pointMetric, []interface{}{point{0, 0}, point{3, 4}, point{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bk.Insert(point{1, 1}); !errors.Is(err, vptree.ErrNonIntegerDistance) {
+		t.Errorf("Expected ErrNonIntegerDistance, got %v", err)
+	}
+	if bk.Len() != 3 {
+		t.Errorf("Expected 3 items after a failed insert, got %v", bk.Len())
+	}
+
+	if index, err := bk.Insert(point{6, 8}); err != nil || index != 3 {
+		t.Errorf("Expected index 3, got %v, %v", index, err)
+	}
+
+	indices, distances := bk.SearchIndices(point{0, 0}, 4)
+	if !slices.Equal(indices[2:], []int{1, 3}) || !slices.Equal(distances, []float64{0, 0, 5, 10}) {
+		t.Errorf("Expected two items at 0, then indices 1 and 3 at 5 and 10, got %v at %v", indices, distances)
+	}
+}
@@ -0,0 +1,47 @@
+package vptree
+
+// A BuildReport describes a VP-tree built by NewWithReport.
+type BuildReport struct {
+	BuildStats
+
+	// Nodes is the number of nodes in the tree.
+	Nodes int
+
+	// MaxDepth and AverageDepth are the largest and the mean depth of the
+	// nodes, where the root is at depth 0.
+	MaxDepth     int
+	AverageDepth float64
+}
+
+// NewWithReport is like New, but also reports what the build did. The
+// metric calls are counted where the build computes distances, so they are
+// exact unless a VantagePointSelector calls the metric itself. Comparing
+// reports across versions shows when changes to the partitioning or the
+// selection of vantage points make builds more expensive or trees deeper.
+func NewWithReport(metric Metric, items []interface{}, opts ...Option) (vp *VPTree, report BuildReport) {
+	var hook func(BuildStats)
+	capture := func(t *VPTree) {
+		hook = t.hooks.OnBuildDone
+		t.hooks.OnBuildDone = func(stats BuildStats) {
+			report.BuildStats = stats
+			if hook != nil {
+				hook(stats)
+			}
+		}
+	}
+
+	vp = New(metric, items, append(opts, capture)...)
+	vp.hooks.OnBuildDone = hook
+
+	depths := 0
+	for d, count := range vp.LevelCounts() {
+		report.Nodes += count
+		report.MaxDepth = d
+		depths += d * count
+	}
+	if report.Nodes > 0 {
+		report.AverageDepth = float64(depths) / float64(report.Nodes)
+	}
+
+	return
+}
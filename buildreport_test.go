@@ -0,0 +1,40 @@
+package vptree
+
+import "testing"
+
+// This test checks the report of a build against a counting metric
+func TestNewWithReport(t *testing.T) {
+	_, items := randomCoordinates(1000)
+
+	for _, leafSize := range []int{1, 16} {
+		calls := 0
+		counting := func(a, b interface{}) float64 {
+			calls++
+			return CoordinateMetric(a, b)
+		}
+
+		hookCalled := false
+		vp, report := NewWithReport(counting, items, WithLeafSize(leafSize), WithInstrumentation(Hooks{
+			OnBuildDone: func(BuildStats) { hookCalled = true },
+		}))
+
+		if !hookCalled {
+			t.Errorf("Expected the OnBuildDone hook to be called")
+		}
+		if report.MetricCalls != calls {
+			t.Errorf("Expected %v metric calls, got %v", calls, report.MetricCalls)
+		}
+		if report.Items != len(items) || report.Nodes != len(items) {
+			t.Errorf("Expected %v items and nodes, got %v and %v", len(items), report.Items, report.Nodes)
+		}
+		if report.MaxDepth != report.Height-1 || report.MaxDepth != height(vp.root)-1 {
+			t.Errorf("Expected a max depth of %v, got %v", height(vp.root)-1, report.MaxDepth)
+		}
+		if report.AverageDepth <= 0 || report.AverageDepth > float64(report.MaxDepth) {
+			t.Errorf("Expected an average depth between 0 and %v, got %v", report.MaxDepth, report.AverageDepth)
+		}
+		if report.Duration <= 0 {
+			t.Errorf("Expected a positive duration, got %v", report.Duration)
+		}
+	}
+}
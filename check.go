@@ -0,0 +1,88 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// checkTolerance is the relative rounding error CheckMetric allows for.
+const checkTolerance = 1e-9
+
+// maxReportedViolations limits the number of violations a MetricError lists.
+const maxReportedViolations = 10
+
+// A MetricError is returned by CheckMetric and lists the ways in which a
+// function failed to be a metric.
+type MetricError struct {
+	Violations []string
+}
+
+func (e *MetricError) Error() string {
+	return "vptree: not a metric: " + strings.Join(e.Violations, "; ")
+}
+
+// CheckMetric tests whether metric behaves like a metric on the given items,
+// that is, whether it is non-negative and symmetric, whether d(x, x) = 0,
+// and whether the triangle inequality holds for every triple of items, up to
+// a small relative rounding error. It returns a *MetricError describing the
+// violations it found, or nil.
+//
+// Since all triples are checked, the running time is cubic in the number of
+// items, so CheckMetric is meant to be used on small samples.
+func CheckMetric(metric Metric, items []interface{}) error {
+	var violations []string
+	report := func(format string, args ...interface{}) bool {
+		violations = append(violations, fmt.Sprintf(format, args...))
+		return len(violations) < maxReportedViolations
+	}
+
+	n := len(items)
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+	}
+
+	for i, x := range items {
+		for j, y := range items {
+			dist[i][j] = metric(x, y)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if math.Abs(dist[i][i]) > checkTolerance {
+			if !report("d(x, x) = %v for x = %v", dist[i][i], items[i]) {
+				return &MetricError{violations}
+			}
+		}
+
+		for j := 0; j < n; j++ {
+			if dist[i][j] < 0 || math.IsNaN(dist[i][j]) {
+				if !report("d(x, y) = %v for x = %v, y = %v", dist[i][j], items[i], items[j]) {
+					return &MetricError{violations}
+				}
+			}
+
+			if j > i && math.Abs(dist[i][j]-dist[j][i]) > checkTolerance*math.Max(dist[i][j], dist[j][i]) {
+				if !report("d(x, y) = %v but d(y, x) = %v for x = %v, y = %v", dist[i][j], dist[j][i], items[i], items[j]) {
+					return &MetricError{violations}
+				}
+			}
+
+			for k := 0; k < n; k++ {
+				bound := dist[i][k] + dist[k][j]
+				if dist[i][j] > bound+checkTolerance*bound {
+					if !report("d(x, z) = %v > d(x, y) + d(y, z) = %v for x = %v, y = %v, z = %v", dist[i][j], bound, items[i], items[k], items[j]) {
+						return &MetricError{violations}
+					}
+				}
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &MetricError{violations}
+	}
+
+	return nil
+}
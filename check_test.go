@@ -0,0 +1,45 @@
+package vptree
+
+import (
+	"math"
+	"testing"
+)
+
+// This test makes sure CheckMetric accepts the Euclidean distance
+func TestCheckMetricEuclidean(t *testing.T) {
+	_, vpitems := randomCoordinates(30)
+
+	if err := CheckMetric(CoordinateMetric, vpitems); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+// This test makes sure CheckMetric rejects the squared Euclidean distance,
+// which violates the triangle inequality
+func TestCheckMetricSquaredEuclidean(t *testing.T) {
+	squared := func(a, b interface{}) float64 {
+		return math.Pow(CoordinateMetric(a, b), 2)
+	}
+
+	items := []interface{}{Coordinate{0, 0}, Coordinate{1, 0}, Coordinate{2, 0}}
+
+	err := CheckMetric(squared, items)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if len(err.(*MetricError).Violations) == 0 {
+		t.Error("Expected the error to list violations")
+	}
+}
+
+// This test makes sure CheckMetric rejects asymmetric and negative distances
+func TestCheckMetricAsymmetric(t *testing.T) {
+	asymmetric := func(a, b interface{}) float64 {
+		return a.(Coordinate).X - b.(Coordinate).X
+	}
+
+	if err := CheckMetric(asymmetric, []interface{}{Coordinate{0, 0}, Coordinate{1, 0}}); err == nil {
+		t.Error("Expected an error")
+	}
+}
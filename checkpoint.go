@@ -0,0 +1,320 @@
+package vptree
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+var (
+	// ErrCheckpointMismatch is returned by ResumeFromCheckpoint if the
+	// checkpoint was written for a different number of items.
+	ErrCheckpointMismatch = errors.New("vptree: checkpoint does not match the items")
+
+	// ErrCheckpointFormat is returned by ResumeFromCheckpoint if the
+	// checkpoint is corrupt or truncated.
+	ErrCheckpointFormat = errors.New("vptree: malformed checkpoint")
+)
+
+// A checkpoint is the state of an interrupted build. Items are referred to
+// by their index in the items the build was started with, and nodes by
+// their position in the arena, so the checkpoint never has to encode the
+// items themselves.
+type checkpoint struct {
+	Items   int
+	Indices []int
+	Nodes   []checkpointNode
+	Pending []buildTask
+}
+
+type checkpointNode struct {
+	Index       int
+	Size        int
+	Threshold   float64
+	Left, Right int
+}
+
+// A buildTask is a subtree that is still to be built from the items at
+// positions [Lo, Hi) of the build's permutation of the items, as the left
+// or right child of the node at position Parent in the arena, or as the
+// root if Parent is -1.
+type buildTask struct {
+	Lo, Hi int
+	Depth  int
+	Parent int
+	Right  bool
+}
+
+// BuildWithCheckpoint builds a VP-tree like New, but saves its progress to
+// cpFile after every cpInterval nodes, so that a build that is interrupted
+// can be continued with ResumeFromCheckpoint instead of starting over. The
+// checkpoint file is removed once the build completes.
+//
+// Checkpoints hold the shape of the partial tree and the order of the item
+// indices, but not the items, which the caller has to provide again when
+// resuming. Every checkpoint is written to a temporary file first and then
+// renamed to cpFile, so a crash while saving leaves the previous checkpoint
+// intact.
+func BuildWithCheckpoint(metric Metric, items []interface{}, cpFile string, cpInterval int, opts ...Option) (*VPTree, error) {
+	indices := make([]int, len(items))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	cp := &checkpoint{
+		Items:   len(items),
+		Indices: indices,
+	}
+	if len(items) > 0 {
+		cp.Pending = []buildTask{{Lo: 0, Hi: len(items), Parent: -1}}
+	}
+
+	return buildCheckpointed(metric, items, cp, cpFile, cpInterval, opts)
+}
+
+// ResumeFromCheckpoint continues a build started by BuildWithCheckpoint
+// from the last checkpoint saved to cpFile. The items must be the ones the
+// build was started with, in the same order, and opts should be the same
+// as well. Further checkpoints are saved to cpFile every cpInterval nodes.
+// A checkpoint for a different number of items returns
+// ErrCheckpointMismatch, and one that is corrupt or truncated an error
+// wrapping ErrCheckpointFormat.
+//
+// The OnBuildDone hook of WithInstrumentation only counts the metric calls
+// and the time of the resumed part of the build.
+func ResumeFromCheckpoint(cpFile string, metric Metric, items []interface{}, cpInterval int, opts ...Option) (*VPTree, error) {
+	f, err := os.Open(cpFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cp checkpoint
+	if err := gob.NewDecoder(f).Decode(&cp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCheckpointFormat, err)
+	}
+
+	if cp.Items != len(items) {
+		return nil, ErrCheckpointMismatch
+	}
+	if err := cp.validate(); err != nil {
+		return nil, err
+	}
+
+	return buildCheckpointed(metric, items, &cp, cpFile, cpInterval, opts)
+}
+
+// validate checks that the indices, nodes and tasks of cp are in range,
+// so that building from it can't fail, and returns an error wrapping
+// ErrCheckpointFormat if they are not.
+func (cp *checkpoint) validate() error {
+	if len(cp.Indices) != cp.Items {
+		return fmt.Errorf("%w: %v indices for %v items", ErrCheckpointFormat, len(cp.Indices), cp.Items)
+	}
+
+	seen := make([]bool, cp.Items)
+	for _, idx := range cp.Indices {
+		if idx < 0 || idx >= cp.Items || seen[idx] {
+			return fmt.Errorf("%w: item index %v out of range or repeated", ErrCheckpointFormat, idx)
+		}
+		seen[idx] = true
+	}
+
+	// Children come after their parents in the arena
+	for i, cn := range cp.Nodes {
+		if cn.Index < 0 || cn.Index >= cp.Items {
+			return fmt.Errorf("%w: node %v has item index %v", ErrCheckpointFormat, i, cn.Index)
+		}
+		for _, c := range [2]int{cn.Left, cn.Right} {
+			if c != -1 && (c <= i || c >= len(cp.Nodes)) {
+				return fmt.Errorf("%w: node %v has its child out of range", ErrCheckpointFormat, i)
+			}
+		}
+	}
+
+	// Every task builds at least one node, and the arena has to have room
+	// for all of them
+	nodes := len(cp.Nodes)
+	for _, task := range cp.Pending {
+		if task.Lo < 0 || task.Lo >= task.Hi || task.Hi > cp.Items || task.Depth < 0 {
+			return fmt.Errorf("%w: task [%v, %v) out of range", ErrCheckpointFormat, task.Lo, task.Hi)
+		}
+		if task.Parent < -1 || task.Parent >= len(cp.Nodes) {
+			return fmt.Errorf("%w: task [%v, %v) has its parent out of range", ErrCheckpointFormat, task.Lo, task.Hi)
+		}
+		nodes += task.Hi - task.Lo
+	}
+	if nodes > cp.Items {
+		return fmt.Errorf("%w: more nodes than items", ErrCheckpointFormat)
+	}
+
+	return nil
+}
+
+// buildCheckpointed builds the tasks pending in cp, saving the state every
+// cpInterval nodes.
+func buildCheckpointed(metric Metric, items []interface{}, cp *checkpoint, cpFile string, cpInterval int, opts []Option) (*VPTree, error) {
+	t := &VPTree{
+		distanceMetric: metric,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.internStrings {
+		items = slices.Clone(items)
+		internStrings(items)
+	}
+
+	// The arena has room for every item, so it never moves and nodes can
+	// be found by their position in it
+	if t.nodePool != nil && len(items) > 0 {
+		t.arena = t.nodePool.get(len(items))[:len(cp.Nodes)]
+	} else {
+		t.arena = make([]node, len(cp.Nodes), len(items))
+	}
+	for i, cn := range cp.Nodes {
+		n := &t.arena[i]
+		*n = node{Item: items[cn.Index], Index: cn.Index, Size: cn.Size, Threshold: cn.Threshold}
+		if cn.Left >= 0 {
+			n.Left = &t.arena[cn.Left]
+		}
+		if cn.Right >= 0 {
+			n.Right = &t.arena[cn.Right]
+		}
+	}
+
+	points := make([]interface{}, len(items))
+	for i, idx := range cp.Indices {
+		points[i] = items[idx]
+	}
+
+	// Count the metric calls of the build only
+	start := time.Now()
+	calls := 0
+	if t.hooks.OnBuildDone != nil {
+		t.distanceMetric = func(a, b interface{}) float64 {
+			calls++
+			return metric(a, b)
+		}
+	}
+
+	saved := len(t.arena)
+	for len(cp.Pending) > 0 {
+		task := cp.Pending[len(cp.Pending)-1]
+		cp.Pending = cp.Pending[:len(cp.Pending)-1]
+
+		first := len(t.arena)
+		ps, is := points[task.Lo:task.Hi], cp.Indices[task.Lo:task.Hi]
+
+		if len(ps) <= t.leafSize {
+			t.buildLeaf(ps, is)
+		} else {
+			n, median, rest := t.partition(ps, is, task.Depth)
+
+			// The left subtree is built first, as in New
+			if rest > 0 {
+				self := t.arenaIndex(n)
+				if median < rest {
+					cp.Pending = append(cp.Pending, buildTask{task.Lo + median, task.Lo + rest, task.Depth + 1, self, true})
+				}
+				if median > 0 {
+					cp.Pending = append(cp.Pending, buildTask{task.Lo, task.Lo + median, task.Depth + 1, self, false})
+				}
+			}
+		}
+
+		if task.Parent >= 0 {
+			if task.Right {
+				t.arena[task.Parent].Right = &t.arena[first]
+			} else {
+				t.arena[task.Parent].Left = &t.arena[first]
+			}
+		}
+
+		if cpInterval > 0 && len(t.arena)-saved >= cpInterval && len(cp.Pending) > 0 {
+			if err := t.saveCheckpoint(cp, cpFile); err != nil {
+				return nil, err
+			}
+			saved = len(t.arena)
+		}
+	}
+
+	t.buildDists = nil
+	t.distanceMetric = metric
+	if len(t.arena) > 0 {
+		// Deduplication may have dropped items, and the sizes in the
+		// checkpoint are not to be trusted either
+		t.root = &t.arena[0]
+		resize(t.root)
+	}
+
+	if err := os.Remove(cpFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if t.hooks.OnBuildDone != nil {
+		t.hooks.OnBuildDone(BuildStats{
+			Items:       t.root.size(),
+			Duplicates:  len(items) - t.root.size(),
+			MetricCalls: calls,
+			Height:      height(t.root),
+			Duration:    time.Since(start),
+		})
+	}
+
+	return t, nil
+}
+
+// resize recomputes the sizes of the subtree rooted at n and returns its
+// size.
+func resize(n *node) int {
+	if n == nil {
+		return 0
+	}
+	n.Size = 1 + resize(n.Left) + resize(n.Right)
+	return n.Size
+}
+
+// arenaIndex returns the position of n in the arena.
+func (vp *VPTree) arenaIndex(n *node) int {
+	if n == nil {
+		return -1
+	}
+	return int((uintptr(unsafe.Pointer(n)) - uintptr(unsafe.Pointer(&vp.arena[0]))) / unsafe.Sizeof(node{}))
+}
+
+// saveCheckpoint writes the nodes built so far together with cp's indices
+// and pending tasks to cpFile.
+func (vp *VPTree) saveCheckpoint(cp *checkpoint, cpFile string) error {
+	cp.Nodes = cp.Nodes[:0]
+	for i := range vp.arena {
+		n := &vp.arena[i]
+		cp.Nodes = append(cp.Nodes, checkpointNode{n.Index, n.Size, n.Threshold, vp.arenaIndex(n.Left), vp.arenaIndex(n.Right)})
+	}
+
+	tmp := cpFile + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(cp); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, cpFile)
+}
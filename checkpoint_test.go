@@ -0,0 +1,191 @@
+package vptree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var errCrash = errors.New("crash")
+
+// This test crashes a checkpointed build halfway through, resumes it and
+// checks the finished tree against brute force
+func TestBuildWithCheckpoint(t *testing.T) {
+	coords, items := randomCoordinates(2000)
+	cpFile := filepath.Join(t.TempDir(), "build.cp")
+
+	// Crash the build by panicking in the metric after a while
+	calls := 0
+	crashing := func(a, b interface{}) float64 {
+		calls++
+		if calls == 10000 {
+			panic(errCrash)
+		}
+		return CoordinateMetric(a, b)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != errCrash {
+				t.Fatalf("Expected the build to crash, got %v", r)
+			}
+		}()
+		BuildWithCheckpoint(crashing, items, cpFile, 100)
+	}()
+
+	if _, err := os.Stat(cpFile); err != nil {
+		t.Fatalf("Expected a checkpoint, got %v", err)
+	}
+
+	if _, err := ResumeFromCheckpoint(cpFile, CoordinateMetric, items[:10], 100); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Expected %v for the wrong items, got %v", ErrCheckpointMismatch, err)
+	}
+
+	calls = 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	New(counting, items)
+	full := calls
+	calls = 0
+
+	vp, err := ResumeFromCheckpoint(cpFile, counting, items, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls == 0 || calls >= full {
+		t.Errorf("Expected the resumed build to continue where it left off, but it made %v metric calls, and a full build %v", calls, full)
+	}
+
+	if _, err := os.Stat(cpFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed, got %v", err)
+	}
+
+	if vp.root.Size != len(items) || len(vp.arena) != len(items) {
+		t.Errorf("Expected %v nodes, got a root of size %v and %v nodes", len(items), vp.root.Size, len(vp.arena))
+	}
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, 10)
+		results, distances := vp.Search(q, 10)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+}
+
+// This test makes sure checkpointed builds honour the leaf size
+func TestBuildWithCheckpointLeafSize(t *testing.T) {
+	coords, items := randomCoordinates(500)
+
+	vp, err := BuildWithCheckpoint(CoordinateMetric, items, filepath.Join(t.TempDir(), "build.cp"), 10, WithLeafSize(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, 5)
+		results, distances := vp.Search(q, 5)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	empty, err := BuildWithCheckpoint(CoordinateMetric, nil, filepath.Join(t.TempDir(), "empty.cp"), 10)
+	if err != nil || empty.root != nil {
+		t.Errorf("Expected an empty tree, got %v and %v", empty.root, err)
+	}
+}
+
+// This test makes sure ResumeFromCheckpoint rejects corrupt and truncated
+// checkpoints with an error rather than panicking
+func TestResumeFromCorruptCheckpoint(t *testing.T) {
+	_, items := randomCoordinates(500)
+	cpFile := filepath.Join(t.TempDir(), "build.cp")
+
+	calls := 0
+	func() {
+		defer func() {
+			if r := recover(); r != errCrash {
+				t.Fatalf("Expected the build to crash, got %v", r)
+			}
+		}()
+		BuildWithCheckpoint(func(a, b interface{}) float64 {
+			calls++
+			if calls == 2000 {
+				panic(errCrash)
+			}
+			return CoordinateMetric(a, b)
+		}, items, cpFile, 20)
+	}()
+
+	data, err := os.ReadFile(cpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var good checkpoint
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&good); err != nil {
+		t.Fatal(err)
+	}
+	if len(good.Nodes) == 0 || len(good.Pending) == 0 {
+		t.Fatalf("Expected a checkpoint halfway through the build")
+	}
+
+	write := func(data []byte) {
+		if err := os.WriteFile(cpFile, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(data[:len(data)/2])
+	if _, err := ResumeFromCheckpoint(cpFile, CoordinateMetric, items, 20); !errors.Is(err, ErrCheckpointFormat) {
+		t.Errorf("truncated: expected %v, got %v", ErrCheckpointFormat, err)
+	}
+
+	for name, corrupt := range map[string]func(cp *checkpoint){
+		"index":       func(cp *checkpoint) { cp.Indices[3] = len(items) },
+		"repeated":    func(cp *checkpoint) { cp.Indices[3] = cp.Indices[4] },
+		"indices":     func(cp *checkpoint) { cp.Indices = cp.Indices[:10] },
+		"node index":  func(cp *checkpoint) { cp.Nodes[0].Index = -1 },
+		"left":        func(cp *checkpoint) { cp.Nodes[0].Left = len(cp.Nodes) },
+		"right":       func(cp *checkpoint) { cp.Nodes[len(cp.Nodes)-1].Right = 0 },
+		"range":       func(cp *checkpoint) { cp.Pending[0].Hi = len(items) + 1 },
+		"empty range": func(cp *checkpoint) { cp.Pending[0].Lo = cp.Pending[0].Hi },
+		"parent":      func(cp *checkpoint) { cp.Pending[0].Parent = len(cp.Nodes) },
+		"overlap":     func(cp *checkpoint) { cp.Pending = append(cp.Pending, buildTask{Lo: 0, Hi: len(items), Parent: -1}) },
+	} {
+		var cp checkpoint
+		gob.NewDecoder(bytes.NewReader(data)).Decode(&cp)
+		corrupt(&cp)
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&cp); err != nil {
+			t.Fatal(err)
+		}
+		write(buf.Bytes())
+
+		if _, err := ResumeFromCheckpoint(cpFile, CoordinateMetric, items, 20); !errors.Is(err, ErrCheckpointFormat) {
+			t.Errorf("%v: expected %v, got %v", name, ErrCheckpointFormat, err)
+		}
+	}
+
+	// The intact checkpoint still resumes, and honours the options
+	write(data)
+	var stats BuildStats
+	vp, err := ResumeFromCheckpoint(cpFile, CoordinateMetric, items, 20, WithInstrumentation(Hooks{
+		OnBuildDone: func(s BuildStats) { stats = s },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vp.Len() != len(items) || stats.Items != len(items) || stats.MetricCalls == 0 {
+		t.Errorf("Expected %v items and build stats for them, got %v and %+v", len(items), vp.Len(), stats)
+	}
+}
@@ -0,0 +1,134 @@
+package vptree
+
+// A Cluster is a group of items that lie within Radius of Center.
+type Cluster struct {
+	Items  []interface{}
+	Radius float64
+	Center interface{}
+}
+
+// HierarchicalClusters partitions the items of the VP-tree into clusters
+// following the tree's own ball decomposition. Starting at the root, the tree
+// is descended into every subtree holding at least minClusterSize items.
+// The vantage point of a node, together with its subtrees that are too small
+// to descend into, forms a cluster centered on the vantage point if there are
+// at least minClusterSize such items, and is otherwise merged into the
+// clusters of the node's inner (or, failing that, outer) subtree.
+//
+// Every item ends up in exactly one cluster, and, unless the tree holds
+// fewer than minClusterSize items, every cluster has at least
+// minClusterSize items.
+func (vp *VPTree) HierarchicalClusters(minClusterSize int) (clusters []Cluster) {
+	emit := func(center interface{}, items []interface{}) {
+		c := Cluster{Items: items, Center: center}
+		for _, item := range items {
+			if d := vp.distanceMetric(item, c.Center); d > c.Radius {
+				c.Radius = d
+			}
+		}
+		clusters = append(clusters, c)
+	}
+
+	size := vp.liveSizer()
+
+	var split func(n *node, extra []interface{})
+	split = func(n *node, extra []interface{}) {
+		leftBig := n.Left != nil && size(n.Left) >= minClusterSize
+		rightBig := n.Right != nil && size(n.Right) >= minClusterSize
+
+		// Gather the vantage point and the subtrees too small to
+		// descend into. The item Upsert replaced at a node is left
+		// out, and one of the others becomes the center.
+		rest := extra
+		center := n.Item
+		if vp.live(n) {
+			rest = append(rest, n.Item)
+		}
+		if !leftBig {
+			vp.collect(n.Left, &rest)
+		}
+		if !rightBig {
+			vp.collect(n.Right, &rest)
+		}
+		if !vp.live(n) && len(rest) > 0 {
+			center = rest[0]
+		}
+
+		switch {
+		case !leftBig && !rightBig:
+			if len(rest) > 0 {
+				emit(center, rest)
+			}
+		case len(rest) >= minClusterSize:
+			emit(center, rest)
+			rest = nil
+			fallthrough
+		default:
+			if leftBig {
+				split(n.Left, rest)
+				rest = nil
+			}
+			if rightBig {
+				split(n.Right, rest)
+			}
+		}
+	}
+
+	if vp.root != nil {
+		split(vp.root, nil)
+	}
+
+	return
+}
+
+// MaxIntraclusterDistance returns the diameter of cluster, the largest
+// distance between two of its items under the VP-tree's metric, for example
+// to judge the clusters returned by HierarchicalClusters. It builds a
+// temporary VP-tree over the items and searches it for the item farthest
+// from each of them, skipping the subtrees that can't hold an item farther
+// away than the largest distance found so far.
+func (vp *VPTree) MaxIntraclusterDistance(cluster []interface{}) float64 {
+	t := New(vp.distanceMetric, cluster)
+
+	diameter := 0.0
+	for _, item := range cluster {
+		diameter = t.farthest(t.root, item, diameter)
+	}
+
+	return diameter
+}
+
+// farthest returns the larger of best and the distance from target to the
+// farthest item in the subtree rooted at n.
+func (vp *VPTree) farthest(n *node, target interface{}, best float64) float64 {
+	if n == nil {
+		return best
+	}
+
+	d := vp.distanceMetric(n.Item, target)
+	best = max(best, d)
+
+	// The outer subtree has no upper bound on its distances, so search it
+	// first; the inner one lies within the threshold of the vantage point,
+	// so none of its items is farther from target than d plus the threshold
+	best = vp.farthest(n.Right, target, best)
+	if d+n.Threshold > best {
+		best = vp.farthest(n.Left, target, best)
+	}
+
+	return best
+}
+
+// collect appends the items of the subtree rooted at n to items, leaving out
+// those Upsert replaced.
+func (vp *VPTree) collect(n *node, items *[]interface{}) {
+	if n == nil {
+		return
+	}
+
+	if vp.live(n) {
+		*items = append(*items, n.Item)
+	}
+	vp.collect(n.Left, items)
+	vp.collect(n.Right, items)
+}
@@ -0,0 +1,97 @@
+package vptree
+
+import (
+	"testing"
+)
+
+// This test makes sure HierarchicalClusters covers every item exactly once,
+// respects the minimum cluster size and reports correct radii
+func TestHierarchicalClusters(t *testing.T) {
+	_, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	clusters := vp.HierarchicalClusters(50)
+
+	if len(clusters) < 2 {
+		t.Fatalf("Expected several clusters, got %v", len(clusters))
+	}
+
+	seen := make(map[interface{}]int)
+	for _, c := range clusters {
+		if len(c.Items) < 50 {
+			t.Errorf("Expected at least 50 items per cluster, got %v", len(c.Items))
+		}
+
+		radius := 0.0
+		for _, item := range c.Items {
+			seen[item]++
+			if d := CoordinateMetric(item, c.Center); d > radius {
+				radius = d
+			}
+		}
+
+		if radius != c.Radius {
+			t.Errorf("Expected cluster radius %v, got %v", radius, c.Radius)
+		}
+	}
+
+	if len(seen) != len(vpitems) {
+		t.Errorf("Expected %v distinct items in clusters, got %v", len(vpitems), len(seen))
+	}
+
+	for item, count := range seen {
+		if count != 1 {
+			t.Errorf("Expected %v in exactly one cluster, found it in %v", item, count)
+		}
+	}
+}
+
+// This test makes sure the clusters are considerably tighter than the data
+// set as a whole
+func TestHierarchicalClustersTight(t *testing.T) {
+	_, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	all := vp.HierarchicalClusters(len(vpitems))
+	if len(all) != 1 || len(all[0].Items) != len(vpitems) {
+		t.Fatalf("Expected a single cluster of all items, got %v clusters", len(all))
+	}
+
+	clusters := vp.HierarchicalClusters(20)
+
+	sum := 0.0
+	for _, c := range clusters {
+		sum += c.Radius
+	}
+
+	if mean := sum / float64(len(clusters)); mean > 0.75*all[0].Radius {
+		t.Errorf("Expected a mean cluster radius well below %v, got %v", all[0].Radius, mean)
+	}
+}
+
+// This test makes sure MaxIntraclusterDistance finds the largest distance
+// between two items of every cluster
+func TestMaxIntraclusterDistance(t *testing.T) {
+	_, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	for _, c := range vp.HierarchicalClusters(50) {
+		expected := 0.0
+		for _, a := range c.Items {
+			for _, b := range c.Items {
+				expected = max(expected, CoordinateMetric(a, b))
+			}
+		}
+
+		if d := vp.MaxIntraclusterDistance(c.Items); d != expected {
+			t.Errorf("Expected a diameter of %v, got %v", expected, d)
+		}
+	}
+
+	if d := vp.MaxIntraclusterDistance(vpitems[:1]); d != 0 {
+		t.Errorf("Expected a diameter of 0 for a single item, got %v", d)
+	}
+	if d := vp.MaxIntraclusterDistance(nil); d != 0 {
+		t.Errorf("Expected a diameter of 0 for no items, got %v", d)
+	}
+}
@@ -0,0 +1,75 @@
+package vptree_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+	"github.com/DataWraith/vptree/vptreetest"
+)
+
+type point struct {
+	X float64
+	Y float64
+}
+
+func pointMetric(a, b interface{}) float64 {
+	p1 := a.(point)
+	p2 := b.(point)
+
+	return math.Sqrt(math.Pow(p1.X-p2.X, 2) + math.Pow(p1.Y-p2.Y, 2))
+}
+
+func randomPoints(rng *rand.Rand, n int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = point{X: rng.Float64(), Y: rng.Float64()}
+	}
+	return items
+}
+
+// This test runs the conformance harness against random points, queried with
+// both the indexed points and points that are not in the tree
+func TestConformance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomPoints(rng, 1000)
+
+	vptreetest.RunConformance(t, pointMetric, items, vptreetest.Config{Seed: 1})
+	vptreetest.RunConformance(t, pointMetric, items, vptreetest.Config{
+		Seed:    2,
+		Targets: randomPoints(rng, 100),
+		MaxK:    100,
+	})
+}
+
+// This test runs the conformance harness against a tiny data set, where k
+// frequently exceeds the number of items
+func TestConformanceSmall(t *testing.T) {
+	items := []interface{}{
+		point{24, 57},
+		point{35, 28},
+		point{55, 48},
+		point{68, 42},
+	}
+
+	vptreetest.RunConformance(t, pointMetric, items, vptreetest.Config{
+		Seed:    3,
+		Targets: []interface{}{point{12, 34}},
+	})
+}
+
+// This test runs the conformance harness against an MVPTree
+func TestConformanceMVP(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	items := randomPoints(rng, 1000)
+
+	vptreetest.RunConformance(t, pointMetric, items, vptreetest.Config{
+		Seed:    4,
+		Targets: randomPoints(rng, 100),
+		MaxK:    100,
+		Build: func(metric vptree.Metric, items []interface{}) vptree.Index {
+			return vptree.NewMVP(metric, items)
+		},
+	})
+}
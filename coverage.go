@@ -0,0 +1,62 @@
+package vptree
+
+import "math/rand"
+
+// defaultCoverageQueries is the number of items CoverageMap samples as
+// queries if it isn't given any.
+const defaultCoverageQueries = 1000
+
+// CoverageMap estimates how much of the query load every node of the tree
+// carries. It runs a k-nearest-neighbour search for each of queries and
+// returns, for every node, the fraction of the searches that visited it,
+// keyed by the index of the node's item. The root is visited by every
+// search and so has a coverage of 1; nodes below it with a high coverage
+// are bottlenecks, and subtrees with a low one can be served by fewer
+// resources.
+//
+// If queries is empty, up to 1000 items of the tree chosen at random are
+// used, which estimates the load for queries distributed like the items.
+// For spill trees, which may hold an item in more than one node, an item's
+// coverage is the largest of its nodes'.
+func (vp *VPTree) CoverageMap(queries []interface{}, k int) map[int]float64 {
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+
+	if len(queries) == 0 {
+		for _, i := range rand.Perm(len(nodes))[:min(defaultCoverageQueries, len(nodes))] {
+			queries = append(queries, nodes[i].Item)
+		}
+	}
+
+	visits := make(map[*node]int, len(nodes))
+	if k > 0 {
+		vp.traceVisits(queries, k, func() (func(map[*node]bool), func()) {
+			local := make(map[*node]int)
+
+			visit := func(visited map[*node]bool) {
+				for n := range visited {
+					local[n]++
+				}
+			}
+
+			done := func() {
+				for n, count := range local {
+					visits[n] += count
+				}
+			}
+
+			return visit, done
+		})
+	}
+
+	coverage := make(map[int]float64, len(nodes))
+	for _, n := range nodes {
+		c := 0.0
+		if len(queries) > 0 {
+			c = float64(visits[n]) / float64(len(queries))
+		}
+		coverage[n.Index] = max(coverage[n.Index], c)
+	}
+
+	return coverage
+}
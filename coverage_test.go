@@ -0,0 +1,54 @@
+package vptree
+
+import "testing"
+
+// This test checks that coverage shrinks from the root down, and that the
+// coverage of the nodes adds up to the mean number of nodes visited
+func TestCoverageMap(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	_, queries := randomCoordinates(200)
+	vp := New(CoordinateMetric, items)
+
+	coverage := vp.CoverageMap(queries, 5)
+
+	if len(coverage) != len(items) {
+		t.Fatalf("Expected coverage for %v nodes, got %v", len(items), len(coverage))
+	}
+	if coverage[vp.root.Index] != 1 {
+		t.Errorf("Expected the root to have a coverage of 1, got %v", coverage[vp.root.Index])
+	}
+
+	// A search only visits a node if it visited its parent
+	var check func(n *node)
+	check = func(n *node) {
+		for _, child := range []*node{n.Left, n.Right} {
+			if child == nil {
+				continue
+			}
+			if coverage[child.Index] > coverage[n.Index] {
+				t.Errorf("Expected a child's coverage of %v to be at most its parent's %v", coverage[child.Index], coverage[n.Index])
+			}
+			check(child)
+		}
+	}
+	check(vp.root)
+
+	visits := 0
+	for _, q := range queries {
+		_, _, stats := vp.SearchWithStats(q, 5)
+		visits += stats.NodesVisited
+	}
+
+	total := 0.0
+	for _, c := range coverage {
+		total += c
+	}
+	if expected := float64(visits) / float64(len(queries)); total < expected-1e-9 || total > expected+1e-9 {
+		t.Errorf("Expected the coverage to add up to %v, got %v", expected, total)
+	}
+
+	// Without queries, the items themselves are sampled
+	if sampled := vp.CoverageMap(nil, 5); sampled[vp.root.Index] != 1 {
+		t.Errorf("Expected the root to have a coverage of 1, got %v", sampled[vp.root.Index])
+	}
+}
@@ -0,0 +1,170 @@
+package vptree
+
+import (
+	"math/rand"
+	"time"
+)
+
+// A CrossoverReport compares a VPTree against a LinearIndex on prefixes of
+// increasing size of a data set. See BenchmarkCrossover.
+type CrossoverReport struct {
+	// Ks are the values of k the searches were run with.
+	Ks []int
+
+	// Sizes holds the measurements for each prefix, in order of
+	// increasing size.
+	Sizes []CrossoverSize
+
+	// Crossover holds, for each of the Ks, the smallest measured size from
+	// which on the VP-tree answered queries faster than the linear scan,
+	// or 0 if it did not win at the largest measured size.
+	Crossover []int
+}
+
+// A CrossoverSize holds the measurements for one prefix of the data set.
+type CrossoverSize struct {
+	// N is the number of items in the prefix.
+	N int
+
+	// BuildTime is the time it took to build the VP-tree.
+	BuildTime time.Duration
+
+	// Tree and Linear hold the costs of a query for each of the Ks.
+	Tree   []CrossoverCost
+	Linear []CrossoverCost
+}
+
+// A CrossoverCost is the mean cost of a query.
+type CrossoverCost struct {
+	Latency     time.Duration
+	MetricCalls float64
+}
+
+// A CrossoverOption configures BenchmarkCrossover.
+type CrossoverOption func(*crossoverConfig)
+
+type crossoverConfig struct {
+	budget time.Duration
+	seed   int64
+}
+
+// defaultCrossoverBudget is how long BenchmarkCrossover runs by default.
+const defaultCrossoverBudget = 10 * time.Second
+
+// WithCrossoverBudget limits the time BenchmarkCrossover spends measuring.
+// Once it is exceeded, no further prefix sizes are started. The default is
+// ten seconds.
+func WithCrossoverBudget(d time.Duration) CrossoverOption {
+	return func(c *crossoverConfig) {
+		c.budget = d
+	}
+}
+
+// WithCrossoverSeed seeds the order of the items and the choice of vantage
+// points, which makes everything but the timings of BenchmarkCrossover
+// reproducible.
+func WithCrossoverSeed(seed int64) CrossoverOption {
+	return func(c *crossoverConfig) {
+		c.seed = seed
+	}
+}
+
+// BenchmarkCrossover answers whether a VP-tree is worth building for metric
+// and data like items. It shuffles items and, for prefixes of doubling size
+// up to all of them, builds a VPTree and a LinearIndex and runs a
+// k-nearest-neighbour search for each of sampleQueries and each of ks on
+// both, recording the build time, the mean query latency and the mean
+// number of metric calls. The report also gives the approximate size from
+// which on the tree wins for each k.
+//
+// Building the trees and running the queries is what takes the time, so the
+// time budget is checked before each prefix; the prefix running when it
+// runs out is still completed.
+func BenchmarkCrossover(metric Metric, items []interface{}, sampleQueries []interface{}, ks []int, opts ...CrossoverOption) CrossoverReport {
+	cfg := crossoverConfig{budget: defaultCrossoverBudget}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	report := CrossoverReport{
+		Ks:        ks,
+		Crossover: make([]int, len(ks)),
+	}
+
+	if len(items) == 0 {
+		return report
+	}
+
+	rng := rand.New(rand.NewSource(cfg.seed))
+	shuffled := make([]interface{}, len(items))
+	for i, j := range rng.Perm(len(items)) {
+		shuffled[i] = items[j]
+	}
+
+	calls := 0
+	counted := func(a, b interface{}) float64 {
+		calls++
+		return metric(a, b)
+	}
+
+	selector := func(items []interface{}, depth int) int {
+		return rng.Intn(len(items))
+	}
+
+	start := time.Now()
+	for n := min(16, len(shuffled)); ; n = min(2*n, len(shuffled)) {
+		prefix := shuffled[:n]
+		size := CrossoverSize{
+			N:      n,
+			Tree:   make([]CrossoverCost, len(ks)),
+			Linear: make([]CrossoverCost, len(ks)),
+		}
+
+		buildStart := time.Now()
+		tree := NewHierarchical(counted, prefix, selector)
+		size.BuildTime = time.Since(buildStart)
+		linear := NewLinearIndex(counted, prefix)
+
+		for i, k := range ks {
+			size.Tree[i] = crossoverCost(&calls, sampleQueries, func(q interface{}) { tree.Search(q, k) })
+			size.Linear[i] = crossoverCost(&calls, sampleQueries, func(q interface{}) { linear.Search(q, k) })
+		}
+
+		report.Sizes = append(report.Sizes, size)
+		if n == len(shuffled) || time.Since(start) > cfg.budget {
+			break
+		}
+	}
+
+	// Walk the sizes from the largest down for as long as the tree keeps
+	// winning
+	for i := range ks {
+		for s := len(report.Sizes) - 1; s >= 0; s-- {
+			size := report.Sizes[s]
+			if size.Tree[i].Latency >= size.Linear[i].Latency {
+				break
+			}
+			report.Crossover[i] = size.N
+		}
+	}
+
+	return report
+}
+
+// crossoverCost runs search on every query and returns the mean cost, with
+// the metric calls counted in calls.
+func crossoverCost(calls *int, queries []interface{}, search func(q interface{})) (cost CrossoverCost) {
+	if len(queries) == 0 {
+		return
+	}
+
+	*calls = 0
+	start := time.Now()
+	for _, q := range queries {
+		search(q)
+	}
+	cost.Latency = time.Since(start) / time.Duration(len(queries))
+	cost.MetricCalls = float64(*calls) / float64(len(queries))
+
+	return
+}
@@ -0,0 +1,69 @@
+package vptree
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// This test runs a tiny crossover benchmark and checks the report for
+// internal consistency
+func TestBenchmarkCrossover(t *testing.T) {
+	_, items := randomCoordinates(300)
+	_, queries := randomCoordinates(20)
+	ks := []int{1, 10}
+
+	report := BenchmarkCrossover(CoordinateMetric, items, queries, ks, WithCrossoverSeed(1), WithCrossoverBudget(time.Minute))
+
+	if len(report.Sizes) == 0 || report.Sizes[len(report.Sizes)-1].N != len(items) {
+		t.Fatalf("Expected the sizes to end with all %v items, got %+v", len(items), report.Sizes)
+	}
+
+	sizes := map[int]bool{0: true}
+	for s, size := range report.Sizes {
+		sizes[size.N] = true
+		if s > 0 && size.N <= report.Sizes[s-1].N {
+			t.Errorf("Expected increasing sizes, got %v after %v", size.N, report.Sizes[s-1].N)
+		}
+		if size.BuildTime < 0 {
+			t.Errorf("Expected a non-negative build time, got %v", size.BuildTime)
+		}
+
+		for i := range ks {
+			tree, linear := size.Tree[i], size.Linear[i]
+			if tree.Latency < 0 || linear.Latency < 0 {
+				t.Errorf("Expected non-negative latencies, got %v and %v", tree.Latency, linear.Latency)
+			}
+			if linear.MetricCalls != float64(size.N) {
+				t.Errorf("Expected the linear scan to make %v metric calls, got %v", size.N, linear.MetricCalls)
+			}
+			if tree.MetricCalls < 1 || tree.MetricCalls > float64(size.N) {
+				t.Errorf("Expected the tree to make between 1 and %v metric calls, got %v", size.N, tree.MetricCalls)
+			}
+		}
+	}
+
+	for i, n := range report.Crossover {
+		if !sizes[n] {
+			t.Errorf("Expected the crossover for k = %v to be a measured size, got %v", ks[i], n)
+		}
+	}
+
+	// Everything but the timings is deterministic given the seed
+	again := BenchmarkCrossover(CoordinateMetric, items, queries, ks, WithCrossoverSeed(1), WithCrossoverBudget(time.Minute))
+	for s := range report.Sizes {
+		for i := range ks {
+			if report.Sizes[s].Tree[i].MetricCalls != again.Sizes[s].Tree[i].MetricCalls {
+				t.Errorf("Expected the same metric calls for the same seed")
+			}
+		}
+	}
+	if !reflect.DeepEqual(report.Ks, again.Ks) || len(report.Sizes) != len(again.Sizes) {
+		t.Errorf("Expected the same report shape for the same seed")
+	}
+
+	// A budget that is exhausted immediately still measures one size
+	if short := BenchmarkCrossover(CoordinateMetric, items, queries, ks, WithCrossoverBudget(0)); len(short.Sizes) != 1 {
+		t.Errorf("Expected one size with no budget, got %v", len(short.Sizes))
+	}
+}
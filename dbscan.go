@@ -0,0 +1,43 @@
+package vptree
+
+import "slices"
+
+// EpsilonNeighborhoods returns the neighbourhoods DBSCAN needs, aligned with
+// the items slice: neighbors[i] holds the indices of all items within
+// distance eps of item i, including i itself, in increasing order, and
+// core[i] is whether there are at least minPts of them. The range searches
+// run on workers goroutines; if workers is not positive, GOMAXPROCS is used.
+//
+// The searches reuse pooled result buffers, so apart from the results, the
+// memory needed doesn't grow with the number of items. The results
+// themselves hold one int per neighbour, however, which for a dense data
+// set, where eps covers a good part of the items, approaches the square of
+// the number of items. For such data, consider a smaller eps, or running
+// range searches one item at a time as DBSCAN expands its clusters.
+func (vp *VPTree) EpsilonNeighborhoods(eps float64, minPts int, workers int) (neighbors [][]int, core []bool) {
+	count := vp.itemCount()
+	neighbors = make([][]int, count)
+	core = make([]bool, count)
+
+	vp.forEachItem(workers, func(n *node) {
+		q := getRadiusQuery(n.Item, eps)
+		vp.searchRadius(q, vp.root, 0)
+
+		indices := make([]int, len(q.found))
+		for i, hi := range q.found {
+			indices[i] = hi.Index
+		}
+		putRadiusQuery(q)
+
+		// Spill trees may find an item more than once
+		slices.Sort(indices)
+		if vp.spill > 0 {
+			indices = slices.Compact(indices)
+		}
+
+		neighbors[n.Index] = indices
+		core[n.Index] = len(indices) >= minPts
+	})
+
+	return
+}
@@ -0,0 +1,35 @@
+package vptree
+
+import (
+	"slices"
+	"testing"
+)
+
+// This test compares the neighbourhoods against brute force
+func TestEpsilonNeighborhoods(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+
+	for _, vp := range []*VPTree{New(CoordinateMetric, items), NewSpillTree(CoordinateMetric, items, 0.1)} {
+		neighbors, core := vp.EpsilonNeighborhoods(0.05, 8, 0)
+
+		if len(neighbors) != len(items) || len(core) != len(items) {
+			t.Fatalf("Expected results for %v items, got %v and %v", len(items), len(neighbors), len(core))
+		}
+
+		for i, c := range coords {
+			var expected []int
+			for j, o := range coords {
+				if CoordinateMetric(c, o) <= 0.05 {
+					expected = append(expected, j)
+				}
+			}
+
+			if !slices.Equal(neighbors[i], expected) {
+				t.Errorf("Expected item %v to have neighbours %v, got %v", i, expected, neighbors[i])
+			}
+			if core[i] != (len(expected) >= 8) {
+				t.Errorf("Expected item %v with %v neighbours to be core: %v", i, len(expected), len(expected) >= 8)
+			}
+		}
+	}
+}
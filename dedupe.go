@@ -0,0 +1,23 @@
+package vptree
+
+// deduplicationEpsilon is the distance below which WithDeduplication asks
+// whether two items are duplicates.
+const deduplicationEpsilon = 1e-9
+
+// WithDeduplication makes the VP-tree drop duplicate items while it is
+// built. Two items are duplicates if their distance is below 1e-9 and equals
+// reports them as equal; of a group of duplicates, only one item is kept.
+// Duplicates only waste nodes, since they cannot be told apart by distance,
+// and searches would return all of them.
+//
+// Duplicates are found while the items are partitioned, without extra
+// metric calls: items at distance 0 from each other land on the same side
+// of every partition, until one of them becomes a vantage point and the
+// others are dropped. Items that are merely close, and duplicates within
+// the leaves of a tree built WithLeafSize, may be kept. BuildStats and
+// BuildReport count the dropped items.
+func WithDeduplication(equals func(a, b interface{}) bool) Option {
+	return func(vp *VPTree) {
+		vp.equals = equals
+	}
+}
@@ -0,0 +1,57 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func coordinatesEqual(a, b interface{}) bool {
+	return a.(Coordinate) == b.(Coordinate)
+}
+
+// This test builds trees over items that each occur several times, and
+// makes sure exactly one copy of each is kept
+func TestWithDeduplication(t *testing.T) {
+	coords, unique := randomCoordinates(500)
+
+	var items []interface{}
+	for copies := 0; copies < 4; copies++ {
+		items = append(items, unique...)
+	}
+	rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+
+	var stats BuildStats
+	vp := New(CoordinateMetric, items, WithDeduplication(coordinatesEqual), WithInstrumentation(Hooks{
+		OnBuildDone: func(s BuildStats) { stats = s },
+	}))
+
+	if vp.Len() != len(unique) {
+		t.Errorf("Expected %v items, got %v", len(unique), vp.Len())
+	}
+	if stats.Items != len(unique) || stats.Duplicates != len(items)-len(unique) {
+		t.Errorf("Expected %v items and %v duplicates, got %+v", len(unique), len(items)-len(unique), stats)
+	}
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, 10)
+		results, distances := vp.Search(q, 10)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	// Checkpointed builds deduplicate as well
+	cp, err := BuildWithCheckpoint(CoordinateMetric, items, t.TempDir()+"/build.cp", 100, WithDeduplication(coordinatesEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Len() != len(unique) {
+		t.Errorf("Expected %v items in the checkpointed build, got %v", len(unique), cp.Len())
+	}
+
+	// Items that are at distance 0 but not equal are kept
+	never := func(a, b interface{}) bool { return false }
+	if all := New(CoordinateMetric, items, WithDeduplication(never)); all.Len() != len(items) {
+		t.Errorf("Expected all %v items to be kept, got %v", len(items), all.Len())
+	}
+}
@@ -0,0 +1,142 @@
+package vptree
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+// AllKthNearestDistances returns KthNearestDistance(x, k) for every item x
+// the VP-tree was built from, aligned with the items slice. Since every item
+// is its own nearest neighbour, pass k+1 to skip it. The searches run on
+// numWorkers goroutines; if numWorkers is not positive, GOMAXPROCS is used.
+func (vp *VPTree) AllKthNearestDistances(k int, numWorkers int) []float64 {
+	distances := make([]float64, vp.itemCount())
+	vp.forEachItem(numWorkers, func(n *node) {
+		distances[n.Index] = vp.KthNearestDistance(n.Item, k)
+	})
+	return distances
+}
+
+// LocalOutlierFactors returns the Local Outlier Factor of every item the
+// VP-tree was built from, aligned with the items slice. The LOF compares the
+// density around an item with the density around its k nearest neighbours:
+// scores near 1 are typical, and scores well above 1 mark outliers.
+//
+// Each item's neighbours are the k nearest items other than itself, with
+// ties broken arbitrarily. Items with k or more exact duplicates have
+// infinite density, and get a score of 1 if their neighbours do, too.
+// LocalOutlierFactors returns nil unless the VP-tree holds more than k
+// items.
+func (vp *VPTree) LocalOutlierFactors(k int) []float64 {
+	count := vp.itemCount()
+	if k < 1 || count <= k {
+		return nil
+	}
+
+	kDists := vp.AllKthNearestDistances(k+1, 0)
+	neighbours, distances := vp.neighbourGraph(k, 0)
+
+	// The local reachability density is the inverse of the mean
+	// reachability distance max(kDist(o), d(p, o)) to the neighbours o
+	lrd := make([]float64, count)
+	for p := range neighbours {
+		sum := 0.0
+		for i, o := range neighbours[p] {
+			sum += math.Max(kDists[o], distances[p][i])
+		}
+		lrd[p] = float64(len(neighbours[p])) / sum
+	}
+
+	lof := make([]float64, count)
+	for p := range neighbours {
+		sum := 0.0
+		for _, o := range neighbours[p] {
+			if math.IsInf(lrd[p], 1) {
+				if math.IsInf(lrd[o], 1) {
+					sum++
+				}
+				continue
+			}
+			sum += lrd[o] / lrd[p]
+		}
+		lof[p] = sum / float64(len(neighbours[p]))
+	}
+
+	return lof
+}
+
+// neighbourGraph returns the indices of and distances to the k nearest
+// neighbours of every item, excluding the item itself, aligned with the items
+// slice.
+func (vp *VPTree) neighbourGraph(k int, numWorkers int) (neighbours [][]int, distances [][]float64) {
+	count := vp.itemCount()
+	neighbours = make([][]int, count)
+	distances = make([][]float64, count)
+
+	vp.forEachItem(numWorkers, func(n *node) {
+		indices, dists := vp.SearchIndices(n.Item, k+1)
+
+		// Drop the item itself, or the farthest result if a duplicate
+		// crowded it out
+		self := len(indices) - 1
+		for i, idx := range indices {
+			if idx == n.Index {
+				self = i
+				break
+			}
+		}
+
+		neighbours[n.Index] = append(indices[:self], indices[self+1:]...)
+		distances[n.Index] = append(dists[:self], dists[self+1:]...)
+	})
+
+	return
+}
+
+// itemCount returns one more than the largest index in the VP-tree, which is
+// the number of items it was built from plus those Upsert added.
+func (vp *VPTree) itemCount() int {
+	// Spill trees may hold an item in more than one node, and deleted
+	// items keep their indices
+	count := 0
+	vp.forEachNode(func(n *node) {
+		count = max(count, n.Index+1)
+	})
+	return count
+}
+
+// forEachItem calls f once for the node of every item the VP-tree was built
+// from or Upsert inserted, except the items Upsert replaced, on numWorkers
+// goroutines. If numWorkers is not positive, GOMAXPROCS is used.
+func (vp *VPTree) forEachItem(numWorkers int, f func(n *node)) {
+	if numWorkers < 1 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+
+	jobs := make(chan *node)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			for n := range jobs {
+				f(n)
+			}
+			wg.Done()
+		}()
+	}
+
+	seen := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		if !seen[n.Index] && vp.live(n) {
+			seen[n.Index] = true
+			jobs <- n
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,113 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+// This test makes sure AllKthNearestDistances agrees with KthNearestDistance
+// and is aligned with the items slice
+func TestAllKthNearestDistances(t *testing.T) {
+	_, items := randomCoordinates(500)
+	vp := New(CoordinateMetric, items)
+
+	for _, workers := range []int{0, 1, 4} {
+		distances := vp.AllKthNearestDistances(4, workers)
+
+		if len(distances) != len(items) {
+			t.Fatalf("Expected %v distances, got %v", len(items), len(distances))
+		}
+
+		for i, item := range items {
+			if expected := vp.KthNearestDistance(item, 4); distances[i] != expected {
+				t.Errorf("Expected distances[%v] to be %v, got %v", i, expected, distances[i])
+			}
+		}
+	}
+
+	if distances := New(CoordinateMetric, nil).AllKthNearestDistances(3, 2); len(distances) != 0 {
+		t.Errorf("Expected no distances for an empty tree, got %v", distances)
+	}
+}
+
+// bruteForceLOF computes the Local Outlier Factors of items by comparing
+// every pair
+func bruteForceLOF(items []Coordinate, k int) []float64 {
+	neighbours := make([][]int, len(items))
+	kDists := make([]float64, len(items))
+	for p := range items {
+		var others []int
+		for o := range items {
+			if o != p {
+				others = append(others, o)
+			}
+		}
+		sort.Slice(others, func(i, j int) bool {
+			return CoordinateMetric(items[p], items[others[i]]) < CoordinateMetric(items[p], items[others[j]])
+		})
+		neighbours[p] = others[:k]
+		kDists[p] = CoordinateMetric(items[p], items[others[k-1]])
+	}
+
+	lrd := make([]float64, len(items))
+	for p := range items {
+		sum := 0.0
+		for _, o := range neighbours[p] {
+			sum += math.Max(kDists[o], CoordinateMetric(items[p], items[o]))
+		}
+		lrd[p] = float64(k) / sum
+	}
+
+	lof := make([]float64, len(items))
+	for p := range items {
+		for _, o := range neighbours[p] {
+			lof[p] += lrd[o] / lrd[p] / float64(k)
+		}
+	}
+	return lof
+}
+
+// This test compares LocalOutlierFactors against a brute-force computation,
+// and makes sure a far-away point stands out
+func TestLocalOutlierFactors(t *testing.T) {
+	coords, items := randomCoordinates(300)
+	coords = append(coords, Coordinate{5, 5})
+	items = append(items, Coordinate{5, 5})
+
+	vp := New(CoordinateMetric, items)
+	lof := vp.LocalOutlierFactors(5)
+	expected := bruteForceLOF(coords, 5)
+
+	if len(lof) != len(items) {
+		t.Fatalf("Expected %v scores, got %v", len(items), len(lof))
+	}
+
+	for i := range lof {
+		if math.Abs(lof[i]-expected[i]) > 1e-9 {
+			t.Errorf("Expected lof[%v] to be %v, got %v", i, expected[i], lof[i])
+		}
+	}
+
+	if lof[len(lof)-1] < 10 {
+		t.Errorf("Expected the outlier to score above 10, got %v", lof[len(lof)-1])
+	}
+
+	if vp.LocalOutlierFactors(len(items)) != nil {
+		t.Error("Expected nil when k is not less than the number of items")
+	}
+}
+
+// This test makes sure duplicates don't produce NaNs
+func TestLocalOutlierFactorsDuplicates(t *testing.T) {
+	items := []interface{}{
+		Coordinate{0, 0}, Coordinate{0, 0}, Coordinate{0, 0},
+		Coordinate{1, 1}, Coordinate{1, 1}, Coordinate{1, 1},
+	}
+
+	for i, score := range New(CoordinateMetric, items).LocalOutlierFactors(2) {
+		if score != 1 {
+			t.Errorf("Expected score %v to be 1, got %v", i, score)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+)
+
+// IntrinsicDimension is an estimate of the intrinsic dimensionality of the
+// items of a VP-tree, that is, the number of dimensions of the manifold the
+// items lie on, as opposed to the number of dimensions they are embedded in.
+type IntrinsicDimension struct {
+	// Dimension is the estimated intrinsic dimensionality.
+	Dimension float64
+
+	// Samples is the number of items the estimate is based on.
+	Samples int
+
+	// Pruning describes how well a VP-tree can be expected to prune
+	// searches at this dimensionality.
+	Pruning string
+}
+
+// EstimateIntrinsicDim estimates the intrinsic dimensionality of the items
+// with the TwoNN maximum-likelihood estimator: for every item x in a random
+// sample of sampleSize items, it finds the distances r1 and r2 to the
+// nearest and second-nearest other item, and estimates the dimension as
+//
+//	d = n / Σ log(r2/r1).
+//
+// The higher the intrinsic dimensionality, the more alike the distances
+// between items become, and the less of the tree searches can skip. Items
+// with duplicates are left out of the estimate. If sampleSize is not
+// positive or exceeds the number of items, all items are used.
+func (vp *VPTree) EstimateIntrinsicDim(sampleSize int, rng *rand.Rand) (est IntrinsicDimension) {
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+
+	if sampleSize > 0 && sampleSize < len(nodes) {
+		rng.Shuffle(len(nodes), func(i, j int) {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		})
+		nodes = nodes[:sampleSize]
+	}
+
+	sum := 0.0
+	seen := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		// Spill trees may hold an item more than once
+		if seen[n.Index] {
+			continue
+		}
+		seen[n.Index] = true
+
+		// The nearest neighbour of the item is the item itself
+		_, distances := vp.SearchIndices(n.Item, 3)
+		if len(distances) < 3 || distances[1] == 0 {
+			continue
+		}
+
+		sum += math.Log(distances[2] / distances[1])
+		est.Samples++
+	}
+
+	est.Dimension = float64(est.Samples) / sum
+	if est.Samples == 0 {
+		est.Dimension = 0
+	}
+
+	switch {
+	case est.Dimension < 8:
+		est.Pruning = "good: searches visit a small part of the tree"
+	case est.Dimension < 16:
+		est.Pruning = "moderate: searches visit a sizable part of the tree"
+	default:
+		est.Pruning = "poor: searches degrade towards a linear scan, consider an approximate search"
+	}
+
+	return
+}
+
+// CoveringNumber returns the size of a greedy eps-net of the items: every
+// item is within eps of one of the net's items, and the net's items are more
+// than eps apart. It is found by repeatedly picking an item that isn't
+// covered yet and covering all items within eps of it with a range search.
+// The size is at least the covering number N(eps), the least number of
+// balls of radius eps that cover the items, and at most N(eps/2).
+//
+// How fast CoveringNumber grows as eps shrinks characterizes the intrinsic
+// dimensionality of the items: for d-dimensional data, halving eps
+// multiplies it by about 2^d.
+func (vp *VPTree) CoveringNumber(eps float64) (count int) {
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+
+	covered := make([]bool, vp.itemCount())
+	for _, n := range nodes {
+		if covered[n.Index] {
+			continue
+		}
+		count++
+
+		q := getRadiusQuery(n.Item, eps)
+		vp.searchRadius(q, vp.root, 0)
+		for _, hi := range q.found {
+			covered[hi.Index] = true
+		}
+		putRadiusQuery(q)
+	}
+
+	return
+}
+
+// DoublingDimension estimates the doubling dimension of the items, the
+// least d such that every ball of radius r can be covered by 2^d balls of
+// radius r/2. It computes CoveringNumber at levels radii, starting at eps
+// and halving it each time, and returns the slope of the least-squares line
+// through log2 N(r) against log2(1/r). The radii should span the scale of
+// the neighbourhoods searches explore: once eps is so small that every item
+// has a ball of its own, the covering numbers stop growing.
+//
+// Each level takes a range search per ball. DoublingDimension returns 0 if
+// levels is less than 2.
+func (vp *VPTree) DoublingDimension(eps float64, levels int) float64 {
+	if levels < 2 {
+		return 0
+	}
+
+	// Fit log2 N = a + d * log2(1/r), where log2(1/r) grows by one per
+	// level
+	var sumX, sumY, sumXY, sumXX float64
+	for i := 0; i < levels; i++ {
+		x := float64(i)
+		y := math.Log2(float64(max(vp.CoveringNumber(eps), 1)))
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+		eps /= 2
+	}
+
+	n := float64(levels)
+	return (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+}
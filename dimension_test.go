@@ -0,0 +1,96 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure EstimateIntrinsicDim recovers the dimension of
+// points on a plane and in a cube that are embedded in 10 dimensions
+func TestEstimateIntrinsicDim(t *testing.T) {
+	const dim = 10
+	rng := rand.New(rand.NewSource(1))
+
+	for _, intrinsic := range []int{2, 5} {
+		basis := make([][]float64, intrinsic)
+		for i := range basis {
+			basis[i] = make([]float64, dim)
+			for j := range basis[i] {
+				basis[i][j] = rng.NormFloat64()
+			}
+		}
+
+		var items []interface{}
+		for i := 0; i < 5000; i++ {
+			v := make([]float64, dim)
+			for _, b := range basis {
+				c := rng.Float64()
+				for j := range v {
+					v[j] += c * b[j]
+				}
+			}
+			items = append(items, v)
+		}
+
+		vp := New(euclidean, items)
+		est := vp.EstimateIntrinsicDim(1000, rng)
+
+		if est.Samples != 1000 {
+			t.Errorf("Expected 1000 samples, got %v", est.Samples)
+		}
+
+		if math.Abs(est.Dimension-float64(intrinsic)) > 0.2*float64(intrinsic) {
+			t.Errorf("Expected a dimension of about %v, got %v", intrinsic, est.Dimension)
+		}
+
+		if est.Pruning == "" {
+			t.Errorf("Expected an interpretation of the dimension")
+		}
+	}
+}
+
+// This test checks CoveringNumber on a grid, and makes sure the net it is
+// based on covers all items
+func TestCoveringNumber(t *testing.T) {
+	var items []interface{}
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			items = append(items, Coordinate{float64(x), float64(y)})
+		}
+	}
+	vp := New(CoordinateMetric, items)
+
+	if n := vp.CoveringNumber(0.5); n != 100 {
+		t.Errorf("Expected every item to need its own ball, got %v balls", n)
+	}
+
+	if n := vp.CoveringNumber(100); n != 1 {
+		t.Errorf("Expected one ball to cover everything, got %v balls", n)
+	}
+
+	// A ball of radius 1 covers at most 5 grid points, and the net's
+	// items are more than 1 apart, so at most one in two is picked
+	if n := vp.CoveringNumber(1); n < 20 || n > 50 {
+		t.Errorf("Expected between 20 and 50 balls of radius 1, got %v", n)
+	}
+
+	if n := New(CoordinateMetric, nil).CoveringNumber(1); n != 0 {
+		t.Errorf("Expected no balls for an empty tree, got %v", n)
+	}
+}
+
+// This test makes sure DoublingDimension recovers the dimension of random
+// points in the unit square
+func TestDoublingDimension(t *testing.T) {
+	_, items := randomCoordinates(10000)
+	vp := New(CoordinateMetric, items)
+
+	if d := vp.DoublingDimension(0.2, 3); d < 1.5 || d > 2.5 {
+		t.Errorf("Expected a doubling dimension of about 2, got %v", d)
+	}
+
+	if d := vp.DoublingDimension(0.2, 1); d != 0 {
+		t.Errorf("Expected 0 for a single level, got %v", d)
+	}
+}
@@ -0,0 +1,247 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"net/rpc"
+	"sort"
+	"sync"
+)
+
+// A SerializableItem is an item that can be sent to and from a RemoteShard.
+// Items travel over net/rpc, which encodes them with encoding/gob, so every
+// concrete type used as a SerializableItem must be registered with
+// gob.Register on both ends.
+type SerializableItem interface{}
+
+// A RemoteShard answers k-nearest-neighbour queries for one part of a data
+// set, usually on another machine. Its results must be ordered by increasing
+// distance, like those of VPTree.Search.
+type RemoteShard interface {
+	Search(target SerializableItem, k int) ([]SerializableItem, []float64, error)
+}
+
+// ShardBounds describe where the items of a shard lie: all of them are
+// within Radius of Center. DistributedVPTree uses them to skip shards that
+// cannot contain any of the nearest neighbours.
+type ShardBounds struct {
+	Center SerializableItem
+	Radius float64
+}
+
+// shardBoundsCandidates is the number of items NewShardBounds tries as the
+// center.
+const shardBoundsCandidates = 16
+
+// NewShardBounds returns bounds for a shard holding items. It tries a few
+// evenly spaced items as the center and keeps the one with the smallest
+// radius, which needs a number of metric calls linear in len(items).
+func NewShardBounds(metric Metric, items []interface{}) ShardBounds {
+	bounds := ShardBounds{Radius: math.Inf(1)}
+
+	step := max(1, len(items)/shardBoundsCandidates)
+	for c := 0; c < len(items); c += step {
+		radius := 0.0
+		for _, item := range items {
+			radius = max(radius, metric(items[c], item))
+		}
+		if radius < bounds.Radius {
+			bounds.Center, bounds.Radius = items[c], radius
+		}
+	}
+
+	return bounds
+}
+
+// A DistributedVPTree searches a data set that is sharded across several
+// RemoteShards. It keeps a local routing tree, a VP-tree over the centers of
+// the shards, to decide which shards need to be queried.
+type DistributedVPTree struct {
+	distanceMetric Metric
+	shards         []RemoteShard
+	bounds         []ShardBounds
+	routing        *VPTree
+	maxRadius      float64
+}
+
+// NewDistributed creates a DistributedVPTree over shards, where bounds[i]
+// are the bounds of shards[i] under metric.
+func NewDistributed(metric Metric, shards []RemoteShard, bounds []ShardBounds) *DistributedVPTree {
+	if len(shards) != len(bounds) {
+		panic("vptree: need bounds for every shard")
+	}
+
+	centers := make([]interface{}, len(bounds))
+	dt := &DistributedVPTree{
+		distanceMetric: metric,
+		shards:         shards,
+		bounds:         bounds,
+	}
+	for i, b := range bounds {
+		centers[i] = b.Center
+		dt.maxRadius = max(dt.maxRadius, b.Radius)
+	}
+	dt.routing = New(metric, centers)
+
+	return dt
+}
+
+// Search returns the k nearest neighbours of target over all shards,
+// ordered by increasing distance. It first queries the shard whose center
+// is nearest to target, and then, in parallel, every shard whose bounds
+// allow it to hold an item closer than the k-th neighbour found so far. If
+// a shard fails, Search returns its error.
+func (dt *DistributedVPTree) Search(target interface{}, k int) (results []interface{}, distances []float64, err error) {
+	if k < 1 || len(dt.shards) == 0 {
+		return
+	}
+
+	nearest, _ := dt.routing.SearchIndices(target, 1)
+	first := nearest[0]
+
+	found, err := dt.searchShards(target, k, []int{first})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Any other shard holding a closer item than tau has its center within
+	// tau + radius of the target
+	tau := math.Inf(1)
+	if len(found) == k {
+		tau = found[k-1].Distance
+	}
+
+	var candidates []int
+	if math.IsInf(tau, 1) {
+		for i := range dt.shards {
+			if i != first {
+				candidates = append(candidates, i)
+			}
+		}
+	} else {
+		indices, dists := dt.routing.SearchRadiusIndices(target, tau+dt.maxRadius)
+		for j, i := range indices {
+			if i != first && dists[j]-dt.bounds[i].Radius < tau {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+
+	more, err := dt.searchShards(target, k, candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	found = append(found, more...)
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Distance < found[j].Distance
+	})
+	if len(found) > k {
+		found = found[:k]
+	}
+
+	for _, nb := range found {
+		results = append(results, nb.Item)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// searchShards queries the given shards in parallel and returns the union
+// of their results, with Index set to the shard an item came from.
+func (dt *DistributedVPTree) searchShards(target interface{}, k int, shards []int) ([]Neighbour, error) {
+	var (
+		mu    sync.Mutex
+		found []Neighbour
+		errs  = make([]error, len(shards))
+		wg    sync.WaitGroup
+	)
+
+	for j, i := range shards {
+		wg.Add(1)
+		go func(j, i int) {
+			defer wg.Done()
+
+			items, dists, err := dt.shards[i].Search(target, k)
+			if err != nil {
+				errs[j] = fmt.Errorf("vptree: shard %v: %w", i, err)
+				return
+			}
+			if len(items) != len(dists) {
+				errs[j] = fmt.Errorf("vptree: shard %v returned %v items but %v distances", i, len(items), len(dists))
+				return
+			}
+
+			mu.Lock()
+			for r, item := range items {
+				found = append(found, Neighbour{item, i, dists[r]})
+			}
+			mu.Unlock()
+		}(j, i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// shardServiceName is the name the shard service is registered under.
+const shardServiceName = "VPTreeShard"
+
+// ShardSearchArgs are the arguments of a remote shard search.
+type ShardSearchArgs struct {
+	Target SerializableItem
+	K      int
+}
+
+// ShardSearchReply is the result of a remote shard search.
+type ShardSearchReply struct {
+	Items     []SerializableItem
+	Distances []float64
+}
+
+// shardService serves searches on a VP-tree over net/rpc.
+type shardService struct {
+	tree *VPTree
+}
+
+func (s *shardService) Search(args *ShardSearchArgs, reply *ShardSearchReply) error {
+	results, distances := s.tree.Search(args.Target, args.K)
+
+	reply.Items = make([]SerializableItem, len(results))
+	for i, item := range results {
+		reply.Items[i] = item
+	}
+	reply.Distances = distances
+
+	return nil
+}
+
+// RegisterShard registers tree with server, so that clients connected to
+// server can query it with a shard returned by NewRPCShard.
+func RegisterShard(server *rpc.Server, tree *VPTree) error {
+	return server.RegisterName(shardServiceName, &shardService{tree})
+}
+
+// rpcShard is a RemoteShard backed by a net/rpc client.
+type rpcShard struct {
+	client *rpc.Client
+}
+
+// NewRPCShard returns a RemoteShard that queries the VP-tree registered
+// with RegisterShard on the other end of client.
+func NewRPCShard(client *rpc.Client) RemoteShard {
+	return &rpcShard{client}
+}
+
+func (s *rpcShard) Search(target SerializableItem, k int) ([]SerializableItem, []float64, error) {
+	var reply ShardSearchReply
+	if err := s.client.Call(shardServiceName+".Search", &ShardSearchArgs{target, k}, &reply); err != nil {
+		return nil, nil, err
+	}
+	return reply.Items, reply.Distances, nil
+}
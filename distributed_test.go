@@ -0,0 +1,110 @@
+package vptree
+
+import (
+	"encoding/gob"
+	"errors"
+	"math/rand"
+	"net"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+)
+
+// countingShard counts the searches passed on to a RemoteShard
+type countingShard struct {
+	RemoteShard
+	searches atomic.Int64
+}
+
+func (s *countingShard) Search(target SerializableItem, k int) ([]SerializableItem, []float64, error) {
+	s.searches.Add(1)
+	return s.RemoteShard.Search(target, k)
+}
+
+// serveShard serves a VP-tree over items through net/rpc on an in-memory
+// connection
+func serveShard(t *testing.T, items []interface{}) *countingShard {
+	server := rpc.NewServer()
+	if err := RegisterShard(server, New(CoordinateMetric, items)); err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	client := rpc.NewClient(clientConn)
+	t.Cleanup(func() { client.Close() })
+
+	return &countingShard{RemoteShard: NewRPCShard(client)}
+}
+
+// This test shards random coordinates by quadrant across four RPC servers
+// and compares the distributed search to brute force
+func TestDistributedVPTree(t *testing.T) {
+	gob.Register(Coordinate{})
+
+	coords, _ := randomCoordinates(1000)
+	quadrants := make([][]interface{}, 4)
+	for _, c := range coords {
+		q := 0
+		if c.X >= 0.5 {
+			q++
+		}
+		if c.Y >= 0.5 {
+			q += 2
+		}
+		quadrants[q] = append(quadrants[q], c)
+	}
+
+	var shards []RemoteShard
+	var counting []*countingShard
+	var bounds []ShardBounds
+	for _, items := range quadrants {
+		s := serveShard(t, items)
+		counting = append(counting, s)
+		shards = append(shards, s)
+		bounds = append(bounds, NewShardBounds(CoordinateMetric, items))
+	}
+
+	dt := NewDistributed(CoordinateMetric, shards, bounds)
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(20) + 1
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, k)
+		results, distances, err := dt.Search(q, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	// A query in the corner of a quadrant only needs that quadrant
+	for _, s := range counting {
+		s.searches.Store(0)
+	}
+	if _, _, err := dt.Search(Coordinate{0.01, 0.01}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if n := counting[0].searches.Load() + counting[1].searches.Load() + counting[2].searches.Load() + counting[3].searches.Load(); n != 1 {
+		t.Errorf("Expected a single shard to be queried, got %v", n)
+	}
+}
+
+type failingShard struct{}
+
+var errShardDown = errors.New("shard down")
+
+func (failingShard) Search(target SerializableItem, k int) ([]SerializableItem, []float64, error) {
+	return nil, nil, errShardDown
+}
+
+// This test makes sure the error of a failing shard is returned
+func TestDistributedVPTreeError(t *testing.T) {
+	dt := NewDistributed(CoordinateMetric, []RemoteShard{failingShard{}}, []ShardBounds{{Coordinate{}, 1}})
+
+	if _, _, err := dt.Search(Coordinate{}, 1); !errors.Is(err, errShardDown) {
+		t.Errorf("Expected %v, got %v", errShardDown, err)
+	}
+}
@@ -0,0 +1,48 @@
+package vptree
+
+// SearchDiversified returns up to k near neighbours of target that are spread
+// out around it, together with their distances to target, in order of least
+// distance to largest distance. It goes through the neighbours of target
+// from nearest to farthest and selects a neighbour at distance tau from
+// target only if it is at least minDiversity*tau away from every neighbour
+// selected before it. A minDiversity of 0 makes it equivalent to Search.
+//
+// Neighbours are fetched in batches of growing size, so the search may have
+// to visit a large part of the tree if few neighbours qualify.
+func (vp *VPTree) SearchDiversified(target interface{}, k int, minDiversity float64) (results []interface{}, distances []float64) {
+	if k < 1 || vp.root == nil {
+		return
+	}
+
+	checked := 0
+	for batch := 2 * k; ; batch *= 2 {
+		candidates := vp.nearest(target, batch)
+
+		// The first candidates were checked in an earlier batch
+		for _, c := range candidates[checked:] {
+			if vp.diverse(c.Item, results, minDiversity*c.Dist) {
+				results = append(results, c.Item)
+				distances = append(distances, c.Dist)
+				if len(results) == k {
+					return
+				}
+			}
+		}
+		checked = len(candidates)
+
+		if len(candidates) < batch {
+			return
+		}
+	}
+}
+
+// diverse returns whether item is at least minDist away from all of the
+// selected items.
+func (vp *VPTree) diverse(item interface{}, selected []interface{}, minDist float64) bool {
+	for _, s := range selected {
+		if vp.distanceMetric(item, s) < minDist {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test compares SearchDiversified against a greedy selection over all
+// items sorted by distance
+func TestSearchDiversified(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(10) + 1
+		minDiversity := rand.Float64() * 2
+
+		sorted := append([]Coordinate(nil), coords...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return CoordinateMetric(sorted[i], q) < CoordinateMetric(sorted[j], q)
+		})
+
+		var expected []Coordinate
+		var expectedDists []float64
+		for _, c := range sorted {
+			tau := CoordinateMetric(c, q)
+			ok := true
+			for _, s := range expected {
+				if CoordinateMetric(c, s) < minDiversity*tau {
+					ok = false
+				}
+			}
+			if ok && len(expected) < k {
+				expected = append(expected, c)
+				expectedDists = append(expectedDists, tau)
+			}
+		}
+
+		results, distances := vp.SearchDiversified(q, k, minDiversity)
+		compareCoordDistSets(t, results, expected, distances, expectedDists)
+	}
+}
+
+// This test makes sure a minimum diversity of 0 gives the same results as
+// Search
+func TestSearchDiversifiedZero(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	expectedResults, expectedDistances := vp.Search(q, 10)
+	results, distances := vp.SearchDiversified(q, 10, 0)
+
+	compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+}
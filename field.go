@@ -0,0 +1,53 @@
+package vptree
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// FieldMetric returns a metric that measures the distance between two
+// structs (or pointers to structs) by applying baseMetric to their field
+// called fieldName. This way a VP-tree can index whole records by one of
+// their fields, e.g. a User by its Location, and return the records
+// themselves.
+//
+// The position of the field is looked up once per struct type and cached.
+// The returned metric panics if an item is not a struct, has no field of
+// that name, or if the field is unexported.
+func FieldMetric(fieldName string, baseMetric Metric) Metric {
+	var indices sync.Map // reflect.Type -> []int
+
+	field := func(item interface{}) interface{} {
+		v := reflect.ValueOf(item)
+		for v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+
+		var index []int
+		if cached, ok := indices.Load(v.Type()); ok {
+			index = cached.([]int)
+		} else {
+			if v.Kind() != reflect.Struct {
+				panic(fmt.Sprintf("vptree: FieldMetric: %v is not a struct", v.Type()))
+			}
+
+			f, ok := v.Type().FieldByName(fieldName)
+			if !ok {
+				panic(fmt.Sprintf("vptree: FieldMetric: %v has no field %q", v.Type(), fieldName))
+			}
+			if f.PkgPath != "" {
+				panic(fmt.Sprintf("vptree: FieldMetric: field %q of %v is unexported", fieldName, v.Type()))
+			}
+
+			index = f.Index
+			indices.Store(v.Type(), index)
+		}
+
+		return v.FieldByIndex(index).Interface()
+	}
+
+	return func(a, b interface{}) float64 {
+		return baseMetric(field(a), field(b))
+	}
+}
@@ -0,0 +1,65 @@
+package vptree
+
+import (
+	"testing"
+)
+
+type user struct {
+	Name     string
+	Location Coordinate
+	secret   int
+}
+
+// This test makes sure FieldMetric measures distances on the named field and
+// works for both structs and pointers to structs
+func TestFieldMetric(t *testing.T) {
+	m := FieldMetric("Location", CoordinateMetric)
+
+	alice := user{"Alice", Coordinate{0, 0}, 0}
+	bob := user{"Bob", Coordinate{3, 4}, 0}
+
+	if d := m(alice, bob); d != 5 {
+		t.Errorf("Expected distance 5, got %v", d)
+	}
+
+	if d := m(&alice, &bob); d != 5 {
+		t.Errorf("Expected distance 5 between pointers, got %v", d)
+	}
+
+	items := []interface{}{
+		alice,
+		bob,
+		user{"Carol", Coordinate{10, 10}, 0},
+	}
+
+	vp := New(m, items)
+	results, _ := vp.Search(user{Location: Coordinate{2, 3}}, 1)
+
+	if len(results) != 1 || results[0].(user).Name != "Bob" {
+		t.Errorf("Expected to find Bob, got %v", results)
+	}
+}
+
+// This test makes sure FieldMetric panics for missing and unexported fields
+func TestFieldMetricPanics(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%v: expected a panic", name)
+			}
+		}()
+		f()
+	}
+
+	expectPanic("missing field", func() {
+		FieldMetric("Position", CoordinateMetric)(user{}, user{})
+	})
+
+	expectPanic("unexported field", func() {
+		FieldMetric("secret", CoordinateMetric)(user{}, user{})
+	})
+
+	expectPanic("not a struct", func() {
+		FieldMetric("Location", CoordinateMetric)(1, 2)
+	})
+}
@@ -0,0 +1,121 @@
+package vptree
+
+import (
+	"sort"
+	"sync"
+)
+
+// A Forest is an ensemble of VP-trees over the same items, each built with
+// different random vantage points. A search that may only visit a few nodes
+// of a tree misses neighbours that lie across a threshold close to the
+// target; since the trees put their thresholds in different places, a
+// Forest searching several trees with small budgets tends to find more true
+// neighbours than a single tree searched with their sum.
+//
+// The trees share the items themselves; every tree only adds its own nodes.
+type Forest struct {
+	items []interface{}
+	trees []*VPTree
+}
+
+// NewForest creates a Forest of b VP-trees over items, each built by New with
+// the metric and opts. Values of b less than 1 select a single tree.
+func NewForest(metric Metric, items []interface{}, b int, opts ...Option) *Forest {
+	if b < 1 {
+		b = 1
+	}
+
+	f := &Forest{
+		items: items,
+		trees: make([]*VPTree, b),
+	}
+
+	var wg sync.WaitGroup
+	for i := range f.trees {
+		wg.Add(1)
+		go func(i int) {
+			f.trees[i] = New(metric, items, opts...)
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	return f
+}
+
+// Len returns the number of items in the Forest.
+func (f *Forest) Len() int {
+	return len(f.items)
+}
+
+// NumTrees returns the number of trees in the Forest.
+func (f *Forest) NumTrees() int {
+	return len(f.trees)
+}
+
+// SearchApprox searches every tree of the Forest in parallel for the k
+// nearest neighbours of target, visiting at most perTreeBudget nodes of each
+// tree as with WithMaxVisits, and returns the k nearest items of the union of
+// their results, in order of least distance to largest distance. A
+// perTreeBudget less than 1 searches the trees exhaustively, which returns
+// the same neighbours as VPTree.Search.
+func (f *Forest) SearchApprox(target interface{}, k, perTreeBudget int) (results []interface{}, distances []float64) {
+	indices, distances := f.SearchApproxIndices(target, k, perTreeBudget)
+	for _, idx := range indices {
+		results = append(results, f.items[idx])
+	}
+	return
+}
+
+// SearchApproxIndices is like SearchApprox, but returns the indices of the
+// items instead of the items themselves.
+func (f *Forest) SearchApproxIndices(target interface{}, k, perTreeBudget int) (indices []int, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	lists := make([][]int, len(f.trees))
+	dists := make([][]float64, len(f.trees))
+
+	var wg sync.WaitGroup
+	for i, t := range f.trees {
+		wg.Add(1)
+		go func(i int, t *VPTree) {
+			s := t.NewSearcher(WithMaxVisits(perTreeBudget))
+			lists[i], dists[i] = s.searchIndices(target, k)
+			wg.Done()
+		}(i, t)
+	}
+	wg.Wait()
+
+	// The trees share the metric, so the distances they report are exact
+	// and an item found by several trees only needs to be kept once
+	var candidates []heapItem
+	seen := make(map[int]bool)
+	for i, list := range lists {
+		for j, idx := range list {
+			if !seen[idx] {
+				seen[idx] = true
+				candidates = append(candidates, heapItem{f.items[idx], idx, dists[i][j]})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Dist != candidates[j].Dist {
+			return candidates[i].Dist < candidates[j].Dist
+		}
+		return candidates[i].Index < candidates[j].Index
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	for _, c := range candidates {
+		indices = append(indices, c.Index)
+		distances = append(distances, c.Dist)
+	}
+
+	return
+}
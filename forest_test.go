@@ -0,0 +1,136 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// clusteredVectors returns n vectors of the given dimension in Gaussian
+// clusters of the given size around centers scattered over the unit cube
+func clusteredVectors(rng *rand.Rand, n, dim, size int) []interface{} {
+	items := make([]interface{}, n)
+	var center []float64
+	for i := range items {
+		if i%size == 0 {
+			center = make([]float64, dim)
+			for j := range center {
+				center[j] = rng.Float64()
+			}
+		}
+		v := make([]float64, dim)
+		for j := range v {
+			v[j] = center[j] + 0.05*rng.NormFloat64()
+		}
+		items[i] = v
+	}
+	return items
+}
+
+// forestRecall returns the fraction of the true k nearest neighbours of the
+// queries that search finds
+func forestRecall(items, queries []interface{}, k int, search func(target interface{}) []int) float64 {
+	hits := 0
+	for _, q := range queries {
+		all := make([]float64, len(items))
+		for i, item := range items {
+			all[i] = euclidean(item, q)
+		}
+		sort.Float64s(all)
+
+		indices := search(q)
+		for _, idx := range indices {
+			if euclidean(items[idx], q) <= all[k-1] {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(k*len(queries))
+}
+
+// This test makes sure that a Forest searched with small budgets finds more
+// of the true nearest neighbours than a single tree searched with their sum
+func TestForestRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := clusteredVectors(rng, 5000, 8, 50)
+	queries := clusteredVectors(rng, 200, 8, 1)
+
+	const (
+		k      = 10
+		trees  = 4
+		budget = 400
+	)
+
+	f := NewForest(euclidean, items, trees)
+	single := New(euclidean, items).NewSearcher(WithMaxVisits(budget))
+
+	forest := forestRecall(items, queries, k, func(target interface{}) []int {
+		indices, _ := f.SearchApproxIndices(target, k, budget/trees)
+		return indices
+	})
+	tree := forestRecall(items, queries, k, func(target interface{}) []int {
+		indices, _ := single.searchIndices(target, k)
+		return indices
+	})
+
+	t.Logf("Recall at a budget of %v visits: forest %.3f, single tree %.3f", budget, forest, tree)
+	if forest <= tree {
+		t.Errorf("Expected the forest to beat a single tree at equal budget, got %v <= %v", forest, tree)
+	}
+}
+
+// This test makes sure a Forest searched without a budget finds the exact
+// nearest neighbours, and that it returns every item only once
+func TestForestExact(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	f := NewForest(CoordinateMetric, vpitems, 3)
+
+	if f.NumTrees() != 3 || f.Len() != 1000 {
+		t.Errorf("Expected 3 trees over 1000 items, got %v trees over %v items", f.NumTrees(), f.Len())
+	}
+
+	for i := 0; i < 50; i++ {
+		target := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		coords, distances := f.SearchApprox(target, 10, 0)
+		expectedCoords, expectedDists := nearestNeighbours(target, items, 10)
+		compareCoordDistSets(t, coords, expectedCoords, distances, expectedDists)
+
+		indices, _ := f.SearchApproxIndices(target, 10, 0)
+		seen := make(map[int]bool)
+		for _, idx := range indices {
+			if seen[idx] {
+				t.Fatalf("Index %v returned twice", idx)
+			}
+			seen[idx] = true
+		}
+	}
+
+	if results, _ := f.SearchApprox(Coordinate{}, 0, 0); len(results) != 0 {
+		t.Errorf("Expected no results for k = 0, got %v", results)
+	}
+}
+
+func BenchmarkForest(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	items := clusteredVectors(rng, 20000, 8, 50)
+	queries := clusteredVectors(rng, 100, 8, 1)
+
+	const budget = 400
+
+	b.Run("Tree", func(b *testing.B) {
+		s := New(euclidean, items).NewSearcher(WithMaxVisits(budget))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.Search(queries[i%len(queries)], 10)
+		}
+	})
+
+	b.Run("Forest", func(b *testing.B) {
+		f := NewForest(euclidean, items, 4)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			f.SearchApprox(queries[i%len(queries)], 10, budget/4)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package vptree
+
+import (
+	"github.com/DataWraith/vptree/internal/sphere"
+)
+
+// earthRadiusKm is the mean radius of the Earth in kilometers.
+const earthRadiusKm = 6371.0088
+
+// A GeoPoint is a location on the surface of the Earth, given by its latitude
+// and longitude in degrees.
+type GeoPoint struct {
+	Lat float64
+	Lon float64
+}
+
+// haversineKm returns the great-circle distance between a and b in
+// kilometers. Great-circle distance is a metric on the sphere, and since it
+// is computed from the points themselves, paths across the antimeridian or
+// the poles need no special treatment.
+func haversineKm(a, b GeoPoint) float64 {
+	return earthRadiusKm * sphere.CentralAngle(a.Lat, a.Lon, b.Lat, b.Lon)
+}
+
+func geoMetric(a, b interface{}) float64 {
+	return haversineKm(a.(GeoPoint), b.(GeoPoint))
+}
+
+// A GeoTree is a VP-tree over GeoPoints using great-circle distance.
+type GeoTree struct {
+	tree *VPTree
+}
+
+// NewGeo creates a new GeoTree containing the given points.
+func NewGeo(points []GeoPoint) *GeoTree {
+	items := make([]interface{}, len(points))
+	for i, p := range points {
+		items[i] = p
+	}
+
+	return &GeoTree{
+		tree: New(geoMetric, items),
+	}
+}
+
+// NearestKm returns the up to k points nearest to p and their distances to p
+// in kilometers, in order of least distance to largest distance.
+func (g *GeoTree) NearestKm(p GeoPoint, k int) (points []GeoPoint, distances []float64) {
+	results, distances := g.tree.Search(p, k)
+	return toGeoPoints(results), distances
+}
+
+// WithinRadiusMeters returns all points within the given number of meters
+// of p and their distances to p in meters, in order of least distance to
+// largest distance.
+func (g *GeoTree) WithinRadiusMeters(p GeoPoint, meters float64) (points []GeoPoint, distances []float64) {
+	results, distances := g.tree.SearchRadius(p, meters/1000)
+	for i := range distances {
+		distances[i] *= 1000
+	}
+	return toGeoPoints(results), distances
+}
+
+func toGeoPoints(items []interface{}) (points []GeoPoint) {
+	for _, item := range items {
+		points = append(points, item.(GeoPoint))
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package vptree
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	berlin  = GeoPoint{52.5200, 13.4050}
+	paris   = GeoPoint{48.8566, 2.3522}
+	london  = GeoPoint{51.5074, -0.1278}
+	newYork = GeoPoint{40.7128, -74.0060}
+	sydney  = GeoPoint{-33.8688, 151.2093}
+)
+
+// This test pins the great-circle distances between a few cities
+func TestHaversineKnownDistances(t *testing.T) {
+	tests := []struct {
+		a, b GeoPoint
+		km   float64
+	}{
+		{berlin, paris, 878},
+		{london, newYork, 5570},
+		{berlin, sydney, 16090},
+		{paris, paris, 0},
+	}
+
+	for _, test := range tests {
+		d := haversineKm(test.a, test.b)
+		if math.Abs(d-test.km) > 0.005*test.km {
+			t.Errorf("Expected distance between %v and %v to be about %v km, got %v", test.a, test.b, test.km, d)
+		}
+	}
+}
+
+// This test makes sure NearestKm finds the right cities in the right order
+func TestGeoTreeNearestKm(t *testing.T) {
+	g := NewGeo([]GeoPoint{berlin, paris, london, newYork, sydney})
+
+	points, distances := g.NearestKm(berlin, 3)
+
+	expected := []GeoPoint{berlin, paris, london}
+	if len(points) != len(expected) {
+		t.Fatalf("Expected %v points, got %v", len(expected), len(points))
+	}
+
+	for i := range expected {
+		if points[i] != expected[i] {
+			t.Errorf("Expected points[%v] to be %v, got %v", i, expected[i], points[i])
+		}
+		if distances[i] != haversineKm(berlin, expected[i]) {
+			t.Errorf("Expected distances[%v] to be %v, got %v", i, haversineKm(berlin, expected[i]), distances[i])
+		}
+	}
+}
+
+// This test makes sure queries close to the antimeridian find neighbours on
+// the other side of it
+func TestGeoTreeAntimeridian(t *testing.T) {
+	east := GeoPoint{0, 179.9}
+	west := GeoPoint{0, -179.9}
+	far := GeoPoint{0, 170}
+
+	g := NewGeo([]GeoPoint{west, far})
+
+	points, distances := g.NearestKm(GeoPoint{0, 179.95}, 1)
+	if len(points) != 1 || points[0] != west {
+		t.Fatalf("Expected nearest point to be %v, got %v", west, points)
+	}
+
+	if math.Abs(distances[0]-16.68) > 0.01 {
+		t.Errorf("Expected distance across the antimeridian to be about 16.68 km, got %v", distances[0])
+	}
+
+	if d := haversineKm(east, west); math.Abs(d-22.24) > 0.01 {
+		t.Errorf("Expected distance between %v and %v to be about 22.24 km, got %v", east, west, d)
+	}
+}
+
+// This test makes sure radius queries around a pole find points on all
+// sides of it
+func TestGeoTreePolar(t *testing.T) {
+	points := []GeoPoint{
+		{89.9, 0},
+		{89.9, 90},
+		{89.9, 180},
+		{89.9, -90},
+		{89, 0},
+		{-89.9, 0},
+	}
+
+	g := NewGeo(points)
+
+	within, distances := g.WithinRadiusMeters(GeoPoint{90, 45}, 12000)
+	if len(within) != 4 {
+		t.Fatalf("Expected 4 points within 12 km of the north pole, got %v", within)
+	}
+
+	for i, p := range within {
+		if p.Lat != 89.9 {
+			t.Errorf("Expected only points at latitude 89.9, got %v", p)
+		}
+		if math.Abs(distances[i]-11119.5) > 1 {
+			t.Errorf("Expected distances[%v] to be about 11119.5 m, got %v", i, distances[i])
+		}
+	}
+}
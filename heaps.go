@@ -0,0 +1,101 @@
+package vptree
+
+// A Neighbour is an item found by a search, together with its index in the
+// items slice the VP-tree was built from and its distance to the target.
+type Neighbour struct {
+	Item     interface{}
+	Index    int
+	Distance float64
+}
+
+// A MinDistanceHeap is a min-heap of Neighbours ordered by distance, for use
+// with container/heap. Popping it yields the Neighbours closest-first.
+type MinDistanceHeap []Neighbour
+
+func (h MinDistanceHeap) Len() int { return len(h) }
+
+func (h MinDistanceHeap) Less(i, j int) bool {
+	return h[i].Distance < h[j].Distance
+}
+
+func (h MinDistanceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *MinDistanceHeap) Push(x interface{}) {
+	*h = append(*h, x.(Neighbour))
+}
+
+func (h *MinDistanceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[0 : n-1]
+	return item
+}
+
+// Top returns the closest Neighbour without removing it. The heap must not be
+// empty.
+func (h MinDistanceHeap) Top() Neighbour {
+	return h[0]
+}
+
+// A MaxDistanceHeap is a max-heap of Neighbours ordered by distance, for use
+// with container/heap. It is what k-nearest-neighbour searches need to keep
+// track of the k best candidates: the farthest one is always on top.
+type MaxDistanceHeap []Neighbour
+
+func (h MaxDistanceHeap) Len() int { return len(h) }
+
+func (h MaxDistanceHeap) Less(i, j int) bool {
+	// We want a max-heap, so we use greater-than here
+	return h[i].Distance > h[j].Distance
+}
+
+func (h MaxDistanceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *MaxDistanceHeap) Push(x interface{}) {
+	*h = append(*h, x.(Neighbour))
+}
+
+func (h *MaxDistanceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[0 : n-1]
+	return item
+}
+
+// Top returns the farthest Neighbour without removing it. The heap must not
+// be empty.
+func (h MaxDistanceHeap) Top() Neighbour {
+	return h[0]
+}
+
+// A BoundedHeap keeps the candidates of a k-nearest-neighbour search, with
+// the farthest one on top. The search never pushes more items than the
+// capacity the heap was created with; once the heap is full, it pops the
+// farthest item before pushing a closer one.
+type BoundedHeap interface {
+	Push(item interface{}, dist float64)
+	PopMax() (interface{}, float64)
+	PeekMax() (interface{}, float64)
+	Len() int
+}
+
+// A HeapFactory creates a BoundedHeap for up to capacity items.
+type HeapFactory func(capacity int) BoundedHeap
+
+// WithHeapFactory makes the VP-tree keep the candidates of its searches in
+// heaps created by f instead of its built-in heap, for example to use a
+// bucket queue for integer distances. It applies to Search, SearchIndices,
+// SearchWithStats, SearchDebugTrace, KthNearestDistance and AdaptiveSearch;
+// Searchers, parallel and large-k searches and spill trees keep using the
+// built-in heap.
+func WithHeapFactory(f HeapFactory) Option {
+	return func(vp *VPTree) {
+		vp.heapFactory = f
+	}
+}
@@ -0,0 +1,103 @@
+package vptree
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test pushes random distances into both heaps and makes sure they pop
+// out in the right order
+func TestDistanceHeaps(t *testing.T) {
+	var minHeap MinDistanceHeap
+	var maxHeap MaxDistanceHeap
+
+	distances := make([]float64, 100)
+	for i := range distances {
+		distances[i] = rand.Float64()
+		heap.Push(&minHeap, Neighbour{Index: i, Distance: distances[i]})
+		heap.Push(&maxHeap, Neighbour{Index: i, Distance: distances[i]})
+	}
+
+	sort.Float64s(distances)
+
+	if minHeap.Top().Distance != distances[0] {
+		t.Errorf("Expected the min-heap's top to be %v, got %v", distances[0], minHeap.Top().Distance)
+	}
+
+	if maxHeap.Top().Distance != distances[len(distances)-1] {
+		t.Errorf("Expected the max-heap's top to be %v, got %v", distances[len(distances)-1], maxHeap.Top().Distance)
+	}
+
+	for i := range distances {
+		if d := heap.Pop(&minHeap).(Neighbour).Distance; d != distances[i] {
+			t.Errorf("Expected min-heap pop %v to be %v, got %v", i, distances[i], d)
+		}
+
+		if d := heap.Pop(&maxHeap).(Neighbour).Distance; d != distances[len(distances)-1-i] {
+			t.Errorf("Expected max-heap pop %v to be %v, got %v", i, distances[len(distances)-1-i], d)
+		}
+	}
+}
+
+// sortedHeap is a BoundedHeap that keeps its items in a slice sorted by
+// distance
+type sortedHeap struct {
+	items  []interface{}
+	dists  []float64
+	pushes *int
+}
+
+func (h *sortedHeap) Push(item interface{}, dist float64) {
+	*h.pushes++
+	i := sort.SearchFloat64s(h.dists, dist)
+	h.items = append(h.items[:i], append([]interface{}{item}, h.items[i:]...)...)
+	h.dists = append(h.dists[:i], append([]float64{dist}, h.dists[i:]...)...)
+}
+
+func (h *sortedHeap) PopMax() (interface{}, float64) {
+	last := len(h.items) - 1
+	item, dist := h.items[last], h.dists[last]
+	h.items, h.dists = h.items[:last], h.dists[:last]
+	return item, dist
+}
+
+func (h *sortedHeap) PeekMax() (interface{}, float64) {
+	return h.items[len(h.items)-1], h.dists[len(h.dists)-1]
+}
+
+func (h *sortedHeap) Len() int {
+	return len(h.items)
+}
+
+// This test searches a VP-tree that keeps its candidates in a custom heap
+func TestWithHeapFactory(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+
+	pushes := 0
+	vp := New(CoordinateMetric, items, WithHeapFactory(func(capacity int) BoundedHeap {
+		return &sortedHeap{pushes: &pushes}
+	}))
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(20) + 1
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, k)
+		results, distances := vp.Search(q, k)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+
+		if d := vp.KthNearestDistance(q, k); d != expectedDists[k-1] {
+			t.Errorf("Expected the %v-th nearest distance to be %v, got %v", k, expectedDists[k-1], d)
+		}
+
+		// Searchers keep using the built-in heap
+		results, distances = vp.NewSearcher().Search(q, k)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	if pushes == 0 {
+		t.Errorf("Expected the custom heap to be used")
+	}
+}
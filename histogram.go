@@ -0,0 +1,203 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+)
+
+// A Histogram describes a sample of pairwise distances.
+type Histogram struct {
+	// Samples is the number of distances sampled.
+	Samples int
+
+	// Edges are the bounds of the buckets: bucket i counts the distances
+	// from Edges[i] up to below Edges[i+1], except for the last bucket,
+	// which also counts the distances equal to its upper edge.
+	Edges  []float64
+	Counts []int
+
+	Min, Max, Mean float64
+
+	// Percentiles holds the p-th percentile of the distances at index p,
+	// from the minimum at 0 to the maximum at 100.
+	Percentiles [101]float64
+}
+
+// DistanceHistogram estimates the distribution of the distances between the
+// items of the VP-tree from samplePairs random pairs, and sorts them into
+// buckets of equal width between the smallest and the largest distance
+// sampled. The pairs are drawn independently, with replacement: each pair
+// is two different items chosen uniformly at random, and the same pair may
+// be drawn more than once. The sample only depends on rng.
+func (vp *VPTree) DistanceHistogram(samplePairs int, buckets int, rng *rand.Rand) Histogram {
+	nodes := vp.uniqueNodes()
+	if len(nodes) < 2 {
+		return newHistogram(nil, buckets)
+	}
+
+	dists := make([]float64, samplePairs)
+	for s := range dists {
+		i, j := distinctPair(rng, len(nodes))
+		dists[s] = vp.distanceMetric(nodes[i].Item, nodes[j].Item)
+	}
+
+	return newHistogram(dists, buckets)
+}
+
+// StratifiedDistanceHistograms is like DistanceHistogram, but describes
+// the local and the global scale of the data separately, using the
+// structure of the tree. It takes the subtrees rooted at the given depth
+// and samples samplePairs pairs of items within the same subtree, which
+// shows how far apart items that the tree keeps together are, and
+// samplePairs pairs of items from different subtrees. The items above the
+// depth are left out. A subtree is picked for a pair within it with a
+// probability proportional to its number of pairs, and both histograms use
+// the same buckets, so that they can be compared directly.
+func (vp *VPTree) StratifiedDistanceHistograms(depth int, samplePairs int, buckets int, rng *rand.Rand) (within, across Histogram) {
+	var subtrees [][]*node
+	var collect func(n *node, d int)
+	collect = func(n *node, d int) {
+		if n == nil {
+			return
+		}
+		if d == depth {
+			var nodes []*node
+			vp.collectNodes(n, &nodes)
+			subtrees = append(subtrees, nodes)
+			return
+		}
+		collect(n.Left, d+1)
+		collect(n.Right, d+1)
+	}
+	collect(vp.root, 0)
+
+	// Cumulative numbers of pairs within, and of items in, the subtrees
+	pairs := make([]float64, len(subtrees))
+	items := make([]float64, len(subtrees))
+	totalPairs, totalItems := 0.0, 0.0
+	for i, s := range subtrees {
+		totalPairs += float64(len(s)) * float64(len(s)-1)
+		totalItems += float64(len(s))
+		pairs[i], items[i] = totalPairs, totalItems
+	}
+
+	var withinDists, acrossDists []float64
+	if totalPairs > 0 {
+		withinDists = make([]float64, samplePairs)
+		for s := range withinDists {
+			sub := subtrees[pickWeighted(pairs, rng)]
+			i, j := distinctPair(rng, len(sub))
+			withinDists[s] = vp.distanceMetric(sub[i].Item, sub[j].Item)
+		}
+	}
+
+	if len(subtrees) > 1 {
+		acrossDists = make([]float64, samplePairs)
+		for s := range acrossDists {
+			a, b := 0, 0
+			for a == b {
+				a, b = pickWeighted(items, rng), pickWeighted(items, rng)
+			}
+			i, j := rng.Intn(len(subtrees[a])), rng.Intn(len(subtrees[b]))
+			acrossDists[s] = vp.distanceMetric(subtrees[a][i].Item, subtrees[b][j].Item)
+		}
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, d := range append(slices.Clip(withinDists), acrossDists...) {
+		lo, hi = math.Min(lo, d), math.Max(hi, d)
+	}
+
+	return newHistogramRange(withinDists, buckets, lo, hi), newHistogramRange(acrossDists, buckets, lo, hi)
+}
+
+// uniqueNodes returns a node for every item of the VP-tree.
+func (vp *VPTree) uniqueNodes() []*node {
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+
+	if vp.spill > 0 {
+		// Spill trees may hold an item in more than one node
+		seen := make(map[int]bool, len(nodes))
+		unique := nodes[:0]
+		for _, n := range nodes {
+			if !seen[n.Index] {
+				seen[n.Index] = true
+				unique = append(unique, n)
+			}
+		}
+		nodes = unique
+	}
+
+	return nodes
+}
+
+// distinctPair returns two different random numbers below n, which must be
+// at least 2.
+func distinctPair(rng *rand.Rand, n int) (i, j int) {
+	i = rng.Intn(n)
+	j = rng.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	return
+}
+
+// pickWeighted returns a random index into cumulative, a slice of
+// cumulative weights, with a probability proportional to its weight.
+func pickWeighted(cumulative []float64, rng *rand.Rand) int {
+	x := rng.Float64() * cumulative[len(cumulative)-1]
+	return sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > x })
+}
+
+// newHistogram returns the histogram of dists with buckets of equal width
+// between their minimum and maximum. It sorts dists.
+func newHistogram(dists []float64, buckets int) Histogram {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, d := range dists {
+		lo, hi = math.Min(lo, d), math.Max(hi, d)
+	}
+	return newHistogramRange(dists, buckets, lo, hi)
+}
+
+// newHistogramRange returns the histogram of dists with buckets of equal
+// width between lo and hi, which must bound all of them. It sorts dists.
+func newHistogramRange(dists []float64, buckets int, lo, hi float64) Histogram {
+	h := Histogram{Samples: len(dists)}
+	if len(dists) == 0 || buckets < 1 {
+		return h
+	}
+
+	slices.Sort(dists)
+	h.Min, h.Max = dists[0], dists[len(dists)-1]
+
+	sum := 0.0
+	for _, d := range dists {
+		sum += d
+	}
+	h.Mean = sum / float64(len(dists))
+
+	for p := range h.Percentiles {
+		h.Percentiles[p] = dists[p*(len(dists)-1)/100]
+	}
+
+	width := (hi - lo) / float64(buckets)
+	h.Edges = make([]float64, buckets+1)
+	for i := range h.Edges {
+		h.Edges[i] = lo + float64(i)*width
+	}
+	h.Edges[buckets] = hi
+
+	h.Counts = make([]int, buckets)
+	for _, d := range dists {
+		b := buckets - 1
+		if width > 0 {
+			b = min(int((d-lo)/width), buckets-1)
+		}
+		h.Counts[b]++
+	}
+
+	return h
+}
@@ -0,0 +1,82 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// This test samples distances between uniform random points, whose
+// distribution is known
+func TestDistanceHistogram(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// On a line, the distance between two uniform random points in [0, 1]
+	// has the density 2(1-d), with mean 1/3 and median 1-1/sqrt(2)
+	var line []interface{}
+	for i := 0; i < 2000; i++ {
+		line = append(line, Coordinate{X: rng.Float64()})
+	}
+	vp := New(CoordinateMetric, line)
+
+	h := vp.DistanceHistogram(20000, 10, rand.New(rand.NewSource(2)))
+
+	if h.Samples != 20000 || len(h.Counts) != 10 || len(h.Edges) != 11 {
+		t.Fatalf("Expected 20000 samples in 10 buckets, got %v in %v with %v edges", h.Samples, len(h.Counts), len(h.Edges))
+	}
+	if math.Abs(h.Mean-1.0/3) > 0.01 {
+		t.Errorf("Expected a mean of about 1/3, got %v", h.Mean)
+	}
+	if median := 1 - 1/math.Sqrt2; math.Abs(h.Percentiles[50]-median) > 0.01 {
+		t.Errorf("Expected a median of about %v, got %v", median, h.Percentiles[50])
+	}
+	if h.Percentiles[0] != h.Min || h.Percentiles[100] != h.Max || h.Edges[0] != h.Min || h.Edges[10] != h.Max {
+		t.Errorf("Expected the extreme percentiles and edges to be the minimum and maximum, got %+v", h)
+	}
+
+	total := 0
+	for i, c := range h.Counts {
+		total += c
+
+		// The share of a bucket [a, b) is (b-a)(2-a-b)
+		a, b := h.Edges[i], h.Edges[i+1]
+		if expected := (b - a) * (2 - a - b) * 20000; math.Abs(float64(c)-expected) > 0.1*expected+50 {
+			t.Errorf("Expected about %v distances in bucket %v, got %v", expected, i, c)
+		}
+	}
+	if total != h.Samples {
+		t.Errorf("Expected the counts to add up to %v, got %v", h.Samples, total)
+	}
+
+	// The mean distance between uniform random points in the unit square
+	// is about 0.5214
+	_, square := randomCoordinates(2000)
+	if h := New(CoordinateMetric, square).DistanceHistogram(20000, 10, rng); math.Abs(h.Mean-0.5214) > 0.01 {
+		t.Errorf("Expected a mean of about 0.5214, got %v", h.Mean)
+	}
+
+	again := vp.DistanceHistogram(20000, 10, rand.New(rand.NewSource(2)))
+	if !reflect.DeepEqual(h, again) {
+		t.Errorf("Expected the same histogram for the same seed")
+	}
+}
+
+// This test checks that items within a subtree are closer than items from
+// different subtrees
+func TestStratifiedDistanceHistograms(t *testing.T) {
+	_, items := randomCoordinates(2000)
+	vp := New(CoordinateMetric, items)
+
+	within, across := vp.StratifiedDistanceHistograms(3, 5000, 20, rand.New(rand.NewSource(1)))
+
+	if within.Samples != 5000 || across.Samples != 5000 {
+		t.Fatalf("Expected 5000 samples each, got %v and %v", within.Samples, across.Samples)
+	}
+	if !reflect.DeepEqual(within.Edges, across.Edges) {
+		t.Errorf("Expected both histograms to share their edges")
+	}
+	if within.Mean >= across.Mean {
+		t.Errorf("Expected items within a subtree to be closer than across subtrees, got means %v and %v", within.Mean, across.Mean)
+	}
+}
@@ -0,0 +1,44 @@
+package vptree
+
+// InfluenceZone returns the items whose nearest neighbour is query, that is,
+// the items that would get a new nearest neighbour if query were removed from
+// the tree. Items at distance 0 from query are considered copies of query
+// and are not returned. Ties count as having query as nearest neighbour.
+func (vp *VPTree) InfluenceZone(query interface{}) (results []interface{}) {
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+
+	for _, n := range nodes {
+		dq := vp.distanceMetric(n.Item, query)
+		if dq == 0 {
+			continue
+		}
+
+		// Find the nearest neighbour of the item, other than itself
+		indices, distances := vp.SearchIndices(n.Item, 2)
+		for i, idx := range indices {
+			if idx != n.Index {
+				if distances[i] >= dq {
+					results = append(results, n.Item)
+				}
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// collectNodes appends the nodes of the subtree rooted at n to nodes, leaving
+// out those of the items Upsert replaced.
+func (vp *VPTree) collectNodes(n *node, nodes *[]*node) {
+	if n == nil {
+		return
+	}
+
+	if vp.live(n) {
+		*nodes = append(*nodes, n)
+	}
+	vp.collectNodes(n.Left, nodes)
+	vp.collectNodes(n.Right, nodes)
+}
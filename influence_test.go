@@ -0,0 +1,59 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test compares InfluenceZone against a brute-force nearest neighbour
+// computation
+func TestInfluenceZone(t *testing.T) {
+	items, vpitems := randomCoordinates(300)
+	vp := New(CoordinateMetric, vpitems)
+
+	for q := 0; q < 10; q++ {
+		query := items[rand.Intn(len(items))]
+
+		expected := make(map[Coordinate]bool)
+		for _, x := range items {
+			if x == query {
+				continue
+			}
+
+			nearest, _ := nearestNeighbours(x, items, 2)
+			if nearest[1] == query {
+				expected[x] = true
+			}
+		}
+
+		zone := vp.InfluenceZone(query)
+		if len(zone) != len(expected) {
+			t.Errorf("Expected %v items in the influence zone of %v, got %v", len(expected), query, len(zone))
+		}
+
+		for _, z := range zone {
+			if !expected[z.(Coordinate)] {
+				t.Errorf("%v does not have %v as its nearest neighbour", z, query)
+			}
+		}
+	}
+}
+
+// This test makes sure a point surrounded by others influences them
+func TestInfluenceZoneSmall(t *testing.T) {
+	center := Coordinate{0, 0}
+	items := []interface{}{
+		center,
+		Coordinate{1, 0},
+		Coordinate{0, 1},
+		Coordinate{-1, 0},
+		Coordinate{10, 10},
+		Coordinate{10, 11},
+	}
+
+	vp := New(CoordinateMetric, items)
+
+	if zone := vp.InfluenceZone(center); len(zone) != 3 {
+		t.Errorf("Expected 3 items in the influence zone, got %v", zone)
+	}
+}
@@ -0,0 +1,27 @@
+// Package sphere holds the great-circle formula shared by GeoTree and the
+// Haversine metrics.
+package sphere
+
+import "math"
+
+// CentralAngle returns the angle in radians between two points, given by
+// their latitudes and longitudes in degrees, as seen from the center of the
+// sphere. It uses the atan2 form of the great-circle formula rather than the
+// haversine formula. The haversine formula loses precision for nearly
+// antipodal points, and the arccosine of the spherical law of cosines loses
+// it for nearby points. The atan2 form is accurate for both.
+func CentralAngle(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1 *= math.Pi / 180
+	lat2 *= math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	sinLat1, cosLat1 := math.Sincos(lat1)
+	sinLat2, cosLat2 := math.Sincos(lat2)
+	sinDLon, cosDLon := math.Sincos(dLon)
+
+	x := cosLat2 * sinDLon
+	y := cosLat1*sinLat2 - sinLat1*cosLat2*cosDLon
+	z := sinLat1*sinLat2 + cosLat1*cosLat2*cosDLon
+
+	return math.Atan2(math.Hypot(x, y), z)
+}
@@ -0,0 +1,49 @@
+package vptree
+
+// CrossSearch searches target for the k nearest neighbours of every item of
+// source, such as the closest target documents to every source document,
+// and returns them aligned with the items slice source was built from. The
+// searches run on GOMAXPROCS goroutines. Items that are not in source, such
+// as those Upsert replaced, get no neighbours.
+func CrossSearch(source, target *VPTree, k int) [][]Neighbour {
+	neighbours := make([][]Neighbour, source.itemCount())
+	if k < 1 {
+		return neighbours
+	}
+
+	source.forEachItem(0, func(n *node) {
+		nearest := target.nearest(n.Item, k)
+
+		found := make([]Neighbour, len(nearest))
+		for i, hi := range nearest {
+			found[i] = Neighbour{hi.Item, hi.Index, hi.Dist}
+		}
+		neighbours[n.Index] = found
+	})
+
+	return neighbours
+}
+
+// RadiusJoin returns all pairs of an item x of a and an item y of b whose
+// distance is at most eps, as measured by b's metric, such as the matching
+// records of two data sets. Every pair is returned once, ordered by the
+// index of x and then by distance. The range searches of b run on
+// GOMAXPROCS goroutines, one for every item of a.
+func RadiusJoin(a, b *VPTree, eps float64) [][2]interface{} {
+	matches := make([][][2]interface{}, a.itemCount())
+	a.forEachItem(0, func(n *node) {
+		b.withinRadius(n.Item, eps, func(found []heapItem) {
+			pairs := make([][2]interface{}, len(found))
+			for i, hi := range found {
+				pairs[i] = [2]interface{}{n.Item, hi.Item}
+			}
+			matches[n.Index] = pairs
+		})
+	})
+
+	var pairs [][2]interface{}
+	for _, m := range matches {
+		pairs = append(pairs, m...)
+	}
+	return pairs
+}
@@ -0,0 +1,94 @@
+package vptree
+
+import (
+	"testing"
+)
+
+// This test compares CrossSearch with brute-force searches of the target
+// items for every source item
+func TestCrossSearch(t *testing.T) {
+	sourceItems, sourceVPItems := randomCoordinates(200)
+	targetItems, targetVPItems := randomCoordinates(1000)
+
+	source := New(CoordinateMetric, sourceVPItems)
+	target := New(CoordinateMetric, targetVPItems)
+
+	neighbours := CrossSearch(source, target, 5)
+	if len(neighbours) != len(sourceItems) {
+		t.Fatalf("Expected %v result lists, got %v", len(sourceItems), len(neighbours))
+	}
+
+	for i, found := range neighbours {
+		var coords []interface{}
+		var distances []float64
+		for _, nb := range found {
+			if targetVPItems[nb.Index] != nb.Item {
+				t.Errorf("Expected item %v at index %v, got %v", targetVPItems[nb.Index], nb.Index, nb.Item)
+			}
+			coords = append(coords, nb.Item)
+			distances = append(distances, nb.Distance)
+		}
+
+		expected, expectedDistances := nearestNeighbours(sourceItems[i], targetItems, 5)
+		compareCoordDistSets(t, coords, expected, distances, expectedDistances)
+	}
+
+	source.Upsert(sourceVPItems[0], Coordinate{X: 0.5, Y: 0.5})
+	neighbours = CrossSearch(source, target, 5)
+	if len(neighbours) != len(sourceItems)+1 || neighbours[0] != nil || len(neighbours[len(sourceItems)]) != 5 {
+		t.Errorf("Expected no neighbours for the replaced item and 5 for the inserted one")
+	}
+
+	if neighbours := CrossSearch(New(CoordinateMetric, nil), target, 5); len(neighbours) != 0 {
+		t.Errorf("Expected no results for an empty source, got %v", neighbours)
+	}
+}
+
+// This test compares RadiusJoin with a brute-force join, including a spill
+// tree, which holds some items more than once
+func TestRadiusJoin(t *testing.T) {
+	aItems, aVPItems := randomCoordinates(300)
+	bItems, bVPItems := randomCoordinates(300)
+
+	type pair struct{ x, y Coordinate }
+
+	expected := make(map[pair]bool)
+	for _, x := range aItems {
+		for _, y := range bItems {
+			if CoordinateMetric(x, y) <= 0.05 {
+				expected[pair{x, y}] = true
+			}
+		}
+	}
+
+	for name, b := range map[string]*VPTree{
+		"Tree":  New(CoordinateMetric, bVPItems),
+		"Spill": NewSpillTree(CoordinateMetric, bVPItems, 0.5),
+	} {
+		pairs := RadiusJoin(NewSpillTree(CoordinateMetric, aVPItems, 0.5), b, 0.05)
+
+		found := make(map[pair]bool)
+		for _, p := range pairs {
+			key := pair{p[0].(Coordinate), p[1].(Coordinate)}
+			if found[key] {
+				t.Errorf("%v: pair %v returned twice", name, key)
+			}
+			found[key] = true
+		}
+
+		missing := 0
+		for p := range expected {
+			if !found[p] {
+				missing++
+			}
+		}
+
+		if len(found) != len(expected) || missing > 0 {
+			t.Errorf("%v: expected %v pairs, got %v, of which %v are missing", name, len(expected), len(found), missing)
+		}
+	}
+
+	if pairs := RadiusJoin(New(CoordinateMetric, aVPItems), New(CoordinateMetric, bVPItems), -1); len(pairs) != 0 {
+		t.Errorf("Expected no pairs for a negative eps, got %v", len(pairs))
+	}
+}
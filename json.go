@@ -0,0 +1,207 @@
+package vptree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// An ItemEncoder encodes an item of a VP-tree as JSON.
+type ItemEncoder func(item interface{}) (json.RawMessage, error)
+
+// An ItemDecoder decodes an item encoded by an ItemEncoder. Since the items
+// are interface values, encoding/json can't restore their types on its own.
+type ItemDecoder func(data json.RawMessage) (interface{}, error)
+
+// WithItemEncoder sets the ItemEncoder MarshalJSON encodes the items with.
+// By default, they are encoded with json.Marshal.
+func WithItemEncoder(encode ItemEncoder) Option {
+	return func(vp *VPTree) {
+		vp.itemEncoder = encode
+	}
+}
+
+// MarshalJSON encodes the VP-tree as JSON, using the ItemEncoder set with
+// WithItemEncoder. See EncodeJSON for the format.
+func (vp *VPTree) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := vp.EncodeJSON(&buf, vp.itemEncoder); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJSON writes the VP-tree to w as JSON, encoding the items with
+// encode, or with json.Marshal if encode is nil. The tree is streamed to w
+// node by node, so even large trees don't need to be held in memory twice.
+// The JSON is an object of the form
+//
+//	{"spill":0,"deleted":[2,7],"root":NODE}
+//
+// where spill is the overlap factor of a spill tree, deleted holds the
+// indices of the items Upsert replaced in increasing order, and is left out
+// if there are none, and every NODE is null or an object of the form
+//
+//	{"index":3,"size":5,"threshold":0.25,"item":ITEM,"left":NODE,"right":NODE}
+//
+// holding the index of the item in the items the tree was built from, the
+// size of the subtree, its threshold, which is null if it is infinite, the
+// encoded item and the children. UnmarshalJSONWith reads the tree back.
+func (vp *VPTree) EncodeJSON(w io.Writer, encode ItemEncoder) error {
+	if encode == nil {
+		encode = func(item interface{}) (json.RawMessage, error) {
+			return json.Marshal(item)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := []byte(`{"spill":`)
+	buf = strconv.AppendFloat(buf, vp.spill, 'g', -1, 64)
+	if len(vp.deleted) > 0 {
+		buf = append(buf, `,"deleted":[`...)
+		for i, index := range vp.deletedIndices() {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = strconv.AppendInt(buf, int64(index), 10)
+		}
+		buf = append(buf, ']')
+	}
+	buf = append(buf, `,"root":`...)
+	bw.Write(buf)
+
+	if err := encodeJSONNode(bw, vp.root, encode); err != nil {
+		return err
+	}
+
+	bw.WriteString("}")
+	return bw.Flush()
+}
+
+// encodeJSONNode writes the subtree rooted at n to bw.
+func encodeJSONNode(bw *bufio.Writer, n *node, encode ItemEncoder) error {
+	if n == nil {
+		_, err := bw.WriteString("null")
+		return err
+	}
+
+	item, err := encode(n.Item)
+	if err != nil {
+		return fmt.Errorf("vptree: encoding item %v: %w", n.Index, err)
+	}
+	if !json.Valid(item) {
+		return fmt.Errorf("vptree: encoding item %v: invalid JSON %q", n.Index, item)
+	}
+
+	buf := []byte(`{"index":`)
+	buf = strconv.AppendInt(buf, int64(n.Index), 10)
+	buf = append(buf, `,"size":`...)
+	buf = strconv.AppendInt(buf, int64(n.Size), 10)
+	buf = append(buf, `,"threshold":`...)
+	if math.IsInf(n.Threshold, 1) {
+		buf = append(buf, "null"...)
+	} else {
+		buf = strconv.AppendFloat(buf, n.Threshold, 'g', -1, 64)
+	}
+	buf = append(buf, `,"item":`...)
+	buf = append(buf, item...)
+	buf = append(buf, `,"left":`...)
+	bw.Write(buf)
+
+	if err := encodeJSONNode(bw, n.Left, encode); err != nil {
+		return err
+	}
+
+	bw.WriteString(`,"right":`)
+
+	if err := encodeJSONNode(bw, n.Right, encode); err != nil {
+		return err
+	}
+
+	_, err = bw.WriteString("}")
+	return err
+}
+
+type jsonTree struct {
+	Spill   float64   `json:"spill"`
+	Deleted []int     `json:"deleted"`
+	Root    *jsonNode `json:"root"`
+}
+
+type jsonNode struct {
+	Index     int             `json:"index"`
+	Size      int             `json:"size"`
+	Threshold *float64        `json:"threshold"`
+	Item      json.RawMessage `json:"item"`
+	Left      *jsonNode       `json:"left"`
+	Right     *jsonNode       `json:"right"`
+}
+
+// UnmarshalJSONWith decodes a VP-tree encoded by MarshalJSON or EncodeJSON
+// from data, decoding the items with decode. The metric has to be the one
+// the tree was built with.
+func UnmarshalJSONWith(data []byte, metric Metric, decode ItemDecoder) (*VPTree, error) {
+	var jt jsonTree
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return nil, fmt.Errorf("vptree: decoding tree: %w", err)
+	}
+
+	var count func(jn *jsonNode) int
+	count = func(jn *jsonNode) int {
+		if jn == nil {
+			return 0
+		}
+		return 1 + count(jn.Left) + count(jn.Right)
+	}
+
+	t := &VPTree{
+		distanceMetric: metric,
+		spill:          jt.Spill,
+		arena:          make([]node, 0, count(jt.Root)),
+	}
+
+	var build func(jn *jsonNode) (*node, error)
+	build = func(jn *jsonNode) (*node, error) {
+		if jn == nil {
+			return nil, nil
+		}
+
+		item, err := decode(jn.Item)
+		if err != nil {
+			return nil, fmt.Errorf("vptree: decoding item %v: %w", jn.Index, err)
+		}
+
+		n := t.newNode()
+		n.Item, n.Index, n.Size, n.Threshold = item, jn.Index, jn.Size, math.Inf(1)
+		if jn.Threshold != nil {
+			n.Threshold = *jn.Threshold
+		}
+
+		if n.Left, err = build(jn.Left); err != nil {
+			return nil, err
+		}
+		if n.Right, err = build(jn.Right); err != nil {
+			return nil, err
+		}
+
+		return n, nil
+	}
+
+	var err error
+	if t.root, err = build(jt.Root); err != nil {
+		return nil, err
+	}
+
+	for _, index := range jt.Deleted {
+		if t.deleted == nil {
+			t.deleted = make(map[int]bool)
+		}
+		t.deleted[index] = true
+	}
+
+	return t, nil
+}
@@ -0,0 +1,161 @@
+package vptree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func decodeCoordinate(data json.RawMessage) (interface{}, error) {
+	var c Coordinate
+	err := json.Unmarshal(data, &c)
+	return c, err
+}
+
+// This test round-trips trees of Coordinates through JSON
+func TestJSONRoundTrip(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+
+	for name, vp := range map[string]*VPTree{
+		"Tree":  New(CoordinateMetric, vpitems),
+		"Leaf":  New(CoordinateMetric, vpitems, WithLeafSize(8)),
+		"Empty": New(CoordinateMetric, nil),
+	} {
+		data, err := json.Marshal(vp)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		loaded, err := UnmarshalJSONWith(data, CoordinateMetric, decodeCoordinate)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(walkInfos(loaded), walkInfos(vp)) {
+			t.Errorf("%v: the decoded tree differs from the original", name)
+		}
+
+		if name == "Empty" {
+			continue
+		}
+
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		coords1, distances1 := loaded.Search(q, 10)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+	}
+}
+
+// This test makes sure the items Upsert replaced stay deleted after a round
+// trip through JSON
+func TestJSONUpsert(t *testing.T) {
+	_, vpitems := randomCoordinates(50)
+	vp := New(CoordinateMetric, vpitems)
+
+	old := vpitems[0]
+	vp.Upsert(old, Coordinate{X: 2, Y: 2})
+
+	data, err := json.Marshal(vp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := UnmarshalJSONWith(data, CoordinateMetric, decodeCoordinate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != 50 {
+		t.Errorf("Expected 50 items, got %v", loaded.Len())
+	}
+	if results, _ := loaded.SearchRadius(old, 0); len(results) != 0 {
+		t.Errorf("Expected the replaced item to stay deleted, got %v", results)
+	}
+	if results, _ := loaded.SearchRadius(Coordinate{X: 2, Y: 2}, 0); len(results) != 1 {
+		t.Errorf("Expected to find the new item, got %v", results)
+	}
+}
+
+// This test pins the JSON schema with a tiny tree built with a seeded
+// vantage point selector. Run the tests with -update to rewrite the golden
+// file.
+func TestJSONGolden(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sel := func(items []interface{}, depth int) int {
+		return rng.Intn(len(items))
+	}
+
+	items := []interface{}{
+		Coordinate{0, 0},
+		Coordinate{1, 0},
+		Coordinate{0, 2},
+		Coordinate{3, 3},
+		Coordinate{5, 1},
+	}
+
+	encode := func(item interface{}) (json.RawMessage, error) {
+		c := item.(Coordinate)
+		return json.Marshal([]float64{c.X, c.Y})
+	}
+
+	vp := New(CoordinateMetric, items, WithVantagePointSelector(sel), WithItemEncoder(encode))
+
+	var buf bytes.Buffer
+	if err := vp.EncodeJSON(&buf, encode); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := vp.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("Expected MarshalJSON to match EncodeJSON, got %s and %s", data, buf.Bytes())
+	}
+
+	const golden = "testdata/tree.json"
+	if *updateGolden {
+		if err := os.WriteFile(golden, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(data, '\n'), expected) {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+// This test makes sure encoding errors and invalid JSON are reported
+func TestJSONErrors(t *testing.T) {
+	_, vpitems := randomCoordinates(10)
+	vp := New(CoordinateMetric, vpitems)
+
+	errBroken := errors.New("broken")
+	if err := vp.EncodeJSON(&bytes.Buffer{}, func(interface{}) (json.RawMessage, error) { return nil, errBroken }); !errors.Is(err, errBroken) {
+		t.Errorf("Expected the encoder's error, got %v", err)
+	}
+	if err := vp.EncodeJSON(&bytes.Buffer{}, func(interface{}) (json.RawMessage, error) { return json.RawMessage("{"), nil }); err == nil {
+		t.Errorf("Expected an error for invalid JSON")
+	}
+
+	data, _ := json.Marshal(vp)
+	if _, err := UnmarshalJSONWith(data, CoordinateMetric, func(json.RawMessage) (interface{}, error) { return nil, errBroken }); !errors.Is(err, errBroken) {
+		t.Errorf("Expected the decoder's error, got %v", err)
+	}
+	if _, err := UnmarshalJSONWith(data[:len(data)/2], CoordinateMetric, decodeCoordinate); err == nil {
+		t.Errorf("Expected an error for truncated JSON")
+	}
+}
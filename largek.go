@@ -0,0 +1,144 @@
+package vptree
+
+import (
+	"math/rand"
+)
+
+// defaultLargeKFraction is the fraction of the items from which on Search
+// switches to collecting candidates in a buffer. See WithLargeKFraction.
+const defaultLargeKFraction = 0.1
+
+// WithLargeKFraction sets the fraction of the tree's items from which on
+// Search collects candidates in a buffer, like SearchLargeK does, rather than
+// in a heap. A fraction of 0 restores the default of 10%, and a fraction
+// greater than 1 disables the switch.
+func WithLargeKFraction(c float64) Option {
+	return func(vp *VPTree) {
+		vp.largeKFraction = c
+	}
+}
+
+// SearchLargeK is like Search, but is faster when k is a sizable fraction of
+// the number of items. Instead of maintaining a heap of the k nearest
+// neighbours found so far, it appends candidates to a buffer, and whenever
+// the buffer holds 2k candidates, it selects the k nearest of them to find
+// the new search radius. This saves the heap operations, but the search
+// radius shrinks in steps rather than with every candidate, so more of the
+// tree is visited; with cheap metrics, this pays off from k of about 10% of
+// the items on. Search switches to this strategy on its own for large k; see
+// WithLargeKFraction.
+func (vp *VPTree) SearchLargeK(target interface{}, k int) (results []interface{}, distances []float64) {
+	return itemsAndDistances(vp.nearestBuffered(target, k))
+}
+
+// isLargeK returns whether Search should use the buffered strategy for k.
+func (vp *VPTree) isLargeK(k int) bool {
+	if vp.root == nil || vp.spill > 0 {
+		return false
+	}
+
+	c := vp.largeKFraction
+	if c == 0 {
+		c = defaultLargeKFraction
+	}
+
+	return float64(k) >= c*float64(vp.root.Size)
+}
+
+// nearestBuffered is like nearest, but uses the buffered strategy of
+// SearchLargeK. Spill trees always use the heap, which removes items they
+// hold more than once.
+func (vp *VPTree) nearestBuffered(target interface{}, k int) []heapItem {
+	if k < 1 {
+		return nil
+	}
+
+	if vp.spill > 0 {
+		return vp.nearest(target, k)
+	}
+
+	q := vp.getBufferedQuery(target, k)
+	defer putKNNQuery(q)
+	vp.runKNN(q)
+
+	return q.results()
+}
+
+// getBufferedQuery returns a query for the k nearest neighbours of target
+// that collects the candidates in a buffer.
+func (vp *VPTree) getBufferedQuery(target interface{}, k int) *knnQuery {
+	n := vp.root.size()
+	q := getKNNQuery(target, k, n)
+
+	// The buffer never needs to hold more than the items of the tree,
+	// however large k is
+	q.buffer = make([]heapItem, 0, min(2*min(k, n), n))
+	return q
+}
+
+// addBuffered adds a candidate to the query's buffer, shrinking the buffer
+// to the k nearest candidates when it is full.
+func (q *knnQuery) addBuffered(hi heapItem) {
+	q.buffer = append(q.buffer, hi)
+	if len(q.buffer) == cap(q.buffer) {
+		q.shrinkBuffer()
+	}
+}
+
+// shrinkBuffer drops all but the k nearest candidates from the buffer and
+// lowers tau to the distance of the k-th of them.
+func (q *knnQuery) shrinkBuffer() {
+	if len(q.buffer) < q.k {
+		return
+	}
+
+	selectNearest(q.buffer, q.k)
+	clear(q.buffer[q.k:])
+	q.buffer = q.buffer[:q.k]
+	q.tau = q.buffer[q.k-1].Dist
+}
+
+// selectNearest reorders items so that the k nearest of them come first,
+// with the k-th nearest at index k-1, using quickselect.
+func selectNearest(items []heapItem, k int) {
+	lo, hi := 0, len(items)-1
+	for lo < hi {
+		// Partition around a random pivot, moving it to its final
+		// position p
+		pivot := lo + rand.Intn(hi-lo+1)
+		items[pivot], items[hi] = items[hi], items[pivot]
+
+		p := lo
+		for i := lo; i < hi; i++ {
+			if items[i].Dist < items[hi].Dist {
+				items[i], items[p] = items[p], items[i]
+				p++
+			}
+		}
+		items[p], items[hi] = items[hi], items[p]
+
+		switch {
+		case p == k-1:
+			return
+		case p < k-1:
+			lo = p + 1
+		default:
+			hi = p - 1
+		}
+	}
+}
+
+// itemsAndDistances splits heap items into their items and distances.
+func itemsAndDistances(found []heapItem) (results []interface{}, distances []float64) {
+	if len(found) == 0 {
+		return
+	}
+
+	results = make([]interface{}, len(found))
+	distances = make([]float64, len(found))
+	for i, hi := range found {
+		results[i], distances[i] = hi.Item, hi.Dist
+	}
+
+	return
+}
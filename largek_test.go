@@ -0,0 +1,100 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure SearchLargeK and Search with large k return the same
+// results as the heap-based search
+func TestSearchLargeK(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	heapOnly := New(CoordinateMetric, items, WithLargeKFraction(2))
+	vp := New(CoordinateMetric, items)
+
+	for _, k := range []int{1, 2, 10, 100, 500, 999, 1000, 2000} {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedResults, expectedDistances := heapOnly.Search(q, k)
+
+		results, distances := vp.SearchLargeK(q, k)
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+		results, distances = vp.Search(q, k)
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+	}
+}
+
+// This test makes sure a k far larger than the tree doesn't size the buffer
+// after k
+func TestSearchLargeKHugeK(t *testing.T) {
+	items, vpitems := randomCoordinates(10)
+	vp := New(CoordinateMetric, vpitems)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	expectedCoords, expectedDists := nearestNeighbours(q, items, 10)
+
+	for _, k := range []int{math.MaxInt32, math.MaxInt} {
+		coords, distances := vp.SearchLargeK(q, k)
+		compareCoordDistSets(t, coords, expectedCoords, distances, expectedDists)
+
+		coords, distances = vp.Search(q, k)
+		compareCoordDistSets(t, coords, expectedCoords, distances, expectedDists)
+	}
+}
+
+// This test checks selectNearest against the sorted distances
+func TestSelectNearest(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		items := make([]heapItem, rand.Intn(100)+1)
+		for j := range items {
+			items[j].Dist = float64(rand.Intn(20))
+		}
+		k := rand.Intn(len(items)) + 1
+
+		selectNearest(items, k)
+
+		for j := range items {
+			if j < k && items[j].Dist > items[k-1].Dist || j >= k && items[j].Dist < items[k-1].Dist {
+				t.Fatalf("Expected items[%v] to be on the other side of the %v-th nearest, got %v", j, k, items)
+			}
+		}
+	}
+}
+
+func BenchmarkSearchLargeK(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	heapOnly := New(CoordinateMetric, items, WithLargeKFraction(2))
+	vp := New(CoordinateMetric, items)
+
+	for _, percent := range []int{1, 3, 10, 50} {
+		k := len(items) * percent / 100
+
+		b.Run(fmt.Sprintf("Heap/%v%%", percent), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				heapOnly.Search(Coordinate{X: rng.Float64(), Y: rng.Float64()}, k)
+			}
+		})
+
+		b.Run(fmt.Sprintf("Buffered/%v%%", percent), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				vp.SearchLargeK(Coordinate{X: rng.Float64(), Y: rng.Float64()}, k)
+			}
+		})
+
+		// Search should be as fast as the faster of the two
+		b.Run(fmt.Sprintf("Search/%v%%", percent), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				vp.Search(Coordinate{X: rng.Float64(), Y: rng.Float64()}, k)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package vptree
+
+import "math"
+
+// WithLeafSize stops the VP-tree from partitioning subtrees of at most n
+// items. Their items are instead chained one after another, and a search
+// that reaches such a leaf computes the distance to every item in it.
+//
+// Partitioning costs metric calls at build time, and only pays off if
+// searches can prune inside the partition. For items that come in tight
+// clusters, where the items of a small subtree are all about equally far
+// from any target, a larger leaf size builds the tree with fewer metric
+// calls without making searches more expensive. See AutoTune.
+func WithLeafSize(n int) Option {
+	return func(vp *VPTree) {
+		vp.leafSize = n
+	}
+}
+
+// buildLeaf chains items into a leaf. Each node's threshold is infinite, so
+// searches always descend to the next item.
+func (vp *VPTree) buildLeaf(items []interface{}, indices []int) *node {
+	var head, prev *node
+	for i := range items {
+		n := vp.newNode()
+		n.Item, n.Index = items[i], indices[i]
+		n.Size = len(items) - i
+		n.Threshold = math.Inf(1)
+
+		if prev == nil {
+			head = n
+		} else {
+			prev.Left = n
+		}
+		prev = n
+	}
+
+	// The last node has no children, so its threshold is never used
+	if prev != nil {
+		prev.Threshold = 0
+	}
+	return head
+}
@@ -0,0 +1,31 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test checks searches on trees with leaves of several sizes against
+// brute force
+func TestWithLeafSize(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+
+	for _, leafSize := range []int{1, 5, 64, 2000} {
+		vp := New(CoordinateMetric, items, WithLeafSize(leafSize))
+
+		if vp.root.Size != len(items) {
+			t.Errorf("Expected the root to hold %v items, got %v", len(items), vp.root.Size)
+		}
+
+		for i := 0; i < 20; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			expectedCoords, expectedDists := nearestNeighbours(q, coords, 10)
+			results, distances := vp.Search(q, 10)
+			compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+
+			results, distances = vp.SearchRadius(q, expectedDists[9])
+			compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+		}
+	}
+}
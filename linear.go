@@ -0,0 +1,95 @@
+package vptree
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// A LinearIndex answers the same queries as a VPTree by computing the
+// distance from the target to every item. It needs no build and no extra
+// memory, which makes it the baseline a VP-tree has to beat: for small data
+// sets, or for metrics under which the tree cannot prune, a linear scan is
+// often faster. See BenchmarkCrossover.
+type LinearIndex struct {
+	items          []interface{}
+	distanceMetric Metric
+}
+
+// NewLinearIndex creates a LinearIndex over items using metric.
+func NewLinearIndex(metric Metric, items []interface{}) *LinearIndex {
+	return &LinearIndex{
+		items:          items,
+		distanceMetric: metric,
+	}
+}
+
+// Len returns the number of items in the LinearIndex.
+func (li *LinearIndex) Len() int {
+	return len(li.items)
+}
+
+// Search searches the LinearIndex for the k nearest neighbours of target. It
+// returns the up to k closest items, ordered by increasing distance.
+func (li *LinearIndex) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	for _, nb := range li.nearest(target, k) {
+		results = append(results, nb.Item)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (li *LinearIndex) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	for _, nb := range li.nearest(target, k) {
+		indices = append(indices, nb.Index)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// SearchRadius returns all items within radius of target, ordered by
+// increasing distance.
+func (li *LinearIndex) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	var found []Neighbour
+	for i, item := range li.items {
+		if dist := li.distanceMetric(item, target); dist <= radius {
+			found = append(found, Neighbour{item, i, dist})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Distance < found[j].Distance
+	})
+
+	for _, nb := range found {
+		results = append(results, nb.Item)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// nearest returns the k nearest neighbours of target, closest first.
+func (li *LinearIndex) nearest(target interface{}, k int) []Neighbour {
+	if k < 1 || len(li.items) == 0 {
+		return nil
+	}
+
+	h := make(MaxDistanceHeap, 0, min(k, len(li.items)))
+	for i, item := range li.items {
+		dist := li.distanceMetric(item, target)
+		switch {
+		case len(h) < k:
+			heap.Push(&h, Neighbour{item, i, dist})
+		case dist < h.Top().Distance:
+			h[0] = Neighbour{item, i, dist}
+			heap.Fix(&h, 0)
+		}
+	}
+
+	found := make([]Neighbour, len(h))
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(&h).(Neighbour)
+	}
+	return found
+}
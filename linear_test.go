@@ -0,0 +1,30 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test checks the LinearIndex against the brute-force search used by
+// the VP-tree tests
+func TestLinearIndex(t *testing.T) {
+	coords, items := randomCoordinates(500)
+	li := NewLinearIndex(CoordinateMetric, items)
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(20) + 1
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, k)
+		results, distances := li.Search(q, k)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+
+		radius := expectedDists[len(expectedDists)-1]
+		results, distances = li.SearchRadius(q, radius)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	if results, _ := li.Search(Coordinate{}, 0); len(results) != 0 {
+		t.Errorf("Expected no results for k = 0, got %v", len(results))
+	}
+}
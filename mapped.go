@@ -0,0 +1,462 @@
+package vptree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// mappedMagic starts every VP-tree in the mapped file format.
+const mappedMagic = "VPTM"
+
+// mappedVersion is the version of the mapped file format written by
+// SaveFile.
+const mappedVersion = 1
+
+// The mapped file format starts with a header of mappedHeaderSize bytes,
+// followed by one record of mappedNodeSize bytes per node and then the item
+// blob. All fields are little-endian, and both sizes are multiples of 8, so
+// that every record and field is aligned in the mapped memory.
+const (
+	mappedHeaderSize = 32
+	mappedNodeSize   = 40
+)
+
+// mappedDeleted marks the record of a node whose item Upsert replaced.
+const mappedDeleted = 1
+
+// SaveFile writes the VP-tree to the file at path in a format that OpenFile
+// can serve queries from without decoding it, using the ItemCodec set with
+// WithItemCodec for the items.
+//
+// The file starts with a header holding a magic number, the format version,
+// the number of nodes and items, and the overlap factor of spill trees. The nodes follow in preorder as fixed-width records of their
+// threshold, the index of their item, the record numbers of their children,
+// and the offset and length of their item in the item blob, which makes up
+// the rest of the file. All fields are little-endian and aligned. Every item
+// is encoded twice, once to find its length and once to write it, so that
+// the memory SaveFile uses doesn't depend on the size of the tree.
+func (vp *VPTree) SaveFile(path string) (err error) {
+	if vp.itemCodec == nil {
+		return ErrNoItemCodec
+	}
+
+	count := vp.root.size()
+	if count > math.MaxUint32 {
+		return fmt.Errorf("vptree: %v nodes are too many for the mapped file format", count)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	bw := bufio.NewWriterSize(f, binaryBufferSize)
+
+	buf := append(make([]byte, 0, mappedHeaderSize), mappedMagic...)
+	buf = append(buf, mappedVersion, 0, 0, 0)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(count))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(vp.Len()))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(vp.spill))
+	if _, err := bw.Write(buf); err != nil {
+		return fmt.Errorf("vptree: writing header: %w", err)
+	}
+
+	e := mappedEncoder{vp: vp, bw: bw}
+	if err := e.records(vp.root, 0); err != nil {
+		return err
+	}
+	if err := e.items(vp.root); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("vptree: writing items: %w", err)
+	}
+
+	return nil
+}
+
+// A mappedEncoder writes nodes in the mapped file format.
+type mappedEncoder struct {
+	vp *VPTree
+	bw *bufio.Writer
+
+	// offset is the position of the next item in the item blob
+	offset uint64
+
+	// buf and item are reused for every node
+	buf, item []byte
+}
+
+// encodeItem encodes the item of n into e.item.
+func (e *mappedEncoder) encodeItem(n *node) error {
+	var err error
+	if e.item, err = e.vp.itemCodec.AppendItem(e.item[:0], n.Item); err != nil {
+		return fmt.Errorf("vptree: encoding item %v: %w", n.Index, err)
+	}
+	if len(e.item) > math.MaxUint32 {
+		return fmt.Errorf("vptree: item %v is too large for the mapped file format", n.Index)
+	}
+	return nil
+}
+
+// records writes the records of the subtree rooted at n, whose record
+// number is i, in preorder.
+func (e *mappedEncoder) records(n *node, i int) error {
+	if n == nil {
+		return nil
+	}
+
+	if err := e.encodeItem(n); err != nil {
+		return err
+	}
+
+	// Children come after their parent, so 0 can stand for no child
+	left, right := 0, 0
+	if n.Left != nil {
+		left = i + 1
+	}
+	if n.Right != nil {
+		right = i + 1 + n.Left.size()
+	}
+
+	flags := uint32(0)
+	if !e.vp.live(n) {
+		flags |= mappedDeleted
+	}
+
+	buf := binary.LittleEndian.AppendUint64(e.buf[:0], math.Float64bits(n.Threshold))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(n.Index))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(left))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(right))
+	buf = binary.LittleEndian.AppendUint64(buf, e.offset)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(e.item)))
+	buf = binary.LittleEndian.AppendUint32(buf, flags)
+	e.buf = buf
+	e.offset += uint64(len(e.item))
+
+	if _, err := e.bw.Write(buf); err != nil {
+		return fmt.Errorf("vptree: writing node %v: %w", i, err)
+	}
+
+	if err := e.records(n.Left, left); err != nil {
+		return err
+	}
+	return e.records(n.Right, right)
+}
+
+// items writes the items of the subtree rooted at n in preorder.
+func (e *mappedEncoder) items(n *node) error {
+	if n == nil {
+		return nil
+	}
+
+	if err := e.encodeItem(n); err != nil {
+		return err
+	}
+	if _, err := e.bw.Write(e.item); err != nil {
+		return fmt.Errorf("vptree: writing item %v: %w", n.Index, err)
+	}
+
+	if err := e.items(n.Left); err != nil {
+		return err
+	}
+	return e.items(n.Right)
+}
+
+// A MappedTree is a read-only VP-tree served from a file written by
+// SaveFile, which is memory-mapped where the platform supports it and read
+// into memory otherwise. Its nodes are read from the file as the searches
+// visit them, and its items are decoded on every visit, so that opening it
+// takes constant time regardless of its size, at the price of slower
+// queries than a VPTree. A MappedTree is safe for concurrent use if its
+// ItemCodec is.
+type MappedTree struct {
+	metric Metric
+	codec  ItemCodec
+
+	// data is the whole file, and nodes and items are its record and
+	// item blob sections
+	data  []byte
+	nodes []byte
+	items []byte
+
+	count  int
+	length int
+	spill  float64
+
+	// unmap releases data
+	unmap func() error
+}
+
+// OpenFile opens a VP-tree that SaveFile has written to the file at path,
+// using metric, which has to be the metric the tree was built with, and
+// codec to decode its items. A file that is not in the mapped file format,
+// or that is corrupt or truncated, returns an error wrapping
+// ErrBinaryFormat, and a version of the format OpenFile doesn't know
+// returns ErrBinaryVersion. The structure of the tree is validated, but not
+// the items; if codec fails to decode one during a search, the search
+// panics. The MappedTree must be closed with Close to release the file.
+func OpenFile(path string, metric Metric, codec ItemCodec) (*MappedTree, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMappedTree(data, metric, codec)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+
+	m.unmap = unmap
+	return m, nil
+}
+
+// newMappedTree validates data in the mapped file format and returns a
+// MappedTree serving queries from it.
+func newMappedTree(data []byte, metric Metric, codec ItemCodec) (*MappedTree, error) {
+	if len(data) < mappedHeaderSize || string(data[:len(mappedMagic)]) != mappedMagic {
+		return nil, ErrBinaryFormat
+	}
+	if data[len(mappedMagic)] != mappedVersion {
+		return nil, ErrBinaryVersion
+	}
+
+	count := binary.LittleEndian.Uint64(data[8:])
+	length := binary.LittleEndian.Uint64(data[16:])
+	spill := math.Float64frombits(binary.LittleEndian.Uint64(data[24:]))
+
+	if count > uint64(len(data)-mappedHeaderSize)/mappedNodeSize {
+		return nil, fmt.Errorf("%w: %v nodes do not fit into %v bytes", ErrBinaryFormat, count, len(data))
+	}
+	if length > count {
+		return nil, fmt.Errorf("%w: %v items in %v nodes", ErrBinaryFormat, length, count)
+	}
+
+	end := mappedHeaderSize + int(count)*mappedNodeSize
+	m := &MappedTree{
+		metric: metric,
+		codec:  codec,
+		data:   data,
+		nodes:  data[mappedHeaderSize:end],
+		items:  data[end:],
+		count:  int(count),
+		length: int(length),
+		spill:  spill,
+	}
+
+	// Children come after their parents, which rules out cycles, so
+	// checking every record on its own is enough for searches to stay
+	// within the file
+	for i := 0; i < m.count; i++ {
+		r := m.record(i)
+		if (r.left != 0 && (r.left <= i || r.left >= m.count)) || (r.right != 0 && (r.right <= i || r.right >= m.count)) {
+			return nil, fmt.Errorf("%w: node %v has its children out of range", ErrBinaryFormat, i)
+		}
+		if r.offset > uint64(len(m.items)) || uint64(r.length) > uint64(len(m.items))-r.offset {
+			return nil, fmt.Errorf("%w: node %v has its item out of range", ErrBinaryFormat, i)
+		}
+		if r.index < 0 {
+			return nil, fmt.Errorf("%w: node %v has index %v", ErrBinaryFormat, i, r.index)
+		}
+	}
+
+	return m, nil
+}
+
+// A mappedRecord is the decoded record of a node.
+type mappedRecord struct {
+	threshold   float64
+	index       int
+	left, right int
+	offset      uint64
+	length      uint32
+	flags       uint32
+}
+
+// record decodes the record of node i.
+func (m *MappedTree) record(i int) mappedRecord {
+	b := m.nodes[i*mappedNodeSize : (i+1)*mappedNodeSize]
+	return mappedRecord{
+		threshold: math.Float64frombits(binary.LittleEndian.Uint64(b)),
+		index:     int(binary.LittleEndian.Uint64(b[8:]) & math.MaxInt),
+		left:      int(binary.LittleEndian.Uint32(b[16:])),
+		right:     int(binary.LittleEndian.Uint32(b[20:])),
+		offset:    binary.LittleEndian.Uint64(b[24:]),
+		length:    binary.LittleEndian.Uint32(b[32:]),
+		flags:     binary.LittleEndian.Uint32(b[36:]),
+	}
+}
+
+// item decodes the item of r.
+func (m *MappedTree) item(r mappedRecord) interface{} {
+	item, err := m.codec.DecodeItem(m.items[r.offset : r.offset+uint64(r.length)])
+	if err != nil {
+		panic(fmt.Errorf("vptree: decoding item %v: %w", r.index, err))
+	}
+	return item
+}
+
+// Close releases the file. The MappedTree must not be used afterwards.
+func (m *MappedTree) Close() error {
+	if m.unmap == nil {
+		return nil
+	}
+
+	err := m.unmap()
+	m.unmap, m.data, m.nodes, m.items = nil, nil, nil, nil
+	return err
+}
+
+// Len returns the number of items in the MappedTree.
+func (m *MappedTree) Len() int {
+	return m.length
+}
+
+// Search searches the MappedTree for the k nearest neighbours of target,
+// like VPTree.Search.
+func (m *MappedTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	for _, hi := range m.search(target, k) {
+		results = append(results, hi.Item)
+		distances = append(distances, hi.Dist)
+	}
+	return
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (m *MappedTree) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	for _, hi := range m.search(target, k) {
+		indices = append(indices, hi.Index)
+		distances = append(distances, hi.Dist)
+	}
+	return
+}
+
+// A mappedFrame is a node of a MappedTree still to be searched, with the
+// check of its parent like a searchFrame.
+type mappedFrame struct {
+	node  int
+	check frameCheck
+	dist  float64
+	bound float64
+}
+
+// search returns the k nearest neighbours of target in order of increasing
+// distance. It follows VPTree.search, including the defeatist search of
+// spill trees.
+func (m *MappedTree) search(target interface{}, k int) []heapItem {
+	if k < 1 || m.count == 0 {
+		return nil
+	}
+
+	q := getKNNQuery(target, k, m.count)
+	defer putKNNQuery(q)
+
+	var buf [initialStackSize]mappedFrame
+	stack := append(buf[:0], mappedFrame{})
+
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if (f.check == checkInner && f.dist-q.tau > f.bound) || (f.check == checkOuter && f.dist+q.tau < f.bound) {
+			continue
+		}
+
+		r := m.record(f.node)
+		item := m.item(r)
+		dist := m.metric(item, target)
+
+		if dist < q.tau && r.flags&mappedDeleted == 0 && !(m.spill > 0 && q.h.contains(r.index)) {
+			q.add(heapItem{item, r.index, dist})
+		}
+
+		// The child to search first is pushed last
+		var first, second mappedFrame
+		switch {
+		case m.spill > 0:
+			if r.right != 0 && dist >= r.threshold*(1-m.spill) {
+				stack = append(stack, mappedFrame{node: r.right})
+			}
+			if r.left != 0 && dist <= r.threshold*(1+m.spill) {
+				stack = append(stack, mappedFrame{node: r.left})
+			}
+			continue
+		case dist < r.threshold:
+			first = mappedFrame{r.left, checkInner, dist, r.threshold}
+			second = mappedFrame{r.right, checkOuter, dist, r.threshold}
+		default:
+			first = mappedFrame{r.right, checkOuter, dist, r.threshold}
+			second = mappedFrame{r.left, checkInner, dist, r.threshold}
+		}
+
+		if second.node != 0 {
+			stack = append(stack, second)
+		}
+		if first.node != 0 {
+			stack = append(stack, first)
+		}
+	}
+
+	return q.results()
+}
+
+// SearchRadius searches the MappedTree for all items within radius of
+// target, like VPTree.SearchRadius.
+func (m *MappedTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	if m.count == 0 {
+		return
+	}
+
+	var found []heapItem
+	var seen map[int]bool
+	if m.spill > 0 {
+		// Spill trees may hold an item more than once
+		seen = make(map[int]bool)
+	}
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		r := m.record(stack[len(stack)-1])
+		stack = stack[:len(stack)-1]
+
+		item := m.item(r)
+		dist := m.metric(item, target)
+
+		if dist <= radius && r.flags&mappedDeleted == 0 && !seen[r.index] {
+			if seen != nil {
+				seen[r.index] = true
+			}
+			found = append(found, heapItem{item, r.index, dist})
+		}
+
+		// The left child is pushed last to be searched first
+		if r.right != 0 && dist+radius >= r.threshold {
+			stack = append(stack, r.right)
+		}
+		if r.left != 0 && dist-radius <= r.threshold {
+			stack = append(stack, r.left)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Dist < found[j].Dist
+	})
+
+	for _, hi := range found {
+		results = append(results, hi.Item)
+		distances = append(distances, hi.Dist)
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package vptree
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// This test saves trees with SaveFile, opens them with OpenFile and checks
+// that the MappedTree answers queries exactly like the VP-tree
+func TestMappedTree(t *testing.T) {
+	_, vpitems := randomCoordinates(1000)
+
+	upserted := New(CoordinateMetric, vpitems)
+	for i := 0; i < 100; i++ {
+		upserted.Upsert(vpitems[i], Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	for name, vp := range map[string]*VPTree{
+		"Tree":     New(CoordinateMetric, vpitems),
+		"Leaf":     New(CoordinateMetric, vpitems, WithLeafSize(8)),
+		"Spill":    NewSpillTree(CoordinateMetric, vpitems, 0.1),
+		"Upserted": upserted,
+		"Empty":    New(CoordinateMetric, nil),
+	} {
+		vp.itemCodec = coordinateCodec{}
+
+		path := filepath.Join(t.TempDir(), "tree.vpt")
+		if err := vp.SaveFile(path); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		m, err := OpenFile(path, CoordinateMetric, coordinateCodec{})
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		if m.Len() != vp.Len() {
+			t.Errorf("%v: expected %v items, got %v", name, vp.Len(), m.Len())
+		}
+
+		for i := 0; i < 100; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			k := rand.Intn(20) + 1
+
+			indices1, distances1 := vp.SearchIndices(q, k)
+			indices2, distances2 := m.SearchIndices(q, k)
+			if !reflect.DeepEqual(indices1, indices2) || !reflect.DeepEqual(distances1, distances2) {
+				t.Fatalf("%v: SearchIndices(%v, %v) returned %v, %v, expected %v, %v", name, q, k, indices2, distances2, indices1, distances1)
+			}
+
+			results1, _ := vp.Search(q, k)
+			results2, _ := m.Search(q, k)
+			if !reflect.DeepEqual(results1, results2) {
+				t.Fatalf("%v: Search(%v, %v) returned %v, expected %v", name, q, k, results2, results1)
+			}
+
+			results1, distances1 = vp.SearchRadius(q, 0.05)
+			results2, distances2 = m.SearchRadius(q, 0.05)
+			if !reflect.DeepEqual(results1, results2) || !reflect.DeepEqual(distances1, distances2) {
+				t.Fatalf("%v: SearchRadius(%v, 0.05) returned %v, expected %v", name, q, results2, results1)
+			}
+		}
+
+		if err := m.Close(); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+}
+
+// This test checks that OpenFile rejects files that are not intact VP-trees
+// in the mapped file format
+func TestMappedTreeMalformed(t *testing.T) {
+	_, vpitems := randomCoordinates(100)
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree.vpt")
+	if err := vp.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte(nil), data...))
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		err  error
+	}{
+		"Empty":     {nil, ErrBinaryFormat},
+		"Magic":     {corrupt(func(d []byte) []byte { d[0] = 'X'; return d }), ErrBinaryFormat},
+		"Version":   {corrupt(func(d []byte) []byte { d[4] = 99; return d }), ErrBinaryVersion},
+		"Truncated": {data[:mappedHeaderSize+50*mappedNodeSize], ErrBinaryFormat},
+		"Items":     {data[:len(data)-1], ErrBinaryFormat},
+		"Cycle": {corrupt(func(d []byte) []byte {
+			// The root's left child becomes its own left child
+			d[mappedHeaderSize+mappedNodeSize+16] = 1
+			return d
+		}), ErrBinaryFormat},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, tc.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := OpenFile(path, CoordinateMetric, coordinateCodec{}); !errors.Is(err, tc.err) {
+			t.Errorf("%v: expected %v, got %v", name, tc.err, err)
+		}
+	}
+
+	if err := New(CoordinateMetric, vpitems).SaveFile(path); err != ErrNoItemCodec {
+		t.Errorf("Expected ErrNoItemCodec, got %v", err)
+	}
+}
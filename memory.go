@@ -0,0 +1,91 @@
+package vptree
+
+import "unsafe"
+
+// MemStats describes the memory a VP-tree takes up.
+type MemStats struct {
+	// Nodes is the number of nodes. Spill trees may hold an item in more
+	// than one node.
+	Nodes int
+
+	// NodeBytes is the memory allocated for the nodes, including unused
+	// capacity.
+	NodeBytes int
+
+	// TreeBytes is the memory of the VPTree itself.
+	TreeBytes int
+
+	// ItemBytes is the memory of the items, as reported by the itemSize
+	// function passed to MemoryStats, or 0.
+	ItemBytes int
+
+	// Total is the sum of NodeBytes, TreeBytes and ItemBytes.
+	Total int
+}
+
+// MemoryStats estimates the memory the VP-tree takes up. The sizes are
+// computed from the actual types, so they stay accurate as the node layout
+// changes. Every node holds an interface value referring to its item, but
+// the memory of the item itself is only counted if itemSize is not nil, by
+// summing itemSize over the items of all nodes. The memory of the Metric and
+// of pooled search state isn't counted. The nodes of the items Upsert
+// replaced are counted, since the tree holds on to them and their items
+// until it is rebuilt.
+func (vp *VPTree) MemoryStats(itemSize func(item interface{}) int) (stats MemStats) {
+	var nodes []*node
+	vp.forEachNode(func(n *node) {
+		nodes = append(nodes, n)
+	})
+
+	nodeSize := int(unsafe.Sizeof(node{}))
+
+	stats.Nodes = len(nodes)
+	stats.TreeBytes = int(unsafe.Sizeof(*vp))
+
+	// Spill trees allocate their nodes one by one rather than in an arena
+	stats.NodeBytes = cap(vp.arena) * nodeSize
+	stats.NodeBytes += max(len(nodes)-len(vp.arena), 0) * nodeSize
+
+	if itemSize != nil {
+		for _, n := range nodes {
+			stats.ItemBytes += itemSize(n.Item)
+		}
+	}
+
+	stats.Total = stats.NodeBytes + stats.TreeBytes + stats.ItemBytes
+	return
+}
+
+// WithStringInterning makes equal string items share their memory in the
+// VP-tree, such as product SKUs that occur many times. Every Go string in an
+// interface value is a separately allocated header pointing to its bytes;
+// while the tree is built, all equal strings are replaced by the first of
+// them, so that one header and one copy of the bytes are kept for each
+// distinct string. Items of other types, and items added by Upsert, are
+// left as they are.
+//
+// Interning only saves memory if the caller drops its own items afterwards,
+// and the Metric is not involved: it only ever sees items, and couldn't
+// change the ones the tree holds.
+func WithStringInterning() Option {
+	return func(vp *VPTree) {
+		vp.internStrings = true
+	}
+}
+
+// internStrings replaces every string in items by the first equal one.
+func internStrings(items []interface{}) {
+	canonical := make(map[string]interface{})
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			continue
+		}
+
+		if c, ok := canonical[s]; ok {
+			items[i] = c
+		} else {
+			canonical[s] = item
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// This test compares the estimate of MemoryStats against the memory a large
+// tree actually takes up on the heap
+func TestMemoryStats(t *testing.T) {
+	items := make([]interface{}, 100000)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	// Collect twice, so that the pools of earlier tests are emptied
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	vp := New(CoordinateMetric, items)
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(items)
+
+	stats := vp.MemoryStats(nil)
+	actual := int(after.HeapAlloc) - int(before.HeapAlloc)
+
+	if stats.Nodes != len(items) {
+		t.Errorf("Expected %v nodes, got %v", len(items), stats.Nodes)
+	}
+
+	if stats.Total < actual*9/10 || stats.Total > actual*11/10 {
+		t.Errorf("Expected an estimate within 10%% of %v bytes, got %v", actual, stats.Total)
+	}
+
+	runtime.KeepAlive(vp)
+
+	// Every Coordinate is boxed in 16 bytes of its own
+	withItems := vp.MemoryStats(func(item interface{}) int {
+		return int(unsafe.Sizeof(item.(Coordinate)))
+	})
+	if withItems.ItemBytes != 16*len(items) || withItems.Total != stats.Total+withItems.ItemBytes {
+		t.Errorf("Expected %v bytes of items on top of %v, got %+v", 16*len(items), stats.Total, withItems)
+	}
+}
+
+// This test checks that WithStringInterning makes equal strings share their
+// memory without changing the search results
+func TestStringInterning(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 1000; i++ {
+		// strconv allocates every string anew
+		items = append(items, "SKU-"+strconv.Itoa(i%10), i)
+	}
+
+	// The strings sit at multiples of 100 on a line with the ints
+	key := func(item interface{}) int {
+		if s, ok := item.(string); ok {
+			n, _ := strconv.Atoi(strings.TrimPrefix(s, "SKU-"))
+			return 100 * n
+		}
+		return item.(int)
+	}
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(float64(key(a) - key(b)))
+	}
+
+	plain := New(metric, items)
+	interned := New(metric, items, WithStringInterning())
+
+	data := make(map[string]map[*byte]bool)
+	interned.Walk(func(n NodeInfo) bool {
+		if s, ok := n.Item.(string); ok {
+			if data[s] == nil {
+				data[s] = make(map[*byte]bool)
+			}
+			data[s][unsafe.StringData(s)] = true
+		}
+		return true
+	})
+
+	if len(data) != 10 {
+		t.Fatalf("Expected 10 distinct strings, got %v", len(data))
+	}
+	for s, copies := range data {
+		if len(copies) != 1 {
+			t.Errorf("Expected one copy of %q, got %v", s, len(copies))
+		}
+	}
+
+	if unsafe.StringData(items[20].(string)) == unsafe.StringData(items[0].(string)) {
+		t.Errorf("Expected the caller's items to be left alone")
+	}
+
+	for _, q := range []interface{}{"SKU-3", 500} {
+		indices1, distances1 := plain.SearchIndices(q, 20)
+		indices2, distances2 := interned.SearchIndices(q, 20)
+		if !reflect.DeepEqual(distances1, distances2) || len(indices1) != len(indices2) {
+			t.Errorf("Expected the same results for %v", q)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"math"
+)
+
+// Angular is the angle, in radians, between two []float64 vectors. Unlike the
+// popular cosine distance 1 - cos(a, b), which violates the triangle
+// inequality, the angle is a metric on the directions of the vectors: vectors
+// that are positive multiples of each other are at distance 0.
+//
+// The zero vector has no direction. Angular defines its distance to every
+// other vector as π/2, and to itself as 0, which keeps the triangle
+// inequality intact.
+//
+// The angle is computed as 2·atan2(|u-v|, |u+v|) from the unit vectors u and
+// v, rather than as the arccosine of the cosine similarity: the arccosine
+// loses about half of the significant digits for nearly parallel vectors,
+// and needs the cosine clamped to [-1, 1] to avoid NaNs.
+func Angular(a, b interface{}) float64 {
+	x, y := a.([]float64), b.([]float64)
+	checkLengths(len(x), len(y))
+
+	nx, ny := 0.0, 0.0
+	for i := range x {
+		nx += x[i] * x[i]
+		ny += y[i] * y[i]
+	}
+
+	if nx == 0 || ny == 0 {
+		return zeroAngle(nx == 0, ny == 0)
+	}
+
+	nx, ny = math.Sqrt(nx), math.Sqrt(ny)
+
+	diff, sum := 0.0, 0.0
+	for i := range x {
+		u, v := x[i]/nx, y[i]/ny
+		diff += (u - v) * (u - v)
+		sum += (u + v) * (u + v)
+	}
+
+	return 2 * math.Atan2(math.Sqrt(diff), math.Sqrt(sum))
+}
+
+// Angular32 is like Angular, but for []float32 vectors.
+func Angular32(a, b interface{}) float64 {
+	x, y := a.([]float32), b.([]float32)
+	checkLengths(len(x), len(y))
+
+	nx, ny := dotFloat32(x, x), dotFloat32(y, y)
+
+	if nx == 0 || ny == 0 {
+		return zeroAngle(nx == 0, ny == 0)
+	}
+
+	nx, ny = math.Sqrt(nx), math.Sqrt(ny)
+
+	diff, sum := 0.0, 0.0
+	for i := range x {
+		u, v := float64(x[i])/nx, float64(y[i])/ny
+		diff += (u - v) * (u - v)
+		sum += (u + v) * (u + v)
+	}
+
+	return 2 * math.Atan2(math.Sqrt(diff), math.Sqrt(sum))
+}
+
+// A UnitVector is a []float64 vector scaled to unit length, as produced by
+// NewUnitVector.
+type UnitVector struct {
+	V    []float64
+	Zero bool
+}
+
+// NewUnitVector prepares v for use with AngularUnit by normalizing it once,
+// instead of on every distance computation.
+func NewUnitVector(v []float64) UnitVector {
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+
+	u := UnitVector{V: make([]float64, len(v)), Zero: norm == 0}
+	if !u.Zero {
+		norm = math.Sqrt(norm)
+		for i, x := range v {
+			u.V[i] = x / norm
+		}
+	}
+
+	return u
+}
+
+// AngularUnit is the same distance as Angular, but operates on UnitVectors,
+// which saves normalizing the vectors on every call.
+func AngularUnit(a, b interface{}) float64 {
+	x, y := a.(UnitVector), b.(UnitVector)
+	checkLengths(len(x.V), len(y.V))
+
+	if x.Zero || y.Zero {
+		return zeroAngle(x.Zero, y.Zero)
+	}
+
+	diff, sum := 0.0, 0.0
+	for i := range x.V {
+		u, v := x.V[i], y.V[i]
+		diff += (u - v) * (u - v)
+		sum += (u + v) * (u + v)
+	}
+
+	return 2 * math.Atan2(math.Sqrt(diff), math.Sqrt(sum))
+}
+
+// zeroAngle is the angle between two vectors, at least one of which is the
+// zero vector.
+func zeroAngle(zeroX, zeroY bool) float64 {
+	if zeroX && zeroY {
+		return 0
+	}
+	return math.Pi / 2
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+// This test pins a few angles
+func TestAngularKnownValues(t *testing.T) {
+	tests := []struct {
+		a, b  []float64
+		angle float64
+	}{
+		{[]float64{1, 0}, []float64{0, 1}, math.Pi / 2},
+		{[]float64{1, 0}, []float64{-1, 0}, math.Pi},
+		{[]float64{1, 1}, []float64{2, 2}, 0},
+		{[]float64{1, 0}, []float64{1, 1}, math.Pi / 4},
+		{[]float64{0, 0}, []float64{1, 1}, math.Pi / 2},
+		{[]float64{0, 0}, []float64{0, 0}, 0},
+	}
+
+	for _, test := range tests {
+		if d := Angular(test.a, test.b); math.Abs(d-test.angle) > 1e-7 {
+			t.Errorf("Angular(%v, %v): expected %v, got %v", test.a, test.b, test.angle, d)
+		}
+		if d := Angular32(to32(test.a), to32(test.b)); math.Abs(d-test.angle) > 1e-7 {
+			t.Errorf("Angular32(%v, %v): expected %v, got %v", test.a, test.b, test.angle, d)
+		}
+		if d := AngularUnit(NewUnitVector(test.a), NewUnitVector(test.b)); math.Abs(d-test.angle) > 1e-7 {
+			t.Errorf("AngularUnit(%v, %v): expected %v, got %v", test.a, test.b, test.angle, d)
+		}
+	}
+}
+
+// This test makes sure nearly parallel and nearly opposite vectors, where
+// the cosine similarity loses precision, get accurate angles
+func TestAngularPrecision(t *testing.T) {
+	v := []float64{0.1, 0.2, 0.3}
+	if d := Angular(v, v); d != 0 {
+		t.Errorf("Expected an angle of 0, got %v", d)
+	}
+
+	w := []float64{0.1, 0.2, 0.3 + 1e-10}
+	if d := Angular(v, w); d == 0 || math.IsNaN(d) || d > 1e-9 {
+		t.Errorf("Expected a tiny positive angle, got %v", d)
+	}
+
+	if d := Angular(v, []float64{-0.1, -0.2, -0.3}); d != math.Pi {
+		t.Errorf("Expected an angle of π, got %v", d)
+	}
+}
+
+// This test demonstrates why the angle, and not the cosine distance, should
+// be used with a VP-tree: CheckMetric accepts the former and rejects the
+// latter
+func TestAngularVersusCosineDistance(t *testing.T) {
+	cosineDistance := func(a, b interface{}) float64 {
+		return 1 - math.Cos(Angular(a, b))
+	}
+
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 30; i++ {
+		items = append(items, randomVector(rng, 3))
+	}
+
+	if err := vptree.CheckMetric(Angular, items); err != nil {
+		t.Errorf("Expected Angular to pass CheckMetric, got %v", err)
+	}
+
+	if err := vptree.CheckMetric(cosineDistance, items); err == nil {
+		t.Error("Expected the cosine distance to fail CheckMetric")
+	}
+}
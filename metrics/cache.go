@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DataWraith/vptree"
+)
+
+// A Cache memoizes the distances computed by an expensive metric. It keeps
+// up to a fixed number of item pairs and evicts the least recently used pair
+// when full. A Cache is safe for concurrent use.
+type Cache struct {
+	inner      vptree.Metric
+	keyOf      func(interface{}) uint64
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[pairKey]*list.Element
+	lru     *list.List
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// pairKey identifies an unordered pair of items, so that d(a, b) and d(b, a)
+// share an entry.
+type pairKey struct {
+	lo, hi uint64
+}
+
+type cacheEntry struct {
+	key  pairKey
+	dist float64
+}
+
+// NewCache creates a Cache around inner holding at most maxEntries pairs.
+//
+// keyOf must map every item to a key that no other item shares, or the Cache
+// will return distances belonging to other pairs. If keyOf is nil,
+// PointerKey is used, which is only correct if items are pointers that stay
+// alive for as long as the Cache is used. NewCache panics if maxEntries is
+// not positive.
+func NewCache(inner vptree.Metric, keyOf func(interface{}) uint64, maxEntries int) *Cache {
+	if maxEntries < 1 {
+		panic(fmt.Sprintf("metrics: cache size must be positive, got %v", maxEntries))
+	}
+
+	if keyOf == nil {
+		keyOf = PointerKey
+	}
+
+	return &Cache{
+		inner:      inner,
+		keyOf:      keyOf,
+		maxEntries: maxEntries,
+		entries:    make(map[pairKey]*list.Element, maxEntries),
+		lru:        list.New(),
+	}
+}
+
+// Cached wraps inner in a new Cache and returns its Distance method. Use
+// NewCache instead if you need the hit and miss counters.
+func Cached(inner vptree.Metric, keyOf func(interface{}) uint64, maxEntries int) vptree.Metric {
+	return NewCache(inner, keyOf, maxEntries).Distance
+}
+
+// Distance returns the distance between a and b, calling the inner metric
+// only if the pair is not cached. Concurrent calls for the same uncached pair
+// may each call the inner metric.
+func (c *Cache) Distance(a, b interface{}) float64 {
+	ka, kb := c.keyOf(a), c.keyOf(b)
+	if ka > kb {
+		ka, kb = kb, ka
+	}
+	key := pairKey{ka, kb}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		dist := e.Value.(*cacheEntry).dist
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return dist
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	dist := c.inner(a, b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		// Another goroutine got here first
+		c.lru.MoveToFront(e)
+		return dist
+	}
+
+	if c.lru.Len() >= c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, dist})
+	return dist
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *Cache) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Len returns the number of pairs currently cached.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// PointerKey is the default key function of a Cache. It uses the address of
+// pointer items (or the data pointer of slices and maps) as their key, and
+// panics for items of any other kind.
+func PointerKey(item interface{}) uint64 {
+	v := reflect.ValueOf(item)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func:
+		return uint64(v.Pointer())
+	default:
+		panic(fmt.Sprintf("metrics: PointerKey needs pointer items, got %T", item))
+	}
+}
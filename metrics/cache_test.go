@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+type countingMetric struct {
+	mu    sync.Mutex
+	calls map[[2]*float64]int
+}
+
+func (c *countingMetric) distance(a, b interface{}) float64 {
+	x, y := a.(*float64), b.(*float64)
+
+	c.mu.Lock()
+	if *x > *y {
+		c.calls[[2]*float64{y, x}]++
+	} else {
+		c.calls[[2]*float64{x, y}]++
+	}
+	c.mu.Unlock()
+
+	return math.Abs(*x - *y)
+}
+
+func pointerItems(n int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		v := rand.Float64()
+		items[i] = &v
+	}
+	return items
+}
+
+// This test makes sure the inner metric is called at most once per distinct
+// pair while the cache is large enough
+func TestCacheMemoizes(t *testing.T) {
+	inner := &countingMetric{calls: make(map[[2]*float64]int)}
+	cache := NewCache(inner.distance, nil, 1000)
+	items := pointerItems(20)
+
+	for round := 0; round < 3; round++ {
+		for _, a := range items {
+			for _, b := range items {
+				if d := cache.Distance(a, b); d != math.Abs(*a.(*float64)-*b.(*float64)) {
+					t.Fatalf("Expected %v, got %v", math.Abs(*a.(*float64)-*b.(*float64)), d)
+				}
+			}
+		}
+	}
+
+	for pair, n := range inner.calls {
+		if n != 1 {
+			t.Errorf("Expected one call for %v, got %v", pair, n)
+		}
+	}
+
+	// 20 items have 20*19/2 + 20 unordered pairs, including self-pairs
+	hits, misses := cache.Stats()
+	if misses != 210 || hits != 3*400-210 {
+		t.Errorf("Expected 210 misses and %v hits, got %v and %v", 3*400-210, misses, hits)
+	}
+}
+
+// This test makes sure the least recently used pair is evicted once the
+// cache is full
+func TestCacheEviction(t *testing.T) {
+	inner := &countingMetric{calls: make(map[[2]*float64]int)}
+	cache := NewCache(inner.distance, nil, 2)
+	items := pointerItems(4)
+
+	cache.Distance(items[0], items[1])
+	cache.Distance(items[0], items[2])
+	cache.Distance(items[1], items[0]) // refreshes {0, 1}
+	cache.Distance(items[0], items[3]) // evicts {0, 2}
+
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 cached pairs, got %v", cache.Len())
+	}
+
+	cache.Distance(items[0], items[1])
+	cache.Distance(items[0], items[2])
+
+	hits, misses := cache.Stats()
+	if hits != 2 || misses != 4 {
+		t.Errorf("Expected 2 hits and 4 misses, got %v and %v", hits, misses)
+	}
+}
+
+// This test runs concurrent searches through a cached metric. It is most
+// useful under the race detector.
+func TestCacheConcurrent(t *testing.T) {
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(*a.(*float64) - *b.(*float64))
+	}
+
+	items := pointerItems(500)
+	tree := vptree.New(Cached(metric, nil, 5000), items)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				target := items[rand.Intn(len(items))]
+				if _, distances := tree.Search(target, 5); len(distances) != 5 || distances[0] != 0 {
+					t.Errorf("Expected 5 results starting at distance 0, got %v", distances)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
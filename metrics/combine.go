@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/DataWraith/vptree"
+)
+
+// Weighted returns the weighted sum of the component metrics. A non-negative
+// weighted sum of metrics is again a metric (a weight of 0 may turn distinct
+// items into items at distance 0, though). Weighted panics if the number of
+// weights does not match the number of components, or if a weight is
+// negative.
+func Weighted(components []vptree.Metric, weights []float64) vptree.Metric {
+	bounded := WeightedBounded(components, weights)
+	return func(a, b interface{}) float64 {
+		return bounded(a, b, -1)
+	}
+}
+
+// WeightedBounded is like Weighted, but the returned function takes an upper
+// bound and stops evaluating components as soon as the partial sum exceeds
+// it. In that case the returned value is larger than upper, but may be less
+// than the full weighted sum. A negative upper bound disables the early
+// exit. Ordering the components from cheapest to most expensive makes the
+// early exit most effective.
+func WeightedBounded(components []vptree.Metric, weights []float64) func(a, b interface{}, upper float64) float64 {
+	if len(components) != len(weights) {
+		panic(fmt.Sprintf("metrics: %d components but %d weights", len(components), len(weights)))
+	}
+
+	for _, w := range weights {
+		if w < 0 {
+			panic(fmt.Sprintf("metrics: negative weight %v", w))
+		}
+	}
+
+	return func(a, b interface{}, upper float64) float64 {
+		sum := 0.0
+		for i, m := range components {
+			if weights[i] == 0 {
+				continue
+			}
+
+			sum += weights[i] * m(a, b)
+			if upper >= 0 && sum > upper {
+				return sum
+			}
+		}
+		return sum
+	}
+}
+
+// Max returns the maximum of the component metrics, which is again a metric.
+func Max(components ...vptree.Metric) vptree.Metric {
+	bounded := MaxBounded(components...)
+	return func(a, b interface{}) float64 {
+		return bounded(a, b, -1)
+	}
+}
+
+// MaxBounded is like Max, but the returned function takes an upper bound and
+// stops evaluating components as soon as one of them exceeds it. In that case
+// the returned value is larger than upper, but may be less than the maximum.
+// A negative upper bound disables the early exit.
+func MaxBounded(components ...vptree.Metric) func(a, b interface{}, upper float64) float64 {
+	return func(a, b interface{}, upper float64) float64 {
+		max := 0.0
+		for _, m := range components {
+			if d := m(a, b); d > max {
+				max = d
+				if upper >= 0 && max > upper {
+					return max
+				}
+			}
+		}
+		return max
+	}
+}
+
+// Symmetrize returns the average of m(a, b) and m(b, a), which is symmetric
+// even if m is not, for example due to floating-point rounding. If m
+// satisfies the triangle inequality in both directions, so does the
+// average. However, if m only approximately satisfies it, as nearly
+// symmetric functions often do, the average may violate it by the same
+// amount. Symmetrize doubles the cost of every distance.
+func Symmetrize(m vptree.Metric) vptree.Metric {
+	return func(a, b interface{}) float64 {
+		return (m(a, b) + m(b, a)) / 2
+	}
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+// This test makes sure the combinators compute the right values and produce
+// metrics
+func TestCombinators(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 20; i++ {
+		items = append(items, randomVector(rng, 4))
+	}
+
+	weighted := Weighted([]vptree.Metric{Euclidean, Manhattan, Chebyshev}, []float64{1, 0.5, 2})
+	max := Max(Euclidean, Chebyshev, Minkowski(3))
+
+	for _, x := range items {
+		for _, y := range items {
+			expected := Euclidean(x, y) + 0.5*Manhattan(x, y) + 2*Chebyshev(x, y)
+			if d := weighted(x, y); d != expected {
+				t.Errorf("Weighted: expected %v, got %v", expected, d)
+			}
+
+			// Euclidean dominates the other two
+			if d := max(x, y); d != Euclidean(x, y) {
+				t.Errorf("Max: expected %v, got %v", Euclidean(x, y), d)
+			}
+		}
+	}
+
+	if err := vptree.CheckMetric(weighted, items); err != nil {
+		t.Errorf("Expected Weighted to be a metric, got %v", err)
+	}
+
+	if err := vptree.CheckMetric(max, items); err != nil {
+		t.Errorf("Expected Max to be a metric, got %v", err)
+	}
+}
+
+// This test makes sure the bounded combinators skip the remaining components
+// once the bound is exceeded
+func TestCombinatorsShortCircuit(t *testing.T) {
+	calls := 0
+	constant := func(d float64) vptree.Metric {
+		return func(a, b interface{}) float64 {
+			calls++
+			return d
+		}
+	}
+
+	weighted := WeightedBounded([]vptree.Metric{constant(1), constant(2), constant(3)}, []float64{1, 1, 1})
+
+	if d := weighted(nil, nil, -1); d != 6 || calls != 3 {
+		t.Errorf("Expected 6 after 3 calls without a bound, got %v after %v calls", d, calls)
+	}
+
+	calls = 0
+	if d := weighted(nil, nil, 2.5); d <= 2.5 || calls != 2 {
+		t.Errorf("Expected a value above 2.5 after 2 calls, got %v after %v calls", d, calls)
+	}
+
+	max := MaxBounded(constant(1), constant(5), constant(3))
+
+	calls = 0
+	if d := max(nil, nil, 4); d <= 4 || calls != 2 {
+		t.Errorf("Expected a value above 4 after 2 calls, got %v after %v calls", d, calls)
+	}
+
+	calls = 0
+	if d := max(nil, nil, 10); d != 5 || calls != 3 {
+		t.Errorf("Expected 5 after 3 calls, got %v after %v calls", d, calls)
+	}
+}
+
+// This test makes sure invalid weights panic
+func TestWeightedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	Weighted([]vptree.Metric{Euclidean}, []float64{-1})
+}
+
+// This test makes sure Symmetrize turns an asymmetric distance into a
+// symmetric one
+func TestSymmetrize(t *testing.T) {
+	// Moving up costs the distance, moving down twice the distance
+	uphill := func(a, b interface{}) float64 {
+		x, y := a.(float64), b.(float64)
+		if y >= x {
+			return y - x
+		}
+		return 2 * (x - y)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 20; i++ {
+		items = append(items, rng.Float64())
+	}
+
+	if err := vptree.CheckMetric(uphill, items); err == nil {
+		t.Errorf("Expected the uphill distance not to be a metric")
+	}
+
+	symmetric := Symmetrize(uphill)
+	for _, x := range items {
+		for _, y := range items {
+			expected := 1.5 * math.Abs(x.(float64)-y.(float64))
+			if d := symmetric(x, y); math.Abs(d-expected) > 1e-12 {
+				t.Errorf("Expected %v, got %v", expected, d)
+			}
+		}
+	}
+
+	if err := vptree.CheckMetric(symmetric, items); err != nil {
+		t.Errorf("Expected the symmetrized distance to be a metric, got %v", err)
+	}
+}
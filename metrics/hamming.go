@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// HammingU64 is the Hamming distance between two uint64 values, that is, the
+// number of bits in which they differ. It is useful for 64-bit perceptual
+// hashes.
+func HammingU64(a, b interface{}) float64 {
+	return float64(bits.OnesCount64(a.(uint64) ^ b.(uint64)))
+}
+
+// HammingBits is the Hamming distance between two bit strings stored as
+// []uint64 of equal length.
+func HammingBits(a, b interface{}) float64 {
+	x, y := a.([]uint64), b.([]uint64)
+	checkLengths(len(x), len(y))
+
+	count := 0
+	for i := range x {
+		count += bits.OnesCount64(x[i] ^ y[i])
+	}
+	return float64(count)
+}
+
+// HammingBytes is the Hamming distance between two bit strings stored as
+// []byte of equal length.
+func HammingBytes(a, b interface{}) float64 {
+	x, y := a.([]byte), b.([]byte)
+	checkLengths(len(x), len(y))
+
+	count := 0
+	for len(x) >= 8 {
+		count += bits.OnesCount64(binary.LittleEndian.Uint64(x) ^ binary.LittleEndian.Uint64(y))
+		x, y = x[8:], y[8:]
+	}
+	for i := range x {
+		count += bits.OnesCount8(x[i] ^ y[i])
+	}
+	return float64(count)
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+	"github.com/DataWraith/vptree/vptreetest"
+)
+
+// naiveHamming counts differing bits one at a time
+func naiveHamming(x, y []byte) float64 {
+	count := 0
+	for i := range x {
+		for bit := uint(0); bit < 8; bit++ {
+			if (x[i]>>bit)&1 != (y[i]>>bit)&1 {
+				count++
+			}
+		}
+	}
+	return float64(count)
+}
+
+func randomBytes(rng *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	rng.Read(b)
+	return b
+}
+
+func bytesToWords(b []byte) []uint64 {
+	w := make([]uint64, len(b)/8)
+	for i := range w {
+		for j := 0; j < 8; j++ {
+			w[i] |= uint64(b[8*i+j]) << (8 * uint(j))
+		}
+	}
+	return w
+}
+
+// This test compares the Hamming metrics against a bit-by-bit count
+func TestHammingAgainstNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		n := 8 * (rng.Intn(8) + 1)
+		x, y := randomBytes(rng, n), randomBytes(rng, n)
+		expected := naiveHamming(x, y)
+
+		if d := HammingBytes(x, y); d != expected {
+			t.Errorf("HammingBytes: expected %v, got %v", expected, d)
+		}
+
+		// Odd lengths exercise the byte-wise tail
+		if d, e := HammingBytes(x[:n-3], y[:n-3]), naiveHamming(x[:n-3], y[:n-3]); d != e {
+			t.Errorf("HammingBytes: expected %v, got %v", e, d)
+		}
+
+		if d := HammingBits(bytesToWords(x), bytesToWords(y)); d != expected {
+			t.Errorf("HammingBits: expected %v, got %v", expected, d)
+		}
+
+		if d := HammingU64(bytesToWords(x)[0], bytesToWords(y)[0]); d != naiveHamming(x[:8], y[:8]) {
+			t.Errorf("HammingU64: expected %v, got %v", naiveHamming(x[:8], y[:8]), d)
+		}
+	}
+}
+
+// This test makes sure mismatched lengths panic
+func TestHammingPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	HammingBytes([]byte{1, 2}, []byte{1})
+}
+
+// Hamming distances are small integers, so nearly every query has ties at
+// the k-th distance. This test runs the conformance harness on short hashes
+// where ties are everywhere, including exact duplicates.
+func TestHammingTies(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	var items []interface{}
+	for i := 0; i < 500; i++ {
+		items = append(items, uint64(rng.Intn(1<<12)))
+	}
+
+	vptreetest.RunConformance(t, HammingU64, items, vptreetest.Config{Seed: 2})
+}
+
+func BenchmarkHammingBits256(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, HammingBits, bytesToWords(randomBytes(rng, 32)), bytesToWords(randomBytes(rng, 32)))
+}
+
+func BenchmarkHammingBytes256(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, HammingBytes, randomBytes(rng, 32), randomBytes(rng, 32))
+}
+
+func BenchmarkHammingSearch256(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, bytesToWords(randomBytes(rng, 32)))
+	}
+	tree := vptree.New(HammingBits, items)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(items[i%len(items)], 10)
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/DataWraith/vptree"
+	"github.com/DataWraith/vptree/internal/sphere"
+)
+
+// EarthRadius is the mean radius of the Earth in meters.
+const EarthRadius = 6371008.8
+
+// A LatLon is a point on a sphere, given by its latitude and longitude in
+// degrees.
+type LatLon struct {
+	Lat float64
+	Lon float64
+}
+
+// Haversine is the great-circle distance in meters between two LatLon points
+// on the Earth, modelled as a sphere of radius EarthRadius.
+func Haversine(a, b interface{}) float64 {
+	return EarthRadius * centralAngle(a.(LatLon), b.(LatLon))
+}
+
+// HaversineRadius returns the great-circle distance between two LatLon points
+// on a sphere of the given radius. The distance is in the unit of the radius.
+func HaversineRadius(radius float64) vptree.Metric {
+	return func(a, b interface{}) float64 {
+		return radius * centralAngle(a.(LatLon), b.(LatLon))
+	}
+}
+
+// centralAngle returns the angle between two points as seen from the center
+// of the sphere. Despite the name of the metrics, it uses the atan2 form of
+// the great-circle formula, see sphere.CentralAngle.
+func centralAngle(a, b LatLon) float64 {
+	return sphere.CentralAngle(a.Lat, a.Lon, b.Lat, b.Lon)
+}
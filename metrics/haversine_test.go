@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+// This test pins known great-circle distances
+func TestHaversineKnownDistances(t *testing.T) {
+	berlin := LatLon{52.5200, 13.4050}
+	sydney := LatLon{-33.8688, 151.2093}
+	paris := LatLon{48.8566, 2.3522}
+
+	tests := []struct {
+		a, b   LatLon
+		meters float64
+	}{
+		{berlin, sydney, 16090e3},
+		{berlin, paris, 878e3},
+		{LatLon{0, 0}, LatLon{0, 180}, math.Pi * EarthRadius},
+		{LatLon{90, 0}, LatLon{-90, 0}, math.Pi * EarthRadius},
+	}
+
+	for _, test := range tests {
+		if d := Haversine(test.a, test.b); math.Abs(d-test.meters) > 0.005*test.meters {
+			t.Errorf("Expected distance between %v and %v to be about %v m, got %v", test.a, test.b, test.meters, d)
+		}
+	}
+
+	// On the unit sphere, the distance is the central angle
+	if d := HaversineRadius(1)(LatLon{0, 0}, LatLon{0, 90}); math.Abs(d-math.Pi/2) > 1e-15 {
+		t.Errorf("Expected π/2 on the unit sphere, got %v", d)
+	}
+}
+
+// This test makes sure nearby and nearly antipodal points keep their
+// precision
+func TestHaversinePrecision(t *testing.T) {
+	// 1e-9 degrees of latitude are about 0.11 mm
+	if d := Haversine(LatLon{45, 10}, LatLon{45 + 1e-9, 10}); math.Abs(d-1.11195e-4) > 1e-9 {
+		t.Errorf("Expected about 0.000111195 m, got %v", d)
+	}
+
+	// Moving 1e-6 degrees away from the antipode shortens the distance
+	// by about 0.11 m
+	full := Haversine(LatLon{0, 0}, LatLon{0, 180})
+	near := Haversine(LatLon{0, 0}, LatLon{0, 180 - 1e-6})
+	if math.Abs(full-near-0.111195) > 1e-3 {
+		t.Errorf("Expected a difference of about 0.111195 m, got %v", full-near)
+	}
+}
+
+// This test checks symmetry and the triangle inequality on random points
+func TestHaversineIsMetric(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 30; i++ {
+		items = append(items, LatLon{
+			Lat: 180 / math.Pi * math.Asin(2*rng.Float64()-1),
+			Lon: 360*rng.Float64() - 180,
+		})
+	}
+
+	if err := vptree.CheckMetric(Haversine, items); err != nil {
+		t.Errorf("Expected Haversine to pass CheckMetric, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Jaccard is the Jaccard distance 1 - |A ∩ B| / |A ∪ B| between two sets
+// given as map[string]struct{}. The distance between two empty sets is 0.
+func Jaccard(a, b interface{}) float64 {
+	x, y := a.(map[string]struct{}), b.(map[string]struct{})
+	if len(x) > len(y) {
+		x, y = y, x
+	}
+
+	intersection := 0
+	for key := range x {
+		if _, ok := y[key]; ok {
+			intersection++
+		}
+	}
+
+	return jaccardDistance(intersection, len(x)+len(y)-intersection)
+}
+
+// JaccardSorted is the Jaccard distance between two sets of IDs given as
+// sorted []uint64 without duplicates. It counts the intersection by merging
+// the two slices and does not allocate.
+func JaccardSorted(a, b interface{}) float64 {
+	x, y := a.([]uint64), b.([]uint64)
+
+	intersection := 0
+	for i, j := 0, 0; i < len(x) && j < len(y); {
+		switch {
+		case x[i] < y[j]:
+			i++
+		case x[i] > y[j]:
+			j++
+		default:
+			intersection++
+			i++
+			j++
+		}
+	}
+
+	return jaccardDistance(intersection, len(x)+len(y)-intersection)
+}
+
+func jaccardDistance(intersection, union int) float64 {
+	if union == 0 {
+		return 0
+	}
+	return 1 - float64(intersection)/float64(union)
+}
+
+// A MinHasher computes MinHash signatures of sets of IDs. The fraction of
+// positions in which the signatures of two sets differ estimates their
+// Jaccard distance, with a standard error of at most 1/(2√k) for signatures
+// of length k, at a cost independent of the size of the sets.
+type MinHasher struct {
+	seeds []uint64
+}
+
+// NewMinHasher creates a MinHasher producing signatures of length k. Only
+// signatures computed by MinHashers with the same k and seed are comparable.
+func NewMinHasher(k int, seed int64) *MinHasher {
+	rng := rand.New(rand.NewSource(seed))
+
+	m := &MinHasher{seeds: make([]uint64, k)}
+	for i := range m.seeds {
+		m.seeds[i] = rng.Uint64()
+	}
+
+	return m
+}
+
+// Signature returns the MinHash signature of the given set of IDs.
+func (m *MinHasher) Signature(ids []uint64) []uint64 {
+	sig := make([]uint64, len(m.seeds))
+	for i, seed := range m.seeds {
+		min := uint64(math.MaxUint64)
+		for _, id := range ids {
+			if h := mix64(id ^ seed); h < min {
+				min = h
+			}
+		}
+		sig[i] = min
+	}
+	return sig
+}
+
+// MinHashJaccard estimates the Jaccard distance between two sets from their
+// MinHash signatures ([]uint64 of equal length) as the fraction of positions
+// in which the signatures differ. This is a metric on signatures, but only an
+// approximation of the Jaccard distance between the underlying sets, so it is
+// best combined with the approximate search modes and a final exact
+// re-ranking.
+func MinHashJaccard(a, b interface{}) float64 {
+	x, y := a.([]uint64), b.([]uint64)
+	checkLengths(len(x), len(y))
+
+	if len(x) == 0 {
+		return 0
+	}
+
+	differ := 0
+	for i := range x {
+		if x[i] != y[i] {
+			differ++
+		}
+	}
+
+	return float64(differ) / float64(len(x))
+}
+
+// mix64 is the finalizer of SplitMix64, a fast hash function with good
+// avalanche behaviour.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
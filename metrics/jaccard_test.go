@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+func randomIDSet(rng *rand.Rand, universe, size int) []uint64 {
+	seen := make(map[uint64]bool)
+	for len(seen) < size {
+		seen[uint64(rng.Intn(universe))] = true
+	}
+
+	ids := make([]uint64, 0, size)
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func toStringSet(ids []uint64) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, id := range ids {
+		set[fmt.Sprint(id)] = struct{}{}
+	}
+	return set
+}
+
+// bruteForceJaccard computes the Jaccard distance with explicit set
+// operations
+func bruteForceJaccard(x, y []uint64) float64 {
+	union := make(map[uint64]bool)
+	inX := make(map[uint64]bool)
+	for _, id := range x {
+		union[id] = true
+		inX[id] = true
+	}
+
+	intersection := 0
+	for _, id := range y {
+		if inX[id] {
+			intersection++
+		}
+		union[id] = true
+	}
+
+	if len(union) == 0 {
+		return 0
+	}
+	return 1 - float64(intersection)/float64(len(union))
+}
+
+// This test compares both Jaccard distances against explicit set operations
+func TestJaccardAgainstBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		x := randomIDSet(rng, 50, rng.Intn(20))
+		y := randomIDSet(rng, 50, rng.Intn(20))
+		expected := bruteForceJaccard(x, y)
+
+		if d := JaccardSorted(x, y); math.Abs(d-expected) > 1e-12 {
+			t.Errorf("JaccardSorted(%v, %v): expected %v, got %v", x, y, expected, d)
+		}
+
+		if d := Jaccard(toStringSet(x), toStringSet(y)); math.Abs(d-expected) > 1e-12 {
+			t.Errorf("Jaccard(%v, %v): expected %v, got %v", x, y, expected, d)
+		}
+	}
+
+	if d := JaccardSorted([]uint64{}, []uint64{}); d != 0 {
+		t.Errorf("Expected the distance between empty sets to be 0, got %v", d)
+	}
+}
+
+// This test makes sure the exact Jaccard distance passes CheckMetric
+func TestJaccardIsMetric(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	var items []interface{}
+	for i := 0; i < 25; i++ {
+		items = append(items, randomIDSet(rng, 30, rng.Intn(10)+1))
+	}
+
+	if err := vptree.CheckMetric(JaccardSorted, items); err != nil {
+		t.Errorf("Expected JaccardSorted to pass CheckMetric, got %v", err)
+	}
+}
+
+// This test makes sure MinHash signatures estimate the Jaccard distance
+// within the expected error
+func TestMinHashJaccard(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	m := NewMinHasher(256, 1)
+
+	for i := 0; i < 20; i++ {
+		x := randomIDSet(rng, 2000, 500)
+		y := randomIDSet(rng, 2000, 500)
+
+		expected := JaccardSorted(x, y)
+		estimate := MinHashJaccard(m.Signature(x), m.Signature(y))
+
+		// Four standard errors
+		if math.Abs(estimate-expected) > 4/(2*math.Sqrt(256)) {
+			t.Errorf("Expected an estimate close to %v, got %v", expected, estimate)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test compares the kernels against sequential sums over vectors of
+// varying length, including lengths that are not a multiple of 4
+func TestKernelsAgainstScalar(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for dim := 0; dim <= 1536; dim += 1 + dim/4 {
+		x, y := randomVector32(rng, dim), randomVector32(rng, dim)
+
+		sqL2, dot := 0.0, 0.0
+		for i := range x {
+			d := float64(x[i]) - float64(y[i])
+			sqL2 += d * d
+			dot += float64(x[i]) * float64(y[i])
+		}
+
+		if d := sqL2Float32(x, y); math.Abs(d-sqL2) > kernelEpsilon*math.Abs(sqL2) {
+			t.Errorf("sqL2Float32 with %v dimensions: expected %v, got %v", dim, sqL2, d)
+		}
+
+		if d := dotFloat32(x, y); math.Abs(d-dot) > kernelEpsilon*math.Abs(dot) {
+			t.Errorf("dotFloat32 with %v dimensions: expected %v, got %v", dim, dot, d)
+		}
+	}
+}
+
+func benchmarkKernel(b *testing.B, kernel func(x, y []float32) float64, dim int) {
+	rng := rand.New(rand.NewSource(1))
+	x, y := randomVector32(rng, dim), randomVector32(rng, dim)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		kernel(x, y)
+	}
+}
+
+func BenchmarkSqL2Float32_64(b *testing.B)   { benchmarkKernel(b, sqL2Float32, 64) }
+func BenchmarkSqL2Float32_384(b *testing.B)  { benchmarkKernel(b, sqL2Float32, 384) }
+func BenchmarkSqL2Float32_1536(b *testing.B) { benchmarkKernel(b, sqL2Float32, 1536) }
+func BenchmarkDotFloat32_64(b *testing.B)    { benchmarkKernel(b, dotFloat32, 64) }
+func BenchmarkDotFloat32_384(b *testing.B)   { benchmarkKernel(b, dotFloat32, 384) }
+func BenchmarkDotFloat32_1536(b *testing.B)  { benchmarkKernel(b, dotFloat32, 1536) }
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"math"
+
+	"github.com/DataWraith/vptree"
+)
+
+// Levenshtein is the edit distance between two strings: the minimum number
+// of single-character insertions, deletions and substitutions needed to turn
+// one into the other. Characters are runes, so a multi-byte UTF-8 character
+// counts as one edit.
+func Levenshtein(a, b interface{}) float64 {
+	return float64(levenshtein([]rune(a.(string)), []rune(b.(string)), -1))
+}
+
+// LevenshteinBytes is like Levenshtein, but treats the strings as sequences
+// of bytes. It is faster, but counts an edit of a multi-byte UTF-8 character
+// as several edits.
+func LevenshteinBytes(a, b interface{}) float64 {
+	return float64(levenshtein([]byte(a.(string)), []byte(b.(string)), -1))
+}
+
+// LevenshteinBounded returns a variant of Levenshtein that gives up as soon
+// as the distance is known to exceed limit, and returns limit+1 in that case.
+// The result, min(Levenshtein, limit+1), is still a metric.
+func LevenshteinBounded(limit int) vptree.Metric {
+	return func(a, b interface{}) float64 {
+		return float64(levenshtein([]rune(a.(string)), []rune(b.(string)), limit))
+	}
+}
+
+// LevenshteinWithin is Levenshtein as a vptree.BoundedMetricFunc: it gives
+// up as soon as the distance is known to exceed upper. Passing it to
+// vptree.WithBoundedMetric makes every search use its current bound as the
+// limit:
+//
+//	tree := vptree.New(metrics.Levenshtein, words,
+//		vptree.WithBoundedMetric(vptree.BoundedMetricFunc(metrics.LevenshteinWithin)))
+func LevenshteinWithin(a, b interface{}, upper float64) float64 {
+	limit := -1
+	if upper < math.MaxInt32 {
+		limit = int(math.Floor(upper))
+	}
+	return float64(levenshtein([]rune(a.(string)), []rune(b.(string)), limit))
+}
+
+// levenshtein computes the edit distance between a and b. If limit is not
+// negative, it returns limit+1 as soon as the distance is known to exceed
+// limit.
+func levenshtein[T rune | byte](a, b []T, limit int) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+
+	// The distance is at least the difference in length
+	if limit >= 0 && len(a)-len(b) > limit {
+		return limit + 1
+	}
+
+	// prev and cur are two rows of the dynamic programming matrix
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+
+		// Values in the matrix never decrease from one row to the
+		// next, so once a whole row exceeds the limit, so does the
+		// result
+		if limit >= 0 && rowMin > limit {
+			return limit + 1
+		}
+
+		prev, cur = cur, prev
+	}
+
+	if limit >= 0 && prev[len(b)] > limit {
+		return limit + 1
+	}
+
+	return prev[len(b)]
+}
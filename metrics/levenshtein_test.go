@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+// referenceLevenshtein fills the full dynamic programming matrix
+func referenceLevenshtein(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+var alphabet = []rune("abcdeäöü日本")
+
+func randomString(rng *rand.Rand, maxLen int) string {
+	r := make([]rune, rng.Intn(maxLen+1))
+	for i := range r {
+		r[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(r)
+}
+
+// This test compares Levenshtein and LevenshteinBounded against the full
+// matrix computation on random strings
+func TestLevenshteinAgainstReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		a, b := randomString(rng, 12), randomString(rng, 12)
+		expected := referenceLevenshtein([]rune(a), []rune(b))
+
+		if d := Levenshtein(a, b); d != float64(expected) {
+			t.Errorf("Levenshtein(%q, %q): expected %v, got %v", a, b, expected, d)
+		}
+
+		for limit := 0; limit <= 12; limit++ {
+			bounded := min(expected, limit+1)
+			if d := LevenshteinBounded(limit)(a, b); d != float64(bounded) {
+				t.Errorf("LevenshteinBounded(%v)(%q, %q): expected %v, got %v", limit, a, b, bounded, d)
+			}
+
+			if d := LevenshteinWithin(a, b, float64(limit)+0.5); d != float64(bounded) {
+				t.Errorf("LevenshteinWithin(%q, %q, %v): expected %v, got %v", a, b, float64(limit)+0.5, bounded, d)
+			}
+		}
+	}
+}
+
+// This test pins the difference between runes and bytes
+func TestLevenshteinUnicode(t *testing.T) {
+	if d := Levenshtein("naïve", "naive"); d != 1 {
+		t.Errorf("Expected one rune edit, got %v", d)
+	}
+
+	if d := LevenshteinBytes("naïve", "naive"); d != 2 {
+		t.Errorf("Expected two byte edits, got %v", d)
+	}
+}
+
+// This test makes sure the Levenshtein metrics satisfy the metric properties
+func TestLevenshteinMetricProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	seen := make(map[string]bool)
+	var items []interface{}
+	for len(items) < 20 {
+		if s := randomString(rng, 6); !seen[s] {
+			seen[s] = true
+			items = append(items, s)
+		}
+	}
+
+	checkMetricProperties(t, "Levenshtein", Levenshtein, items)
+	checkMetricProperties(t, "LevenshteinBounded(2)", LevenshteinBounded(2), items)
+}
+
+// dictionary returns n random lowercase words
+func dictionary(rng *rand.Rand, n int) []interface{} {
+	words := make([]interface{}, n)
+	for i := range words {
+		w := make([]byte, 4+rng.Intn(8))
+		for j := range w {
+			w[j] = byte('a' + rng.Intn(26))
+		}
+		words[i] = string(w)
+	}
+	return words
+}
+
+// typo changes one random letter of word
+func typo(rng *rand.Rand, word string) string {
+	w := []byte(word)
+	w[rng.Intn(len(w))] = byte('a' + rng.Intn(26))
+	return string(w)
+}
+
+func BenchmarkLevenshtein(b *testing.B) {
+	benchmarkMetric(b, Levenshtein, "kitten sitting", "sitting kitten")
+}
+
+func BenchmarkLevenshteinDictionarySearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	words := dictionary(rng, 100000)
+	tree := vptree.New(Levenshtein, words)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(typo(rng, words[rng.Intn(len(words))].(string)), 1)
+	}
+}
+
+func BenchmarkLevenshteinWithinDictionarySearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	words := dictionary(rng, 100000)
+	tree := vptree.New(Levenshtein, words, vptree.WithBoundedMetric(vptree.BoundedMetricFunc(LevenshteinWithin)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(typo(rng, words[rng.Intn(len(words))].(string)), 1)
+	}
+}
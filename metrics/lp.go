@@ -0,0 +1,147 @@
+// Package metrics provides ready-made distance functions for common item
+// types. Every function in this package is a metric in the mathematical
+// sense and can be passed to vptree.New directly.
+//
+// Vector metrics panic if the two vectors differ in length, since such a
+// comparison is always a programming error and a silently wrong distance
+// would corrupt the tree.
+package metrics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/DataWraith/vptree"
+)
+
+func checkLengths(la, lb int) {
+	if la != lb {
+		panic(fmt.Sprintf("metrics: vectors of different lengths (%d and %d)", la, lb))
+	}
+}
+
+// Euclidean is the Euclidean (L2) distance between two []float64 vectors.
+func Euclidean(a, b interface{}) float64 {
+	x, y := a.([]float64), b.([]float64)
+	checkLengths(len(x), len(y))
+
+	sum := 0.0
+	for i := range x {
+		d := x[i] - y[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// Euclidean32 is the Euclidean (L2) distance between two []float32 vectors.
+func Euclidean32(a, b interface{}) float64 {
+	x, y := a.([]float32), b.([]float32)
+	checkLengths(len(x), len(y))
+
+	return math.Sqrt(sqL2Float32(x, y))
+}
+
+// Manhattan is the Manhattan (L1) distance between two []float64 vectors.
+func Manhattan(a, b interface{}) float64 {
+	x, y := a.([]float64), b.([]float64)
+	checkLengths(len(x), len(y))
+
+	sum := 0.0
+	for i := range x {
+		sum += math.Abs(x[i] - y[i])
+	}
+	return sum
+}
+
+// Manhattan32 is the Manhattan (L1) distance between two []float32 vectors.
+func Manhattan32(a, b interface{}) float64 {
+	x, y := a.([]float32), b.([]float32)
+	checkLengths(len(x), len(y))
+
+	sum := 0.0
+	for i := range x {
+		sum += math.Abs(float64(x[i]) - float64(y[i]))
+	}
+	return sum
+}
+
+// Chebyshev is the Chebyshev (L∞) distance between two []float64 vectors.
+func Chebyshev(a, b interface{}) float64 {
+	x, y := a.([]float64), b.([]float64)
+	checkLengths(len(x), len(y))
+
+	max := 0.0
+	for i := range x {
+		if d := math.Abs(x[i] - y[i]); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// Chebyshev32 is the Chebyshev (L∞) distance between two []float32 vectors.
+func Chebyshev32(a, b interface{}) float64 {
+	x, y := a.([]float32), b.([]float32)
+	checkLengths(len(x), len(y))
+
+	max := 0.0
+	for i := range x {
+		if d := math.Abs(float64(x[i]) - float64(y[i])); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// Minkowski returns the Minkowski (Lp) distance between two []float64
+// vectors. The Lp distance is only a metric for p >= 1, so Minkowski panics
+// for smaller p. Use Manhattan, Euclidean or Chebyshev for p = 1, 2 or ∞;
+// Minkowski returns those for these values of p.
+func Minkowski(p float64) vptree.Metric {
+	switch {
+	case p < 1 || math.IsNaN(p):
+		panic(fmt.Sprintf("metrics: Minkowski distance is not a metric for p = %v", p))
+	case p == 1:
+		return Manhattan
+	case p == 2:
+		return Euclidean
+	case math.IsInf(p, 1):
+		return Chebyshev
+	}
+
+	return func(a, b interface{}) float64 {
+		x, y := a.([]float64), b.([]float64)
+		checkLengths(len(x), len(y))
+
+		sum := 0.0
+		for i := range x {
+			sum += math.Pow(math.Abs(x[i]-y[i]), p)
+		}
+		return math.Pow(sum, 1/p)
+	}
+}
+
+// Minkowski32 is like Minkowski, but for []float32 vectors.
+func Minkowski32(p float64) vptree.Metric {
+	switch {
+	case p < 1 || math.IsNaN(p):
+		panic(fmt.Sprintf("metrics: Minkowski distance is not a metric for p = %v", p))
+	case p == 1:
+		return Manhattan32
+	case p == 2:
+		return Euclidean32
+	case math.IsInf(p, 1):
+		return Chebyshev32
+	}
+
+	return func(a, b interface{}) float64 {
+		x, y := a.([]float32), b.([]float32)
+		checkLengths(len(x), len(y))
+
+		sum := 0.0
+		for i := range x {
+			sum += math.Pow(math.Abs(float64(x[i])-float64(y[i])), p)
+		}
+		return math.Pow(sum, 1/p)
+	}
+}
@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+func randomVector(rng *rand.Rand, dim int) []float64 {
+	v := make([]float64, dim)
+	for i := range v {
+		v[i] = rng.NormFloat64()
+	}
+	return v
+}
+
+func randomVector32(rng *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return v
+}
+
+func to32(v []float64) []float32 {
+	w := make([]float32, len(v))
+	for i := range v {
+		w[i] = float32(v[i])
+	}
+	return w
+}
+
+// This helper function checks the metric properties on all triples of
+// items, allowing for a small relative rounding error in the triangle
+// inequality.
+func checkMetricProperties(t *testing.T, name string, m vptree.Metric, items []interface{}) {
+	t.Helper()
+
+	for i, x := range items {
+		if d := m(x, x); d != 0 {
+			t.Errorf("%v: expected d(x, x) to be 0, got %v", name, d)
+		}
+
+		for j, y := range items {
+			dxy := m(x, y)
+			if dxy < 0 {
+				t.Errorf("%v: expected d(x, y) >= 0, got %v", name, dxy)
+			}
+			if i != j && dxy == 0 {
+				t.Errorf("%v: expected d(x, y) > 0 for distinct items %v and %v", name, x, y)
+			}
+			if dyx := m(y, x); dxy != dyx {
+				t.Errorf("%v: expected d(x, y) = d(y, x), got %v and %v", name, dxy, dyx)
+			}
+
+			for _, z := range items {
+				if dxz, dyz := m(x, z), m(y, z); dxz > (dxy+dyz)*(1+1e-12) {
+					t.Errorf("%v: triangle inequality violated: d(x, z) = %v > d(x, y) + d(y, z) = %v", name, dxz, dxy+dyz)
+				}
+			}
+		}
+	}
+}
+
+// This test compares the Lp metrics against their textbook formulas
+func TestLpAgainstNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	naive := func(x, y []float64, p float64) float64 {
+		if math.IsInf(p, 1) {
+			max := 0.0
+			for i := range x {
+				max = math.Max(max, math.Abs(x[i]-y[i]))
+			}
+			return max
+		}
+
+		sum := 0.0
+		for i := range x {
+			sum += math.Pow(math.Abs(x[i]-y[i]), p)
+		}
+		return math.Pow(sum, 1/p)
+	}
+
+	for i := 0; i < 100; i++ {
+		dim := rng.Intn(50) + 1
+		x, y := randomVector(rng, dim), randomVector(rng, dim)
+
+		for _, p := range []float64{1, 1.5, 2, 3, math.Inf(1)} {
+			expected := naive(x, y, p)
+
+			if d := Minkowski(p)(x, y); math.Abs(d-expected) > 1e-9*expected {
+				t.Errorf("Expected L%v distance %v, got %v", p, expected, d)
+			}
+
+			x32, y32 := to32(x), to32(y)
+			expected32 := naive(float32To64(x32), float32To64(y32), p)
+			if d := Minkowski32(p)(x32, y32); math.Abs(d-expected32) > 1e-9*expected32 {
+				t.Errorf("Expected float32 L%v distance %v, got %v", p, expected32, d)
+			}
+		}
+	}
+}
+
+func float32To64(v []float32) []float64 {
+	w := make([]float64, len(v))
+	for i := range v {
+		w[i] = float64(v[i])
+	}
+	return w
+}
+
+// This test makes sure the Lp metrics satisfy the metric properties
+func TestLpMetricProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	var items, items32 []interface{}
+	for i := 0; i < 20; i++ {
+		v := randomVector(rng, 5)
+		items = append(items, v)
+		items32 = append(items32, to32(v))
+	}
+
+	checkMetricProperties(t, "Euclidean", Euclidean, items)
+	checkMetricProperties(t, "Manhattan", Manhattan, items)
+	checkMetricProperties(t, "Chebyshev", Chebyshev, items)
+	checkMetricProperties(t, "Minkowski(3)", Minkowski(3), items)
+	checkMetricProperties(t, "Euclidean32", Euclidean32, items32)
+	checkMetricProperties(t, "Manhattan32", Manhattan32, items32)
+	checkMetricProperties(t, "Chebyshev32", Chebyshev32, items32)
+	checkMetricProperties(t, "Minkowski32(3)", Minkowski32(3), items32)
+}
+
+// This test makes sure mismatched lengths and invalid p values panic
+func TestLpPanics(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%v: expected a panic", name)
+			}
+		}()
+		f()
+	}
+
+	expectPanic("Euclidean", func() { Euclidean([]float64{1, 2}, []float64{1}) })
+	expectPanic("Manhattan32", func() { Manhattan32([]float32{1}, []float32{}) })
+	expectPanic("Minkowski(0.5)", func() { Minkowski(0.5) })
+}
+
+// This test makes sure the metrics can be used to build a VP-tree
+func TestLpWithVPTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+
+	var items []interface{}
+	for i := 0; i < 500; i++ {
+		items = append(items, randomVector(rng, 8))
+	}
+
+	tree := vptree.New(Manhattan, items)
+	target := randomVector(rng, 8)
+
+	_, distances := tree.Search(target, 1)
+
+	best := math.Inf(1)
+	for _, item := range items {
+		best = math.Min(best, Manhattan(item, target))
+	}
+
+	if len(distances) != 1 || distances[0] != best {
+		t.Errorf("Expected nearest distance %v, got %v", best, distances)
+	}
+}
+
+func benchmarkMetric(b *testing.B, m vptree.Metric, x, y interface{}) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m(x, y)
+	}
+}
+
+func BenchmarkEuclidean(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, Euclidean, randomVector(rng, 128), randomVector(rng, 128))
+}
+
+func BenchmarkEuclidean32(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, Euclidean32, randomVector32(rng, 128), randomVector32(rng, 128))
+}
+
+func BenchmarkManhattan(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, Manhattan, randomVector(rng, 128), randomVector(rng, 128))
+}
+
+func BenchmarkChebyshev(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, Chebyshev, randomVector(rng, 128), randomVector(rng, 128))
+}
+
+func BenchmarkMinkowski3(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	benchmarkMetric(b, Minkowski(3), randomVector(rng, 128), randomVector(rng, 128))
+}
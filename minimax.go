@@ -0,0 +1,85 @@
+package vptree
+
+import (
+	"container/heap"
+	"math"
+)
+
+// MinimaxDistance approximates the minimax path distance between a and b:
+// the smallest possible largest step of a path from a to b through the
+// items of the VP-tree. The paths are restricted to the k-nearest-neighbour
+// graph, in which every item, as well as a and b, is connected to its k
+// nearest items, and a is connected to b directly if b is no farther than
+// a's or b's k-th nearest item. The minimax distance is then found with a
+// variant of Dijkstra's algorithm that minimizes the largest edge weight
+// instead of the sum. If b is not reachable from a, MinimaxDistance returns
+// +Inf.
+//
+// Building the neighbour graph takes a k-nearest-neighbour search for every
+// item, so each call costs about as much as AllKthNearestDistances.
+func (vp *VPTree) MinimaxDistance(a, b interface{}, k int) float64 {
+	count := vp.itemCount()
+	neighbours, distances := vp.neighbourGraph(k, 0)
+
+	// The items are the vertices 0 to count-1, a is vertex count and b is
+	// vertex count+1
+	type edge struct {
+		to   int
+		dist float64
+	}
+	adjacent := make([][]edge, count+2)
+	connect := func(u, v int, dist float64) {
+		adjacent[u] = append(adjacent[u], edge{v, dist})
+		adjacent[v] = append(adjacent[v], edge{u, dist})
+	}
+
+	for u := range neighbours {
+		for i, v := range neighbours[u] {
+			connect(u, v, distances[u][i])
+		}
+	}
+
+	// Connect a and b to the graph, and to each other if they are close
+	// enough
+	direct := vp.distanceMetric(a, b)
+	isNeighbour := false
+	for i, target := range []interface{}{a, b} {
+		indices, dists := vp.SearchIndices(target, k)
+		for j, idx := range indices {
+			connect(count+i, idx, dists[j])
+		}
+		if len(dists) < k || direct <= dists[len(dists)-1] {
+			isNeighbour = true
+		}
+	}
+	if isNeighbour {
+		connect(count, count+1, direct)
+	}
+
+	best := make([]float64, count+2)
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	best[count] = 0
+
+	pq := &MinDistanceHeap{{Index: count}}
+	for pq.Len() > 0 {
+		u := heap.Pop(pq).(Neighbour)
+		if u.Distance > best[u.Index] {
+			// Stale entry
+			continue
+		}
+		if u.Index == count+1 {
+			break
+		}
+
+		for _, e := range adjacent[u.Index] {
+			if d := math.Max(u.Distance, e.dist); d < best[e.to] {
+				best[e.to] = d
+				heap.Push(pq, Neighbour{Index: e.to, Distance: d})
+			}
+		}
+	}
+
+	return best[count+1]
+}
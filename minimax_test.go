@@ -0,0 +1,39 @@
+package vptree
+
+import (
+	"math"
+	"testing"
+)
+
+// This test checks MinimaxDistance on two clusters of points on a line,
+// where the widest gap on the way decides the distance
+func TestMinimaxDistance(t *testing.T) {
+	var items []interface{}
+	for _, x := range []float64{0, 1, 2, 3, 10, 11, 12.5, 13} {
+		items = append(items, Coordinate{X: x})
+	}
+	vp := New(CoordinateMetric, items)
+
+	cases := []struct {
+		a, b     Coordinate
+		k        int
+		expected float64
+	}{
+		// Within a cluster, the steps are 1 apart
+		{Coordinate{X: 0}, Coordinate{X: 3}, 2, 1},
+		// Between the clusters, the gap of 7 must be crossed
+		{Coordinate{X: 0}, Coordinate{X: 13}, 4, 7},
+		// With too few neighbours, the clusters aren't connected
+		{Coordinate{X: 0}, Coordinate{X: 13}, 2, math.Inf(1)},
+		// Targets that aren't items are connected to their neighbours
+		{Coordinate{X: 1.5}, Coordinate{X: 12}, 4, 7},
+		// Close targets are connected directly
+		{Coordinate{X: 5}, Coordinate{X: 5.5}, 1, 0.5},
+	}
+
+	for _, c := range cases {
+		if d := vp.MinimaxDistance(c.a, c.b, c.k); d != c.expected {
+			t.Errorf("MinimaxDistance(%v, %v, %v): expected %v, got %v", c.a, c.b, c.k, c.expected, d)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package vptree
+
+import (
+	"container/heap"
+	"math"
+)
+
+// MutualReachabilityMST returns a minimum spanning tree of the items under
+// the mutual reachability distance used by HDBSCAN,
+//
+//	max(core(a), core(b), d(a, b)),
+//
+// where the core distance core(x) is KthNearestDistance(x, k), counting x as
+// its own nearest neighbour. Each of the n-1 edges is returned as
+// [from, to, distance], with the items at from and to and the distance as a
+// float64.
+//
+// The tree is grown with Prim's algorithm starting at an arbitrary item.
+// Rather than scanning a dense distance matrix, every step finds the nearest
+// item outside of the spanning tree with a VP-tree search that skips subtrees
+// whose items are all in the spanning tree already. On spill trees, the core
+// distances are only as good as their approximate k-NN searches, but the
+// spanning tree is still minimal for those core distances.
+func (vp *VPTree) MutualReachabilityMST(k int) (edges [][3]interface{}) {
+	if vp.root == nil {
+		return
+	}
+
+	vp.prim(vp.AllKthNearestDistances(k, 0), func(e mstEdge) {
+		edges = append(edges, [3]interface{}{e.from.Item, e.to.Item, e.dist})
+	})
+
+	return
+}
+
+// MinimumSpanningTree returns a minimum spanning tree of the items under the
+// VP-tree's metric, as its n-1 edges between pairs of items. It is grown
+// like MutualReachabilityMST, with every step of Prim's algorithm finding
+// the nearest item outside of the spanning tree with a VP-tree search
+// instead of a scan of all items.
+func (vp *VPTree) MinimumSpanningTree() (edges [][2]interface{}) {
+	if vp.root == nil {
+		return
+	}
+
+	// With core distances of zero, the mutual reachability distance is
+	// the distance itself
+	vp.prim(make([]float64, vp.itemCount()), func(e mstEdge) {
+		edges = append(edges, [2]interface{}{e.from.Item, e.to.Item})
+	})
+
+	return
+}
+
+// prim grows a minimum spanning tree of the items under the mutual
+// reachability distance for the core distances core, and calls add with
+// every edge added to it.
+func (vp *VPTree) prim(core []float64, add func(e mstEdge)) {
+	m := newMSTState(vp, core)
+
+	var start *node
+	vp.forEachNode(func(n *node) {
+		if start == nil && vp.live(n) {
+			start = n
+		}
+	})
+	if start == nil {
+		return
+	}
+
+	var pq edgeQueue
+	m.visit(start)
+	if e := m.nearestOutside(start); e.to != nil {
+		heap.Push(&pq, e)
+	}
+
+	for pq.Len() > 0 {
+		e := heap.Pop(&pq).(mstEdge)
+
+		if !m.visited[e.to.Index] {
+			m.visit(e.to)
+			add(e)
+
+			if next := m.nearestOutside(e.to); next.to != nil {
+				heap.Push(&pq, next)
+			}
+		}
+
+		// The nearest item outside of the tree has changed, or is the
+		// one we just added
+		if next := m.nearestOutside(e.from); next.to != nil {
+			heap.Push(&pq, next)
+		}
+	}
+}
+
+type mstEdge struct {
+	from, to *node
+	dist     float64
+}
+
+// mstState tracks which items are in the spanning tree, and how many items
+// outside of it are left in every subtree.
+type mstState struct {
+	vp          *VPTree
+	core        []float64
+	visited     []bool
+	remaining   map[*node]int
+	parent      map[*node]*node
+	occurrences map[int][]*node
+}
+
+func newMSTState(vp *VPTree, core []float64) *mstState {
+	m := &mstState{
+		vp:          vp,
+		core:        core,
+		visited:     make([]bool, len(core)),
+		remaining:   make(map[*node]int),
+		parent:      make(map[*node]*node),
+		occurrences: make(map[int][]*node),
+	}
+	m.index(vp.root, nil)
+	return m
+}
+
+func (m *mstState) index(n, parent *node) int {
+	if n == nil {
+		return 0
+	}
+
+	m.parent[n] = parent
+	m.remaining[n] = m.index(n.Left, n) + m.index(n.Right, n)
+
+	// The items Upsert replaced count as spanned already
+	if !m.vp.live(n) {
+		m.visited[n.Index] = true
+		return m.remaining[n]
+	}
+
+	m.occurrences[n.Index] = append(m.occurrences[n.Index], n)
+	m.remaining[n]++
+	return m.remaining[n]
+}
+
+// visit adds the item of n to the spanning tree.
+func (m *mstState) visit(n *node) {
+	m.visited[n.Index] = true
+
+	// Spill trees may hold the item in more than one node
+	for _, o := range m.occurrences[n.Index] {
+		for a := o; a != nil; a = m.parent[a] {
+			m.remaining[a]--
+		}
+	}
+}
+
+// nearestOutside returns the edge from n to the nearest item outside of the
+// spanning tree under the mutual reachability distance. The returned edge
+// has a nil to if there is no such item.
+func (m *mstState) nearestOutside(n *node) mstEdge {
+	best := mstEdge{from: n, dist: math.Inf(1)}
+	m.search(m.vp.root, n, &best)
+	return best
+}
+
+func (m *mstState) search(n, from *node, best *mstEdge) {
+	if n == nil || m.remaining[n] == 0 {
+		return
+	}
+
+	dist := m.vp.distanceMetric(n.Item, from.Item)
+
+	if !m.visited[n.Index] {
+		if mr := math.Max(dist, math.Max(m.core[from.Index], m.core[n.Index])); mr < best.dist || best.to == nil {
+			best.to, best.dist = n, mr
+		}
+	}
+
+	// The mutual reachability distance is at least the distance, so
+	// best.dist bounds the search like tau does in a k-NN search. The
+	// children of spill tree nodes overlap, which widens their bounds.
+	leftBound, rightBound := n.Threshold*(1+m.vp.spill), n.Threshold*(1-m.vp.spill)
+
+	if dist-best.dist <= leftBound {
+		m.search(n.Left, from, best)
+	}
+
+	if dist+best.dist >= rightBound {
+		m.search(n.Right, from, best)
+	}
+}
+
+// edgeQueue is a min-heap of edges by distance.
+type edgeQueue []mstEdge
+
+func (q edgeQueue) Len() int { return len(q) }
+
+func (q edgeQueue) Less(i, j int) bool {
+	return q[i].dist < q[j].dist
+}
+
+func (q edgeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *edgeQueue) Push(x interface{}) {
+	*q = append(*q, x.(mstEdge))
+}
+
+func (q *edgeQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[0 : n-1]
+	return item
+}
@@ -0,0 +1,138 @@
+package vptree
+
+import (
+	"math"
+	"testing"
+)
+
+// bruteForceMSTWeight returns the weight of a minimum spanning tree under the
+// mutual reachability distance with the given core distances, using Prim's
+// algorithm on the dense graph
+func bruteForceMSTWeight(items []Coordinate, core []float64) float64 {
+	mr := func(i, j int) float64 {
+		return math.Max(CoordinateMetric(items[i], items[j]), math.Max(core[i], core[j]))
+	}
+
+	inTree := make([]bool, len(items))
+	best := make([]float64, len(items))
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	best[0] = 0
+
+	weight := 0.0
+	for range items {
+		next := -1
+		for i := range items {
+			if !inTree[i] && (next < 0 || best[i] < best[next]) {
+				next = i
+			}
+		}
+
+		inTree[next] = true
+		weight += best[next]
+
+		for i := range items {
+			if !inTree[i] {
+				best[i] = math.Min(best[i], mr(next, i))
+			}
+		}
+	}
+
+	return weight
+}
+
+// This test compares the weight of the MutualReachabilityMST against a
+// brute-force computation, and makes sure it spans all items
+func TestMutualReachabilityMST(t *testing.T) {
+	coords, items := randomCoordinates(300)
+
+	for _, vp := range []*VPTree{New(CoordinateMetric, items), NewSpillTree(CoordinateMetric, items, 0.2)} {
+		for _, k := range []int{1, 5} {
+			edges := vp.MutualReachabilityMST(k)
+
+			if len(edges) != len(items)-1 {
+				t.Fatalf("Expected %v edges, got %v", len(items)-1, len(edges))
+			}
+
+			// Union-find to check that the edges connect all items
+			parent := make(map[Coordinate]Coordinate)
+			var find func(c Coordinate) Coordinate
+			find = func(c Coordinate) Coordinate {
+				if p, ok := parent[c]; ok && p != c {
+					return find(p)
+				}
+				return c
+			}
+
+			weight := 0.0
+			for _, e := range edges {
+				a, b := find(e[0].(Coordinate)), find(e[1].(Coordinate))
+				if a == b {
+					t.Fatalf("Edge %v closes a cycle", e)
+				}
+				parent[a] = b
+				weight += e[2].(float64)
+			}
+
+			// Spill trees only approximate the core distances, so
+			// they are taken from the tree rather than computed
+			core := vp.AllKthNearestDistances(k, 0)
+			if vp.spill == 0 {
+				for i, x := range coords {
+					_, dists := nearestNeighbours(x, coords, k)
+					core[i] = dists[k-1]
+				}
+			}
+
+			if expected := bruteForceMSTWeight(coords, core); math.Abs(weight-expected) > 1e-9 {
+				t.Errorf("Expected a total weight of %v for k = %v, got %v", expected, k, weight)
+			}
+		}
+	}
+
+	if edges := New(CoordinateMetric, nil).MutualReachabilityMST(3); len(edges) != 0 {
+		t.Errorf("Expected no edges for an empty tree, got %v", edges)
+	}
+}
+
+// This test compares the weight of the MinimumSpanningTree against a
+// brute-force computation, and makes sure it spans all items
+func TestMinimumSpanningTree(t *testing.T) {
+	coords, items := randomCoordinates(300)
+
+	for _, vp := range []*VPTree{New(CoordinateMetric, items), NewSpillTree(CoordinateMetric, items, 0.2)} {
+		edges := vp.MinimumSpanningTree()
+
+		if len(edges) != len(items)-1 {
+			t.Fatalf("Expected %v edges, got %v", len(items)-1, len(edges))
+		}
+
+		parent := make(map[Coordinate]Coordinate)
+		var find func(c Coordinate) Coordinate
+		find = func(c Coordinate) Coordinate {
+			if p, ok := parent[c]; ok && p != c {
+				return find(p)
+			}
+			return c
+		}
+
+		weight := 0.0
+		for _, e := range edges {
+			a, b := find(e[0].(Coordinate)), find(e[1].(Coordinate))
+			if a == b {
+				t.Fatalf("Edge %v closes a cycle", e)
+			}
+			parent[a] = b
+			weight += CoordinateMetric(e[0], e[1])
+		}
+
+		if expected := bruteForceMSTWeight(coords, make([]float64, len(coords))); math.Abs(weight-expected) > 1e-9 {
+			t.Errorf("Expected a total weight of %v, got %v", expected, weight)
+		}
+	}
+
+	if edges := New(CoordinateMetric, nil).MinimumSpanningTree(); len(edges) != 0 {
+		t.Errorf("Expected no edges for an empty tree, got %v", edges)
+	}
+}
@@ -0,0 +1,218 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// A Fusion combines the results of the individual VP-trees of a MultiIndex
+// into a single score for an item. For every metric i, ranks[i] is the
+// (0-based) rank of the item in that metric's result list, or -1 if the item
+// was not among them, and dists[i] is the distance of the item to the target
+// under that metric. Items with lower scores are ranked first.
+//
+// The score must not decrease when a distance grows or an item drops out of
+// a result list, as MultiIndex.Search relies on that to tell when the result
+// lists are long enough.
+type Fusion func(ranks []int, dists []float64) float64
+
+// ReciprocalRankFusion returns a Fusion that scores items by the sum of
+// 1/(c+rank) over all result lists the item appears in, where rank starts at
+// 1. The sum is negated, so that items appearing near the top of many lists
+// come first. A common choice for c is 60.
+func ReciprocalRankFusion(c float64) Fusion {
+	return func(ranks []int, dists []float64) float64 {
+		score := 0.0
+		for _, r := range ranks {
+			if r >= 0 {
+				score -= 1 / (c + float64(r+1))
+			}
+		}
+		return score
+	}
+}
+
+// WeightedSum returns a Fusion that scores items by the weighted sum of their
+// distances under the individual metrics. It panics unless the weights are
+// finite and non-negative, and the Fusion panics if there is not one weight
+// per metric.
+func WeightedSum(weights []float64) Fusion {
+	for _, w := range weights {
+		if !(w >= 0) || math.IsInf(w, 1) {
+			panic(fmt.Sprintf("vptree: WeightedSum: weight %v is not finite and non-negative", w))
+		}
+	}
+
+	return func(ranks []int, dists []float64) float64 {
+		if len(dists) != len(weights) {
+			panic(fmt.Sprintf("vptree: WeightedSum: %d weights for %d metrics", len(weights), len(dists)))
+		}
+
+		score := 0.0
+		for i, d := range dists {
+			score += weights[i] * d
+		}
+		return score
+	}
+}
+
+// A MultiIndex holds one VP-tree per metric over the same set of items and
+// answers queries by fusing the rankings of the individual trees.
+type MultiIndex struct {
+	items   []interface{}
+	metrics []Metric
+	trees   []*VPTree
+	fusion  Fusion
+}
+
+// NewMultiIndex creates a MultiIndex that indexes items under each of the
+// given metrics and merges the per-metric results using fusion.
+func NewMultiIndex(items []interface{}, metrics []Metric, fusion Fusion) *MultiIndex {
+	mi := &MultiIndex{
+		items:   items,
+		metrics: metrics,
+		trees:   make([]*VPTree, len(metrics)),
+		fusion:  fusion,
+	}
+
+	for i, m := range metrics {
+		mi.trees[i] = New(m, items)
+	}
+
+	return mi
+}
+
+// Search queries every VP-tree of the MultiIndex for the nearest neighbours
+// of target in parallel, and returns the k best items of the union of the
+// results according to the Fusion, together with their scores, in order of
+// increasing score.
+//
+// The trees are first queried for k neighbours each. An item missing from
+// all result lists is at least as far from the target as the last item of
+// every list, so while it could score better than the k-th best item of the
+// union, Search doubles the number of neighbours and queries the trees
+// again. The results of WeightedSum are thus exact, while
+// ReciprocalRankFusion only ranks the items of the first k of every list.
+func (mi *MultiIndex) Search(target interface{}, k int) (results []interface{}, scores []float64) {
+	indices, scores := mi.SearchIndices(target, k)
+	for _, idx := range indices {
+		results = append(results, mi.items[idx])
+	}
+	return
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (mi *MultiIndex) SearchIndices(target interface{}, k int) (indices []int, scores []float64) {
+	if k < 1 {
+		return
+	}
+
+	// unseen is the ranks of an item missing from all result lists
+	unseen := make([]int, len(mi.trees))
+	for i := range unseen {
+		unseen[i] = -1
+	}
+
+	var fused []fusedItem
+	for n := k; ; n *= 2 {
+		lists := make([][]int, len(mi.trees))
+		dists := make([][]float64, len(mi.trees))
+
+		var wg sync.WaitGroup
+		for i, t := range mi.trees {
+			wg.Add(1)
+			go func(i int, t *VPTree) {
+				lists[i], dists[i] = t.SearchIndices(target, n)
+				wg.Done()
+			}(i, t)
+		}
+		wg.Wait()
+
+		fused = mi.fuse(target, lists, dists)
+
+		// A short list holds every item, and so does the union
+		last := make([]float64, len(mi.trees))
+		complete := false
+		for i, list := range lists {
+			if len(list) < n {
+				complete = true
+				break
+			}
+			last[i] = dists[i][n-1]
+		}
+
+		if complete || len(fused) < k || fused[k-1].score <= mi.fusion(unseen, last) {
+			break
+		}
+	}
+
+	if len(fused) > k {
+		fused = fused[:k]
+	}
+
+	for _, f := range fused {
+		indices = append(indices, f.index)
+		scores = append(scores, f.score)
+	}
+
+	return
+}
+
+type fusedItem struct {
+	index int
+	score float64
+}
+
+// fuse scores the union of the result lists of the trees, with the distances
+// in dists, and returns it in order of increasing score.
+func (mi *MultiIndex) fuse(target interface{}, lists [][]int, dists [][]float64) []fusedItem {
+	type candidate struct {
+		ranks []int
+		dists []float64
+	}
+
+	// Collect the union of all result lists, remembering the rank and
+	// distance of each item in the lists it appeared in.
+	candidates := make(map[int]*candidate)
+	for i, list := range lists {
+		for rank, idx := range list {
+			c, ok := candidates[idx]
+			if !ok {
+				c = &candidate{
+					ranks: make([]int, len(mi.trees)),
+					dists: make([]float64, len(mi.trees)),
+				}
+				for j := range c.ranks {
+					c.ranks[j] = -1
+				}
+				candidates[idx] = c
+			}
+			c.ranks[i] = rank
+			c.dists[i] = dists[i][rank]
+		}
+	}
+
+	fused := make([]fusedItem, 0, len(candidates))
+	for idx, c := range candidates {
+		// Items missing from a result list still need their distance
+		// under that metric
+		for i, r := range c.ranks {
+			if r < 0 {
+				c.dists[i] = mi.metrics[i](mi.items[idx], target)
+			}
+		}
+		fused = append(fused, fusedItem{idx, mi.fusion(c.ranks, c.dists)})
+	}
+
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].score != fused[j].score {
+			return fused[i].score < fused[j].score
+		}
+		return fused[i].index < fused[j].index
+	})
+
+	return fused
+}
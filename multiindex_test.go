@@ -0,0 +1,166 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func xMetric(a, b interface{}) float64 {
+	return math.Abs(a.(Coordinate).X - b.(Coordinate).X)
+}
+
+func yMetric(a, b interface{}) float64 {
+	return math.Abs(a.(Coordinate).Y - b.(Coordinate).Y)
+}
+
+func multiIndexItems() []interface{} {
+	return []interface{}{
+		Coordinate{1, 7},
+		Coordinate{2, 1},
+		Coordinate{3, 3},
+		Coordinate{5, 2},
+		Coordinate{9, 9},
+	}
+}
+
+// This test checks the weighted distance sum fusion against hand-computed
+// scores
+func TestMultiIndexWeightedSum(t *testing.T) {
+	mi := NewMultiIndex(multiIndexItems(), []Metric{xMetric, yMetric}, WeightedSum([]float64{1, 1}))
+
+	results, scores := mi.Search(Coordinate{0, 0}, 3)
+
+	// {1, 7} is among the three nearest neighbours under xMetric, but its
+	// summed distance of 8 puts it behind {5, 2}
+	compareCoordDistSets(t, results, []Coordinate{{2, 1}, {3, 3}, {5, 2}}, scores, []float64{3, 6, 7})
+}
+
+// This test makes sure the weighted distance sum finds items that are not
+// among the k nearest neighbours under any single metric
+func TestMultiIndexWeightedSumExact(t *testing.T) {
+	items := []interface{}{Coordinate{0, 10}, Coordinate{10, 0}, Coordinate{1, 1}}
+	mi := NewMultiIndex(items, []Metric{xMetric, yMetric}, WeightedSum([]float64{1, 1}))
+
+	// {0, 10} is the nearest neighbour under xMetric and {10, 0} under
+	// yMetric, but {1, 1} has the least summed distance
+	results, scores := mi.Search(Coordinate{0, 0}, 1)
+	compareCoordDistSets(t, results, []Coordinate{{1, 1}}, scores, []float64{2})
+
+	var random []interface{}
+	for i := 0; i < 1000; i++ {
+		random = append(random, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	weights := []float64{1, 3}
+	mi = NewMultiIndex(random, []Metric{xMetric, yMetric}, WeightedSum(weights))
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		all := make([]float64, len(random))
+		for j, item := range random {
+			all[j] = weights[0]*xMetric(item, q) + weights[1]*yMetric(item, q)
+		}
+		sort.Float64s(all)
+
+		_, scores := mi.SearchIndices(q, 10)
+		for r, score := range scores {
+			if score != all[r] {
+				t.Errorf("Expected scores[%v] to be %v, got %v", r, all[r], score)
+			}
+		}
+	}
+}
+
+// This test makes sure WeightedSum panics for negative, NaN and infinite
+// weights, and for a number of weights that doesn't match the metrics
+func TestWeightedSumPanics(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%v: expected a panic", name)
+			}
+		}()
+		f()
+	}
+
+	expectPanic("negative weight", func() { WeightedSum([]float64{1, -1}) })
+	expectPanic("NaN weight", func() { WeightedSum([]float64{math.NaN(), 1}) })
+	expectPanic("infinite weight", func() { WeightedSum([]float64{1, math.Inf(1)}) })
+
+	mi := NewMultiIndex(multiIndexItems(), []Metric{xMetric, yMetric}, WeightedSum([]float64{1}))
+	expectPanic("too few weights", func() { mi.Search(Coordinate{0, 0}, 3) })
+
+	mi = NewMultiIndex(multiIndexItems(), []Metric{xMetric, yMetric}, WeightedSum([]float64{1, 1, 1}))
+	expectPanic("too many weights", func() { mi.Search(Coordinate{0, 0}, 3) })
+}
+
+// This test checks reciprocal rank fusion against hand-computed scores
+func TestMultiIndexReciprocalRankFusion(t *testing.T) {
+	mi := NewMultiIndex(multiIndexItems(), []Metric{xMetric, yMetric}, ReciprocalRankFusion(60))
+
+	indices, scores := mi.SearchIndices(Coordinate{0, 0}, 3)
+
+	expectedIndices := []int{1, 2, 0}
+	expectedScores := []float64{
+		-(1.0/62 + 1.0/61),
+		-(1.0/63 + 1.0/63),
+		-(1.0 / 61),
+	}
+
+	if len(indices) != len(expectedIndices) {
+		t.Fatalf("Expected %v results, got %v", len(expectedIndices), len(indices))
+	}
+
+	for i := range indices {
+		if indices[i] != expectedIndices[i] {
+			t.Errorf("Expected indices[%v] to be %v, got %v", i, expectedIndices[i], indices[i])
+		}
+		if math.Abs(scores[i]-expectedScores[i]) > 1e-12 {
+			t.Errorf("Expected scores[%v] to be %v, got %v", i, expectedScores[i], scores[i])
+		}
+	}
+}
+
+// This test runs many concurrent queries against a MultiIndex and makes sure
+// they agree with sequential queries
+func TestMultiIndexConcurrent(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	mi := NewMultiIndex(items, []Metric{CoordinateMetric, xMetric, yMetric}, ReciprocalRankFusion(60))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			for j := 0; j < 50; j++ {
+				q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+				indices1, scores1 := mi.SearchIndices(q, 10)
+				indices2, scores2 := mi.SearchIndices(q, 10)
+
+				if len(indices1) != 10 || len(indices2) != 10 {
+					t.Errorf("Expected 10 results, got %v and %v", len(indices1), len(indices2))
+					break
+				}
+
+				for r := range indices1 {
+					if indices1[r] != indices2[r] || scores1[r] != scores2[r] {
+						t.Errorf("Result %v differs between identical queries", r)
+					}
+				}
+			}
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+}
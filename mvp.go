@@ -0,0 +1,319 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// mvpLeafSize is the largest number of items, besides its two vantage
+	// points, an MVPTree node keeps in a leaf instead of splitting them.
+	mvpLeafSize = 32
+
+	// mvpPathLength is the largest number of distances to the vantage
+	// points of their ancestors the items of an MVPTree keep for pruning.
+	mvpPathLength = 16
+)
+
+// An MVPTree is a multi-vantage-point tree (Bozkaya and Özsoyoglu). Each of
+// its nodes has two vantage points: the items are split in half by their
+// distance to the first, and each half is split in half again by their
+// distance to the second, so that a node has four children. The second
+// vantage point is shared by both halves, which saves metric calls over a
+// VP-tree of the same fan-out.
+//
+// Besides, the items keep the distances to the vantage points of their
+// first few ancestors, which were computed while building the tree anyway.
+// A search computes the distances from the target to the same vantage points
+// on its way down, and at the leaves rules out items by the triangle
+// inequality, like a PivotIndex, before calling the metric for them. An
+// MVPTree thus calls the metric less often than a VPTree, which pays off
+// for expensive metrics such as the edit distance.
+type MVPTree struct {
+	root           *mvpNode
+	distanceMetric Metric
+	size           int
+}
+
+type mvpNode struct {
+	// V1 and V2 are the vantage points, with Index1 and Index2 their
+	// indices. A node of a single item has no V2.
+	V1, V2         interface{}
+	Index1, Index2 int
+	HasV2          bool
+
+	// Leaves hold their items in Entries, with the distances to the
+	// vantage points in D1 and D2. Inner nodes have up to four Children,
+	// whose items' distances to V1 lie within Lo1 and Hi1 and to V2 within
+	// Lo2 and Hi2.
+	Entries            []mvpEntry
+	Children           [4]*mvpNode
+	Lo1, Hi1, Lo2, Hi2 [4]float64
+}
+
+type mvpEntry struct {
+	Item  interface{}
+	Index int
+
+	// D1 and D2 are scratch space while building, and the distances to
+	// the vantage points of the leaf afterwards. Path holds the distances
+	// to the vantage points of the ancestors, from the root down.
+	D1, D2 float64
+	Path   []float64
+}
+
+// NewMVP creates a new MVPTree using the metric and items provided.
+func NewMVP(metric Metric, items []interface{}) *MVPTree {
+	t := &MVPTree{distanceMetric: metric, size: len(items)}
+
+	entries := make([]mvpEntry, len(items))
+	for i, item := range items {
+		entries[i] = mvpEntry{Item: item, Index: i}
+	}
+
+	t.root = t.build(entries)
+	return t
+}
+
+func (t *MVPTree) build(entries []mvpEntry) *mvpNode {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Take a random item out of the entries and make it the first vantage
+	// point
+	idx := rand.Intn(len(entries))
+	entries[idx], entries[len(entries)-1] = entries[len(entries)-1], entries[idx]
+	v1 := entries[len(entries)-1]
+	entries = entries[:len(entries)-1]
+
+	n := &mvpNode{V1: v1.Item, Index1: v1.Index}
+	if len(entries) == 0 {
+		return n
+	}
+
+	// The item farthest from the first vantage point is the second, so
+	// that the two split the items differently
+	far := 0
+	for i := range entries {
+		entries[i].D1 = t.distanceMetric(entries[i].Item, v1.Item)
+		if entries[i].D1 > entries[far].D1 {
+			far = i
+		}
+	}
+	entries[far], entries[len(entries)-1] = entries[len(entries)-1], entries[far]
+	v2 := entries[len(entries)-1]
+	entries = entries[:len(entries)-1]
+
+	n.V2, n.Index2, n.HasV2 = v2.Item, v2.Index, true
+	for i := range entries {
+		entries[i].D2 = t.distanceMetric(entries[i].Item, v2.Item)
+	}
+
+	if len(entries) <= mvpLeafSize {
+		n.Entries = entries
+		return n
+	}
+
+	for i := range entries {
+		if len(entries[i].Path) < mvpPathLength {
+			entries[i].Path = append(entries[i].Path, entries[i].D1, entries[i].D2)
+		}
+	}
+
+	// Split in half by the distance to V1, then each half in half by the
+	// distance to V2
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].D1 < entries[j].D1
+	})
+	half := len(entries) / 2
+	groups := [4][]mvpEntry{}
+	for h, part := range [2][]mvpEntry{entries[:half], entries[half:]} {
+		sort.Slice(part, func(i, j int) bool {
+			return part[i].D2 < part[j].D2
+		})
+		groups[2*h], groups[2*h+1] = part[:len(part)/2], part[len(part)/2:]
+	}
+
+	for c, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		n.Lo1[c], n.Hi1[c] = math.Inf(1), math.Inf(-1)
+		n.Lo2[c], n.Hi2[c] = math.Inf(1), math.Inf(-1)
+		for _, e := range group {
+			n.Lo1[c], n.Hi1[c] = min(n.Lo1[c], e.D1), max(n.Hi1[c], e.D1)
+			n.Lo2[c], n.Hi2[c] = min(n.Lo2[c], e.D2), max(n.Hi2[c], e.D2)
+		}
+
+		n.Children[c] = t.build(group)
+	}
+
+	return n
+}
+
+// Len returns the number of items in the MVPTree.
+func (t *MVPTree) Len() int {
+	return t.size
+}
+
+// Search searches the MVPTree for the k nearest neighbours of target. It
+// returns the up to k nearest neighbours and the corresponding distances in
+// order of least distance to largest distance.
+func (t *MVPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	q := getKNNQuery(target, k, t.size)
+	defer putKNNQuery(q)
+	t.search(q, t.root, make([]float64, 0, mvpPathLength))
+
+	return itemsAndDistances(q.results())
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (t *MVPTree) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	q := getKNNQuery(target, k, t.size)
+	defer putKNNQuery(q)
+	t.search(q, t.root, make([]float64, 0, mvpPathLength))
+
+	for _, hi := range q.results() {
+		indices = append(indices, hi.Index)
+		distances = append(distances, hi.Dist)
+	}
+	return
+}
+
+// search searches the subtree rooted at n, where path holds the distances
+// from the target to the vantage points of n's ancestors that the items
+// keep in their Path.
+func (t *MVPTree) search(q *knnQuery, n *mvpNode, path []float64) {
+	if n == nil {
+		return
+	}
+
+	d1 := t.distanceMetric(n.V1, q.target)
+	if d1 < q.tau {
+		q.add(heapItem{n.V1, n.Index1, d1})
+	}
+	if !n.HasV2 {
+		return
+	}
+
+	d2 := t.distanceMetric(n.V2, q.target)
+	if d2 < q.tau {
+		q.add(heapItem{n.V2, n.Index2, d2})
+	}
+
+	for _, e := range n.Entries {
+		if mvpBound(e, d1, d2, path) >= q.tau {
+			continue
+		}
+		if dist := t.distanceMetric(e.Item, q.target); dist < q.tau {
+			q.add(heapItem{e.Item, e.Index, dist})
+		}
+	}
+
+	if len(path) < mvpPathLength {
+		path = append(path, d1, d2)
+	}
+
+	// Search the children from the nearest lower bound up. Tau may shrink
+	// in between, so every child is checked right before it is searched.
+	var order [4]int
+	var bounds [4]float64
+	for c := range order {
+		bounds[c] = n.bound(c, d1, d2)
+
+		// Insertion sort
+		i := c
+		for ; i > 0 && bounds[order[i-1]] > bounds[c]; i-- {
+			order[i] = order[i-1]
+		}
+		order[i] = c
+	}
+
+	for _, c := range order {
+		if bounds[c] <= q.tau {
+			t.search(q, n.Children[c], path)
+		}
+	}
+}
+
+// bound returns a lower bound on the distance from the target to the items
+// of the c-th child, given the target's distances d1 and d2 to the vantage
+// points.
+func (n *mvpNode) bound(c int, d1, d2 float64) float64 {
+	return max(0, n.Lo1[c]-d1, d1-n.Hi1[c], n.Lo2[c]-d2, d2-n.Hi2[c])
+}
+
+// mvpBound returns a lower bound on the distance from the target to the item
+// of e, given the target's distances d1 and d2 to the vantage points of e's
+// leaf and path to those of its ancestors.
+func mvpBound(e mvpEntry, d1, d2 float64, path []float64) float64 {
+	bound := max(math.Abs(d1-e.D1), math.Abs(d2-e.D2))
+	for i, d := range e.Path {
+		bound = max(bound, math.Abs(path[i]-d))
+	}
+	return bound
+}
+
+// SearchRadius searches the MVPTree for all items within distance radius of
+// target. It returns the items and the corresponding distances in order of
+// least distance to largest distance.
+func (t *MVPTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	var found []heapItem
+	t.searchRadius(t.root, target, radius, make([]float64, 0, mvpPathLength), &found)
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Dist < found[j].Dist
+	})
+
+	return itemsAndDistances(found)
+}
+
+func (t *MVPTree) searchRadius(n *mvpNode, target interface{}, radius float64, path []float64, found *[]heapItem) {
+	if n == nil {
+		return
+	}
+
+	d1 := t.distanceMetric(n.V1, target)
+	if d1 <= radius {
+		*found = append(*found, heapItem{n.V1, n.Index1, d1})
+	}
+	if !n.HasV2 {
+		return
+	}
+
+	d2 := t.distanceMetric(n.V2, target)
+	if d2 <= radius {
+		*found = append(*found, heapItem{n.V2, n.Index2, d2})
+	}
+
+	for _, e := range n.Entries {
+		if mvpBound(e, d1, d2, path) > radius {
+			continue
+		}
+		if dist := t.distanceMetric(e.Item, target); dist <= radius {
+			*found = append(*found, heapItem{e.Item, e.Index, dist})
+		}
+	}
+
+	if len(path) < mvpPathLength {
+		path = append(path, d1, d2)
+	}
+
+	for c, child := range n.Children {
+		if n.bound(c, d1, d2) <= radius {
+			t.searchRadius(child, target, radius, path, found)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package vptree_test
+
+import (
+	"math/rand"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+	"github.com/DataWraith/vptree/metrics"
+)
+
+// misspelledWords returns n misspellings of random base words, each of
+// which differs from its base word by up to three random edits
+func misspelledWords(rng *rand.Rand, bases []string, n int) []interface{} {
+	words := make([]interface{}, n)
+	for i := range words {
+		w := []byte(bases[rng.Intn(len(bases))])
+		for e := rng.Intn(4); e > 0; e-- {
+			j := rng.Intn(len(w))
+			switch rng.Intn(3) {
+			case 0:
+				w[j] = byte('a' + rng.Intn(26))
+			case 1:
+				w = append(w[:j], w[j+1:]...)
+			default:
+				w = append(w[:j], append([]byte{byte('a' + rng.Intn(26))}, w[j:]...)...)
+			}
+		}
+		words[i] = string(w)
+	}
+	return words
+}
+
+// baseWords returns n random lower-case words of 6 to 12 letters
+func baseWords(rng *rand.Rand, n int) []string {
+	bases := make([]string, n)
+	for i := range bases {
+		w := make([]byte, 6+rng.Intn(7))
+		for j := range w {
+			w[j] = byte('a' + rng.Intn(26))
+		}
+		bases[i] = string(w)
+	}
+	return bases
+}
+
+// countingLevenshtein returns the Levenshtein distance and a counter of its
+// calls
+func countingLevenshtein() (vptree.Metric, *atomic.Int64) {
+	var calls atomic.Int64
+	return func(a, b interface{}) float64 {
+		calls.Add(1)
+		return metrics.Levenshtein(a, b)
+	}, &calls
+}
+
+// This test makes sure an MVPTree finds the same neighbours as a VPTree on
+// misspelled words under the edit distance, with fewer calls of the metric
+func TestMVPTreeMetricCalls(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	bases := baseWords(rng, 500)
+	words := misspelledWords(rng, bases, 5000)
+	queries := misspelledWords(rng, bases, 50)
+
+	metric, calls := countingLevenshtein()
+	vp := vptree.New(metric, words)
+	mvp := vptree.NewMVP(metric, words)
+
+	var vpCalls, mvpCalls int64
+	for _, q := range queries {
+		calls.Store(0)
+		_, expected := vp.Search(q, 10)
+		vpCalls += calls.Load()
+
+		calls.Store(0)
+		_, distances := mvp.Search(q, 10)
+		mvpCalls += calls.Load()
+
+		if !slices.Equal(distances, expected) {
+			t.Errorf("Expected distances %v for %q, got %v", expected, q, distances)
+		}
+	}
+
+	t.Logf("Metric calls per search: VPTree %v, MVPTree %v", vpCalls/int64(len(queries)), mvpCalls/int64(len(queries)))
+	if mvpCalls >= vpCalls {
+		t.Errorf("Expected the MVPTree to call the metric less often than the VPTree, got %v >= %v", mvpCalls, vpCalls)
+	}
+}
+
+func BenchmarkMVPTree(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	bases := baseWords(rng, 2000)
+	words := misspelledWords(rng, bases, 20000)
+	queries := misspelledWords(rng, bases, 100)
+
+	metric, calls := countingLevenshtein()
+
+	run := func(b *testing.B, idx vptree.Index) {
+		calls.Store(0)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			idx.Search(queries[i%len(queries)], 10)
+		}
+		b.ReportMetric(float64(calls.Load())/float64(b.N), "calls/op")
+	}
+
+	b.Run("VPTree", func(b *testing.B) {
+		run(b, vptree.New(metric, words))
+	})
+
+	b.Run("MVPTree", func(b *testing.B) {
+		run(b, vptree.NewMVP(metric, words))
+	})
+}
@@ -0,0 +1,127 @@
+package vptree
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// A NodePool recycles the memory of VP-tree nodes, so that trees that are
+// rebuilt frequently don't churn the garbage collector. Trees built with
+// WithNodePool take their nodes from the pool and return them with Release.
+// A NodePool is safe for concurrent use by multiple trees.
+type NodePool struct {
+	mu       sync.Mutex
+	arenas   [][]node
+	maxNodes int
+
+	hits     int
+	misses   int
+	retained int
+}
+
+// NodePoolStats describe how well a NodePool serves its trees.
+type NodePoolStats struct {
+	// Hits and Misses count the builds that could and could not reuse
+	// the nodes of a released tree.
+	Hits   int
+	Misses int
+
+	// RetainedBytes is the memory held by the pool for future builds.
+	RetainedBytes int
+}
+
+// NewNodePool creates a NodePool that retains the nodes of up to maxNodes
+// nodes of released trees. If maxNodes is not positive, the pool retains
+// all nodes.
+func NewNodePool(maxNodes int) *NodePool {
+	return &NodePool{maxNodes: maxNodes}
+}
+
+// WithNodePool makes the VP-tree take the memory for its nodes from pool.
+// Spill trees don't use the pool.
+func WithNodePool(pool *NodePool) Option {
+	return func(vp *VPTree) {
+		vp.nodePool = pool
+	}
+}
+
+// Stats returns the pool's statistics.
+func (p *NodePool) Stats() NodePoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return NodePoolStats{
+		Hits:          p.hits,
+		Misses:        p.misses,
+		RetainedBytes: p.retained * int(unsafe.Sizeof(node{})),
+	}
+}
+
+// get returns an empty arena with room for at least n nodes, reusing the
+// smallest retained arena that is large enough.
+func (p *NodePool) get(n int) []node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	best := -1
+	for i, a := range p.arenas {
+		if cap(a) >= n && (best < 0 || cap(a) < cap(p.arenas[best])) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		p.misses++
+		return make([]node, 0, n)
+	}
+
+	p.hits++
+	arena := p.arenas[best]
+	p.arenas[best] = p.arenas[len(p.arenas)-1]
+	p.arenas[len(p.arenas)-1] = nil
+	p.arenas = p.arenas[:len(p.arenas)-1]
+	p.retained -= cap(arena)
+
+	return arena[:0]
+}
+
+// put poisons the nodes of arena, so that they neither keep items alive nor
+// pass for live nodes, and retains the arena if there is room for it.
+func (p *NodePool) put(arena []node) {
+	arena = arena[:cap(arena)]
+	for i := range arena {
+		arena[i] = node{Index: -1}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.maxNodes > 0 && p.retained+len(arena) > p.maxNodes {
+		return
+	}
+
+	p.arenas = append(p.arenas, arena)
+	p.retained += len(arena)
+}
+
+// Release empties the VP-tree and returns its nodes to its NodePool, if it
+// was built with one. The tree must not be used concurrently with Release,
+// and searches on it find nothing afterwards.
+func (vp *VPTree) Release() {
+	vp.root = nil
+	if vp.nodePool != nil && cap(vp.arena) > 0 {
+		vp.nodePool.put(vp.arena)
+	}
+	vp.arena = nil
+
+	vp.mutated("Release")
+}
+
+// newNode returns a new node, from the tree's arena if possible.
+func (vp *VPTree) newNode() *node {
+	if len(vp.arena) < cap(vp.arena) {
+		vp.arena = vp.arena[:len(vp.arena)+1]
+		return &vp.arena[len(vp.arena)-1]
+	}
+	return &node{}
+}
@@ -0,0 +1,101 @@
+package vptree
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// This test makes sure a rebuilt tree reuses the nodes of a released one,
+// and that the released nodes are poisoned
+func TestNodePool(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	pool := NewNodePool(0)
+
+	vp := New(CoordinateMetric, items, WithNodePool(pool))
+	old := vp.root
+	vp.Release()
+
+	if old.Index != -1 || old.Item != nil || old.Left != nil || old.Right != nil {
+		t.Errorf("Expected the released root to be poisoned, got %+v", *old)
+	}
+
+	if results, _ := vp.Search(Coordinate{0.5, 0.5}, 3); len(results) != 0 {
+		t.Errorf("Expected a released tree to find nothing, got %v", results)
+	}
+
+	plain := New(CoordinateMetric, items)
+	vp = New(CoordinateMetric, items, WithNodePool(pool))
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedResults, expectedDistances := plain.Search(q, 10)
+		results, distances := vp.Search(q, 10)
+
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+	}
+
+	stats := pool.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.RetainedBytes != 0 {
+		t.Errorf("Expected 1 hit, 1 miss and nothing retained, got %+v", stats)
+	}
+}
+
+// This test makes sure a NodePool doesn't retain more than its limit
+func TestNodePoolLimit(t *testing.T) {
+	_, items := randomCoordinates(100)
+	pool := NewNodePool(150)
+
+	a := New(CoordinateMetric, items, WithNodePool(pool))
+	b := New(CoordinateMetric, items, WithNodePool(pool))
+	a.Release()
+	b.Release()
+
+	pool.mu.Lock()
+	retained := pool.retained
+	pool.mu.Unlock()
+
+	if retained != 100 {
+		t.Errorf("Expected 100 retained nodes, got %v", retained)
+	}
+
+	if stats := pool.Stats(); stats.RetainedBytes <= 0 {
+		t.Errorf("Expected the retained nodes to take up memory, got %+v", stats)
+	}
+}
+
+// This test builds, searches and releases trees sharing a NodePool on
+// several goroutines, to be run with -race
+func TestNodePoolConcurrent(t *testing.T) {
+	_, items := randomCoordinates(500)
+	pool := NewNodePool(0)
+	plain := New(CoordinateMetric, items)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				vp := New(CoordinateMetric, items, WithNodePool(pool))
+
+				q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+				_, expected := plain.Search(q, 5)
+				_, distances := vp.Search(q, 5)
+				for r := range expected {
+					if distances[r] != expected[r] {
+						t.Errorf("Expected distance %v, got %v", expected[r], distances[r])
+					}
+				}
+
+				vp.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := pool.Stats(); stats.Hits+stats.Misses != 160 || stats.Misses > 8 {
+		t.Errorf("Expected 160 builds with at most 8 misses, got %+v", stats)
+	}
+}
@@ -0,0 +1,158 @@
+package vptree
+
+import "math/rand"
+
+// defaultOnlineAlpha is the balance factor NewOnline uses for alpha outside
+// of (0.5, 1).
+const defaultOnlineAlpha = 0.7
+
+// An OnlineVPTree is a VP-tree that items can be inserted into one at a
+// time while it stays balanced, without ever rebuilding it as a whole.
+//
+// Ordered search trees stay balanced through rotations, but a VP-tree node
+// can't be rotated: its threshold splits the items by their distance to
+// its vantage point, and a child's vantage point splits them differently,
+// so no exchange of parent and child keeps both partitions intact. Instead,
+// an OnlineVPTree keeps its nodes weight-balanced like a scapegoat tree:
+// after an insert, the highest node on the inserted item's path whose larger
+// child holds more than alpha times the nodes of its subtree is rebuilt
+// from its items. Unlike New, which splits the items at the distance of an
+// arbitrary one of them, the builds split them at the median distance, so
+// that rebuilt subtrees are balanced unless many items are equally far from
+// the vantage point. Rebuilding a subtree of m items
+// takes O(m log m) metric calls, which amortizes to O(log² n) per insert,
+// and the depth of the tree stays within log n / log(1/alpha) plus a
+// constant.
+type OnlineVPTree struct {
+	vp    *VPTree
+	alpha float64
+}
+
+// NewOnline creates an OnlineVPTree from the metric and the items provided,
+// which are built into a VP-tree by New with opts. The balance factor alpha
+// is the largest share of a subtree's nodes one child may hold; smaller
+// values keep the tree shallower at the cost of more rebuilds. Values
+// outside of (0.5, 1) select 0.7.
+func NewOnline(metric Metric, items []interface{}, alpha float64, opts ...Option) *OnlineVPTree {
+	if !(alpha > 0.5 && alpha < 1) {
+		alpha = defaultOnlineAlpha
+	}
+
+	exact := func(vp *VPTree) {
+		vp.exactMedian = true
+	}
+
+	return &OnlineVPTree{
+		vp:    New(metric, items, append(opts, exact)...),
+		alpha: alpha,
+	}
+}
+
+// Insert adds item to the OnlineVPTree and returns its index, one more than
+// the largest index so far, starting after the items NewOnline was called
+// with. Insert must not be called concurrently with other methods of the
+// OnlineVPTree.
+func (ot *OnlineVPTree) Insert(item interface{}) int {
+	vp := ot.vp
+	index := vp.nextIndex
+	if index == 0 {
+		index = vp.itemCount()
+	}
+
+	path := vp.insert(item)
+
+	for depth, link := range path {
+		n := *link
+
+		// Leaves are unbalanced on purpose, see WithLeafSize
+		if n.Size <= vp.leafSize {
+			break
+		}
+
+		if float64(max(n.Left.size(), n.Right.size())) > ot.alpha*float64(n.Size) {
+			ot.rebuild(path[:depth], link, depth)
+			break
+		}
+	}
+
+	vp.mutated("Insert")
+	return index
+}
+
+// rebuild builds the subtree at link, whose ancestors' links are path, anew
+// from its items.
+func (ot *OnlineVPTree) rebuild(path []**node, link **node, depth int) {
+	vp := ot.vp
+
+	var nodes []*node
+	vp.collectNodes(*link, &nodes)
+
+	items := make([]interface{}, 0, len(nodes))
+	indices := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		items = append(items, n.Item)
+		indices = append(indices, n.Index)
+	}
+
+	*link = vp.buildFromPoints(items, indices, depth)
+	vp.buildDists = nil
+
+	// Deduplication may have dropped items
+	if dropped := len(nodes) - (*link).size(); dropped > 0 {
+		for _, l := range path {
+			(*l).Size -= dropped
+		}
+	}
+}
+
+// Len returns the number of items in the OnlineVPTree.
+func (ot *OnlineVPTree) Len() int {
+	return ot.vp.Len()
+}
+
+// Search searches the OnlineVPTree for the k nearest neighbours of target,
+// like VPTree.Search.
+func (ot *OnlineVPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	return ot.vp.Search(target, k)
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (ot *OnlineVPTree) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	return ot.vp.SearchIndices(target, k)
+}
+
+// SearchRadius searches the OnlineVPTree for all items within radius of
+// target, like VPTree.SearchRadius.
+func (ot *OnlineVPTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	return ot.vp.SearchRadius(target, radius)
+}
+
+// selectKth reorders dists, using swap to move them, so that dists[k] is the
+// value it would have if dists were sorted, with no larger values before it
+// and no smaller ones after it.
+func selectKth(dists []float64, k int, swap func(i, j int)) {
+	lo, hi := 0, len(dists)-1
+	for lo < hi {
+		// Lomuto partition around a random pivot
+		swap(lo+rand.Intn(hi-lo+1), hi)
+		pivot := dists[hi]
+		store := lo
+		for i := lo; i < hi; i++ {
+			if dists[i] < pivot {
+				swap(store, i)
+				store++
+			}
+		}
+		swap(store, hi)
+
+		switch {
+		case k < store:
+			hi = store - 1
+		case k > store:
+			lo = store + 1
+		default:
+			return
+		}
+	}
+}
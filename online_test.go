@@ -0,0 +1,106 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// treeDepth returns the depth of the deepest node of vp.
+func treeDepth(vp *VPTree) (depth int) {
+	vp.Walk(func(n NodeInfo) bool {
+		depth = max(depth, n.Depth)
+		return true
+	})
+	return
+}
+
+// This test inserts items in an order that degenerates plain leaf inserts
+// and checks that the OnlineVPTree stays shallow and answers queries
+// exactly
+func TestOnlineVPTree(t *testing.T) {
+	const n = 2000
+
+	// Every point is farther from the earlier ones than they are from
+	// each other, so it always lands in the rightmost subtree
+	var items []Coordinate
+	ot := NewOnline(CoordinateMetric, nil, 0.7)
+	plain := New(CoordinateMetric, nil)
+	for i := 0; i < n; i++ {
+		c := Coordinate{X: float64(i) + rand.Float64()/2, Y: rand.Float64()}
+		items = append(items, c)
+
+		if index := ot.Insert(c); index != i {
+			t.Fatalf("Expected index %v, got %v", i, index)
+		}
+		plain.insert(c)
+	}
+
+	if ot.Len() != n {
+		t.Errorf("Expected %v items, got %v", n, ot.Len())
+	}
+
+	bound := int(math.Log(n)/math.Log(1/0.7)) + 2
+	if depth := treeDepth(ot.vp); depth > bound {
+		t.Errorf("Expected a depth of at most %v, got %v", bound, depth)
+	}
+	if depth := treeDepth(plain); depth < n/10 {
+		t.Errorf("Expected plain inserts to degenerate, got a depth of %v", depth)
+	}
+
+	for i := 0; i < 100; i++ {
+		q := Coordinate{X: rand.Float64() * n, Y: rand.Float64()}
+
+		coords1, distances1 := ot.Search(q, 10)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+
+		within, _ := ot.SearchRadius(q, 2)
+		expected := 0
+		for _, c := range items {
+			if CoordinateMetric(c, q) <= 2 {
+				expected++
+			}
+		}
+		if len(within) != expected {
+			t.Errorf("Expected %v items within 2, got %v", expected, len(within))
+		}
+	}
+
+	var nodes int
+	ot.vp.forEachNode(func(n *node) {
+		nodes++
+		if n.Size != 1+n.Left.size()+n.Right.size() {
+			t.Fatalf("Node %v has size %v, but its children hold %v nodes", n.Index, n.Size, n.Left.size()+n.Right.size())
+		}
+	})
+	if nodes != n {
+		t.Errorf("Expected %v nodes, got %v", n, nodes)
+	}
+}
+
+// This test inserts into an OnlineVPTree with leaves and one built from
+// items
+func TestOnlineVPTreeOptions(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	ot := NewOnline(CoordinateMetric, vpitems, 0, WithLeafSize(8))
+
+	for i := 0; i < 1000; i++ {
+		c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		items = append(items, c)
+		if index := ot.Insert(c); index != 1000+i {
+			t.Fatalf("Expected index %v, got %v", 1000+i, index)
+		}
+	}
+
+	if ot.alpha != defaultOnlineAlpha {
+		t.Errorf("Expected the default alpha, got %v", ot.alpha)
+	}
+
+	for i := 0; i < 100; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		coords1, distances1 := ot.Search(q, 10)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+	}
+}
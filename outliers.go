@@ -0,0 +1,83 @@
+package vptree
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// OutlierScores scores every item the VP-tree was built from by the
+// distance to its k-th nearest neighbour other than itself, aligned with
+// the items slice. Items far from all others get high scores. The searches
+// run on workers goroutines; if workers is not positive, GOMAXPROCS is used.
+func (vp *VPTree) OutlierScores(k int, workers int) []float64 {
+	return vp.AllKthNearestDistances(k+1, workers)
+}
+
+// TopOutliers returns the indices and scores of the m items with the
+// highest OutlierScores, in order of decreasing score.
+//
+// It does not compute every score. The items are scored in random order,
+// and once m items have been scored, the search for an item stops as soon
+// as it has found k neighbours closer than the lowest of the m best scores
+// so far, since the item can no longer make it among them. The more m is
+// smaller than the number of items, the more this saves.
+func (vp *VPTree) TopOutliers(k, m int) (indices []int, scores []float64) {
+	if k < 1 || m < 1 {
+		return
+	}
+
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+	rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+
+	var top MinDistanceHeap
+	seen := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		// Spill trees may hold an item more than once
+		if seen[n.Index] {
+			continue
+		}
+		seen[n.Index] = true
+
+		q := getKNNQuery(n.Item, k+1, vp.root.size())
+		full := len(top) == m
+		if full {
+			cutoff := top.Top().Distance
+			q.skip = func(*node) bool {
+				return q.h.Len() == q.k && q.tau < cutoff
+			}
+		}
+		vp.search(q, searchFrame{n: vp.root})
+
+		score := q.tau
+		complete := q.h.Len() == q.k
+		putKNNQuery(q)
+
+		switch {
+		case !complete:
+			score = math.Inf(1)
+		case full && score < top.Top().Distance:
+			continue
+		}
+
+		heap.Push(&top, Neighbour{n.Item, n.Index, score})
+		if len(top) > m {
+			heap.Pop(&top)
+		}
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Distance != top[j].Distance {
+			return top[i].Distance > top[j].Distance
+		}
+		return top[i].Index < top[j].Index
+	})
+
+	for _, nb := range top {
+		indices = append(indices, nb.Index)
+		scores = append(scores, nb.Distance)
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test checks the outlier scores against brute force
+func TestOutlierScores(t *testing.T) {
+	coords, items := randomCoordinates(500)
+	vp := New(CoordinateMetric, items)
+
+	scores := vp.OutlierScores(3, 0)
+	if len(scores) != len(items) {
+		t.Fatalf("Expected %v scores, got %v", len(items), len(scores))
+	}
+
+	for i, c := range coords {
+		// The nearest neighbour is the item itself
+		_, dists := nearestNeighbours(c, coords, 4)
+		if scores[i] != dists[3] {
+			t.Errorf("Expected item %v to score %v, got %v", i, dists[3], scores[i])
+		}
+	}
+}
+
+// This test plants a few far-away items and makes sure they get the top
+// scores, and that TopOutliers agrees with OutlierScores while computing
+// fewer distances
+func TestTopOutliers(t *testing.T) {
+	_, items := randomCoordinates(2000)
+
+	planted := map[int]bool{}
+	for _, i := range rand.Perm(len(items))[:5] {
+		planted[i] = true
+		items[i] = Coordinate{X: 10 + 10*rand.Float64(), Y: 10 + 10*rand.Float64()}
+	}
+
+	calls := 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+	vp := New(counting, items)
+
+	calls = 0
+	scores := vp.OutlierScores(3, 1)
+	allCalls := calls
+
+	calls = 0
+	indices, top := vp.TopOutliers(3, 5)
+	topCalls := calls
+
+	if len(indices) != 5 {
+		t.Fatalf("Expected 5 outliers, got %v", len(indices))
+	}
+	for i, idx := range indices {
+		if !planted[idx] {
+			t.Errorf("Expected item %v to be a planted outlier", idx)
+		}
+		if top[i] != scores[idx] {
+			t.Errorf("Expected item %v to score %v, got %v", idx, scores[idx], top[i])
+		}
+	}
+	if !sort.IsSorted(sort.Reverse(sort.Float64Slice(top))) {
+		t.Errorf("Expected the scores in decreasing order, got %v", top)
+	}
+
+	if topCalls >= allCalls {
+		t.Errorf("Expected TopOutliers to compute fewer distances than OutlierScores, got %v and %v", topCalls, allCalls)
+	}
+
+	if indices, _ := New(CoordinateMetric, items[:2]).TopOutliers(3, 5); len(indices) != 2 {
+		t.Errorf("Expected both items of a tiny tree, got %v", indices)
+	}
+}
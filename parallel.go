@@ -0,0 +1,279 @@
+package vptree
+
+import (
+	"math"
+	"math/bits"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// publishTau lowers the shared tau to tau if that is smaller, and returns
+// the resulting shared tau.
+func (q *knnQuery) publishTau(tau float64) float64 {
+	for {
+		old := q.sharedTau.Load()
+		if math.Float64frombits(old) <= tau {
+			return math.Float64frombits(old)
+		}
+		if q.sharedTau.CompareAndSwap(old, math.Float64bits(tau)) {
+			return tau
+		}
+	}
+}
+
+// SearchParallel is like Search, but splits the tree a few levels below the
+// root and searches the resulting subtrees on up to workers goroutines. Each
+// goroutine collects candidates in its own heap, and they share the smallest
+// tau found so far to prune each other's subtrees. The results are the same
+// as those of Search, but the parallelism only pays off for large trees or
+// expensive metrics. If workers is not positive, GOMAXPROCS is used.
+func (vp *VPTree) SearchParallel(target interface{}, k, workers int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var sharedTau atomic.Uint64
+	sharedTau.Store(math.Float64bits(math.MaxFloat64))
+
+	newQuery := func() *knnQuery {
+		q := getKNNQuery(target, k, vp.root.size())
+		q.sharedTau = &sharedTau
+		if vp.hooks.OnSearchDone != nil {
+			q.stats = &QueryStats{}
+		}
+		return q
+	}
+
+	// Search the top of the tree sequentially, collecting the subtrees
+	// below it for the workers
+	top := newQuery()
+	top.splitDepth = splitDepth(workers)
+	vp.search(top, searchFrame{n: vp.root})
+
+	workers = min(workers, len(top.frontier))
+	queries := []*knnQuery{top}
+	for w := 0; w < workers; w++ {
+		queries = append(queries, newQuery())
+	}
+
+	// The frontier is in the order the sequential search would visit it,
+	// so the most promising subtrees are taken first
+	searchFrontier(top.frontier, workers, func(w int, f searchFrame) {
+		vp.search(queries[w+1], f)
+	})
+
+	// Merge the local heaps. Spill trees store some items more than once,
+	// so the heaps may share items.
+	var merged []heapItem
+	var stats QueryStats
+	seen := make(map[int]bool)
+	for _, q := range queries {
+		for i := 0; i < q.h.Len(); i++ {
+			if hi := q.h.at(i); !seen[hi.Index] {
+				seen[hi.Index] = true
+				merged = append(merged, hi)
+			}
+		}
+
+		if q.stats != nil {
+			stats.MetricCalls += q.stats.MetricCalls
+			stats.NodesVisited += q.stats.NodesVisited
+			stats.HeapEvictions += q.stats.HeapEvictions
+			stats.MaxDepth = max(stats.MaxDepth, q.stats.MaxDepth)
+		}
+
+		putKNNQuery(q)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Dist < merged[j].Dist
+	})
+	if len(merged) > k {
+		merged = merged[:k]
+	}
+
+	if vp.hooks.OnSearchDone != nil {
+		stats.Tau = math.Inf(1)
+		if len(merged) == k {
+			stats.Tau = merged[k-1].Dist
+		}
+		vp.hooks.OnSearchDone(stats)
+	}
+
+	if len(merged) == 0 {
+		return
+	}
+
+	results = make([]interface{}, len(merged))
+	distances = make([]float64, len(merged))
+	for i, hi := range merged {
+		results[i] = hi.Item
+		distances[i] = hi.Dist
+	}
+
+	return
+}
+
+// SearchRadiusParallel is like SearchRadius, but splits the tree a few
+// levels below the root and searches the resulting subtrees on up to workers
+// goroutines. Since the radius doesn't change during the search, the
+// goroutines don't need to coordinate, which makes this worthwhile for
+// searches that find a large part of the tree. If workers is not positive,
+// GOMAXPROCS is used.
+func (vp *VPTree) SearchRadiusParallel(target interface{}, radius float64, workers int) (results []interface{}, distances []float64) {
+	queries := vp.searchRadiusParallel(target, radius, workers, nil)
+
+	total := 0
+	for _, q := range queries {
+		total += len(q.found)
+	}
+
+	found := make([]heapItem, 0, total)
+	for _, q := range queries {
+		found = append(found, q.found...)
+		putRadiusQuery(q)
+	}
+
+	if vp.spill > 0 {
+		// Spill trees may hold an item more than once
+		seen := make(map[int]bool, len(found))
+		unique := found[:0]
+		for _, hi := range found {
+			if !seen[hi.Index] {
+				seen[hi.Index] = true
+				unique = append(unique, hi)
+			}
+		}
+		found = unique
+	}
+
+	if len(found) == 0 {
+		return
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Dist < found[j].Dist
+	})
+
+	results = make([]interface{}, len(found))
+	distances = make([]float64, len(found))
+	for i, hi := range found {
+		results[i], distances[i] = hi.Item, hi.Dist
+	}
+
+	return
+}
+
+// SearchRadiusParallelFunc is like SearchRadiusParallel, but instead of
+// collecting the results, it calls fn with every item within distance radius
+// of target as soon as it is found, so that huge result sets can be streamed.
+// The items are passed in no particular order, and fn is called concurrently
+// from several goroutines, so it must be safe for concurrent use.
+func (vp *VPTree) SearchRadiusParallelFunc(target interface{}, radius float64, workers int, fn func(item interface{}, distance float64)) {
+	emit := func(hi heapItem) {
+		fn(hi.Item, hi.Dist)
+	}
+
+	if vp.spill > 0 {
+		// Spill trees may hold an item more than once
+		var mu sync.Mutex
+		seen := make(map[int]bool)
+		emit = func(hi heapItem) {
+			mu.Lock()
+			dup := seen[hi.Index]
+			seen[hi.Index] = true
+			mu.Unlock()
+
+			if !dup {
+				fn(hi.Item, hi.Dist)
+			}
+		}
+	}
+
+	for _, q := range vp.searchRadiusParallel(target, radius, workers, emit) {
+		putRadiusQuery(q)
+	}
+}
+
+// searchRadiusParallel runs a range search on up to workers goroutines, each
+// with its own query, and returns the queries, which must be returned with
+// putRadiusQuery. If emit is not nil, the queries pass the items they find
+// to it instead of collecting them.
+func (vp *VPTree) searchRadiusParallel(target interface{}, radius float64, workers int, emit func(hi heapItem)) (queries []*radiusQuery) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	newQuery := func() *radiusQuery {
+		q := getRadiusQuery(target, radius)
+		q.emit = emit
+		if vp.hooks.OnSearchDone != nil {
+			q.stats = &QueryStats{Tau: radius}
+		}
+		return q
+	}
+
+	// Search the top of the tree sequentially, collecting the subtrees
+	// below it for the workers
+	top := newQuery()
+	top.splitDepth = splitDepth(workers)
+	vp.searchRadius(top, vp.root, 0)
+
+	workers = min(workers, len(top.frontier))
+	queries = append(queries, top)
+	for w := 0; w < workers; w++ {
+		queries = append(queries, newQuery())
+	}
+
+	searchFrontier(top.frontier, workers, func(w int, f searchFrame) {
+		vp.searchRadius(queries[w+1], f.n, int(f.depth))
+	})
+
+	if vp.hooks.OnSearchDone != nil {
+		stats := QueryStats{Tau: radius}
+		for _, q := range queries {
+			stats.MetricCalls += q.stats.MetricCalls
+			stats.NodesVisited += q.stats.NodesVisited
+			stats.MaxDepth = max(stats.MaxDepth, q.stats.MaxDepth)
+		}
+		vp.hooks.OnSearchDone(stats)
+	}
+
+	return
+}
+
+// splitDepth returns the depth at which parallel searches split the tree,
+// which leaves a few subtrees per worker, so that uneven subtrees even out.
+func splitDepth(workers int) int32 {
+	return int32(bits.Len(uint(4*workers - 1)))
+}
+
+// searchFrontier calls search with the frames of frontier on workers
+// goroutines, in order, passing the number of the worker as w. It returns
+// once all frames have been searched.
+func searchFrontier(frontier []searchFrame, workers int, search func(w int, f searchFrame)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(frontier) {
+					return
+				}
+				search(w, frontier[i])
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
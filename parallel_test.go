@@ -0,0 +1,151 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// This test makes sure SearchParallel returns the same results as Search for
+// various k and worker counts, including on small and empty trees
+func TestSearchParallel(t *testing.T) {
+	for _, n := range []int{0, 1, 5, 1000} {
+		_, items := randomCoordinates(n)
+		vp := New(CoordinateMetric, items)
+
+		for i := 0; i < 50; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			k := rand.Intn(30) + 1
+			workers := rand.Intn(8)
+
+			expectedResults, expectedDistances := vp.Search(q, k)
+			results, distances := vp.SearchParallel(q, k, workers)
+
+			compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+		}
+	}
+}
+
+// This test makes sure SearchParallel doesn't return items twice on spill
+// trees, which store some items in more than one subtree
+func TestSearchParallelSpillTree(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := NewSpillTree(CoordinateMetric, items, 0.1)
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedResults, expectedDistances := vp.Search(q, 10)
+		results, distances := vp.SearchParallel(q, 10, 4)
+
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+	}
+}
+
+func BenchmarkSearchParallelSlowMetric(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 10000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	// Spin rather than sleep, since sleeps are much coarser than a
+	// microsecond on many systems
+	slow := func(a, b interface{}) float64 {
+		for start := time.Now(); time.Since(start) < 10*time.Microsecond; {
+		}
+		return CoordinateMetric(a, b)
+	}
+
+	vp := New(slow, items)
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vp.Search(Coordinate{X: rng.Float64(), Y: rng.Float64()}, 10)
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vp.SearchParallel(Coordinate{X: rng.Float64(), Y: rng.Float64()}, 10, 0)
+		}
+	})
+}
+
+// This test makes sure SearchRadiusParallel and SearchRadiusParallelFunc
+// find the same items as SearchRadius, on regular and spill trees
+func TestSearchRadiusParallel(t *testing.T) {
+	_, items := randomCoordinates(1000)
+
+	for _, vp := range []*VPTree{New(CoordinateMetric, items), NewSpillTree(CoordinateMetric, items, 0.1), New(CoordinateMetric, nil)} {
+		for i := 0; i < 50; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			radius := rand.Float64() * 0.3
+			workers := rand.Intn(8)
+
+			expectedResults, expectedDistances := vp.SearchRadius(q, radius)
+			results, distances := vp.SearchRadiusParallel(q, radius, workers)
+
+			compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+			var mu sync.Mutex
+			var streamed []Coordinate
+			vp.SearchRadiusParallelFunc(q, radius, workers, func(item interface{}, distance float64) {
+				mu.Lock()
+				streamed = append(streamed, item.(Coordinate))
+				mu.Unlock()
+			})
+
+			sort.Slice(streamed, func(i, j int) bool {
+				return CoordinateMetric(streamed[i], q) < CoordinateMetric(streamed[j], q)
+			})
+
+			if len(streamed) != len(expectedResults) {
+				t.Fatalf("Expected %v streamed items, got %v", len(expectedResults), len(streamed))
+			}
+
+			for j := range streamed {
+				if streamed[j] != expectedResults[j] {
+					t.Errorf("Expected streamed[%v] to be %v, got %v", j, expectedResults[j], streamed[j])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkSearchRadiusParallel(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 1000000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	vp := New(CoordinateMetric, items)
+
+	// A radius of 0.13 covers about 5% of the unit square
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vp.SearchRadius(Coordinate{X: 0.5, Y: 0.5}, 0.13)
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vp.SearchRadiusParallel(Coordinate{X: 0.5, Y: 0.5}, 0.13, 0)
+		}
+	})
+
+	b.Run("ParallelFunc", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var count atomic.Int64
+			vp.SearchRadiusParallelFunc(Coordinate{X: 0.5, Y: 0.5}, 0.13, 0, func(item interface{}, distance float64) {
+				count.Add(1)
+			})
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package vptree
+
+import (
+	"fmt"
+)
+
+// An Interpolatable item can produce the points on a path to another item.
+// Interpolate(other, 0) should be the item itself, Interpolate(other, 1)
+// should be other, and values in between should move along the path, as
+// with linear interpolation between vectors or spherical interpolation
+// between embeddings.
+type Interpolatable interface {
+	Interpolate(other interface{}, t float64) interface{}
+}
+
+// SearchAlongPath samples steps evenly spaced points on the path from a to b,
+// including a and b themselves, and returns the k nearest neighbours of each
+// sample in order of least distance to largest distance. a must implement
+// Interpolatable; SearchAlongPath panics otherwise.
+func (vp *VPTree) SearchAlongPath(a, b interface{}, steps int, k int) (results [][]interface{}) {
+	from, ok := a.(Interpolatable)
+	if !ok {
+		panic(fmt.Sprintf("vptree: SearchAlongPath needs an Interpolatable, got %T", a))
+	}
+
+	for i := 0; i < steps; i++ {
+		t := 0.0
+		if steps > 1 {
+			t = float64(i) / float64(steps-1)
+		}
+
+		neighbours, _ := vp.Search(from.Interpolate(b, t), k)
+		results = append(results, neighbours)
+	}
+
+	return
+}
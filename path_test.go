@@ -0,0 +1,50 @@
+package vptree
+
+import (
+	"testing"
+)
+
+func (c Coordinate) Interpolate(other interface{}, t float64) interface{} {
+	o := other.(Coordinate)
+	return Coordinate{X: c.X + t*(o.X-c.X), Y: c.Y + t*(o.Y-c.Y)}
+}
+
+// This test walks along the diagonal of a grid and checks the neighbours of
+// every sample
+func TestSearchAlongPath(t *testing.T) {
+	var items []interface{}
+	for x := 0; x <= 10; x++ {
+		for y := 0; y <= 10; y++ {
+			items = append(items, Coordinate{float64(x), float64(y)})
+		}
+	}
+
+	vp := New(CoordinateMetric, items)
+	results := vp.SearchAlongPath(Coordinate{0, 0}, Coordinate{10, 10}, 6, 1)
+
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 samples, got %v", len(results))
+	}
+
+	for i, neighbours := range results {
+		expected := Coordinate{float64(2 * i), float64(2 * i)}
+		if len(neighbours) != 1 || neighbours[0] != expected {
+			t.Errorf("Expected sample %v to find %v, got %v", i, expected, neighbours)
+		}
+	}
+
+	if results := vp.SearchAlongPath(Coordinate{3, 4}, Coordinate{10, 10}, 1, 2); len(results) != 1 || results[0][0] != (Coordinate{3, 4}) {
+		t.Errorf("Expected a single sample at the start, got %v", results)
+	}
+}
+
+// This test makes sure items that can't be interpolated are rejected
+func TestSearchAlongPathPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+
+	New(CoordinateMetric, nil).SearchAlongPath(42, 43, 3, 1)
+}
@@ -0,0 +1,187 @@
+package vptree
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// An Index answers nearest-neighbour and range queries over a fixed set of
+// items. VPTree, OnlineVPTree, MVPTree, BKTree, LinearIndex, PivotIndex and
+// MappedTree all implement it, so that they can be swapped for one another.
+type Index interface {
+	Len() int
+	Search(target interface{}, k int) (results []interface{}, distances []float64)
+	SearchIndices(target interface{}, k int) (indices []int, distances []float64)
+	SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64)
+}
+
+var (
+	_ Index = (*VPTree)(nil)
+	_ Index = (*LinearIndex)(nil)
+	_ Index = (*PivotIndex)(nil)
+	_ Index = (*MappedTree)(nil)
+	_ Index = (*OnlineVPTree)(nil)
+	_ Index = (*MVPTree)(nil)
+	_ Index = (*BKTree)(nil)
+)
+
+// A PivotIndex is a flat pivot table in the style of LAESA. It stores the
+// distances from every item to m pivots, and at query time, after computing
+// the distances from the target to the pivots, bounds the distance to every
+// item from below by the triangle inequality:
+//
+//	d(target, item) >= |d(target, pivot) - d(item, pivot)|
+//
+// Only the items whose bound does not rule them out are compared with the
+// target using the metric. Unlike a VPTree, a PivotIndex spends m metric
+// calls on every query up front and needs n·m distances of memory, but the
+// bounds of all pivots together often prune more than a tree does, which
+// pays off for expensive metrics.
+type PivotIndex struct {
+	items          []interface{}
+	distanceMetric Metric
+
+	// pivots are the indices of the pivots, and table[i*len(pivots)+j] is
+	// the distance of items[i] to the j-th pivot.
+	pivots []int
+	table  []float64
+}
+
+// NewPivotIndex creates a PivotIndex over items with m pivots. The pivots are
+// the first m items of the greedy permutation of items, starting with the
+// first item, so that they are well spread. See GreedyPermutation.
+func NewPivotIndex(metric Metric, items []interface{}, m int) *PivotIndex {
+	pi := &PivotIndex{
+		items:          items,
+		distanceMetric: metric,
+	}
+
+	if len(items) == 0 {
+		return pi
+	}
+
+	pi.pivots = farthestFirst(metric, items, 0, max(m, 1))
+	pi.table = make([]float64, len(items)*len(pi.pivots))
+	for j, p := range pi.pivots {
+		for i, item := range items {
+			pi.table[i*len(pi.pivots)+j] = metric(items[p], item)
+		}
+	}
+
+	return pi
+}
+
+// Len returns the number of items in the PivotIndex.
+func (pi *PivotIndex) Len() int {
+	return len(pi.items)
+}
+
+// Search searches the PivotIndex for the k nearest neighbours of target. It
+// returns the up to k closest items, ordered by increasing distance.
+func (pi *PivotIndex) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	for _, nb := range pi.nearest(target, k) {
+		results = append(results, nb.Item)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// SearchIndices is like Search, but returns the indices of the items instead
+// of the items themselves.
+func (pi *PivotIndex) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	for _, nb := range pi.nearest(target, k) {
+		indices = append(indices, nb.Index)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// SearchRadius returns all items within radius of target, ordered by
+// increasing distance.
+func (pi *PivotIndex) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	if len(pi.items) == 0 {
+		return
+	}
+
+	var found []Neighbour
+	for _, c := range pi.candidates(target) {
+		if c.Distance > radius {
+			break
+		}
+		if dist := pi.distanceMetric(c.Item, target); dist <= radius {
+			found = append(found, Neighbour{c.Item, c.Index, dist})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Distance < found[j].Distance
+	})
+
+	for _, nb := range found {
+		results = append(results, nb.Item)
+		distances = append(distances, nb.Distance)
+	}
+	return
+}
+
+// nearest returns the k nearest neighbours of target, closest first.
+func (pi *PivotIndex) nearest(target interface{}, k int) []Neighbour {
+	if k < 1 || len(pi.items) == 0 {
+		return nil
+	}
+
+	h := make(MaxDistanceHeap, 0, min(k, len(pi.items)))
+	for _, c := range pi.candidates(target) {
+		// The candidates come in order of their lower bounds, so once a
+		// bound exceeds tau, so do all the ones after it
+		if len(h) == k && c.Distance > h.Top().Distance {
+			break
+		}
+
+		dist := pi.distanceMetric(c.Item, target)
+		switch {
+		case len(h) < k:
+			heap.Push(&h, Neighbour{c.Item, c.Index, dist})
+		case dist < h.Top().Distance:
+			h[0] = Neighbour{c.Item, c.Index, dist}
+			heap.Fix(&h, 0)
+		}
+	}
+
+	found := make([]Neighbour, len(h))
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(&h).(Neighbour)
+	}
+	return found
+}
+
+// candidates computes the distances of target to the pivots and returns all
+// items, with the lower bounds of their distances to target in place of the
+// distances, ordered by increasing bound.
+func (pi *PivotIndex) candidates(target interface{}) []Neighbour {
+	m := len(pi.pivots)
+
+	pivotDists := make([]float64, m)
+	for j, p := range pi.pivots {
+		pivotDists[j] = pi.distanceMetric(pi.items[p], target)
+	}
+
+	candidates := make([]Neighbour, len(pi.items))
+	for i, item := range pi.items {
+		row := pi.table[i*m : (i+1)*m]
+
+		bound := 0.0
+		for j, d := range row {
+			bound = math.Max(bound, math.Abs(pivotDists[j]-d))
+		}
+
+		candidates[i] = Neighbour{item, i, bound}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Distance < candidates[j].Distance
+	})
+
+	return candidates
+}
@@ -0,0 +1,98 @@
+package vptree
+
+import (
+	"math/rand"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// This test checks the PivotIndex against a VPTree and a linear scan over
+// the same items
+func TestPivotIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomVectors(rng, 1000, 4)
+
+	indexes := map[string]Index{
+		"PivotIndex": NewPivotIndex(euclidean, items, 8),
+		"VPTree":     New(euclidean, items),
+		"Linear":     NewLinearIndex(euclidean, items),
+	}
+
+	for i := 0; i < 100; i++ {
+		q := randomVectors(rng, 1, 4)[0]
+		k := rng.Intn(20) + 1
+		radius := rng.Float64() * 0.5
+
+		_, expected := indexes["Linear"].Search(q, k)
+		expectedIndices, _ := indexes["Linear"].SearchIndices(q, k)
+		_, expectedRadius := indexes["Linear"].SearchRadius(q, radius)
+
+		for name, idx := range indexes {
+			if idx.Len() != len(items) {
+				t.Errorf("%v: expected %v items, got %v", name, len(items), idx.Len())
+			}
+
+			_, distances := idx.Search(q, k)
+			if !slices.Equal(distances, expected) {
+				t.Errorf("%v: expected distances %v, got %v", name, expected, distances)
+			}
+
+			indices, _ := idx.SearchIndices(q, k)
+			if !slices.Equal(indices, expectedIndices) {
+				t.Errorf("%v: expected indices %v, got %v", name, expectedIndices, indices)
+			}
+
+			_, distances = idx.SearchRadius(q, radius)
+			if !slices.Equal(distances, expectedRadius) {
+				t.Errorf("%v: expected distances %v within %v, got %v", name, expectedRadius, radius, distances)
+			}
+		}
+	}
+}
+
+// This test covers an empty PivotIndex and more pivots than items
+func TestPivotIndexSmall(t *testing.T) {
+	empty := NewPivotIndex(CoordinateMetric, nil, 4)
+	if results, _ := empty.Search(Coordinate{}, 3); len(results) != 0 {
+		t.Errorf("Expected no results from an empty index, got %v", results)
+	}
+	if results, _ := empty.SearchRadius(Coordinate{}, 1); len(results) != 0 {
+		t.Errorf("Expected no results from an empty index, got %v", results)
+	}
+
+	items := []interface{}{Coordinate{0, 0}, Coordinate{1, 0}, Coordinate{5, 0}}
+	pi := NewPivotIndex(CoordinateMetric, items, 10)
+
+	results, distances := pi.Search(Coordinate{2, 0}, 5)
+	compareCoordDistSets(t, results, []Coordinate{{1, 0}, {0, 0}, {5, 0}}, distances, []float64{1, 2, 3})
+}
+
+func BenchmarkPivotIndex(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomVectors(rng, 20000, 8)
+	queries := randomVectors(rng, 100, 8)
+
+	var calls atomic.Int64
+	metric := func(a, b interface{}) float64 {
+		calls.Add(1)
+		return euclidean(a, b)
+	}
+
+	run := func(b *testing.B, idx Index) {
+		calls.Store(0)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			idx.Search(queries[i%len(queries)], 10)
+		}
+		b.ReportMetric(float64(calls.Load())/float64(b.N), "calls/op")
+	}
+
+	b.Run("VPTree", func(b *testing.B) {
+		run(b, New(metric, items))
+	})
+
+	b.Run("PivotIndex", func(b *testing.B) {
+		run(b, NewPivotIndex(metric, items, 16))
+	})
+}
@@ -0,0 +1,112 @@
+package vptree
+
+import (
+	"slices"
+)
+
+// GreedyPermutation returns the indices of the first m items of the greedy
+// permutation of the VP-tree's items, which is also known as farthest-first
+// traversal. It starts with the vantage point of the root, and every further
+// item is the one farthest from all items chosen before it, with ties going
+// to the lowest index. Every prefix of the permutation is thus well spread
+// over the data set. If m exceeds the number of items, all of them are
+// returned.
+func (vp *VPTree) GreedyPermutation(m int) []int {
+	nodes := vp.greedyPermutation(m)
+
+	perm := make([]int, len(nodes))
+	for i, n := range nodes {
+		perm[i] = n.Index
+	}
+	return perm
+}
+
+// PivotEmbedding embeds the VP-tree's metric space into R^m, the pivot
+// space, by mapping every object to its distances to m pivots, which are the
+// first m items of the greedy permutation. embed computes the embedding of
+// any object, whether it is in the tree or not.
+//
+// The embedding is contractive: by the triangle inequality, the L∞ distance
+// between the embeddings of two objects, that is, the largest difference of
+// their distances to any one pivot, is a lower bound of their distance. A
+// filter-and-refine search can thus discard candidates whose L∞ distance in
+// pivot space exceeds the search radius, and only compute the true distance
+// for the rest.
+func (vp *VPTree) PivotEmbedding(m int) (pivots []interface{}, embed func(item interface{}) []float64) {
+	for _, n := range vp.greedyPermutation(m) {
+		pivots = append(pivots, n.Item)
+	}
+
+	metric := vp.distanceMetric
+	embed = func(item interface{}) []float64 {
+		coords := make([]float64, len(pivots))
+		for i, p := range pivots {
+			coords[i] = metric(p, item)
+		}
+		return coords
+	}
+
+	return
+}
+
+// greedyPermutation returns the nodes of the first m items of the greedy
+// permutation. See GreedyPermutation.
+func (vp *VPTree) greedyPermutation(m int) []*node {
+	nodes := vp.uniqueNodes()
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(nodes, func(a, b *node) int { return a.Index - b.Index })
+
+	items := make([]interface{}, len(nodes))
+	start := 0
+	for i, n := range nodes {
+		items[i] = n.Item
+		if n.Index == vp.root.Index {
+			start = i
+		}
+	}
+
+	var perm []*node
+	for _, p := range farthestFirst(vp.distanceMetric, items, start, m) {
+		perm = append(perm, nodes[p])
+	}
+	return perm
+}
+
+// farthestFirst returns the indices of the first m items of the greedy
+// permutation of items that starts at items[start].
+func farthestFirst(metric Metric, items []interface{}, start, m int) []int {
+	m = min(m, len(items))
+	if m < 1 {
+		return nil
+	}
+
+	// dists[i] is the distance of items[i] to the nearest chosen item
+	dists := make([]float64, len(items))
+	for i := range dists {
+		dists[i] = metric(items[start], items[i])
+	}
+
+	chosen := make([]bool, len(items))
+	chosen[start] = true
+
+	perm := []int{start}
+	for len(perm) < m {
+		next := -1
+		for i, d := range dists {
+			if !chosen[i] && (next < 0 || d > dists[next]) {
+				next = i
+			}
+		}
+		chosen[next] = true
+		perm = append(perm, next)
+
+		for i, d := range dists {
+			dists[i] = min(d, metric(items[next], items[i]))
+		}
+	}
+
+	return perm
+}
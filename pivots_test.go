@@ -0,0 +1,90 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// This test checks the greedy permutation against its definition: every item
+// is the one farthest from the items before it
+func TestGreedyPermutation(t *testing.T) {
+	coords, items := randomCoordinates(500)
+	vp := New(CoordinateMetric, items)
+
+	perm := vp.GreedyPermutation(len(items))
+	if len(perm) != len(items) {
+		t.Fatalf("Expected a permutation of %v items, got %v", len(items), len(perm))
+	}
+	if perm[0] != vp.root.Index {
+		t.Errorf("Expected the permutation to start with the root's vantage point %v, got %v", vp.root.Index, perm[0])
+	}
+
+	sorted := slices.Clone(perm)
+	slices.Sort(sorted)
+	for i, p := range sorted {
+		if p != i {
+			t.Fatalf("Expected every index exactly once, got %v", sorted)
+		}
+	}
+
+	// Checking the whole permutation takes quadratic time, so only check
+	// the beginning
+	for i := 1; i < 50; i++ {
+		spread := func(c Coordinate) float64 {
+			d := math.Inf(1)
+			for _, p := range perm[:i] {
+				d = math.Min(d, CoordinateMetric(coords[p], c))
+			}
+			return d
+		}
+
+		for _, c := range coords {
+			if spread(c) > spread(coords[perm[i]]) {
+				t.Fatalf("Item %v of the permutation is not the farthest from the items before it", i)
+			}
+		}
+	}
+
+	if prefix := vp.GreedyPermutation(10); !slices.Equal(prefix, perm[:10]) {
+		t.Errorf("Expected %v to be a prefix of the permutation, got %v", perm[:10], prefix)
+	}
+
+	if perm := New(CoordinateMetric, nil).GreedyPermutation(10); len(perm) != 0 {
+		t.Errorf("Expected an empty permutation for an empty tree, got %v", perm)
+	}
+}
+
+// This test makes sure the pivots are the prefix of the greedy permutation
+// and that the embedding is contractive
+func TestPivotEmbedding(t *testing.T) {
+	coords, items := randomCoordinates(500)
+	vp := New(CoordinateMetric, items)
+
+	pivots, embed := vp.PivotEmbedding(8)
+	for i, p := range vp.GreedyPermutation(8) {
+		if pivots[i] != items[p] {
+			t.Errorf("Expected pivot %v to be %v, got %v", i, items[p], pivots[i])
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		a := coords[rand.Intn(len(coords))]
+		b := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		ea, eb := embed(a), embed(b)
+		if len(ea) != 8 || len(eb) != 8 {
+			t.Fatalf("Expected 8 coordinates, got %v and %v", ea, eb)
+		}
+
+		lower := 0.0
+		for j := range ea {
+			lower = math.Max(lower, math.Abs(ea[j]-eb[j]))
+		}
+
+		if d := CoordinateMetric(a, b); lower > d+1e-12 {
+			t.Errorf("Expected the pivot-space distance %v to be at most %v", lower, d)
+		}
+	}
+}
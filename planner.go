@@ -0,0 +1,147 @@
+package vptree
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// A Plan is a way to run a search.
+type Plan uint8
+
+const (
+	// PlanTree searches the tree, pruning subtrees that cannot hold
+	// results.
+	PlanTree Plan = iota
+
+	// PlanScan computes the distance to every item.
+	PlanScan
+)
+
+// planVisitOverhead is the cost of visiting a node, beyond its metric call,
+// relative to the cost of a metric call during a scan. It accounts for the
+// bookkeeping of the tree search.
+const planVisitOverhead = 0.25
+
+// planExploreInterval is how often a search that the planner would run as a
+// scan runs on the tree anyway, so that the planner notices when pruning
+// improves.
+const planExploreInterval = 16
+
+// planSmoothing is the weight of the latest search in the moving averages of
+// the planner.
+const planSmoothing = 0.1
+
+// A planner decides whether searches run on the tree or as a linear scan,
+// based on how much of the tree recent searches visited.
+type planner struct {
+	// threshold is the ratio of the estimated tree cost to the scan cost
+	// from which on searches scan
+	threshold float64
+
+	// knnVisited and radiusVisited hold the float64 bits of moving
+	// averages of the fraction of nodes visited by k-NN and range
+	// searches on the tree
+	knnVisited    atomic.Uint64
+	radiusVisited atomic.Uint64
+
+	scans atomic.Uint64
+}
+
+// WithAutoPlan makes the VP-tree decide for every k-nearest-neighbour and
+// range search whether to search the tree or scan all items. Scans win when
+// the tree cannot prune, such as for high-dimensional data, huge radii, or k
+// close to the number of items, because they skip the bookkeeping of the
+// tree search. The planner estimates the cost of a tree search from the
+// fraction of the tree recent searches visited, and from k for k-NN
+// searches. Both searches return the same results. The decision and the
+// estimates are reported in QueryStats. Spill trees, and approximate
+// searches such as those of a Searcher with WithMaxVisits, SearchTruncated
+// and SearchSampled, always search the tree.
+func WithAutoPlan() Option {
+	return func(vp *VPTree) {
+		vp.planner = &planner{threshold: 1}
+	}
+}
+
+// visited returns the moving average of the fraction of the tree that
+// k-NN or range searches visit.
+func (p *planner) visited(radius bool) *atomic.Uint64 {
+	if radius {
+		return &p.radiusVisited
+	}
+	return &p.knnVisited
+}
+
+// plan decides how to run a k-NN or range search, recording the decision and
+// the estimates in stats. A search for minResults results visits at least as
+// many nodes.
+func (vp *VPTree) plan(stats *QueryStats, radius bool, minResults int) Plan {
+	p := vp.planner
+	if p == nil || vp.spill > 0 || vp.root == nil {
+		return PlanTree
+	}
+
+	n := float64(vp.root.Size)
+	fraction := math.Max(math.Float64frombits(p.visited(radius).Load()), float64(minResults)/n)
+
+	stats.EstimatedTreeCost = fraction * n * (1 + planVisitOverhead)
+	stats.EstimatedScanCost = n
+	stats.Plan = PlanTree
+
+	if stats.EstimatedTreeCost >= p.threshold*stats.EstimatedScanCost && p.scans.Add(1)%planExploreInterval != 0 {
+		stats.Plan = PlanScan
+	}
+
+	return stats.Plan
+}
+
+// observe updates the planner's moving average with a k-NN or range search
+// on the tree that visited the given number of nodes. Concurrent updates may
+// get lost, which the average can afford.
+func (vp *VPTree) observe(radius bool, nodesVisited int) {
+	if vp.planner == nil || vp.root == nil {
+		return
+	}
+
+	visited := vp.planner.visited(radius)
+	fraction := float64(nodesVisited) / float64(vp.root.Size)
+	// Searches visit at least the root, so 0 means there is no average
+	// yet
+	if old := math.Float64frombits(visited.Load()); old > 0 {
+		fraction = old + planSmoothing*(fraction-old)
+	}
+	visited.Store(math.Float64bits(fraction))
+}
+
+// scanKNN runs q by computing the distance to every item.
+func (vp *VPTree) scanKNN(q *knnQuery) {
+	vp.forEachNode(func(n *node) {
+		q.current = n
+		dist := vp.distance(n.Item, q.target, q.tau, q.scratch)
+
+		if q.stats != nil {
+			q.stats.MetricCalls++
+			q.stats.NodesVisited++
+		}
+
+		if dist < q.tau && vp.live(n) {
+			q.add(heapItem{n.Item, n.Index, dist})
+		}
+	})
+}
+
+// scanRadius runs q by computing the distance to every item.
+func (vp *VPTree) scanRadius(q *radiusQuery) {
+	vp.forEachNode(func(n *node) {
+		dist := vp.distance(n.Item, q.target, q.radius, q.scratch)
+
+		if q.stats != nil {
+			q.stats.MetricCalls++
+			q.stats.NodesVisited++
+		}
+
+		if dist <= q.radius && vp.live(n) {
+			q.found = append(q.found, heapItem{n.Item, n.Index, dist})
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test forces the planner to scan and to search the tree, and makes
+// sure both give the same results as a plain VP-tree
+func TestAutoPlan(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	plain := New(CoordinateMetric, items)
+
+	for _, threshold := range []float64{0, math.Inf(1)} {
+		var plans []Plan
+		vp := New(CoordinateMetric, items, WithAutoPlan(), WithInstrumentation(Hooks{
+			OnSearchDone: func(stats QueryStats) { plans = append(plans, stats.Plan) },
+		}))
+		vp.planner.threshold = threshold
+
+		for i := 0; i < 50; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			k := rand.Intn(20) + 1
+
+			expectedResults, expectedDistances := plain.Search(q, k)
+			results, distances := vp.Search(q, k)
+			compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+			expectedResults, expectedDistances = plain.SearchRadius(q, 0.1)
+			results, distances = vp.SearchRadius(q, 0.1)
+			compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+		}
+
+		scans := 0
+		for _, p := range plans {
+			if p == PlanScan {
+				scans++
+			}
+		}
+
+		// Every planExploreInterval-th search that would scan searches
+		// the tree instead
+		expected := 0
+		if threshold == 0 {
+			expected = len(plans) - len(plans)/planExploreInterval
+		}
+		if scans != expected {
+			t.Errorf("Expected %v of %v searches to scan at threshold %v, got %v", expected, len(plans), threshold, scans)
+		}
+	}
+}
+
+// This test makes sure approximate searches search the tree even when the
+// planner would scan, so that their budgets and skipped subtrees still apply
+func TestAutoPlanApproximate(t *testing.T) {
+	_, items := randomCoordinates(2000)
+
+	calls := 0
+	metric := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	vp := New(metric, items, WithAutoPlan())
+	vp.planner.threshold = 0
+	s := vp.NewSearcher(WithMaxVisits(10))
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		calls = 0
+		s.Search(q, 1500)
+		if calls > 10 {
+			t.Errorf("Expected a Searcher with WithMaxVisits(10) to call the metric at most 10 times, got %v", calls)
+		}
+
+		if _, _, truncated := vp.SearchTruncated(q, 1500, 1000); !truncated {
+			t.Errorf("Expected SearchTruncated to skip subtrees")
+		}
+
+		calls = 0
+		vp.SearchSampled(q, 1500, 0.1)
+		if calls >= len(items) {
+			t.Errorf("Expected SearchSampled to call the metric less than %v times, got %v", len(items), calls)
+		}
+	}
+}
+
+// This test makes sure the planner switches to scanning on data the tree
+// cannot prune, and reports its estimates
+func TestAutoPlanHighDimensional(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomVectors(rng, 2000, 32)
+	vp := New(euclidean, items, WithAutoPlan())
+
+	// Give the planner some searches to learn from
+	scans := 0
+	for _, q := range randomVectors(rng, 50, 32) {
+		_, _, stats := vp.SearchWithStats(q, 10)
+		if stats.Plan == PlanScan {
+			scans++
+			if stats.EstimatedScanCost != 2000 || stats.EstimatedTreeCost < stats.EstimatedScanCost {
+				t.Errorf("Expected a tree cost estimate above the scan cost of 2000, got %+v", stats)
+			}
+		}
+	}
+
+	if scans < 40 {
+		t.Errorf("Expected most of 50 searches to scan, got %v", scans)
+	}
+
+	var stats QueryStats
+
+	// Low-dimensional data prunes well
+	_, items = randomCoordinates(2000)
+	vp = New(CoordinateMetric, items, WithAutoPlan())
+	for i := 0; i < 50; i++ {
+		_, _, stats = vp.SearchWithStats(Coordinate{X: rand.Float64(), Y: rand.Float64()}, 10)
+	}
+
+	if stats.Plan != PlanTree || stats.EstimatedTreeCost >= stats.EstimatedScanCost {
+		t.Errorf("Expected the planner to search the tree, got %+v", stats)
+	}
+}
+
+func randomVectors(rng *rand.Rand, n, dim int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		v := make([]float64, dim)
+		for j := range v {
+			v[j] = rng.Float64()
+		}
+		items[i] = v
+	}
+	return items
+}
+
+func BenchmarkAutoPlanHighDimensional(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomVectors(rng, 20000, 32)
+	queries := randomVectors(rng, 100, 32)
+
+	b.Run("Tree", func(b *testing.B) {
+		vp := New(euclidean, items)
+		for i := 0; i < b.N; i++ {
+			vp.Search(queries[i%len(queries)], 10)
+		}
+	})
+
+	b.Run("AutoPlan", func(b *testing.B) {
+		vp := New(euclidean, items, WithAutoPlan())
+		for i := 0; i < b.N; i++ {
+			vp.Search(queries[i%len(queries)], 10)
+		}
+	})
+}
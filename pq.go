@@ -1,31 +1,112 @@
 package vptree
 
-type priorityQueue []*heapItem
+import (
+	"container/heap"
+)
 
-func (pq priorityQueue) Len() int { return len(pq) }
+// A compactQueue is a max-heap of candidates that keeps the items, their
+// indices and their distances in parallel slices rather than as pointers to
+// heapItems. Comparisons only touch the densely packed distances, and there
+// are no heapItems to allocate or to chase pointers to. k-NN searches keep
+// their candidates in one. Its heap.Interface methods move the entries of
+// all three slices together; push, popMax and replaceTop avoid boxing the
+// candidates in interface values.
+type compactQueue struct {
+	items   []interface{}
+	indices []int
+	dists   []float64
+}
+
+func (cq *compactQueue) Len() int { return len(cq.dists) }
 
-func (pq priorityQueue) Less(i, j int) bool {
+func (cq *compactQueue) Less(i, j int) bool {
 	// We want a max-heap, so we use greater-than here
-	return pq[i].Dist > pq[j].Dist
+	return cq.dists[i] > cq.dists[j]
+}
+
+func (cq *compactQueue) Swap(i, j int) {
+	cq.items[i], cq.items[j] = cq.items[j], cq.items[i]
+	cq.indices[i], cq.indices[j] = cq.indices[j], cq.indices[i]
+	cq.dists[i], cq.dists[j] = cq.dists[j], cq.dists[i]
+}
+
+func (cq *compactQueue) Push(x interface{}) {
+	hi := x.(heapItem)
+	cq.items = append(cq.items, hi.Item)
+	cq.indices = append(cq.indices, hi.Index)
+	cq.dists = append(cq.dists, hi.Dist)
+}
+
+func (cq *compactQueue) Pop() interface{} {
+	n := len(cq.dists) - 1
+	hi := heapItem{cq.items[n], cq.indices[n], cq.dists[n]}
+	cq.items[n] = nil
+	cq.items, cq.indices, cq.dists = cq.items[:n], cq.indices[:n], cq.dists[:n]
+	return hi
+}
+
+// push adds a candidate to the queue.
+func (cq *compactQueue) push(hi heapItem) {
+	cq.items = append(cq.items, hi.Item)
+	cq.indices = append(cq.indices, hi.Index)
+	cq.dists = append(cq.dists, hi.Dist)
+	heap.Fix(cq, len(cq.dists)-1)
+}
+
+// top returns the farthest candidate. The queue must not be empty.
+func (cq *compactQueue) top() heapItem {
+	return cq.at(0)
+}
+
+// at returns the i-th candidate in heap order.
+func (cq *compactQueue) at(i int) heapItem {
+	return heapItem{cq.items[i], cq.indices[i], cq.dists[i]}
+}
+
+// popMax removes the farthest candidate and returns it. The queue must not
+// be empty.
+func (cq *compactQueue) popMax() heapItem {
+	n := len(cq.dists) - 1
+	cq.Swap(0, n)
+	hi := heapItem{cq.items[n], cq.indices[n], cq.dists[n]}
+	cq.items[n] = nil
+	cq.items, cq.indices, cq.dists = cq.items[:n], cq.indices[:n], cq.dists[:n]
+	if n > 0 {
+		heap.Fix(cq, 0)
+	}
+	return hi
 }
 
-func (pq priorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
+// contains reports whether the item with the given index is in the queue.
+func (cq *compactQueue) contains(index int) bool {
+	for _, i := range cq.indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
 }
 
-func (pq *priorityQueue) Push(i interface{}) {
-	item := i.(*heapItem)
-	*pq = append(*pq, item)
+// reset empties the queue, clearing the items, so that it doesn't keep them
+// alive.
+func (cq *compactQueue) reset() {
+	clear(cq.items[:cap(cq.items)])
+	cq.items, cq.indices, cq.dists = cq.items[:0], cq.indices[:0], cq.dists[:0]
 }
 
-func (pq *priorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	item := old[n-1]
-	*pq = old[0 : n-1]
-	return item
+// replaceTop replaces the farthest candidate with hi. The queue must not be
+// empty.
+func (cq *compactQueue) replaceTop(hi heapItem) {
+	cq.items[0], cq.indices[0], cq.dists[0] = hi.Item, hi.Index, hi.Dist
+	heap.Fix(cq, 0)
 }
 
-func (pq priorityQueue) Top() interface{} {
-	return pq[0]
+// newCompactQueue returns an empty compactQueue with room for capacity
+// candidates.
+func newCompactQueue(capacity int) compactQueue {
+	return compactQueue{
+		items:   make([]interface{}, 0, capacity),
+		indices: make([]int, 0, capacity),
+		dists:   make([]float64, 0, capacity),
+	}
 }
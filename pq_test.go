@@ -0,0 +1,128 @@
+package vptree
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// A priorityQueue is a max-heap of pointers to heapItems, which k-NN
+// searches used before compactQueue. The tests keep it as a reference.
+type priorityQueue []*heapItem
+
+func (pq priorityQueue) Len() int { return len(pq) }
+
+func (pq priorityQueue) Less(i, j int) bool {
+	// We want a max-heap, so we use greater-than here
+	return pq[i].Dist > pq[j].Dist
+}
+
+func (pq priorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+}
+
+func (pq *priorityQueue) Push(i interface{}) {
+	item := i.(*heapItem)
+	*pq = append(*pq, item)
+}
+
+func (pq *priorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	*pq = old[0 : n-1]
+	return item
+}
+
+func (pq priorityQueue) Top() interface{} {
+	return pq[0]
+}
+
+// This test feeds the same candidates to a priorityQueue and a compactQueue
+// bounded to k and compares what they keep
+func TestCompactQueue(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, k := range []int{1, 5, 32} {
+		pq := priorityQueue{}
+		cq := &compactQueue{}
+
+		for i := 0; i < 1000; i++ {
+			hi := heapItem{Item: i, Index: i, Dist: rng.Float64()}
+
+			switch {
+			case pq.Len() < k:
+				heap.Push(&pq, &heapItem{hi.Item, hi.Index, hi.Dist})
+				cq.push(hi)
+			case hi.Dist < pq.Top().(*heapItem).Dist:
+				heap.Pop(&pq)
+				heap.Push(&pq, &heapItem{hi.Item, hi.Index, hi.Dist})
+				cq.replaceTop(hi)
+			}
+
+			if cq.Len() != pq.Len() || cq.top() != *pq.Top().(*heapItem) {
+				t.Fatalf("k = %v: expected the top %v, got %v", k, *pq.Top().(*heapItem), cq.top())
+			}
+		}
+
+		for pq.Len() > 0 {
+			expected := *heap.Pop(&pq).(*heapItem)
+			if got := heap.Pop(cq).(heapItem); got != expected {
+				t.Fatalf("k = %v: expected %v, got %v", k, expected, got)
+			}
+		}
+	}
+}
+
+// This benchmark compares the pointer-based priorityQueue with the
+// compactQueue on a stream of candidates bounded to the k nearest, as in a
+// k-NN search
+func BenchmarkCompactQueue(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	candidates := make([]heapItem, 1000)
+	for i := range candidates {
+		candidates[i] = heapItem{Item: i, Index: i, Dist: rng.Float64()}
+	}
+
+	for _, k := range []int{1, 10, 32, 128} {
+		b.Run(fmt.Sprintf("Pointer/k=%v", k), func(b *testing.B) {
+			pool := make([]heapItem, k)
+			pq := make(priorityQueue, 0, k)
+			for i := 0; i < b.N; i++ {
+				pq = pq[:0]
+				for _, c := range candidates {
+					switch {
+					case len(pq) < k:
+						hi := &pool[len(pq)]
+						*hi = c
+						heap.Push(&pq, hi)
+					case c.Dist < pq[0].Dist:
+						hi := heap.Pop(&pq).(*heapItem)
+						*hi = c
+						heap.Push(&pq, hi)
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Compact/k=%v", k), func(b *testing.B) {
+			cq := &compactQueue{
+				items:   make([]interface{}, 0, k),
+				indices: make([]int, 0, k),
+				dists:   make([]float64, 0, k),
+			}
+			for i := 0; i < b.N; i++ {
+				cq.items, cq.indices, cq.dists = cq.items[:0], cq.indices[:0], cq.dists[:0]
+				for _, c := range candidates {
+					switch {
+					case cq.Len() < k:
+						cq.push(c)
+					case c.Dist < cq.dists[0]:
+						cq.replaceTop(c)
+					}
+				}
+			}
+		})
+	}
+}
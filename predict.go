@@ -0,0 +1,124 @@
+package vptree
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// A Weighting decides how much each neighbour counts in Regress.
+type Weighting uint8
+
+const (
+	// UniformWeighting gives every neighbour the same weight.
+	UniformWeighting Weighting = iota
+
+	// InverseDistanceWeighting weights neighbours by the inverse of their
+	// distance to the target. If some neighbours are at distance 0, only
+	// they count.
+	InverseDistanceWeighting
+)
+
+// Classify predicts the label of target as the most common label among its
+// k nearest neighbours, as given by labelOf, and also returns the number of
+// votes for every label. Ties go to the tied label of the nearest
+// neighbour. If the VP-tree is empty or k < 1, the label is empty.
+func (vp *VPTree) Classify(target interface{}, k int, labelOf func(interface{}) string) (label string, votes map[string]int) {
+	results, _ := vp.Search(target, k)
+
+	votes = make(map[string]int)
+	for _, item := range results {
+		votes[labelOf(item)]++
+	}
+
+	// The results are ordered by distance, so the first label with the
+	// most votes is the one of the nearest neighbour
+	best := 0
+	for _, item := range results {
+		if l := labelOf(item); votes[l] > best {
+			label, best = l, votes[l]
+		}
+	}
+
+	return
+}
+
+// Regress predicts a value for target from the values of its k nearest
+// neighbours, as given by valueOf, by averaging them with the given
+// weighting. If the VP-tree is empty or k < 1, it returns NaN.
+func (vp *VPTree) Regress(target interface{}, k int, valueOf func(interface{}) float64, weighting Weighting) float64 {
+	results, distances := vp.Search(target, k)
+	if len(results) == 0 {
+		return math.NaN()
+	}
+
+	// Exact matches dominate the inverse distance weights, so they are
+	// averaged on their own
+	if weighting == InverseDistanceWeighting && distances[0] == 0 {
+		weighting = UniformWeighting
+		for len(distances) > 0 && distances[len(distances)-1] > 0 {
+			results, distances = results[:len(results)-1], distances[:len(distances)-1]
+		}
+	}
+
+	sum, weights := 0.0, 0.0
+	for i, item := range results {
+		w := 1.0
+		if weighting == InverseDistanceWeighting {
+			w = 1 / distances[i]
+		}
+		sum += w * valueOf(item)
+		weights += w
+	}
+
+	return sum / weights
+}
+
+// ClassifyBatch is like Classify, but predicts the labels of all targets,
+// on workers goroutines. If workers is not positive, GOMAXPROCS is used.
+func (vp *VPTree) ClassifyBatch(targets []interface{}, k int, labelOf func(interface{}) string, workers int) []string {
+	labels := make([]string, len(targets))
+	forEachIndex(len(targets), workers, func(i int) {
+		labels[i], _ = vp.Classify(targets[i], k, labelOf)
+	})
+	return labels
+}
+
+// RegressBatch is like Regress, but predicts the values of all targets, on
+// workers goroutines. If workers is not positive, GOMAXPROCS is used.
+func (vp *VPTree) RegressBatch(targets []interface{}, k int, valueOf func(interface{}) float64, weighting Weighting, workers int) []float64 {
+	values := make([]float64, len(targets))
+	forEachIndex(len(targets), workers, func(i int) {
+		values[i] = vp.Regress(targets[i], k, valueOf, weighting)
+	})
+	return values
+}
+
+// forEachIndex calls f for every index below n, on workers goroutines. If
+// workers is not positive, GOMAXPROCS is used.
+func forEachIndex(n, workers int, f func(i int)) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= n {
+					return
+				}
+				f(i)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
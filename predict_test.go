@@ -0,0 +1,109 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// labelled is an item of the two-cluster data set
+type labelled struct {
+	Coordinate
+	Label string
+	Value float64
+}
+
+func labelledMetric(a, b interface{}) float64 {
+	return CoordinateMetric(a.(labelled).Coordinate, b.(labelled).Coordinate)
+}
+
+func labelOf(item interface{}) string  { return item.(labelled).Label }
+func valueOf(item interface{}) float64 { return item.(labelled).Value }
+func at(x, y float64) labelled         { return labelled{Coordinate: Coordinate{x, y}} }
+
+// twoClusters returns items around (0, 0) labelled "a" with value 1, and
+// items around (10, 10) labelled "b" with value 5
+func twoClusters(n int) []interface{} {
+	var items []interface{}
+	for i := 0; i < n; i++ {
+		items = append(items,
+			labelled{Coordinate{rand.Float64(), rand.Float64()}, "a", 1},
+			labelled{Coordinate{10 + rand.Float64(), 10 + rand.Float64()}, "b", 5},
+		)
+	}
+	return items
+}
+
+// This test classifies points near either cluster
+func TestClassify(t *testing.T) {
+	vp := New(labelledMetric, twoClusters(100))
+
+	label, votes := vp.Classify(at(0.5, 0.5), 7, labelOf)
+	if label != "a" || votes["a"] != 7 || len(votes) != 1 {
+		t.Errorf("Expected 7 votes for a, got %v with %v", label, votes)
+	}
+
+	labels := vp.ClassifyBatch([]interface{}{at(0, 1), at(11, 9), at(9, 9)}, 5, labelOf, 2)
+	if labels[0] != "a" || labels[1] != "b" || labels[2] != "b" {
+		t.Errorf("Expected labels [a b b], got %v", labels)
+	}
+
+	if label, votes := New(labelledMetric, nil).Classify(at(0, 0), 3, labelOf); label != "" || len(votes) != 0 {
+		t.Errorf("Expected no label on an empty tree, got %q with %v", label, votes)
+	}
+}
+
+// This test makes sure ties go to the label of the nearest neighbour
+func TestClassifyTie(t *testing.T) {
+	items := []interface{}{
+		labelled{Coordinate{1, 0}, "far", 0},
+		labelled{Coordinate{3, 0}, "far", 0},
+		labelled{Coordinate{0.5, 0}, "near", 0},
+		labelled{Coordinate{2, 0}, "near", 0},
+		labelled{Coordinate{9, 0}, "other", 0},
+	}
+	vp := New(labelledMetric, items)
+
+	for i := 0; i < 10; i++ {
+		label, votes := vp.Classify(at(0, 0), 4, labelOf)
+		if label != "near" || votes["near"] != 2 || votes["far"] != 2 {
+			t.Errorf("Expected the tie to go to near, got %v with %v", label, votes)
+		}
+	}
+}
+
+// This test predicts values near either cluster and at exact matches
+func TestRegress(t *testing.T) {
+	items := twoClusters(100)
+	vp := New(labelledMetric, items)
+
+	if v := vp.Regress(at(0.5, 0.5), 10, valueOf, UniformWeighting); v != 1 {
+		t.Errorf("Expected 1, got %v", v)
+	}
+
+	values := vp.RegressBatch([]interface{}{at(0, 0), at(10, 10)}, 10, valueOf, InverseDistanceWeighting, 0)
+	if math.Abs(values[0]-1) > 1e-9 || math.Abs(values[1]-5) > 1e-9 {
+		t.Errorf("Expected [1 5], got %v", values)
+	}
+
+	// Between two items, both count
+	line := New(labelledMetric, []interface{}{
+		labelled{Coordinate{0, 0}, "", 1},
+		labelled{Coordinate{3, 0}, "", 4},
+	})
+	if v := line.Regress(at(1, 0), 2, valueOf, UniformWeighting); v != 2.5 {
+		t.Errorf("Expected a uniform average of 2.5, got %v", v)
+	}
+	if v := line.Regress(at(1, 0), 2, valueOf, InverseDistanceWeighting); math.Abs(v-2) > 1e-12 {
+		t.Errorf("Expected an inverse distance average of 2, got %v", v)
+	}
+
+	// An exact match dominates
+	if v := line.Regress(at(3, 0), 2, valueOf, InverseDistanceWeighting); v != 4 {
+		t.Errorf("Expected the exact match's value 4, got %v", v)
+	}
+
+	if v := New(labelledMetric, nil).Regress(at(0, 0), 3, valueOf, UniformWeighting); !math.IsNaN(v) {
+		t.Errorf("Expected NaN on an empty tree, got %v", v)
+	}
+}
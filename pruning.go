@@ -0,0 +1,193 @@
+package vptree
+
+import (
+	"runtime"
+	"sync"
+)
+
+// A PruningReport shows, level by level, how well searches prune a VP-tree.
+// See VPTree.PruningReport.
+type PruningReport struct {
+	// Items is the number of items in the tree.
+	Items int
+
+	// Queries and K are the number of queries and the k they were run
+	// with.
+	Queries int
+	K       int
+
+	// Levels holds the statistics for every depth of the tree, starting
+	// with the root at depth 0.
+	Levels []PruningLevel
+}
+
+// PruningLevel holds the pruning statistics of one depth of a VP-tree,
+// summed over all queries of a PruningReport.
+type PruningLevel struct {
+	// Nodes is the number of nodes at this depth.
+	Nodes int
+
+	// Visits is the number of times searches visited a node at this
+	// depth.
+	Visits int
+
+	// Choices is the number of visits to nodes with two children, of
+	// which BothChildren went on to search both subtrees, OneChild only
+	// one of them, and the rest neither.
+	Choices      int
+	BothChildren int
+	OneChild     int
+
+	// Candidates is the number of items in the subtrees of the visited
+	// nodes, that is, the items that were still candidates when the
+	// search reached this depth.
+	Candidates int
+}
+
+// BothChildrenFraction returns the fraction of the visits with a choice
+// that searched both subtrees. Values near 1 mean that searches cannot
+// prune at this depth.
+func (l PruningLevel) BothChildrenFraction() float64 {
+	if l.Choices == 0 {
+		return 0
+	}
+	return float64(l.BothChildren) / float64(l.Choices)
+}
+
+// OneChildFraction returns the fraction of the visits with a choice that
+// searched only one subtree.
+func (l PruningLevel) OneChildFraction() float64 {
+	if l.Choices == 0 {
+		return 0
+	}
+	return float64(l.OneChild) / float64(l.Choices)
+}
+
+// CandidateFraction returns the mean fraction of the items that were still
+// candidates when a search reached this depth.
+func (r PruningReport) CandidateFraction(depth int) float64 {
+	if r.Queries == 0 || r.Items == 0 {
+		return 0
+	}
+	return float64(r.Levels[depth].Candidates) / float64(r.Queries) / float64(r.Items)
+}
+
+// PruningReport runs a k-nearest-neighbour search for every one of
+// sampleQueries and reports how the searches descended the tree at every
+// depth. The searches run in parallel, always on the tree, even if the
+// VP-tree was built with WithAutoPlan, and neither call hooks nor feed the
+// planner, so the report leaves the VP-tree as it was.
+func (vp *VPTree) PruningReport(sampleQueries []interface{}, k int) PruningReport {
+	report := PruningReport{
+		Queries: len(sampleQueries),
+		K:       k,
+	}
+
+	counts := vp.LevelCounts()
+	report.Levels = make([]PruningLevel, len(counts))
+	for d, c := range counts {
+		report.Levels[d].Nodes = c
+	}
+
+	if k < 1 || vp.root == nil {
+		return report
+	}
+	report.Items = vp.root.Size
+
+	vp.traceVisits(sampleQueries, k, func() (func(map[*node]bool), func()) {
+		levels := make([]PruningLevel, len(counts))
+
+		visit := func(visited map[*node]bool) {
+			countVisits(vp.root, 0, visited, levels)
+		}
+
+		done := func() {
+			for d, l := range levels {
+				r := &report.Levels[d]
+				r.Visits += l.Visits
+				r.Choices += l.Choices
+				r.BothChildren += l.BothChildren
+				r.OneChild += l.OneChild
+				r.Candidates += l.Candidates
+			}
+		}
+
+		return visit, done
+	})
+
+	return report
+}
+
+// traceVisits runs a k-nearest-neighbour search on the tree for every one of
+// queries, spread over GOMAXPROCS goroutines, without calling hooks or
+// feeding the planner. Every goroutine calls worker for a visit function,
+// which it calls with the set of nodes visited by each of its searches, and
+// a done function, which it calls once all queries are done. The done
+// functions are called one at a time.
+func (vp *VPTree) traceVisits(queries []interface{}, k int, worker func() (visit func(visited map[*node]bool), done func())) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		next = make(chan interface{})
+	)
+
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			visit, done := worker()
+			visited := make(map[*node]bool)
+			record := func(n *node) bool {
+				visited[n] = true
+				return false
+			}
+
+			for target := range next {
+				q := getKNNQuery(target, k, vp.root.size())
+				q.skip = record
+				vp.search(q, searchFrame{n: vp.root})
+				putKNNQuery(q)
+
+				visit(visited)
+				clear(visited)
+			}
+
+			mu.Lock()
+			done()
+			mu.Unlock()
+		}()
+	}
+
+	for _, target := range queries {
+		next <- target
+	}
+	close(next)
+	wg.Wait()
+}
+
+// countVisits adds the visited nodes in the subtree rooted at n, at the
+// given depth, to levels. A node can only have been visited if its parent
+// was, so the walk stops at the first node that wasn't.
+func countVisits(n *node, depth int, visited map[*node]bool, levels []PruningLevel) {
+	if n == nil || !visited[n] {
+		return
+	}
+
+	l := &levels[depth]
+	l.Visits++
+	l.Candidates += n.Size
+
+	if n.Left != nil && n.Right != nil {
+		l.Choices++
+		switch {
+		case visited[n.Left] && visited[n.Right]:
+			l.BothChildren++
+		case visited[n.Left] || visited[n.Right]:
+			l.OneChild++
+		}
+	}
+
+	countVisits(n.Left, depth+1, visited, levels)
+	countVisits(n.Right, depth+1, visited, levels)
+}
@@ -0,0 +1,70 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// rootChoices sums the levels near the root, where pruning matters most
+func rootChoices(r PruningReport, depths int) (l PruningLevel) {
+	for _, level := range r.Levels[:depths] {
+		l.Choices += level.Choices
+		l.BothChildren += level.BothChildren
+		l.OneChild += level.OneChild
+	}
+	return
+}
+
+// This test checks that searches on low-dimensional data mostly descend into
+// a single child near the root, and that the report agrees with the
+// statistics of the searches themselves
+func TestPruningReportLowDimensional(t *testing.T) {
+	_, items := randomCoordinates(5000)
+	_, queries := randomCoordinates(200)
+
+	vp := New(CoordinateMetric, items)
+	report := vp.PruningReport(queries, 1)
+
+	visits := 0
+	for _, q := range queries {
+		_, _, stats := vp.SearchWithStats(q, 1)
+		visits += stats.NodesVisited
+	}
+
+	reported := 0
+	for d, l := range report.Levels {
+		reported += l.Visits
+		if l.Visits > report.Queries*l.Nodes {
+			t.Errorf("Expected at most %v visits at depth %v, got %v", report.Queries*l.Nodes, d, l.Visits)
+		}
+		if l.BothChildren+l.OneChild > l.Choices {
+			t.Errorf("Expected at most %v choices at depth %v, got %v", l.Choices, d, l.BothChildren+l.OneChild)
+		}
+	}
+
+	if reported != visits {
+		t.Errorf("Expected %v visits, got %v", visits, reported)
+	}
+
+	if report.Levels[0].Visits != len(queries) || report.CandidateFraction(0) != 1 {
+		t.Errorf("Expected every query to visit the root with all items as candidates, got %+v", report.Levels[0])
+	}
+
+	if f := rootChoices(report, 4).OneChildFraction(); f < 0.7 {
+		t.Errorf("Expected mostly single-child descents near the root, got a fraction of %v", f)
+	}
+}
+
+// This test checks that searches on high-dimensional random data can hardly
+// prune near the root
+func TestPruningReportHighDimensional(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomVectors(rng, 2000, 32)
+	queries := randomVectors(rng, 50, 32)
+
+	report := New(euclidean, items).PruningReport(queries, 10)
+
+	if f := rootChoices(report, 4).BothChildrenFraction(); f < 0.9 {
+		t.Errorf("Expected searches to descend into both children near the root, got a fraction of %v", f)
+	}
+}
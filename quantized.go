@@ -0,0 +1,150 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A Quantizer maps []float32 vectors to []uint8 codes, one byte per
+// dimension, by scaling every dimension linearly from its range in a corpus
+// to 0..255. This cuts the memory of float32 vectors by a factor of 4.
+type Quantizer struct {
+	offset []float32
+	scale  []float32
+}
+
+// NewQuantizer creates a Quantizer fitted to the per-dimension minimum and
+// maximum of corpus. The vectors must be of equal length.
+func NewQuantizer(corpus [][]float32) *Quantizer {
+	if len(corpus) == 0 {
+		return &Quantizer{}
+	}
+
+	dim := len(corpus[0])
+	q := &Quantizer{
+		offset: make([]float32, dim),
+		scale:  make([]float32, dim),
+	}
+
+	for i := 0; i < dim; i++ {
+		lo, hi := corpus[0][i], corpus[0][i]
+		for _, v := range corpus[1:] {
+			lo = min(lo, v[i])
+			hi = max(hi, v[i])
+		}
+
+		q.offset[i] = lo
+		q.scale[i] = (hi - lo) / 255
+	}
+
+	return q
+}
+
+// Quantize returns the code of v. Values outside of the corpus' range are
+// clamped to it. Quantize panics if v is not of the length of the corpus'
+// vectors, which is 0 for an empty corpus.
+func (q *Quantizer) Quantize(v []float32) []uint8 {
+	if len(v) != len(q.scale) {
+		panic(fmt.Sprintf("vptree: Quantize: vector of length %d for a Quantizer of %d dimensions", len(v), len(q.scale)))
+	}
+
+	code := make([]uint8, len(v))
+	for i, x := range v {
+		if q.scale[i] == 0 {
+			continue
+		}
+
+		c := math.Round(float64((x - q.offset[i]) / q.scale[i]))
+		code[i] = uint8(math.Max(0, math.Min(255, c)))
+	}
+	return code
+}
+
+// Distance is the Euclidean distance between the vectors that two []uint8
+// codes stand for. It can be used as the metric of a VP-tree over codes.
+func (q *Quantizer) Distance(a, b interface{}) float64 {
+	x, y := a.([]uint8), b.([]uint8)
+
+	sum := 0.0
+	for i := range x {
+		d := float64(q.scale[i]) * (float64(x[i]) - float64(y[i]))
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// A QuantizedIndex is a VP-tree over the quantized codes of []float32 vectors
+// that reranks its candidates using the exact vectors.
+type QuantizedIndex struct {
+	vectors   [][]float32
+	quantizer *Quantizer
+	tree      *VPTree
+}
+
+// NewQuantizedIndex quantizes vectors and builds a VP-tree over the codes.
+// The index keeps a reference to vectors for reranking, so the caller must
+// not modify them afterwards.
+func NewQuantizedIndex(vectors [][]float32) *QuantizedIndex {
+	qi := &QuantizedIndex{
+		vectors:   vectors,
+		quantizer: NewQuantizer(vectors),
+	}
+
+	codes := make([]interface{}, len(vectors))
+	for i, v := range vectors {
+		codes[i] = qi.quantizer.Quantize(v)
+	}
+
+	qi.tree = New(qi.quantizer.Distance, codes)
+	return qi
+}
+
+// Quantizer returns the Quantizer fitted to the index's vectors.
+func (qi *QuantizedIndex) Quantizer() *Quantizer {
+	return qi.quantizer
+}
+
+// SearchQuantizedRerank searches the quantized codes for the k*oversample
+// nearest neighbours of target, and returns the indices of the k nearest of
+// those by exact Euclidean distance, together with the exact distances, in
+// order of least distance to largest distance. Larger values of oversample
+// make up for more of the quantization error.
+func (qi *QuantizedIndex) SearchQuantizedRerank(target []float32, k, oversample int) (indices []int, distances []float64) {
+	if k < 1 || len(qi.vectors) == 0 {
+		return
+	}
+
+	indices, _ = qi.tree.SearchIndices(qi.quantizer.Quantize(target), k*max(oversample, 1))
+
+	candidates := make([]*heapItem, len(indices))
+	for i, idx := range indices {
+		candidates[i] = &heapItem{Index: idx, Dist: euclidean32(qi.vectors[idx], target)}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Dist < candidates[j].Dist
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	indices = indices[:0]
+	for _, hi := range candidates {
+		indices = append(indices, hi.Index)
+		distances = append(distances, hi.Dist)
+	}
+
+	return
+}
+
+// euclidean32 is the Euclidean distance between two []float32 vectors.
+func euclidean32(x, y []float32) float64 {
+	sum := 0.0
+	for i := range x {
+		d := float64(x[i]) - float64(y[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,126 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func randomEmbeddings(n, dim int) [][]float32 {
+	basis := make([][]float64, 4)
+	for i := range basis {
+		basis[i] = make([]float64, dim)
+		for j := range basis[i] {
+			basis[i][j] = rand.NormFloat64()
+		}
+	}
+
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		v := lowRankVector(basis, dim)
+		vectors[i] = make([]float32, dim)
+		for j, x := range v {
+			vectors[i][j] = float32(x)
+		}
+	}
+	return vectors
+}
+
+// This test makes sure the quantized distance stays within the quantization
+// error of the exact distance
+func TestQuantizerDistance(t *testing.T) {
+	vectors := randomEmbeddings(200, 16)
+	q := NewQuantizer(vectors)
+
+	// Every coordinate is off by at most half a step
+	maxErr := 0.0
+	for _, s := range q.scale {
+		maxErr += float64(s) * float64(s)
+	}
+	maxErr = math.Sqrt(maxErr)
+
+	for i := 0; i < 100; i++ {
+		a, b := vectors[rand.Intn(len(vectors))], vectors[rand.Intn(len(vectors))]
+
+		exact := euclidean32(a, b)
+		if d := q.Distance(q.Quantize(a), q.Quantize(b)); math.Abs(d-exact) > maxErr {
+			t.Errorf("Expected a distance within %v of %v, got %v", maxErr, exact, d)
+		}
+	}
+}
+
+// This test compares SearchQuantizedRerank against an exact brute-force
+// search
+func TestSearchQuantizedRerank(t *testing.T) {
+	vectors := randomEmbeddings(2000, 64)
+	qi := NewQuantizedIndex(vectors)
+
+	hits := 0
+	for i := 0; i < 50; i++ {
+		target := randomEmbeddings(1, 64)[0]
+		if i%2 == 0 {
+			target = vectors[rand.Intn(len(vectors))]
+		}
+
+		indices, distances := qi.SearchQuantizedRerank(target, 10, 4)
+		if len(indices) != 10 {
+			t.Fatalf("Expected 10 results, got %v", len(indices))
+		}
+
+		if !sort.Float64sAreSorted(distances) {
+			t.Errorf("Distances are not sorted: %v", distances)
+		}
+
+		all := make([]float64, len(vectors))
+		for j, v := range vectors {
+			all[j] = euclidean32(v, target)
+		}
+
+		for j, idx := range indices {
+			if distances[j] != all[idx] {
+				t.Errorf("Expected the exact distance %v, got %v", all[idx], distances[j])
+			}
+		}
+
+		sort.Float64s(all)
+		for _, d := range distances {
+			if d <= all[9] {
+				hits++
+			}
+		}
+	}
+
+	if recall := float64(hits) / 500; recall < 0.9 {
+		t.Errorf("Expected a recall of at least 0.9, got %v", recall)
+	}
+}
+
+// This test makes sure Quantize panics for vectors of the wrong length, also
+// for a Quantizer fitted to an empty corpus, and that an empty index finds
+// nothing
+func TestQuantizeLength(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%v: expected a panic", name)
+			}
+		}()
+		f()
+	}
+
+	q := NewQuantizer(randomEmbeddings(10, 4))
+	expectPanic("longer vector", func() { q.Quantize(make([]float32, 5)) })
+	expectPanic("shorter vector", func() { q.Quantize(make([]float32, 3)) })
+
+	empty := NewQuantizer(nil)
+	expectPanic("empty corpus", func() { empty.Quantize(make([]float32, 4)) })
+
+	if code := empty.Quantize(nil); len(code) != 0 {
+		t.Errorf("Expected an empty code, got %v", code)
+	}
+
+	if indices, _ := NewQuantizedIndex(nil).SearchQuantizedRerank(make([]float32, 4), 10, 4); len(indices) != 0 {
+		t.Errorf("Expected no results from an empty index, got %v", indices)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package vptree
+
+// raceEnabled is whether the tests run under the race detector, which makes
+// sync.Pool drop items at random, so that allocation counts are off.
+const raceEnabled = true
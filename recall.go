@@ -0,0 +1,179 @@
+package vptree
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A RecallReport compares approximate searches against exact ones over a set
+// of queries. See EvaluateRecall.
+type RecallReport struct {
+	// Recall is the mean fraction of the exact k nearest neighbours that
+	// the approximate search found.
+	Recall float64
+
+	// DistanceRatio is the mean ratio of the distance of the i-th
+	// neighbour found by the approximate search to that of the exact
+	// i-th nearest neighbour. It is at least 1.
+	DistanceRatio float64
+
+	// ExactLatency and ApproxLatency are the mean durations of a search.
+	ExactLatency  time.Duration
+	ApproxLatency time.Duration
+
+	// ExactMetricCalls and ApproxMetricCalls are the mean numbers of
+	// distances computed by a search.
+	ExactMetricCalls  float64
+	ApproxMetricCalls float64
+}
+
+// LatencyRatio returns the approximate search's latency as a fraction of
+// the exact search's.
+func (r RecallReport) LatencyRatio() float64 {
+	return float64(r.ApproxLatency) / float64(r.ExactLatency)
+}
+
+// MetricCallRatio returns the approximate search's metric calls as a
+// fraction of the exact search's.
+func (r RecallReport) MetricCallRatio() float64 {
+	return r.ApproxMetricCalls / r.ExactMetricCalls
+}
+
+// EvaluateRecall measures what approximate searches give up on the given
+// queries. It runs k-nearest-neighbour searches for every query with a
+// Searcher configured by exact, usually no options at all, as the ground
+// truth, and with a Searcher for each of the configurations in approx, and
+// returns a report for each of them, in order. The ground truth is only
+// computed once. The queries are spread over workers goroutines; if workers
+// is not positive, GOMAXPROCS is used.
+func (vp *VPTree) EvaluateRecall(queries []interface{}, k int, exact []SearchOption, approx [][]SearchOption, workers int) []RecallReport {
+	truth := vp.evaluateSearches(queries, k, exact, workers)
+
+	reports := make([]RecallReport, len(approx))
+	for i, opts := range approx {
+		found := vp.evaluateSearches(queries, k, opts, workers)
+
+		r := &reports[i]
+		r.ExactLatency, r.ApproxLatency = truth.meanLatency(), found.meanLatency()
+		r.ExactMetricCalls, r.ApproxMetricCalls = truth.meanMetricCalls(), found.meanMetricCalls()
+
+		hits, ratios, ratioCount := 0.0, 0.0, 0
+		for q := range queries {
+			want, got := truth.indices[q], found.indices[q]
+			if len(want) == 0 {
+				hits++
+				continue
+			}
+
+			isTrue := make(map[int]bool, len(want))
+			for _, idx := range want {
+				isTrue[idx] = true
+			}
+
+			count := 0
+			for _, idx := range got {
+				if isTrue[idx] {
+					count++
+				}
+			}
+			hits += float64(count) / float64(len(want))
+
+			for j, d := range found.distances[q] {
+				switch e := truth.distances[q][j]; {
+				case e > 0:
+					ratios += d / e
+					ratioCount++
+				case d == 0:
+					ratios++
+					ratioCount++
+				}
+			}
+		}
+
+		if len(queries) > 0 {
+			r.Recall = hits / float64(len(queries))
+		}
+		r.DistanceRatio = 1
+		if ratioCount > 0 {
+			r.DistanceRatio = ratios / float64(ratioCount)
+		}
+	}
+
+	return reports
+}
+
+// searchEvaluation holds the results and costs of a search configuration on
+// a set of queries.
+type searchEvaluation struct {
+	indices     [][]int
+	distances   [][]float64
+	latency     time.Duration
+	metricCalls int
+}
+
+func (e *searchEvaluation) meanLatency() time.Duration {
+	if len(e.indices) == 0 {
+		return 0
+	}
+	return e.latency / time.Duration(len(e.indices))
+}
+
+func (e *searchEvaluation) meanMetricCalls() float64 {
+	if len(e.indices) == 0 {
+		return 0
+	}
+	return float64(e.metricCalls) / float64(len(e.indices))
+}
+
+// evaluateSearches runs a Searcher with opts on every query, on workers
+// goroutines, and records the results and costs.
+func (vp *VPTree) evaluateSearches(queries []interface{}, k int, opts []SearchOption, workers int) *searchEvaluation {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	e := &searchEvaluation{
+		indices:   make([][]int, len(queries)),
+		distances: make([][]float64, len(queries)),
+	}
+
+	var mu sync.Mutex
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s := vp.NewSearcher(opts...)
+			s.q.stats = &QueryStats{}
+
+			var latency time.Duration
+			var metricCalls int
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(queries) {
+					break
+				}
+
+				*s.q.stats = QueryStats{}
+				start := time.Now()
+				e.indices[i], e.distances[i] = s.searchIndices(queries[i], k)
+				latency += time.Since(start)
+				metricCalls += s.q.stats.MetricCalls
+			}
+
+			mu.Lock()
+			e.latency += latency
+			e.metricCalls += metricCalls
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return e
+}
@@ -0,0 +1,58 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure comparing exact searches against themselves reports
+// perfect recall
+func TestEvaluateRecallExact(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	var queries []interface{}
+	for i := 0; i < 100; i++ {
+		queries = append(queries, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	reports := vp.EvaluateRecall(queries, 10, nil, [][]SearchOption{nil, {WithEpsilon(0.5)}}, 4)
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %v", len(reports))
+	}
+
+	r := reports[0]
+	if r.Recall != 1 || r.DistanceRatio != 1 || r.MetricCallRatio() != 1 {
+		t.Errorf("Expected recall, distance ratio and metric call ratio of 1, got %+v", r)
+	}
+
+	r = reports[1]
+	if r.Recall > 1 || r.DistanceRatio < 1 || r.DistanceRatio > 1.5 || r.MetricCallRatio() > 1 {
+		t.Errorf("Expected an approximate search within its guarantees, got %+v", r)
+	}
+}
+
+// This test checks a report on a search that only visits the root, where the
+// recall can be worked out by hand
+func TestEvaluateRecallRootOnly(t *testing.T) {
+	var items []interface{}
+	for x := 0; x < 10; x++ {
+		items = append(items, Coordinate{X: float64(x)})
+	}
+	vp := New(CoordinateMetric, items)
+
+	// Searching for the root's item finds it at distance 0, but none of
+	// its two nearest neighbours
+	queries := []interface{}{vp.root.Item}
+	r := vp.EvaluateRecall(queries, 3, nil, [][]SearchOption{{WithMaxVisits(1)}}, 1)[0]
+
+	if r.Recall != 1.0/3 {
+		t.Errorf("Expected a recall of 1/3, got %v", r.Recall)
+	}
+	if r.DistanceRatio != 1 {
+		t.Errorf("Expected a distance ratio of 1, got %v", r.DistanceRatio)
+	}
+	if r.ApproxMetricCalls != 1 || r.ExactMetricCalls <= 1 {
+		t.Errorf("Expected 1 metric call against more for the exact search, got %v and %v", r.ApproxMetricCalls, r.ExactMetricCalls)
+	}
+}
@@ -0,0 +1,43 @@
+package vptree
+
+import (
+	"math"
+	"reflect"
+)
+
+// ReverseKNN returns the items that have query among their k nearest
+// neighbours, together with their distances to query, in order of least
+// distance to largest distance. The item itself does not count as one of
+// its own neighbours, and if query is an item of the VP-tree, as by
+// reflect.DeepEqual, it is not its own reverse neighbour either; copies of
+// it are.
+//
+// Only the √n nearest neighbours of query are considered as candidates, so
+// the result is approximate: items farther away that still count query among
+// their k nearest neighbours (e.g. outliers) are missed.
+func (vp *VPTree) ReverseKNN(query interface{}, k int) (results []interface{}, distances []float64) {
+	if vp.root == nil || k < 1 {
+		return
+	}
+
+	candidates, candidateDists := vp.Search(query, int(math.Ceil(math.Sqrt(float64(vp.Len())))))
+
+	self := false
+	for i, c := range candidates {
+		if !self && candidateDists[i] == 0 && reflect.DeepEqual(c, query) {
+			self = true
+			continue
+		}
+
+		// The nearest neighbour of the candidate is the candidate
+		// itself, so we need one more than k
+		_, dists := vp.Search(c, k+1)
+
+		if len(dists) <= k || candidateDists[i] <= dists[k] {
+			results = append(results, c)
+			distances = append(distances, candidateDists[i])
+		}
+	}
+
+	return
+}
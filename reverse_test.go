@@ -0,0 +1,97 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test compares ReverseKNN against a brute-force check of every item
+func TestReverseKNN(t *testing.T) {
+	items, vpitems := randomCoordinates(500)
+	vp := New(CoordinateMetric, vpitems)
+
+	for q := 0; q < 10; q++ {
+		query := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		results, distances := vp.ReverseKNN(query, 3)
+
+		found := make(map[Coordinate]bool)
+		for i, r := range results {
+			found[r.(Coordinate)] = true
+			if distances[i] != CoordinateMetric(r, query) {
+				t.Errorf("Expected distance %v, got %v", CoordinateMetric(r, query), distances[i])
+			}
+		}
+
+		missed := 0
+		for _, x := range items {
+			// Count the items closer to x than the query
+			closer := 0
+			for _, y := range items {
+				if y != x && CoordinateMetric(x, y) < CoordinateMetric(x, query) {
+					closer++
+				}
+			}
+
+			if expected := closer < 3; expected != found[x] {
+				if found[x] {
+					t.Errorf("%v does not have %v among its 3 nearest neighbours", x, query)
+				} else {
+					missed++
+				}
+			}
+		}
+
+		// Reverse neighbours are usually close to the query, so the
+		// candidate set should catch nearly all of them
+		if missed > 1 {
+			t.Errorf("Missed %v reverse neighbours of %v", missed, query)
+		}
+	}
+}
+
+// This test makes sure an item of the tree is not its own reverse neighbour,
+// but a copy of it is
+func TestReverseKNNItem(t *testing.T) {
+	items, vpitems := randomCoordinates(500)
+	vp := New(CoordinateMetric, vpitems)
+
+	for q := 0; q < 10; q++ {
+		query := items[rand.Intn(len(items))]
+
+		results, distances := vp.ReverseKNN(query, 3)
+		for i, r := range results {
+			if r == query || distances[i] == 0 {
+				t.Errorf("Expected %v not to be its own reverse neighbour", query)
+			}
+		}
+
+		for _, x := range items {
+			if x == query {
+				continue
+			}
+
+			// Count the items closer to x than the query
+			closer := 0
+			for _, y := range items {
+				if y != x && CoordinateMetric(x, y) < CoordinateMetric(x, query) {
+					closer++
+				}
+			}
+
+			if closer >= 3 {
+				for _, r := range results {
+					if r == x {
+						t.Errorf("%v does not have %v among its 3 nearest neighbours", x, query)
+					}
+				}
+			}
+		}
+	}
+
+	copied := New(CoordinateMetric, append(vpitems, items[0]))
+	results, distances := copied.ReverseKNN(items[0], 3)
+	if len(results) == 0 || results[0] != items[0] || distances[0] != 0 {
+		t.Errorf("Expected the copy of %v as the nearest reverse neighbour, got %v at %v", items[0], results, distances)
+	}
+}
@@ -0,0 +1,53 @@
+package vptree
+
+// A MetricResult is a distance together with whatever else computing it
+// produced, such as the alignment of two sequences.
+type MetricResult struct {
+	Distance float64
+	Extra    interface{}
+}
+
+// A RichMetric is a Metric that returns a MetricResult. Its distances must
+// fulfill the same requirements as those of a Metric.
+type RichMetric func(a, b interface{}) MetricResult
+
+// A RichSearchResult is a neighbour found by SearchRich, together with the
+// Extra its RichMetric returned for it and the target.
+type RichSearchResult struct {
+	Item     interface{}
+	Distance float64
+	Extra    interface{}
+}
+
+// NewRich is like New, but uses a RichMetric. Search and the other methods
+// only use its distances; SearchRich also returns the extras.
+func NewRich(metric RichMetric, items []interface{}, opts ...Option) (t *VPTree) {
+	t = New(func(a, b interface{}) float64 {
+		return metric(a, b).Distance
+	}, items, opts...)
+
+	t.richMetric = metric
+	return
+}
+
+// SearchRich is like Search, but returns the neighbours together with the
+// Extra the RichMetric returned for them. The search itself only keeps
+// distances, so the metric is called once more for each of the k
+// neighbours; it should return the same result every time. For VP-trees
+// that were not built with NewRich, Extra is nil.
+func (vp *VPTree) SearchRich(target interface{}, k int) []RichSearchResult {
+	nearest := vp.nearest(target, k)
+	if len(nearest) == 0 {
+		return nil
+	}
+
+	results := make([]RichSearchResult, len(nearest))
+	for i, hi := range nearest {
+		results[i] = RichSearchResult{Item: hi.Item, Distance: hi.Dist}
+		if vp.richMetric != nil {
+			results[i].Extra = vp.richMetric(hi.Item, target).Extra
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,50 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// coordinateDelta is the Extra of richCoordinateMetric
+type coordinateDelta struct {
+	DX, DY float64
+}
+
+func richCoordinateMetric(a, b interface{}) MetricResult {
+	c1, c2 := a.(Coordinate), b.(Coordinate)
+	return MetricResult{
+		Distance: CoordinateMetric(c1, c2),
+		Extra:    coordinateDelta{c1.X - c2.X, c1.Y - c2.Y},
+	}
+}
+
+// This test checks that the extras belong to the neighbours they are
+// returned with
+func TestSearchRich(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+	vp := NewRich(richCoordinateMetric, items)
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, 5)
+		found := vp.SearchRich(q, 5)
+
+		var results []interface{}
+		var distances []float64
+		for _, r := range found {
+			results = append(results, r.Item)
+			distances = append(distances, r.Distance)
+
+			c := r.Item.(Coordinate)
+			if delta := r.Extra.(coordinateDelta); delta.DX != c.X-q.X || delta.DY != c.Y-q.Y {
+				t.Errorf("Expected the delta of %v to %v, got %v", c, q, delta)
+			}
+		}
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	if found := New(CoordinateMetric, items).SearchRich(Coordinate{}, 3); len(found) != 3 || found[0].Extra != nil {
+		t.Errorf("Expected 3 results without extras, got %v", found)
+	}
+}
@@ -0,0 +1,135 @@
+package vptree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// An RPForest is a forest of VP-trees, each built over a different random
+// projection of the items into a low-dimensional space. It is useful for
+// approximate nearest-neighbour searches on high-dimensional vectors, where
+// a single VP-tree cannot prune well.
+type RPForest struct {
+	metric      Metric
+	items       []interface{}
+	projections [][][]float64
+	trees       []*VPTree
+}
+
+// ErrVectorLength is returned by NewRPForest if the items are vectors of
+// different lengths.
+var ErrVectorLength = errors.New("vptree: vectors of different lengths")
+
+// NewRPForest creates a new RPForest of numTrees VP-trees, each over a random
+// projection of the items into dim dimensions. The items must be []float64
+// vectors of equal length; metric measures their distance in the original
+// space and is used to rank the candidates found by the trees. Larger values
+// of dim give better results at the cost of slower searches. NewRPForest
+// returns an error if dim is less than 1 or an item is not a []float64, and
+// one wrapping ErrVectorLength if the vectors differ in length.
+func NewRPForest(metric Metric, items []interface{}, numTrees, dim int) (*RPForest, error) {
+	if dim < 1 {
+		return nil, fmt.Errorf("vptree: projection into %d dimensions", dim)
+	}
+
+	f := &RPForest{
+		metric: metric,
+		items:  items,
+	}
+
+	if len(items) == 0 {
+		return f, nil
+	}
+
+	inputDim := -1
+	for i, item := range items {
+		v, ok := item.([]float64)
+		if !ok {
+			return nil, fmt.Errorf("vptree: item %d is a %T, not a []float64", i, item)
+		}
+		if inputDim < 0 {
+			inputDim = len(v)
+		} else if len(v) != inputDim {
+			return nil, fmt.Errorf("%w: item %d has length %d, not %d", ErrVectorLength, i, len(v), inputDim)
+		}
+	}
+
+	for t := 0; t < numTrees; t++ {
+		// Gaussian random projections approximately preserve
+		// Euclidean distances (Johnson-Lindenstrauss)
+		projection := make([][]float64, dim)
+		for i := range projection {
+			projection[i] = make([]float64, inputDim)
+			for j := range projection[i] {
+				projection[i][j] = rand.NormFloat64() / math.Sqrt(float64(dim))
+			}
+		}
+
+		projected := make([]interface{}, len(items))
+		for i, item := range items {
+			projected[i] = project(projection, item.([]float64))
+		}
+
+		f.projections = append(f.projections, projection)
+		f.trees = append(f.trees, New(euclidean, projected))
+	}
+
+	return f, nil
+}
+
+// Search queries every tree of the forest for the k nearest neighbours of
+// the projected target, and returns the k nearest of all candidates found
+// under the forest's metric, in order of least distance to largest distance.
+func (f *RPForest) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	var candidates []heapItem
+	seen := make(map[int]bool)
+
+	for t, tree := range f.trees {
+		indices, _ := tree.SearchIndices(project(f.projections[t], target.([]float64)), k)
+		for _, idx := range indices {
+			if !seen[idx] {
+				seen[idx] = true
+				candidates = append(candidates, heapItem{f.items[idx], idx, f.metric(f.items[idx], target)})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Dist < candidates[j].Dist
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	return itemsAndDistances(candidates)
+}
+
+func project(projection [][]float64, v []float64) []float64 {
+	p := make([]float64, len(projection))
+	for i, row := range projection {
+		for j, x := range row {
+			p[i] += x * v[j]
+		}
+	}
+	return p
+}
+
+// euclidean is the Euclidean distance between two []float64 vectors.
+func euclidean(a, b interface{}) float64 {
+	x, y := a.([]float64), b.([]float64)
+
+	sum := 0.0
+	for i := range x {
+		d := x[i] - y[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
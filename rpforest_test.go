@@ -0,0 +1,107 @@
+package vptree
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This helper function returns a random vector close to a 4-dimensional
+// subspace of R^dim
+func lowRankVector(basis [][]float64, dim int) []float64 {
+	v := make([]float64, dim)
+	for _, b := range basis {
+		c := rand.NormFloat64()
+		for j := range v {
+			v[j] += c * b[j]
+		}
+	}
+	for j := range v {
+		v[j] += 0.05 * rand.NormFloat64()
+	}
+	return v
+}
+
+// This test makes sure the RPForest finds most of the true nearest neighbours
+// of high-dimensional vectors, and that it reports true distances
+func TestRPForestRecall(t *testing.T) {
+	const dim = 32
+
+	basis := make([][]float64, 4)
+	for i := range basis {
+		basis[i] = make([]float64, dim)
+		for j := range basis[i] {
+			basis[i][j] = rand.NormFloat64()
+		}
+	}
+
+	var items []interface{}
+	for i := 0; i < 2000; i++ {
+		items = append(items, lowRankVector(basis, dim))
+	}
+
+	f, err := NewRPForest(euclidean, items, 10, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := 0
+	for q := 0; q < 50; q++ {
+		target := lowRankVector(basis, dim)
+
+		results, distances := f.Search(target, 10)
+		if len(results) != 10 {
+			t.Fatalf("Expected 10 results, got %v", len(results))
+		}
+
+		if !sort.Float64sAreSorted(distances) {
+			t.Errorf("Distances are not sorted: %v", distances)
+		}
+
+		all := make([]float64, len(items))
+		for i, item := range items {
+			all[i] = euclidean(item, target)
+		}
+		sort.Float64s(all)
+
+		for i, r := range results {
+			if euclidean(r, target) != distances[i] {
+				t.Errorf("Expected distances[%v] to be %v, got %v", i, euclidean(r, target), distances[i])
+			}
+			if distances[i] <= all[9] {
+				hits++
+			}
+		}
+	}
+
+	if recall := float64(hits) / 500; recall < 0.8 {
+		t.Errorf("Expected recall of at least 0.8, got %v", recall)
+	}
+}
+
+// This test makes sure NewRPForest rejects projections into fewer than one
+// dimension and items that are not vectors of equal length
+func TestRPForestErrors(t *testing.T) {
+	items := []interface{}{[]float64{1, 2}, []float64{3, 4}}
+
+	if _, err := NewRPForest(euclidean, items, 2, 0); err == nil {
+		t.Errorf("Expected an error for 0 dimensions")
+	}
+
+	if _, err := NewRPForest(euclidean, append(items, []float64{5}), 2, 1); !errors.Is(err, ErrVectorLength) {
+		t.Errorf("Expected %v, got %v", ErrVectorLength, err)
+	}
+
+	if _, err := NewRPForest(euclidean, append(items, "vector"), 2, 1); err == nil {
+		t.Errorf("Expected an error for an item that is not a vector")
+	}
+
+	f, err := NewRPForest(euclidean, nil, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := f.Search([]float64{1}, 1); len(results) != 0 {
+		t.Errorf("Expected no results from an empty forest, got %v", results)
+	}
+}
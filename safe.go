@@ -0,0 +1,45 @@
+package vptree
+
+import "fmt"
+
+// A MetricPanicError is returned by SearchSafe when the metric panics. It
+// holds the item and the target the metric panicked on, and the value passed
+// to panic.
+type MetricPanicError struct {
+	Item   interface{}
+	Target interface{}
+	Value  interface{}
+}
+
+func (e *MetricPanicError) Error() string {
+	return fmt.Sprintf("vptree: metric panicked on item %v and target %v: %v", e.Item, e.Target, e.Value)
+}
+
+// SearchSafe is like Search, but recovers if the metric panics, for example
+// on an item of an unexpected type. It then returns the neighbours found
+// before the panic together with a *MetricPanicError, which names the item
+// and the target the metric panicked on.
+func (vp *VPTree) SearchSafe(target interface{}, k int) (results []interface{}, distances []float64, err error) {
+	if k < 1 {
+		return
+	}
+
+	q := vp.getNearestQuery(target, k)
+	defer putKNNQuery(q)
+
+	defer func() {
+		if r := recover(); r != nil {
+			e := &MetricPanicError{Target: target, Value: r}
+			if q.current != nil {
+				e.Item = q.current.Item
+			}
+			err = e
+			results, distances = itemsAndDistances(q.results())
+		}
+	}()
+
+	vp.runKNN(q)
+
+	results, distances = itemsAndDistances(q.results())
+	return
+}
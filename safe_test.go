@@ -0,0 +1,105 @@
+package vptree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure SearchSafe returns the same neighbours as Search for
+// a metric that doesn't panic
+func TestSearchSafe(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		results, distances, err := vp.SearchSafe(q, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, 10)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+
+	// SearchSafe takes the same path as Search, including the hooks and
+	// the buffer for large k
+	searches := 0
+	hooked := New(CoordinateMetric, items, WithInstrumentation(Hooks{
+		OnSearchDone: func(QueryStats) { searches++ },
+	}))
+	for _, k := range []int{10, 500, 2000} {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		results, distances, err := hooked.SearchSafe(q, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, min(k, len(coords)))
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+	if searches != 3 {
+		t.Errorf("Expected OnSearchDone to be called for every search, got %v calls", searches)
+	}
+
+	if results, _, err := New(CoordinateMetric, nil).SearchSafe(Coordinate{}, 3); len(results) != 0 || err != nil {
+		t.Errorf("Expected no results and no error from an empty tree, got %v, %v", results, err)
+	}
+}
+
+// This test makes sure SearchSafe turns a panic of the metric into an error
+// naming the item, and returns the neighbours found before it
+func TestSearchSafePanic(t *testing.T) {
+	_, items := randomCoordinates(100)
+	items = append(items, "center")
+
+	// The metric takes the string for the center of the unit square while
+	// the tree is built, and panics on it afterwards
+	built := false
+	coordinate := func(x interface{}) interface{} {
+		if x == "center" {
+			if built {
+				panic("unexpected string")
+			}
+			return Coordinate{X: 0.5, Y: 0.5}
+		}
+		return x
+	}
+	vp := New(func(a, b interface{}) float64 {
+		return CoordinateMetric(coordinate(a), coordinate(b))
+	}, items)
+	built = true
+
+	target := Coordinate{X: 0.5, Y: 0.5}
+	results, distances, err := vp.SearchSafe(target, 5)
+
+	var panicErr *MetricPanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected a MetricPanicError, got %v", err)
+	}
+	if panicErr.Item != "center" || panicErr.Target != target {
+		t.Errorf("Expected the error to name the string and the target, got %v and %v", panicErr.Item, panicErr.Target)
+	}
+
+	if len(results) != len(distances) || len(results) > 5 {
+		t.Errorf("Expected at most 5 partial results, got %v with distances %v", results, distances)
+	}
+	for i, r := range results {
+		if d := CoordinateMetric(r, target); d != distances[i] {
+			t.Errorf("Expected a distance of %v for %v, got %v", d, r, distances[i])
+		}
+	}
+
+	// The buffered search for large k reports the item and its partial
+	// results as well
+	results, _, err = vp.SearchSafe(target, 50)
+	if !errors.As(err, &panicErr) || panicErr.Item != "center" {
+		t.Errorf("Expected a MetricPanicError for the string, got %v", err)
+	}
+	if len(results) > 50 {
+		t.Errorf("Expected at most 50 partial results, got %v", len(results))
+	}
+}
@@ -0,0 +1,35 @@
+package vptree
+
+import (
+	"math/rand"
+)
+
+// WeightedSample draws n items of the VP-tree at random, each with a
+// probability proportional to its distance from seed, by inverse transform
+// sampling on the cumulative distances. Items far from seed are thus likely
+// to be picked and items equal to it never are, which is the seeding step of
+// k-means++ and yields diverse samples, e.g. for data augmentation or as
+// outlier candidates. The draws are independent, so an item may be returned
+// more than once. If no item is at a positive distance from seed,
+// WeightedSample returns nil. The sample only depends on rng.
+func (vp *VPTree) WeightedSample(seed interface{}, n int, rng *rand.Rand) []interface{} {
+	nodes := vp.uniqueNodes()
+
+	cumulative := make([]float64, len(nodes))
+	total := 0.0
+	for i, nd := range nodes {
+		total += vp.distanceMetric(seed, nd.Item)
+		cumulative[i] = total
+	}
+
+	if total <= 0 || n < 1 {
+		return nil
+	}
+
+	sample := make([]interface{}, n)
+	for i := range sample {
+		sample[i] = nodes[pickWeighted(cumulative, rng)].Item
+	}
+
+	return sample
+}
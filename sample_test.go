@@ -0,0 +1,48 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// This test draws from items at distances 0 to 4 from the seed and compares
+// the frequencies with the distances
+func TestWeightedSample(t *testing.T) {
+	var items []interface{}
+	for x := 0; x < 5; x++ {
+		items = append(items, Coordinate{X: float64(x)})
+	}
+	vp := New(CoordinateMetric, items)
+
+	sample := vp.WeightedSample(Coordinate{}, 10000, rand.New(rand.NewSource(1)))
+	if len(sample) != 10000 {
+		t.Fatalf("Expected 10000 items, got %v", len(sample))
+	}
+
+	counts := make(map[Coordinate]int)
+	for _, item := range sample {
+		counts[item.(Coordinate)]++
+	}
+
+	if counts[Coordinate{}] != 0 {
+		t.Errorf("Expected the seed itself never to be drawn, got %v draws", counts[Coordinate{}])
+	}
+
+	// The distances add up to 10
+	for x := 1; x < 5; x++ {
+		expected := 10000 * float64(x) / 10
+		if got := counts[Coordinate{X: float64(x)}]; math.Abs(float64(got)-expected) > 0.1*expected {
+			t.Errorf("Expected about %v draws at distance %v, got %v", expected, x, got)
+		}
+	}
+
+	if again := vp.WeightedSample(Coordinate{}, 10000, rand.New(rand.NewSource(1))); !reflect.DeepEqual(sample, again) {
+		t.Errorf("Expected the same sample for the same seed")
+	}
+
+	if sample := New(CoordinateMetric, items[:1]).WeightedSample(Coordinate{}, 5, rand.New(rand.NewSource(1))); sample != nil {
+		t.Errorf("Expected no sample when every item equals the seed, got %v", sample)
+	}
+}
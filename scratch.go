@@ -0,0 +1,59 @@
+package vptree
+
+// A Scratch is working memory for a ScratchMetric, such as the matrix of an
+// edit distance. The VP-tree hands every search its own Scratch, so the
+// metric can reuse the memory across the calls of a search without locking.
+type Scratch struct {
+	// Value is for the metric to use as it sees fit. It is nil until the
+	// metric sets it, and keeps its value from one search to the next.
+	Value interface{}
+
+	// Reset, if not nil, is called before the Scratch is used for another
+	// search.
+	Reset func(s *Scratch)
+}
+
+// A ScratchMetric is a Metric that is passed working memory to compute the
+// distance with. All calls of the metric on one goroutine during a search or
+// build get the same Scratch, and a Scratch is never used by two goroutines
+// at once.
+type ScratchMetric func(a, b interface{}, scratch *Scratch) float64
+
+// NewWithScratch is like New, but uses a ScratchMetric. Searches and the
+// build reuse a Scratch per goroutine, and a Searcher keeps one Scratch for
+// all of its searches.
+func NewWithScratch(metric ScratchMetric, items []interface{}, opts ...Option) (t *VPTree) {
+	build := &Scratch{}
+	t = New(func(a, b interface{}) float64 {
+		return metric(a, b, build)
+	}, items, opts...)
+
+	t.scratchMetric = metric
+	t.scratchPool.Put(build)
+
+	// Computations outside of searches, such as the distances of
+	// AllKthNearestDistances, take a Scratch for every call
+	t.distanceMetric = func(a, b interface{}) float64 {
+		s := t.getScratch()
+		defer t.scratchPool.Put(s)
+		return metric(a, b, s)
+	}
+
+	return
+}
+
+// getScratch returns an unused Scratch, ready for a new search.
+func (vp *VPTree) getScratch() *Scratch {
+	s, _ := vp.scratchPool.Get().(*Scratch)
+	if s == nil {
+		return &Scratch{}
+	}
+	s.reset()
+	return s
+}
+
+func (s *Scratch) reset() {
+	if s.Reset != nil {
+		s.Reset(s)
+	}
+}
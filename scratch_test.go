@@ -0,0 +1,86 @@
+package vptree
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// This test makes sure a Searcher passes the same Scratch to every metric
+// call of its searches, and resets it in between
+func TestScratchSearcher(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	plain := New(CoordinateMetric, items)
+
+	seen := make(map[*Scratch]bool)
+	resets := 0
+	vp := NewWithScratch(func(a, b interface{}, scratch *Scratch) float64 {
+		seen[scratch] = true
+		scratch.Reset = func(*Scratch) { resets++ }
+		return CoordinateMetric(a, b)
+	}, items)
+
+	s := vp.NewSearcher()
+	resets = 0
+	for i := 0; i < 10; i++ {
+		seen = make(map[*Scratch]bool)
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedResults, expectedDistances := plain.Search(q, 10)
+		results, distances := s.Search(q, 10)
+
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+		if len(seen) != 1 {
+			t.Errorf("Expected one Scratch per search, got %v", len(seen))
+		}
+	}
+
+	// The race detector makes sync.Pool drop the Scratch, and a new one has
+	// no Reset yet
+	if resets != 10 && !raceEnabled {
+		t.Errorf("Expected the Scratch to be reset before every search, got %v resets", resets)
+	}
+}
+
+// This test makes sure concurrent searches never share a Scratch
+func TestScratchConcurrent(t *testing.T) {
+	_, items := randomCoordinates(1000)
+
+	var shared atomic.Bool
+	vp := NewWithScratch(func(a, b interface{}, scratch *Scratch) float64 {
+		if scratch.Value == nil {
+			scratch.Value = new(atomic.Int32)
+		}
+
+		inUse := scratch.Value.(*atomic.Int32)
+		if inUse.Add(1) > 1 {
+			shared.Store(true)
+		}
+		defer inUse.Add(-1)
+
+		time.Sleep(time.Microsecond)
+		return CoordinateMetric(a, b)
+	}, items)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+				vp.Search(q, 5)
+				vp.SearchRadius(q, 0.05)
+				vp.SearchParallel(q, 5, 4)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if shared.Load() {
+		t.Errorf("Expected no Scratch to be used by two goroutines at once")
+	}
+}
@@ -0,0 +1,129 @@
+package vptree
+
+import (
+	"math"
+)
+
+// A Searcher runs k-nearest-neighbour searches on a VP-tree, reusing its
+// memory from one search to the next, so that searches in a hot loop don't
+// allocate. A Searcher is not safe for concurrent use; use one Searcher per
+// goroutine. The VP-tree itself may be shared between Searchers.
+type Searcher struct {
+	vp *VPTree
+	q  knnQuery
+
+	results   []interface{}
+	distances []float64
+}
+
+// A SearchOption configures the searches of a Searcher.
+type SearchOption func(*Searcher)
+
+// WithMaxVisits makes searches give up after visiting n nodes and return the
+// best neighbours found so far. This bounds the cost of a search at the
+// price of exactness.
+func WithMaxVisits(n int) SearchOption {
+	return func(s *Searcher) {
+		s.q.maxVisits = n
+	}
+}
+
+// WithEpsilon makes searches approximate: they skip subtrees that cannot
+// contain an item more than a factor of 1+epsilon closer than the current
+// k-th nearest neighbour. The i-th neighbour found is then at most 1+epsilon
+// times as far away as the true i-th nearest neighbour.
+func WithEpsilon(epsilon float64) SearchOption {
+	return func(s *Searcher) {
+		s.q.epsilon = epsilon
+	}
+}
+
+// NewSearcher creates a Searcher for the VP-tree.
+func (vp *VPTree) NewSearcher(opts ...SearchOption) *Searcher {
+	s := &Searcher{vp: vp}
+	if vp.scratchMetric != nil {
+		s.q.scratch = vp.getScratch()
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Search is like VPTree.Search. The returned slices belong to the Searcher
+// and are only valid until its next search.
+func (s *Searcher) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	s.results, s.distances = s.SearchInto(target, k, s.results[:0], s.distances[:0])
+	return s.results, s.distances
+}
+
+// SearchInto is like Search, but appends the neighbours and their distances
+// to results and distances and returns the extended slices, like append.
+// Passing slices with enough capacity avoids allocating altogether.
+func (s *Searcher) SearchInto(target interface{}, k int, results []interface{}, distances []float64) ([]interface{}, []float64) {
+	if k < 1 {
+		return results, distances
+	}
+
+	s.run(target, k)
+
+	// The heap pops the items in large-to-small order
+	q := &s.q
+	start := len(results)
+	for i := 0; i < q.h.Len(); i++ {
+		results = append(results, nil)
+		distances = append(distances, 0)
+	}
+	for i := len(results) - 1; i >= start; i-- {
+		hi := q.h.popMax()
+		results[i], distances[i] = hi.Item, hi.Dist
+	}
+
+	s.clear()
+
+	return results, distances
+}
+
+// searchIndices is like Search, but returns the indices of the neighbours.
+func (s *Searcher) searchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	s.run(target, k)
+
+	q := &s.q
+	indices = make([]int, q.h.Len())
+	distances = make([]float64, q.h.Len())
+	for i := len(indices) - 1; i >= 0; i-- {
+		hi := q.h.popMax()
+		indices[i], distances[i] = hi.Index, hi.Dist
+	}
+
+	s.clear()
+
+	return
+}
+
+// run searches for the k nearest neighbours of target, leaving them in the
+// query's heap.
+func (s *Searcher) run(target interface{}, k int) {
+	q := &s.q
+	q.target, q.k, q.tau, q.visits = target, k, math.MaxFloat64, 0
+	q.h.reset()
+	if cap(q.h.dists) < k {
+		q.h = newCompactQueue(k)
+	}
+	if q.scratch != nil {
+		q.scratch.reset()
+	}
+
+	s.vp.runKNN(q)
+}
+
+// clear makes sure the Searcher doesn't keep the items of its last search
+// alive.
+func (s *Searcher) clear() {
+	s.q.h.reset()
+	s.q.target = nil
+}
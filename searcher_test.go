@@ -0,0 +1,76 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure a Searcher returns the same results as Search across
+// many reuses, with varying k
+func TestSearcher(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+	s := vp.NewSearcher()
+
+	for i := 0; i < 100; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(20) + 1
+
+		expectedResults, expectedDistances := vp.Search(q, k)
+		results, distances := s.Search(q, k)
+
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+	}
+}
+
+// This test makes sure steady-state searches don't allocate
+func TestSearcherAllocs(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+	s := vp.NewSearcher()
+
+	var target interface{} = Coordinate{0.5, 0.5}
+	results := make([]interface{}, 0, 10)
+	distances := make([]float64, 0, 10)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		results, distances = s.SearchInto(target, 10, results[:0], distances[:0])
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+// This test makes sure the approximation options keep their promises
+func TestSearcherOptions(t *testing.T) {
+	_, items := randomCoordinates(1000)
+
+	calls := 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	vp := New(counting, items)
+	budgeted := vp.NewSearcher(WithMaxVisits(20))
+	approximate := vp.NewSearcher(WithEpsilon(0.5))
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		_, exact := vp.Search(q, 5)
+
+		calls = 0
+		results, _ := budgeted.Search(q, 5)
+		if calls > 20 || len(results) != 5 {
+			t.Errorf("Expected 5 results from at most 20 metric calls, got %v from %v", len(results), calls)
+		}
+
+		_, distances := approximate.Search(q, 5)
+		for j := range distances {
+			if distances[j] > 1.5*exact[j] {
+				t.Errorf("Expected distance %v to be within a factor of 1.5 of %v, got %v", j, exact[j], distances[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package vptree
+
+import "math/rand"
+
+// A VantagePointSelector picks the vantage point of a node while a VP-tree is
+// built. It is passed the items of the node's subtree and the node's depth,
+// where the root is at depth 0, and returns the index of the vantage point
+// in items. It must not modify items. Since it sees the whole subtree, it can
+// adapt its strategy to the level, for example by sampling candidates
+// carefully near the root and picking at random further down.
+type VantagePointSelector func(items []interface{}, depth int) int
+
+// WithVantagePointSelector makes the VP-tree pick its vantage points with
+// sel instead of at random.
+func WithVantagePointSelector(sel VantagePointSelector) Option {
+	return func(vp *VPTree) {
+		vp.selector = sel
+	}
+}
+
+// NewHierarchical creates a new VP-tree like New, but picks the vantage
+// point of every node with vpSelector. It is short for New with
+// WithVantagePointSelector.
+func NewHierarchical(metric Metric, items []interface{}, vpSelector VantagePointSelector, opts ...Option) *VPTree {
+	return New(metric, items, append(opts, WithVantagePointSelector(vpSelector))...)
+}
+
+// Default parameters of MaxVarianceSampledSelector.
+const (
+	DefaultSelectorCandidates = 10
+	DefaultSelectorProbes     = 20
+)
+
+// MaxVarianceSampledSelector returns a VantagePointSelector that picks
+// vantage points whose distances to the other items vary the most. Items
+// at widely varying distances from the vantage point are split cleanly by
+// the median, which lets searches prune more of the tree than a vantage
+// point picked at random.
+//
+// For every node, the selector draws numCandidates random items as
+// candidates, and estimates the variance of the distances of each from
+// numProbes random items of the node's subtree, which takes
+// numCandidates*numProbes metric calls. Values below 1 are replaced by
+// DefaultSelectorCandidates and DefaultSelectorProbes.
+func MaxVarianceSampledSelector(metric Metric, numCandidates, numProbes int) VantagePointSelector {
+	if numCandidates < 1 {
+		numCandidates = DefaultSelectorCandidates
+	}
+	if numProbes < 1 {
+		numProbes = DefaultSelectorProbes
+	}
+
+	return func(items []interface{}, depth int) int {
+		if len(items) <= 2 {
+			return rand.Intn(len(items))
+		}
+
+		best, bestVariance := 0, -1.0
+		for c := 0; c < numCandidates; c++ {
+			candidate := rand.Intn(len(items))
+
+			sum, sumSquares := 0.0, 0.0
+			for p := 0; p < numProbes; p++ {
+				d := metric(items[candidate], items[rand.Intn(len(items))])
+				sum += d
+				sumSquares += d * d
+			}
+
+			mean := sum / float64(numProbes)
+			if variance := sumSquares/float64(numProbes) - mean*mean; variance > bestVariance {
+				best, bestVariance = candidate, variance
+			}
+		}
+
+		return best
+	}
+}
@@ -0,0 +1,90 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test builds a tree with a level-aware selector and makes sure the
+// selector sees every subtree with its depth, and that the tree finds the
+// same neighbours as brute force
+func TestNewHierarchical(t *testing.T) {
+	coords, items := randomCoordinates(1000)
+
+	var calls []int
+	rootItems := 0
+	vp := NewHierarchical(CoordinateMetric, items, func(items []interface{}, depth int) int {
+		for len(calls) <= depth {
+			calls = append(calls, 0)
+		}
+		calls[depth]++
+		if depth == 0 {
+			rootItems = len(items)
+		}
+
+		// Pick the first item near the root, and random ones below
+		if depth < 3 {
+			return 0
+		}
+		return rand.Intn(len(items))
+	})
+
+	if rootItems != len(items) {
+		t.Errorf("Expected the selector to see %v items at the root, got %v", len(items), rootItems)
+	}
+
+	// The selector is called once per node, with the node's depth
+	counts := vp.LevelCounts()
+	if len(calls) != len(counts) {
+		t.Fatalf("Expected calls at %v depths, got %v", len(counts), len(calls))
+	}
+	for d := range counts {
+		if calls[d] != counts[d] {
+			t.Errorf("Expected %v calls at depth %v, got %v", counts[d], d, calls[d])
+		}
+	}
+
+	if vp.root.Item != items[0] {
+		t.Errorf("Expected the root to be %v, got %v", items[0], vp.root.Item)
+	}
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expectedCoords, expectedDists := nearestNeighbours(q, coords, 10)
+		results, distances := vp.Search(q, 10)
+		compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+	}
+}
+
+// This test checks that vantage points picked by MaxVarianceSampledSelector
+// make searches cheaper than random ones, and still find the right
+// neighbours. Single trees vary a lot, so the costs are summed over a few.
+func TestMaxVarianceSampledSelector(t *testing.T) {
+	coords, items := randomCoordinates(5000)
+	selector := MaxVarianceSampledSelector(CoordinateMetric, 0, 0)
+
+	randomCalls, sampledCalls := 0, 0
+	for i := 0; i < 5; i++ {
+		random := New(CoordinateMetric, items)
+		sampled := NewHierarchical(CoordinateMetric, items, selector)
+
+		for j := 0; j < 100; j++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			results, distances, stats := sampled.SearchWithStats(q, 10)
+			if i == 0 {
+				expectedCoords, expectedDists := nearestNeighbours(q, coords, 10)
+				compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+			}
+			sampledCalls += stats.MetricCalls
+
+			_, _, stats = random.SearchWithStats(q, 10)
+			randomCalls += stats.MetricCalls
+		}
+	}
+
+	if sampledCalls >= randomCalls {
+		t.Errorf("Expected fewer metric calls than with random vantage points, got %v and %v", sampledCalls, randomCalls)
+	}
+}
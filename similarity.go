@@ -0,0 +1,91 @@
+package vptree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// maxValidationSample is the number of items SimilarityToMetric checks the
+// metric on at most.
+const maxValidationSample = 50
+
+// ErrNoSample is returned by SimilarityToMetric if it is asked to validate
+// the metric, but has no items to do so on.
+var ErrNoSample = errors.New("vptree: no sample to validate the metric on")
+
+// SimilarityToMetric turns a similarity function, for which higher values
+// mean more similar items, into a distance by returning 1 - sim(a, b). The
+// result is only a metric if sim is normalized so that sim(x, x) = 1, and
+// even then many similarities, such as the cosine similarity, violate the
+// triangle inequality, which makes VP-tree searches miss neighbours.
+//
+// If validate is true, the returned Metric is tested with CheckMetric on the
+// items of sample, of which up to 50 spread evenly over the slice are used,
+// and the *MetricError listing the violations is returned along with the
+// Metric. Validating without a sample returns ErrNoSample.
+func SimilarityToMetric(sim func(a, b interface{}) float64, validate bool, sample ...interface{}) (Metric, error) {
+	metric := func(a, b interface{}) float64 {
+		return 1 - sim(a, b)
+	}
+
+	if !validate {
+		return metric, nil
+	}
+
+	if len(sample) == 0 {
+		return metric, ErrNoSample
+	}
+
+	if len(sample) > maxValidationSample {
+		spread := make([]interface{}, maxValidationSample)
+		for i := range spread {
+			spread[i] = sample[i*len(sample)/maxValidationSample]
+		}
+		sample = spread
+	}
+
+	return metric, CheckMetric(metric, sample)
+}
+
+// normalizeSamplePairs is the number of pairs NormalizeMetric samples.
+const normalizeSamplePairs = 1000
+
+// NormalizeMetric returns base divided by the standard deviation of its
+// distances, estimated from 1000 random pairs of items, so that the
+// distances between the items have unit variance. The pairs are drawn with
+// a fixed seed, which makes the result only depend on items.
+//
+// Unlike standardizing the distances, NormalizeMetric doesn't subtract
+// their mean: that would give items a nonzero distance to themselves and
+// negative distances to their neighbours, and break the triangle
+// inequality that VP-tree searches rely on. Scaling keeps base a metric and
+// doesn't change the neighbours a search finds, only their distances, which
+// makes radii and thresholds comparable across data sets whose distances
+// span very different ranges. With fewer than two items, or if all sampled
+// distances are equal, base is returned unchanged.
+func NormalizeMetric(base Metric, items []interface{}) Metric {
+	if len(items) < 2 {
+		return base
+	}
+
+	rng := rand.New(rand.NewSource(1))
+
+	var sum, sumSquares float64
+	for s := 0; s < normalizeSamplePairs; s++ {
+		i, j := distinctPair(rng, len(items))
+		d := base(items[i], items[j])
+		sum += d
+		sumSquares += d * d
+	}
+
+	mean := sum / normalizeSamplePairs
+	stddev := math.Sqrt(math.Max(sumSquares/normalizeSamplePairs-mean*mean, 0))
+	if stddev == 0 || math.IsInf(stddev, 0) || math.IsNaN(stddev) {
+		return base
+	}
+
+	return func(a, b interface{}) float64 {
+		return base(a, b) / stddev
+	}
+}
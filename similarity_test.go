@@ -0,0 +1,97 @@
+package vptree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test converts a similarity that yields a metric and one that does not
+func TestSimilarityToMetric(t *testing.T) {
+	var sample []interface{}
+	for i := 0; i < 200; i++ {
+		sample = append(sample, []float64{rand.Float64()*2 - 1, rand.Float64()*2 - 1})
+	}
+
+	// 1 minus the Chebyshev distance, scaled to the range of the sample
+	chebyshev := func(a, b interface{}) float64 {
+		x, y := a.([]float64), b.([]float64)
+		return 1 - math.Max(math.Abs(x[0]-y[0]), math.Abs(x[1]-y[1]))/2
+	}
+
+	metric, err := SimilarityToMetric(chebyshev, true, sample...)
+	if err != nil {
+		t.Errorf("Expected no violations, got %v", err)
+	}
+	if d := metric([]float64{0, 0}, []float64{1, 0.5}); d != 0.5 {
+		t.Errorf("Expected a distance of 0.5, got %v", d)
+	}
+
+	cosine := func(a, b interface{}) float64 {
+		x, y := a.([]float64), b.([]float64)
+		return (x[0]*y[0] + x[1]*y[1]) / math.Hypot(x[0], x[1]) / math.Hypot(y[0], y[1])
+	}
+
+	_, err = SimilarityToMetric(cosine, true, sample...)
+	var merr *MetricError
+	if !errors.As(err, &merr) || len(merr.Violations) == 0 {
+		t.Errorf("Expected the cosine distance to violate the triangle inequality, got %v", err)
+	}
+
+	if _, err := SimilarityToMetric(cosine, false); err != nil {
+		t.Errorf("Expected no validation, got %v", err)
+	}
+
+	if _, err := SimilarityToMetric(cosine, true); !errors.Is(err, ErrNoSample) {
+		t.Errorf("Expected ErrNoSample, got %v", err)
+	}
+}
+
+// This test normalizes a metric whose distances span a large range and
+// checks that the distances have unit variance and the search results stay
+// the same
+func TestNormalizeMetric(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+
+	scaled := func(a, b interface{}) float64 {
+		return 1e6 * CoordinateMetric(a, b)
+	}
+	normalized := NormalizeMetric(scaled, vpitems)
+
+	var dists []float64
+	for i := 0; i < 5000; i++ {
+		dists = append(dists, normalized(items[rand.Intn(len(items))], items[rand.Intn(len(items))]))
+	}
+
+	var sum, sumSquares float64
+	for _, d := range dists {
+		sum += d
+		sumSquares += d * d
+	}
+	mean := sum / float64(len(dists))
+	if variance := sumSquares/float64(len(dists)) - mean*mean; math.Abs(variance-1) > 0.1 {
+		t.Errorf("Expected a variance of about 1, got %v", variance)
+	}
+
+	if d := normalized(items[0], items[0]); d != 0 {
+		t.Errorf("Expected the distance of an item to itself to stay 0, got %v", d)
+	}
+
+	vp1 := New(scaled, vpitems)
+	vp2 := New(normalized, vpitems)
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	results1, _ := vp1.Search(q, 10)
+	results2, _ := vp2.Search(q, 10)
+	for i := range results1 {
+		if results1[i] != results2[i] {
+			t.Errorf("Expected the same neighbours, got %v and %v", results1, results2)
+			break
+		}
+	}
+
+	same := []interface{}{Coordinate{1, 1}, Coordinate{1, 1}}
+	if d := NormalizeMetric(scaled, same)(Coordinate{0, 0}, Coordinate{0, 1}); d != 1e6 {
+		t.Errorf("Expected the base metric for equal distances, got %v", d)
+	}
+}
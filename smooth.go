@@ -0,0 +1,48 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+)
+
+// KNNSmooth replaces every item's value with the average of the values of
+// its k nearest neighbours, weighted by kernel(distance). values must be
+// aligned with the items slice the VP-tree was built from. The item itself
+// counts as one of its neighbours, at distance 0. If all weights of an item's
+// neighbours are 0, the item keeps its value.
+//
+// The result is aligned with the items slice, too. KNNSmooth panics if
+// values does not hold one value per item.
+func (vp *VPTree) KNNSmooth(values []float64, k int, kernel func(float64) float64) []float64 {
+	if count := vp.itemCount(); len(values) != count {
+		panic(fmt.Sprintf("vptree: %d values for %d items", len(values), count))
+	}
+
+	smoothed := make([]float64, len(values))
+	vp.forEachItem(0, func(n *node) {
+		indices, distances := vp.SearchIndices(n.Item, k)
+
+		sum, weights := 0.0, 0.0
+		for i, idx := range indices {
+			w := kernel(distances[i])
+			sum += w * values[idx]
+			weights += w
+		}
+
+		if weights == 0 {
+			smoothed[n.Index] = values[n.Index]
+		} else {
+			smoothed[n.Index] = sum / weights
+		}
+	})
+
+	return smoothed
+}
+
+// GaussianKernel returns the kernel exp(-d²/(2·bandwidth²)) for use with
+// KNNSmooth.
+func GaussianKernel(bandwidth float64) func(float64) float64 {
+	return func(d float64) float64 {
+		return math.Exp(-d * d / (2 * bandwidth * bandwidth))
+	}
+}
@@ -0,0 +1,51 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test compares KNNSmooth against a brute-force weighted average
+func TestKNNSmooth(t *testing.T) {
+	coords, items := randomCoordinates(300)
+
+	values := make([]float64, len(items))
+	for i, c := range coords {
+		values[i] = c.X + 0.1*rand.NormFloat64()
+	}
+
+	kernel := GaussianKernel(0.05)
+	smoothed := New(CoordinateMetric, items).KNNSmooth(values, 8, kernel)
+
+	for i, c := range coords {
+		neighbours, distances := nearestNeighbours(c, coords, 8)
+
+		sum, weights := 0.0, 0.0
+		for j, nb := range neighbours {
+			for idx, other := range coords {
+				if other == nb {
+					sum += kernel(distances[j]) * values[idx]
+					weights += kernel(distances[j])
+				}
+			}
+		}
+
+		if expected := sum / weights; math.Abs(smoothed[i]-expected) > 1e-9 {
+			t.Errorf("Expected smoothed[%v] to be %v, got %v", i, expected, smoothed[i])
+		}
+	}
+}
+
+// This test makes sure items keep their values when all weights are 0
+func TestKNNSmoothZeroWeights(t *testing.T) {
+	_, items := randomCoordinates(10)
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	zero := func(float64) float64 { return 0 }
+	for i, v := range New(CoordinateMetric, items).KNNSmooth(values, 3, zero) {
+		if v != values[i] {
+			t.Errorf("Expected smoothed[%v] to be %v, got %v", i, values[i], v)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// maxSpillFraction is the largest fraction of a node's items that may end up
+// in one child of a spill tree node. Nodes whose overlap zone would exceed it
+// are split without overlap, so that the tree stays of bounded size.
+const maxSpillFraction = 0.7
+
+// NewSpillTree creates a VP-tree variant whose inner and outer balls overlap.
+// Items whose distance to a vantage point lies within a factor of 1±rho of the
+// node's threshold are stored in both children, so every item is reachable
+// from either side of the boundary.
+//
+// Searches on a spill tree do not backtrack: they only descend into both
+// children when the target falls into the overlap zone, and otherwise just
+// into the child on the target's side. This makes them approximate, with
+// larger values of rho giving better recall at the cost of more memory and
+// more visited nodes.
+func NewSpillTree(metric Metric, items []interface{}, rho float64) *VPTree {
+	t := &VPTree{
+		distanceMetric: metric,
+		spill:          rho,
+	}
+
+	indices := make([]int, len(items))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	t.root = t.buildSpill(items, indices)
+	return t
+}
+
+func (vp *VPTree) buildSpill(items []interface{}, indices []int) *node {
+	if len(items) == 0 {
+		return nil
+	}
+
+	// Take a random item and make it this node's item
+	idx := rand.Intn(len(items))
+	n := &node{Item: items[idx], Index: indices[idx], Size: 1}
+
+	rest := make([]interface{}, 0, len(items)-1)
+	restIndices := make([]int, 0, len(items)-1)
+	dists := make([]float64, 0, len(items)-1)
+	for i, item := range items {
+		if i != idx {
+			rest = append(rest, item)
+			restIndices = append(restIndices, indices[i])
+			dists = append(dists, vp.distanceMetric(item, n.Item))
+		}
+	}
+
+	if len(rest) == 0 {
+		return n
+	}
+
+	sorted := append([]float64(nil), dists...)
+	sort.Float64s(sorted)
+	n.Threshold = sorted[len(sorted)/2]
+
+	lower, upper := n.Threshold*(1-vp.spill), n.Threshold*(1+vp.spill)
+
+	inLeft := func(d float64) bool { return d <= upper }
+	inRight := func(d float64) bool { return d >= lower }
+
+	leftCount, rightCount := 0, 0
+	for _, d := range dists {
+		if inLeft(d) {
+			leftCount++
+		}
+		if inRight(d) {
+			rightCount++
+		}
+	}
+
+	if limit := int(maxSpillFraction * float64(len(rest))); leftCount > limit || rightCount > limit {
+		inLeft = func(d float64) bool { return d <= n.Threshold }
+		inRight = func(d float64) bool { return d > n.Threshold }
+	}
+
+	var left, right []interface{}
+	var leftIndices, rightIndices []int
+	for i, d := range dists {
+		if inLeft(d) {
+			left = append(left, rest[i])
+			leftIndices = append(leftIndices, restIndices[i])
+		}
+		if inRight(d) {
+			right = append(right, rest[i])
+			rightIndices = append(rightIndices, restIndices[i])
+		}
+	}
+
+	n.Left = vp.buildSpill(left, leftIndices)
+	n.Right = vp.buildSpill(right, rightIndices)
+
+	if n.Left != nil {
+		n.Size += n.Left.Size
+	}
+	if n.Right != nil {
+		n.Size += n.Right.Size
+	}
+
+	return n
+}
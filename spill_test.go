@@ -0,0 +1,77 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// recallOf returns how many of the true k nearest neighbours of random
+// targets the tree finds, and fails if any result is reported twice
+func recallOf(t *testing.T, vp *VPTree, items []Coordinate, k, queries int) (hits int) {
+	for q := 0; q < queries; q++ {
+		target := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		coords, _ := vp.Search(target, k)
+		expected, _ := nearestNeighbours(target, items, k)
+
+		seen := make(map[interface{}]bool)
+		for _, c := range coords {
+			if seen[c] {
+				t.Fatalf("Item %v returned twice", c)
+			}
+			seen[c] = true
+
+			for _, e := range expected {
+				if c == e {
+					hits++
+				}
+			}
+		}
+	}
+	return
+}
+
+// This test makes sure larger overlaps improve the recall of spill trees
+func TestSpillTreeRecall(t *testing.T) {
+	items, vpitems := randomCoordinates(2000)
+
+	defeatist := recallOf(t, NewSpillTree(CoordinateMetric, vpitems, 1e-9), items, 10, 200)
+	spill := recallOf(t, NewSpillTree(CoordinateMetric, vpitems, 0.2), items, 10, 200)
+
+	if spill <= defeatist {
+		t.Errorf("Expected the spill tree to find more than the %v neighbours found without overlap, got %v", defeatist, spill)
+	}
+
+	if recall := float64(spill) / 2000; recall < 0.75 {
+		t.Errorf("Expected a recall of at least 0.75, got %v", recall)
+	}
+}
+
+// This test makes sure radius searches on spill trees are exact and free of
+// duplicates
+func TestSpillTreeSearchRadius(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	vp := NewSpillTree(CoordinateMetric, vpitems, 0.3)
+
+	target := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	coords, _ := vp.SearchRadius(target, 0.1)
+
+	expected := 0
+	for _, item := range items {
+		if CoordinateMetric(item, target) <= 0.1 {
+			expected++
+		}
+	}
+
+	if len(coords) != expected {
+		t.Errorf("Expected %v items within radius, got %v", expected, len(coords))
+	}
+
+	seen := make(map[interface{}]bool)
+	for _, c := range coords {
+		if seen[c] {
+			t.Errorf("Item %v returned twice", c)
+		}
+		seen[c] = true
+	}
+}
@@ -0,0 +1,164 @@
+package vptree
+
+import (
+	"math"
+	"time"
+)
+
+// QueryStats describes the work done by a single search.
+type QueryStats struct {
+	// MetricCalls is the number of distances computed.
+	MetricCalls int
+
+	// NodesVisited is the number of tree nodes examined.
+	NodesVisited int
+
+	// HeapEvictions is the number of candidates that were pushed out of
+	// the result heap by closer ones.
+	HeapEvictions int
+
+	// Tau is the final search radius, that is, the distance to the k-th
+	// nearest neighbour, or +Inf if fewer than k items were found.
+	Tau float64
+
+	// MaxDepth is the depth of the deepest node visited, where the root
+	// is at depth 0.
+	MaxDepth int
+
+	// Plan is how the search was run. Unless the VP-tree was built with
+	// WithAutoPlan, it is always PlanTree, and the estimated costs are 0.
+	Plan Plan
+
+	// EstimatedTreeCost and EstimatedScanCost are the planner's
+	// estimates of the cost of searching the tree and of scanning all
+	// items, in metric calls.
+	EstimatedTreeCost float64
+	EstimatedScanCost float64
+}
+
+// SearchWithStats is like Search, but also reports what the search cost.
+// Search itself does not collect any statistics, unless the VP-tree has an
+// OnSearchDone hook.
+func (vp *VPTree) SearchWithStats(target interface{}, k int) (results []interface{}, distances []float64, stats QueryStats) {
+	stats.Tau = math.Inf(1)
+	if k < 1 {
+		return
+	}
+
+	q := vp.getKNNQuery(target, k)
+	defer putKNNQuery(q)
+	q.stats = &stats
+	vp.runKNN(q)
+
+	results, distances = itemsAndDistances(q.results())
+	return
+}
+
+// BuildStats describes the work done building a VP-tree.
+type BuildStats struct {
+	// Items is the number of items in the tree.
+	Items int
+
+	// Duplicates is the number of items dropped as duplicates. See
+	// WithDeduplication.
+	Duplicates int
+
+	// MetricCalls is the number of distances computed.
+	MetricCalls int
+
+	// Height is the number of nodes on the longest path from the root to
+	// a leaf.
+	Height int
+
+	// Duration is the wall-clock time the build took.
+	Duration time.Duration
+}
+
+// Hooks are functions a VP-tree calls to report on its work, for bridging to
+// monitoring systems. Nil hooks are skipped, and cost nothing. The hooks are
+// called synchronously, on the goroutine that did the work, and must be safe
+// for concurrent use if the VP-tree is searched concurrently.
+type Hooks struct {
+	// OnSearchDone is called after every k-nearest-neighbour or range
+	// search. For range searches, QueryStats.Tau is the radius and
+	// HeapEvictions is 0.
+	OnSearchDone func(QueryStats)
+
+	// OnBuildDone is called after New has built the tree.
+	OnBuildDone func(BuildStats)
+
+	// OnMutation is called after an operation that changes the tree, with
+	// the name of the operation.
+	OnMutation func(op string)
+}
+
+// WithInstrumentation installs hooks on a VP-tree.
+func WithInstrumentation(hooks Hooks) Option {
+	return func(vp *VPTree) {
+		vp.hooks = hooks
+	}
+}
+
+// height returns the number of nodes on the longest path from n to a leaf.
+func height(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return 1 + max(height(n.Left), height(n.Right))
+}
+
+// LevelCounts returns the number of nodes at every depth of the tree, where
+// the root is at depth 0. In a perfectly balanced tree, there are 2^d nodes
+// at depth d, so deviations from that show how unbalanced the tree is.
+func (vp *VPTree) LevelCounts() (counts []int) {
+	level := []*node{}
+	if vp.root != nil {
+		level = append(level, vp.root)
+	}
+
+	for len(level) > 0 {
+		counts = append(counts, len(level))
+
+		var next []*node
+		for _, n := range level {
+			if n.Left != nil {
+				next = append(next, n.Left)
+			}
+			if n.Right != nil {
+				next = append(next, n.Right)
+			}
+		}
+		level = next
+	}
+
+	return
+}
+
+// SubtreeSizes returns the number of items in each of the subtrees rooted at
+// the given depth, from left to right, where the root is at depth 0. Every
+// node keeps the size of its subtree, so this only visits the nodes above
+// depth, unless Upsert replaced items, which are counted out in a walk of
+// the whole tree. Comparing the sizes of sibling subtrees shows how
+// unbalanced the tree is at that level.
+func (vp *VPTree) SubtreeSizes(depth int) (sizes []int) {
+	size := vp.liveSizer()
+
+	var walk func(n *node, d int)
+	walk = func(n *node, d int) {
+		if n == nil {
+			return
+		}
+		if d == depth {
+			sizes = append(sizes, size(n))
+			return
+		}
+		walk(n.Left, d+1)
+		walk(n.Right, d+1)
+	}
+
+	if depth >= 0 {
+		walk(vp.root, 0)
+	}
+
+	return
+}
@@ -0,0 +1,195 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test cross-checks QueryStats against a counting metric and makes sure
+// SearchWithStats returns the same results as Search
+func TestSearchWithStats(t *testing.T) {
+	_, items := randomCoordinates(1000)
+
+	calls := 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	vp := New(counting, items)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(10) + 1
+
+		expectedResults, expectedDistances := vp.Search(q, k)
+
+		calls = 0
+		results, distances, stats := vp.SearchWithStats(q, k)
+
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+		if stats.MetricCalls != calls {
+			t.Errorf("Expected %v metric calls, got %v", calls, stats.MetricCalls)
+		}
+
+		if stats.NodesVisited < stats.MetricCalls || stats.NodesVisited >= len(items) {
+			t.Errorf("Expected between %v and %v visited nodes, got %v", stats.MetricCalls, len(items), stats.NodesVisited)
+		}
+
+		if stats.Tau != distances[k-1] {
+			t.Errorf("Expected tau to be %v, got %v", distances[k-1], stats.Tau)
+		}
+
+		if stats.MaxDepth < 1 || stats.MaxDepth >= stats.NodesVisited {
+			t.Errorf("Expected a max depth between 1 and %v, got %v", stats.NodesVisited, stats.MaxDepth)
+		}
+	}
+}
+
+// This test pins the statistics of a search on a tree small enough to
+// reason about
+func TestSearchWithStatsExhaustive(t *testing.T) {
+	items := []interface{}{
+		Coordinate{0, 0},
+		Coordinate{1, 0},
+		Coordinate{2, 0},
+		Coordinate{3, 0},
+	}
+
+	vp := New(CoordinateMetric, items)
+
+	// With k larger than the tree, nothing can be pruned or evicted
+	_, _, stats := vp.SearchWithStats(Coordinate{0, 0}, 5)
+
+	if stats.MetricCalls != 4 || stats.NodesVisited != 4 || stats.HeapEvictions != 0 || !math.IsInf(stats.Tau, 1) {
+		t.Errorf("Expected 4 metric calls and nodes, no evictions and an infinite tau, got %+v", stats)
+	}
+
+	// With k = 1, every closer item evicts the previous candidate
+	_, _, stats = vp.SearchWithStats(Coordinate{0, 0}, 1)
+
+	if stats.Tau != 0 || stats.HeapEvictions >= stats.MetricCalls {
+		t.Errorf("Expected a tau of 0 and fewer evictions than metric calls, got %+v", stats)
+	}
+}
+
+// This test installs recording hooks and checks their payloads for a build,
+// a k-NN search and a range search
+func TestInstrumentation(t *testing.T) {
+	_, items := randomCoordinates(500)
+
+	calls := 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	var builds []BuildStats
+	var searches []QueryStats
+
+	vp := New(counting, items, WithInstrumentation(Hooks{
+		OnBuildDone:  func(s BuildStats) { builds = append(builds, s) },
+		OnSearchDone: func(s QueryStats) { searches = append(searches, s) },
+	}))
+
+	if len(builds) != 1 {
+		t.Fatalf("Expected one build, got %v", len(builds))
+	}
+
+	if b := builds[0]; b.Items != len(items) || b.MetricCalls != calls || b.Height != height(vp.root) || b.Height < 2 {
+		t.Errorf("Expected %v items, %v metric calls and a height of %v, got %+v", len(items), calls, height(vp.root), b)
+	}
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	calls = 0
+	_, distances := vp.Search(q, 5)
+
+	if len(searches) != 1 {
+		t.Fatalf("Expected one search, got %v", len(searches))
+	}
+
+	if s := searches[0]; s.MetricCalls != calls || s.Tau != distances[4] {
+		t.Errorf("Expected %v metric calls and a tau of %v, got %+v", calls, distances[4], s)
+	}
+
+	calls = 0
+	vp.SearchRadius(q, 0.1)
+
+	if len(searches) != 2 {
+		t.Fatalf("Expected two searches, got %v", len(searches))
+	}
+
+	if s := searches[1]; s.MetricCalls != calls || s.Tau != 0.1 {
+		t.Errorf("Expected %v metric calls and a tau of 0.1, got %+v", calls, s)
+	}
+
+	// SearchWithStats reports to the hook, too
+	_, _, stats := vp.SearchWithStats(q, 3)
+
+	if len(searches) != 3 || searches[2] != stats {
+		t.Errorf("Expected the hook to receive %+v, got %+v", stats, searches)
+	}
+}
+
+// This test checks LevelCounts against the tree's size and height
+func TestLevelCounts(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	counts := vp.LevelCounts()
+
+	if len(counts) != height(vp.root) {
+		t.Errorf("Expected %v levels, got %v", height(vp.root), len(counts))
+	}
+
+	total := 0
+	for d, c := range counts {
+		if c < 1 || c > 1<<d {
+			t.Errorf("Expected between 1 and %v nodes at depth %v, got %v", 1<<d, d, c)
+		}
+		total += c
+	}
+
+	if total != len(items) {
+		t.Errorf("Expected %v nodes in total, got %v", len(items), total)
+	}
+
+	if counts := New(CoordinateMetric, nil).LevelCounts(); len(counts) != 0 {
+		t.Errorf("Expected no levels for an empty tree, got %v", counts)
+	}
+}
+
+// This test checks SubtreeSizes against the level counts and the number of
+// items
+func TestSubtreeSizesByDepth(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	counts := vp.LevelCounts()
+	for d := range counts {
+		sizes := vp.SubtreeSizes(d)
+		if len(sizes) != counts[d] {
+			t.Errorf("Expected %v subtrees at depth %v, got %v", counts[d], d, len(sizes))
+		}
+
+		// The subtrees at depth d hold every item except those above
+		total := 0
+		for _, s := range sizes {
+			total += s
+		}
+		above := 0
+		for _, c := range counts[:d] {
+			above += c
+		}
+		if total != len(items)-above {
+			t.Errorf("Expected %v items below depth %v, got %v", len(items)-above, d, total)
+		}
+	}
+
+	if sizes := vp.SubtreeSizes(len(counts)); len(sizes) != 0 {
+		t.Errorf("Expected no subtrees below the deepest level, got %v", sizes)
+	}
+}
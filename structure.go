@@ -0,0 +1,172 @@
+package vptree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// ErrStructureMismatch is returned by LoadStructure if the structure refers
+// to items that are not in the items slice, if the items don't match the
+// structure's checksum, or if the structure is malformed.
+var ErrStructureMismatch = errors.New("vptree: structure does not match the items")
+
+// A structure is the shape of a VP-tree without its items. The nodes are in
+// preorder, so the root comes first, and refer to their children by their
+// position, or -1 for no child. Deleted lists the items Upsert replaced, and
+// Checksum, if not nil, is the checksum of the items, see itemChecksum.
+type structure struct {
+	Items    int
+	Spill    float64
+	Nodes    []checkpointNode
+	Deleted  []int
+	Checksum []byte
+}
+
+// SerializeStructure writes the structure of the VP-tree to w: for every
+// node, the index of its item in the items slice the tree was built from,
+// its threshold and its children, but not the item itself. This is useful
+// when the items are kept elsewhere, such as in a database, and would only
+// take up space in the serialized tree. LoadStructure reads the tree back.
+//
+// If the VP-tree has an ItemCodec, see WithItemCodec, the structure also
+// holds a checksum of the encoded items, so that LoadStructure can detect
+// items that changed or are in a different order.
+func (vp *VPTree) SerializeStructure(w io.Writer) error {
+	s := structure{
+		Items: vp.itemCount(),
+		Spill: vp.spill,
+	}
+	appendStructure(&s.Nodes, vp.root)
+	s.Deleted = vp.deletedIndices()
+
+	if vp.itemCodec != nil {
+		items := make([]interface{}, s.Items)
+		vp.forEachNode(func(n *node) {
+			items[n.Index] = n.Item
+		})
+
+		var err error
+		if s.Checksum, err = itemChecksum(vp.itemCodec, s.Nodes, items); err != nil {
+			return err
+		}
+	}
+
+	return gob.NewEncoder(w).Encode(&s)
+}
+
+// itemChecksum returns the FNV-1a hash of the indices and encodings of the
+// items of nodes, in their order.
+func itemChecksum(codec ItemCodec, nodes []checkpointNode, items []interface{}) ([]byte, error) {
+	h := fnv.New64a()
+
+	var buf []byte
+	for _, sn := range nodes {
+		buf = binary.AppendUvarint(buf[:0], uint64(sn.Index))
+		start := len(buf)
+
+		var err error
+		if buf, err = codec.AppendItem(buf, items[sn.Index]); err != nil {
+			return nil, fmt.Errorf("vptree: encoding item %v: %w", sn.Index, err)
+		}
+
+		h.Write(binary.AppendUvarint(nil, uint64(len(buf)-start)))
+		h.Write(buf)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// appendStructure appends the subtree rooted at n to nodes in preorder and
+// returns the position of n, or -1 if n is nil.
+func appendStructure(nodes *[]checkpointNode, n *node) int {
+	if n == nil {
+		return -1
+	}
+
+	pos := len(*nodes)
+	*nodes = append(*nodes, checkpointNode{Index: n.Index, Size: n.Size, Threshold: n.Threshold})
+
+	left := appendStructure(nodes, n.Left)
+	right := appendStructure(nodes, n.Right)
+	(*nodes)[pos].Left, (*nodes)[pos].Right = left, right
+
+	return pos
+}
+
+// LoadStructure reads a structure written by SerializeStructure from r and
+// reconstructs the VP-tree, looking up the items by their index in items,
+// which has to hold the items the tree was built from, in the same order.
+// The metric has to be the one the tree was built with, and opts are
+// applied to the tree as by New.
+//
+// If the structure holds a checksum of the items and opts set an ItemCodec,
+// the checksum of items is compared with it, which catches items that were
+// changed or reordered since SerializeStructure. Without the checksum, only
+// too few items are detected.
+func LoadStructure(r io.Reader, items []interface{}, metric Metric, opts ...Option) (*VPTree, error) {
+	var s structure
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("vptree: reading structure: %w", err)
+	}
+
+	if s.Items > len(items) {
+		return nil, ErrStructureMismatch
+	}
+
+	t := &VPTree{
+		distanceMetric: metric,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	t.spill, t.arena = s.Spill, make([]node, len(s.Nodes))
+
+	for i, sn := range s.Nodes {
+		// In preorder, children come after their parents, which also
+		// rules out cycles
+		if sn.Index < 0 || sn.Index >= len(items) ||
+			sn.Left >= len(s.Nodes) || (sn.Left >= 0 && sn.Left <= i) ||
+			sn.Right >= len(s.Nodes) || (sn.Right >= 0 && sn.Right <= i) {
+			return nil, ErrStructureMismatch
+		}
+
+		n := &t.arena[i]
+		n.Item, n.Index, n.Size, n.Threshold = items[sn.Index], sn.Index, sn.Size, sn.Threshold
+		if sn.Left >= 0 {
+			n.Left = &t.arena[sn.Left]
+		}
+		if sn.Right >= 0 {
+			n.Right = &t.arena[sn.Right]
+		}
+	}
+
+	if s.Checksum != nil && t.itemCodec != nil {
+		checksum, err := itemChecksum(t.itemCodec, s.Nodes, items)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(checksum, s.Checksum) {
+			return nil, fmt.Errorf("%w: checksum mismatch", ErrStructureMismatch)
+		}
+	}
+
+	for _, index := range s.Deleted {
+		if t.deleted == nil {
+			t.deleted = make(map[int]bool)
+		}
+		t.deleted[index] = true
+	}
+
+	if len(t.arena) > 0 {
+		t.root = &t.arena[0]
+	}
+
+	return t, nil
+}
@@ -0,0 +1,133 @@
+package vptree
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// walkInfos returns the NodeInfos of vp in preorder.
+func walkInfos(vp *VPTree) (infos []NodeInfo) {
+	vp.Walk(func(n NodeInfo) bool {
+		infos = append(infos, n)
+		return true
+	})
+	return
+}
+
+// This test round-trips a tree through SerializeStructure and LoadStructure
+// and compares the shape and the search results
+func TestSerializeStructure(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+
+	for name, vp := range map[string]*VPTree{
+		"Tree":  New(CoordinateMetric, vpitems),
+		"Leaf":  New(CoordinateMetric, vpitems, WithLeafSize(8)),
+		"Spill": NewSpillTree(CoordinateMetric, vpitems, 0.1),
+		"Empty": New(CoordinateMetric, nil),
+	} {
+		var buf bytes.Buffer
+		if err := vp.SerializeStructure(&buf); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		loaded, err := LoadStructure(&buf, vpitems, CoordinateMetric)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(walkInfos(loaded), walkInfos(vp)) {
+			t.Errorf("%v: the loaded tree differs from the original", name)
+		}
+
+		if name == "Empty" {
+			continue
+		}
+
+		for i := 0; i < 20; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			// Spill trees search approximately, so compare with the
+			// original tree rather than with the true neighbours
+			results1, distances1 := loaded.Search(q, 10)
+			results2, distances2 := vp.Search(q, 10)
+			if !reflect.DeepEqual(results1, results2) || !reflect.DeepEqual(distances1, distances2) {
+				t.Errorf("%v: expected %v at %v, got %v at %v", name, results2, distances2, results1, distances1)
+			}
+
+			if name == "Tree" {
+				coords, distances := nearestNeighbours(q, items, 10)
+				compareCoordDistSets(t, results1, coords, distances1, distances)
+			}
+		}
+	}
+}
+
+// This test makes sure mismatched items and broken structures are rejected
+func TestLoadStructureMismatch(t *testing.T) {
+	_, vpitems := randomCoordinates(100)
+	vp := New(CoordinateMetric, vpitems)
+
+	var buf bytes.Buffer
+	if err := vp.SerializeStructure(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if _, err := LoadStructure(bytes.NewReader(data), vpitems[:50], CoordinateMetric); !errors.Is(err, ErrStructureMismatch) {
+		t.Errorf("Expected ErrStructureMismatch for too few items, got %v", err)
+	}
+
+	if _, err := LoadStructure(bytes.NewReader(data[:len(data)/2]), vpitems, CoordinateMetric); err == nil {
+		t.Errorf("Expected an error for a truncated structure")
+	}
+}
+
+// This test checks that the checksum of the items catches items that were
+// reordered or changed, and that replaced items stay deleted
+func TestLoadStructureChecksum(t *testing.T) {
+	_, vpitems := randomCoordinates(100)
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}))
+
+	moved := Coordinate{X: 2, Y: 2}
+	vp.Upsert(vpitems[0], moved)
+	items := append(append([]interface{}(nil), vpitems...), moved)
+
+	var buf bytes.Buffer
+	if err := vp.SerializeStructure(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	codec := WithItemCodec(coordinateCodec{})
+
+	loaded, err := LoadStructure(bytes.NewReader(data), items, CoordinateMetric, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 100 {
+		t.Errorf("Expected 100 items, got %v", loaded.Len())
+	}
+	if results, _ := loaded.Search(vpitems[0], 1); len(results) != 1 || results[0] == vpitems[0] {
+		t.Errorf("Expected the replaced item to stay deleted, got %v", results)
+	}
+
+	swapped := append([]interface{}(nil), items...)
+	swapped[3], swapped[4] = swapped[4], swapped[3]
+	if _, err := LoadStructure(bytes.NewReader(data), swapped, CoordinateMetric, codec); !errors.Is(err, ErrStructureMismatch) {
+		t.Errorf("Expected ErrStructureMismatch for reordered items, got %v", err)
+	}
+
+	changed := append([]interface{}(nil), items...)
+	changed[50] = Coordinate{X: -1, Y: -1}
+	if _, err := LoadStructure(bytes.NewReader(data), changed, CoordinateMetric, codec); !errors.Is(err, ErrStructureMismatch) {
+		t.Errorf("Expected ErrStructureMismatch for a changed item, got %v", err)
+	}
+
+	// Without an ItemCodec, the checksum can't be checked
+	if _, err := LoadStructure(bytes.NewReader(data), swapped, CoordinateMetric); err != nil {
+		t.Errorf("Expected the checksum to be skipped without an ItemCodec, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// A TernaryTree is a VP-tree variant whose nodes split their items into
+// three regions instead of two: the closest third of the items to the
+// vantage point, the middle third, and the farthest third. The extra split
+// can prune better in metric spaces with very non-uniform distance
+// distributions, at the cost of a shallower but wider tree.
+type TernaryTree struct {
+	root           *ternaryNode
+	distanceMetric Metric
+	size           int
+}
+
+type ternaryNode struct {
+	Item  interface{}
+	Index int
+
+	// The items of Near are no farther from Item than Inner, those of
+	// Middle are between Inner and Outer, and those of Far are no closer
+	// than Outer
+	Inner, Outer      float64
+	Near, Middle, Far *ternaryNode
+}
+
+// NewTernary creates a new TernaryTree using the metric and items provided.
+func NewTernary(metric Metric, items []interface{}) *TernaryTree {
+	t := &TernaryTree{distanceMetric: metric, size: len(items)}
+
+	entries := make([]heapItem, len(items))
+	for i, item := range items {
+		entries[i] = heapItem{Item: item, Index: i}
+	}
+
+	t.root = t.build(entries)
+	return t
+}
+
+// build builds the subtree over entries, using their Dist fields as scratch
+// space.
+func (t *TernaryTree) build(entries []heapItem) *ternaryNode {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Take a random item out of the entries and make it this node's item
+	idx := rand.Intn(len(entries))
+	entries[idx], entries[len(entries)-1] = entries[len(entries)-1], entries[idx]
+	vp := entries[len(entries)-1]
+	entries = entries[:len(entries)-1]
+
+	n := &ternaryNode{Item: vp.Item, Index: vp.Index}
+	if len(entries) == 0 {
+		return n
+	}
+
+	for i := range entries {
+		entries[i].Dist = t.distanceMetric(entries[i].Item, vp.Item)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Dist < entries[j].Dist
+	})
+
+	// Split at the 33% and 67% quantiles. Items at the same distance may
+	// end up on either side of a threshold, which the search allows for.
+	a, b := len(entries)/3, 2*len(entries)/3
+	if a > 0 {
+		n.Inner = entries[a-1].Dist
+	}
+	if b > 0 {
+		n.Outer = entries[b-1].Dist
+	}
+
+	n.Near = t.build(entries[:a])
+	n.Middle = t.build(entries[a:b])
+	n.Far = t.build(entries[b:])
+
+	return n
+}
+
+// Search searches the TernaryTree for the k nearest neighbours of target. It
+// returns the up to k nearest neighbours and the corresponding distances in
+// order of least distance to largest distance.
+func (t *TernaryTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	q := getKNNQuery(target, k, t.size)
+	defer putKNNQuery(q)
+	t.search(q, t.root)
+
+	return itemsAndDistances(q.results())
+}
+
+func (t *TernaryTree) search(q *knnQuery, n *ternaryNode) {
+	if n == nil {
+		return
+	}
+
+	dist := t.distanceMetric(n.Item, q.target)
+
+	if dist < q.tau {
+		if q.h.Len() == q.k {
+			q.h.replaceTop(heapItem{n.Item, n.Index, dist})
+		} else {
+			q.h.push(heapItem{n.Item, n.Index, dist})
+		}
+		if q.h.Len() == q.k {
+			q.tau = q.h.dists[0]
+		}
+	}
+
+	// Search the region the target falls into first, then the others
+	// from nearest to farthest. Tau may shrink in between, so every
+	// region is checked right before it is searched.
+	near := func() {
+		if dist-q.tau <= n.Inner {
+			t.search(q, n.Near)
+		}
+	}
+	middle := func() {
+		if dist+q.tau >= n.Inner && dist-q.tau <= n.Outer {
+			t.search(q, n.Middle)
+		}
+	}
+	far := func() {
+		if dist+q.tau >= n.Outer {
+			t.search(q, n.Far)
+		}
+	}
+
+	switch {
+	case dist <= n.Inner:
+		near()
+		middle()
+		far()
+	case dist <= n.Outer:
+		middle()
+		if dist-n.Inner < n.Outer-dist {
+			near()
+			far()
+		} else {
+			far()
+			near()
+		}
+	default:
+		far()
+		middle()
+		near()
+	}
+}
+
+// SearchRadius searches the TernaryTree for all items within distance radius
+// of target. It returns the items and the corresponding distances in order
+// of least distance to largest distance.
+func (t *TernaryTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	var found []heapItem
+	t.searchRadius(t.root, target, radius, &found)
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Dist < found[j].Dist
+	})
+
+	return itemsAndDistances(found)
+}
+
+func (t *TernaryTree) searchRadius(n *ternaryNode, target interface{}, radius float64, found *[]heapItem) {
+	if n == nil {
+		return
+	}
+
+	dist := t.distanceMetric(n.Item, target)
+	if dist <= radius {
+		*found = append(*found, heapItem{n.Item, n.Index, dist})
+	}
+
+	if dist-radius <= n.Inner {
+		t.searchRadius(n.Near, target, radius, found)
+	}
+	if dist+radius >= n.Inner && dist-radius <= n.Outer {
+		t.searchRadius(n.Middle, target, radius, found)
+	}
+	if dist+radius >= n.Outer {
+		t.searchRadius(n.Far, target, radius, found)
+	}
+}
@@ -0,0 +1,72 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test compares the searches of a TernaryTree against brute force
+func TestTernaryTree(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 10, 1000} {
+		coords, items := randomCoordinates(n)
+		tt := NewTernary(CoordinateMetric, items)
+
+		for i := 0; i < 20; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			k := rand.Intn(20) + 1
+
+			expectedCoords, expectedDists := nearestNeighbours(q, coords, k)
+			results, distances := tt.Search(q, k)
+			compareCoordDistSets(t, results, expectedCoords, distances, expectedDists)
+
+			radius := rand.Float64() * 0.2
+			allCoords, allDists := nearestNeighbours(q, coords, len(coords))
+			var inRadius []Coordinate
+			var inRadiusDists []float64
+			for j, d := range allDists {
+				if d <= radius {
+					inRadius = append(inRadius, allCoords[j])
+					inRadiusDists = append(inRadiusDists, d)
+				}
+			}
+
+			results, distances = tt.SearchRadius(q, radius)
+			compareCoordDistSets(t, results, inRadius, distances, inRadiusDists)
+		}
+	}
+}
+
+// This test makes sure ties at the thresholds don't get lost
+func TestTernaryTreeDuplicates(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 100; i++ {
+		items = append(items, Coordinate{float64(i % 3), 0})
+	}
+	tt := NewTernary(CoordinateMetric, items)
+
+	results, _ := tt.SearchRadius(Coordinate{1, 0}, 0)
+	if len(results) != 33 {
+		t.Errorf("Expected 33 items at distance 0, got %v", len(results))
+	}
+
+	_, distances := tt.Search(Coordinate{2, 0}, 34)
+	if distances[32] != 0 || distances[33] != 1 {
+		t.Errorf("Expected 33 items at distance 0 followed by one at 1, got %v", distances)
+	}
+}
+
+func BenchmarkTernarySearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	tt := NewTernary(CoordinateMetric, items)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tt.Search(Coordinate{X: rng.Float64(), Y: rng.Float64()}, 10)
+	}
+}
@@ -0,0 +1,22 @@
+package vptree
+
+// SearchTopK is like Search, but finds the candidates nearest neighbours of
+// target and returns only the k nearest of them. With a heap of candidates
+// rather than k items, the search radius shrinks more slowly, so fewer
+// subtrees are pruned. This does not change the results as long as the
+// metric obeys the triangle inequality, but it improves recall for distance
+// functions that do not, such as the squared Euclidean distance, for which
+// Search may prune subtrees holding true nearest neighbours. A candidates
+// smaller than k is treated as k.
+func (vp *VPTree) SearchTopK(target interface{}, k, candidates int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	nearest := vp.nearest(target, max(k, candidates))
+	if len(nearest) > k {
+		nearest = nearest[:k]
+	}
+
+	return itemsAndDistances(nearest)
+}
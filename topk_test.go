@@ -0,0 +1,68 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure SearchTopK is exact for a metric
+func TestSearchTopK(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	vp := New(CoordinateMetric, vpitems)
+
+	for i := 0; i < 100; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		coords1, distances1 := vp.SearchTopK(q, 10, 50)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+	}
+
+	if results, distances := vp.SearchTopK(Coordinate{}, 0, 10); len(results) != 0 || len(distances) != 0 {
+		t.Errorf("Expected no results for k = 0, got %v", results)
+	}
+	if results, _ := vp.SearchTopK(Coordinate{}, 5, 1); len(results) != 5 {
+		t.Errorf("Expected 5 results when candidates < k, got %v", len(results))
+	}
+}
+
+// This test uses the squared Euclidean distance, which violates the
+// triangle inequality, and checks that considering more candidates never
+// makes the results worse and finds more of the true nearest neighbours
+func TestSearchTopKRecall(t *testing.T) {
+	items, vpitems := randomCoordinates(1000)
+	squared := func(a, b interface{}) float64 {
+		d := CoordinateMetric(a, b)
+		return d * d
+	}
+	vp := New(squared, vpitems)
+
+	plain, topK := 0, 0
+	for i := 0; i < 200; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		_, expected := nearestNeighbours(q, items, 10)
+
+		_, distances1 := vp.Search(q, 10)
+		_, distances2 := vp.SearchTopK(q, 10, 100)
+
+		for j := range distances2 {
+			if distances2[j] > distances1[j] {
+				t.Fatalf("Expected SearchTopK to be at least as close as Search, got %v and %v", distances2, distances1)
+			}
+		}
+
+		for j, d := range expected {
+			if distances1[j] == d*d {
+				plain++
+			}
+			if distances2[j] == d*d {
+				topK++
+			}
+		}
+	}
+
+	if topK <= plain {
+		t.Errorf("Expected SearchTopK to find more true neighbours than Search, got %v and %v", topK, plain)
+	}
+}
@@ -0,0 +1,51 @@
+package vptree
+
+// The actions of a TraceEntry.
+const (
+	TraceVisited     = "visited"
+	TraceAdded       = "added to heap"
+	TracePrunedLeft  = "pruned left"
+	TracePrunedRight = "pruned right"
+)
+
+// A TraceEntry records one decision of a search traced by SearchDebugTrace.
+//
+// For TraceVisited and TraceAdded, NodeItem is the vantage point of the node
+// the search visited and Distance is its distance to the target. For
+// TracePrunedLeft and TracePrunedRight, NodeItem is the vantage point of the
+// root of the skipped subtree, and Distance is the distance from the target
+// to its parent's vantage point, which the pruning decision was based on.
+// Tau is the search radius after the decision.
+type TraceEntry struct {
+	NodeItem interface{}
+	Distance float64
+	Tau      float64
+	Action   string
+}
+
+// SearchDebugTrace is like Search, but also returns a trace of every node the
+// search visited or skipped, in order. It is meant for finding out why a
+// search did or didn't return an item, not for production use.
+func (vp *VPTree) SearchDebugTrace(target interface{}, k int) (results []interface{}, distances []float64, trace []TraceEntry) {
+	if k < 1 {
+		return
+	}
+
+	q := vp.getKNNQuery(target, k)
+	defer putKNNQuery(q)
+	q.tracing = true
+	vp.runKNN(q)
+
+	results, distances = itemsAndDistances(q.results())
+	return results, distances, q.trace
+}
+
+// tracePrune records that the subtree rooted at n, the left or right child of
+// a node at distance dist from the target, was skipped.
+func (q *knnQuery) tracePrune(n *node, left bool, dist float64) {
+	action := TracePrunedRight
+	if left {
+		action = TracePrunedLeft
+	}
+	q.trace = append(q.trace, TraceEntry{n.Item, dist, q.tau, action})
+}
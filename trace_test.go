@@ -0,0 +1,70 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test checks the trace against the tree: every node is either visited
+// or inside a pruned subtree, and the added items end up in the results
+func TestSearchDebugTrace(t *testing.T) {
+	_, items := randomCoordinates(1000)
+
+	calls := 0
+	counting := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	vp := New(counting, items)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	expectedResults, expectedDistances := vp.Search(q, 5)
+
+	calls = 0
+	results, distances, trace := vp.SearchDebugTrace(q, 5)
+	compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+	sizes := make(map[interface{}]int)
+	var nodes []*node
+	vp.collectNodes(vp.root, &nodes)
+	for _, n := range nodes {
+		sizes[n.Item] = n.Size
+	}
+
+	visited, skipped := 0, 0
+	added := make(map[interface{}]bool)
+	for _, e := range trace {
+		switch e.Action {
+		case TraceVisited:
+			visited++
+			if e.Distance != CoordinateMetric(e.NodeItem, q) {
+				t.Errorf("Expected distance %v for %v, got %v", CoordinateMetric(e.NodeItem, q), e.NodeItem, e.Distance)
+			}
+		case TraceAdded:
+			added[e.NodeItem] = true
+		case TracePrunedLeft, TracePrunedRight:
+			skipped += sizes[e.NodeItem]
+		default:
+			t.Errorf("Unexpected action %q", e.Action)
+		}
+	}
+
+	if visited != calls {
+		t.Errorf("Expected %v visits, one per metric call, got %v", calls, visited)
+	}
+
+	if visited+skipped != len(items) {
+		t.Errorf("Expected %v visited and skipped nodes, got %v and %v", len(items), visited, skipped)
+	}
+
+	for _, r := range results {
+		if !added[r] {
+			t.Errorf("Expected %v to have been added to the heap", r)
+		}
+	}
+
+	if last := trace[len(trace)-1]; last.Tau != distances[4] {
+		t.Errorf("Expected the final tau to be %v, got %v", distances[4], last.Tau)
+	}
+}
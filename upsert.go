@@ -0,0 +1,196 @@
+package vptree
+
+import (
+	"reflect"
+	"slices"
+)
+
+// WithRebuildAfter makes the VP-tree rebuild itself after every n calls of
+// Upsert, which drops the items Upsert replaced and rebalances the tree. A
+// non-positive n, the default, never rebuilds.
+func WithRebuildAfter(n int) Option {
+	return func(vp *VPTree) {
+		vp.rebuildAfter = n
+	}
+}
+
+// Upsert replaces the item old with new, or inserts new if old isn't in the
+// VP-tree, and reports whether it replaced old. Items are identified by
+// structural equality, as by reflect.DeepEqual, among the items at distance
+// 0 from old; if there are several, one of them is replaced.
+//
+// The replacement is lazy: old is only marked as deleted, and new is
+// inserted as a leaf below the node it falls into. new gets the next unused
+// index, one more than the largest index in the tree. The node of old keeps
+// splitting its subtree, but searches and the other methods of the VP-tree
+// leave old out. The inserted items make the tree less balanced over time,
+// see WithRebuildAfter.
+//
+// On a spill tree, new is only inserted into the child it falls into, not
+// into the overlap zone, which lowers the recall of searches for it. Upsert
+// must not be called concurrently with other methods of the VP-tree. With
+// WithWAL, Upsert is recorded in the write-ahead log first.
+func (vp *VPTree) Upsert(old, new interface{}) (replaced bool) {
+	if vp.wal != nil {
+		vp.logUpsert(old, new)
+	}
+
+	vp.withinRadius(old, 0, func(found []heapItem) {
+		for _, hi := range found {
+			if reflect.DeepEqual(hi.Item, old) {
+				if vp.deleted == nil {
+					vp.deleted = make(map[int]bool)
+				}
+				vp.deleted[hi.Index] = true
+				replaced = true
+				return
+			}
+		}
+	})
+
+	vp.insert(new)
+	vp.mutated("Upsert")
+
+	vp.upserts++
+	if vp.rebuildAfter > 0 && vp.upserts >= vp.rebuildAfter {
+		vp.rebuild()
+	}
+
+	return
+}
+
+// insert adds item as a new leaf, descending from the root into the child
+// whose side of the threshold it falls on, and returns the links it passed
+// on the way, from the root's to the new leaf's.
+func (vp *VPTree) insert(item interface{}) (path []**node) {
+	if vp.nextIndex == 0 {
+		vp.nextIndex = vp.itemCount()
+	}
+
+	n := &node{Item: item, Index: vp.nextIndex, Size: 1}
+	vp.nextIndex++
+
+	link := &vp.root
+	for *link != nil {
+		path = append(path, link)
+		parent := *link
+		parent.Size++
+
+		if vp.distanceMetric(item, parent.Item) < parent.Threshold {
+			link = &parent.Left
+		} else {
+			link = &parent.Right
+		}
+	}
+	*link = n
+
+	return append(path, link)
+}
+
+// rebuild builds the VP-tree anew from the items that are not deleted,
+// keeping their indices.
+func (vp *VPTree) rebuild() {
+	var nodes []*node
+	vp.forEachNode(func(n *node) {
+		if vp.live(n) {
+			nodes = append(nodes, n)
+		}
+	})
+
+	// Spill trees hold some items more than once
+	slices.SortFunc(nodes, func(a, b *node) int { return a.Index - b.Index })
+	nodes = slices.CompactFunc(nodes, func(a, b *node) bool { return a.Index == b.Index })
+
+	items := make([]interface{}, len(nodes))
+	indices := make([]int, len(nodes))
+	for i, n := range nodes {
+		items[i], indices[i] = n.Item, n.Index
+	}
+
+	if vp.nodePool != nil && cap(vp.arena) > 0 {
+		vp.nodePool.put(vp.arena)
+	}
+	vp.arena, vp.deleted, vp.upserts = nil, nil, 0
+
+	if vp.spill > 0 {
+		vp.root = vp.buildSpill(items, indices)
+	} else {
+		if vp.nodePool != nil && len(items) > 0 {
+			vp.arena = vp.nodePool.get(len(items))
+		} else {
+			vp.arena = make([]node, 0, len(items))
+		}
+		vp.duplicates = 0
+		vp.root = vp.buildFromPoints(items, indices, 0)
+		vp.buildDists = nil
+	}
+
+	vp.mutated("Rebuild")
+}
+
+// live reports whether n's item is not deleted.
+func (vp *VPTree) live(n *node) bool {
+	return vp.deleted == nil || !vp.deleted[n.Index]
+}
+
+// deletedIndices returns the indices of the deleted items in increasing
+// order.
+func (vp *VPTree) deletedIndices() []int {
+	deleted := make([]int, 0, len(vp.deleted))
+	for index := range vp.deleted {
+		deleted = append(deleted, index)
+	}
+	slices.Sort(deleted)
+	return deleted
+}
+
+// liveSizer returns a function that counts the items of a subtree that are
+// not deleted. Node sizes count the nodes of deleted items, too, so if there
+// are any, the returned function looks up counts taken in a walk of the
+// tree.
+func (vp *VPTree) liveSizer() func(n *node) int {
+	if len(vp.deleted) == 0 {
+		return (*node).size
+	}
+
+	deleted := make(map[*node]int)
+	var count func(n *node) int
+	count = func(n *node) int {
+		if n == nil {
+			return 0
+		}
+		c := count(n.Left) + count(n.Right)
+		if !vp.live(n) {
+			c++
+		}
+		if c > 0 {
+			deleted[n] = c
+		}
+		return c
+	}
+	count(vp.root)
+
+	return func(n *node) int {
+		return n.size() - deleted[n]
+	}
+}
+
+// forEachNode calls f for every node of the VP-tree in preorder. It walks
+// the tree rather than the arena, which also holds nodes that rebuilds
+// replaced, and misses those that Upsert added.
+func (vp *VPTree) forEachNode(f func(n *node)) {
+	var buf [initialStackSize]*node
+	stack := append(buf[:0], vp.root)
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if n == nil {
+			continue
+		}
+
+		f(n)
+		stack = append(stack, n.Right, n.Left)
+	}
+}
@@ -0,0 +1,209 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test moves items around with Upsert and compares searches with a
+// brute-force search over the current positions
+func TestUpsert(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"Lazy":    nil,
+		"Rebuild": {WithRebuildAfter(50)},
+		"Leaf":    {WithLeafSize(8)},
+		"Scan":    {WithAutoPlan()},
+	} {
+		items, vpitems := randomCoordinates(500)
+		vp := New(CoordinateMetric, vpitems, opts...)
+
+		for i := 0; i < 300; i++ {
+			j := rand.Intn(len(items))
+			moved := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			if !vp.Upsert(items[j], moved) {
+				t.Fatalf("%v: expected %v to be replaced", name, items[j])
+			}
+			items[j] = moved
+
+			if i%3 == 0 {
+				fresh := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+				if vp.Upsert(Coordinate{X: -1, Y: -1}, fresh) {
+					t.Fatalf("%v: expected an insert for an item that isn't in the tree", name)
+				}
+				items = append(items, fresh)
+			}
+		}
+
+		if vp.Len() != len(items) {
+			t.Errorf("%v: expected %v items, got %v", name, len(items), vp.Len())
+		}
+
+		for i := 0; i < 50; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+			coords1, distances1 := vp.Search(q, 10)
+			coords2, distances2 := nearestNeighbours(q, items, 10)
+			compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+
+			within, _ := vp.SearchRadius(q, 0.1)
+			expected := 0
+			for _, c := range items {
+				if CoordinateMetric(c, q) <= 0.1 {
+					expected++
+				}
+			}
+			if len(within) != expected {
+				t.Errorf("%v: expected %v items within 0.1, got %v", name, expected, len(within))
+			}
+		}
+	}
+}
+
+// This test checks the indices of inserted items and the automatic rebuild
+func TestUpsertRebuild(t *testing.T) {
+	_, vpitems := randomCoordinates(100)
+	vp := New(CoordinateMetric, vpitems, WithRebuildAfter(10))
+
+	for i := 0; i < 9; i++ {
+		vp.Upsert(vpitems[i], Coordinate{X: 2 + float64(i)})
+	}
+	if len(vp.deleted) != 9 || vp.root.Size != 109 {
+		t.Fatalf("Expected 9 deleted items in 109 nodes before the rebuild, got %v in %v", len(vp.deleted), vp.root.Size)
+	}
+
+	if indices, _ := vp.SearchIndices(Coordinate{X: 2}, 1); len(indices) != 1 || indices[0] != 100 {
+		t.Errorf("Expected the first inserted item to get index 100, got %v", indices)
+	}
+
+	version := vp.Version()
+	vp.Upsert(vpitems[9], Coordinate{X: 11})
+
+	if len(vp.deleted) != 0 || vp.upserts != 0 || vp.Version() == version {
+		t.Errorf("Expected the tenth upsert to rebuild the tree")
+	}
+	if vp.Len() != 100 || vp.root.Size != 100 {
+		t.Errorf("Expected 100 items after the rebuild, got %v in %v nodes", vp.Len(), vp.root.Size)
+	}
+
+	if indices, _ := vp.SearchIndices(Coordinate{X: 11}, 1); len(indices) != 1 || indices[0] != 109 {
+		t.Errorf("Expected the rebuild to keep the index 109, got %v", indices)
+	}
+
+	empty := New(CoordinateMetric, nil)
+	empty.Upsert(nil, Coordinate{})
+	if results, _ := empty.Search(Coordinate{}, 1); empty.Len() != 1 || len(results) != 1 {
+		t.Errorf("Expected an insert into the empty tree, got %v", results)
+	}
+}
+
+// This test makes sure the methods that visit every item leave out the items
+// Upsert replaced
+func TestUpsertHelpers(t *testing.T) {
+	items, vpitems := randomCoordinates(300)
+	vp := New(CoordinateMetric, vpitems)
+
+	gone := make(map[Coordinate]bool)
+	for _, j := range rand.Perm(len(items))[:50] {
+		gone[items[j]] = true
+		vp.Upsert(items[j], Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+	n := vp.Len()
+
+	check := func(method string, item interface{}) {
+		if gone[item.(Coordinate)] {
+			t.Errorf("%v returned the replaced item %v", method, item)
+		}
+	}
+
+	if edges := vp.MinimumSpanningTree(); len(edges) != n-1 {
+		t.Errorf("Expected %v edges in the MinimumSpanningTree, got %v", n-1, len(edges))
+	} else {
+		for _, e := range edges {
+			check("MinimumSpanningTree", e[0])
+			check("MinimumSpanningTree", e[1])
+		}
+	}
+
+	if edges := vp.MutualReachabilityMST(3); len(edges) != n-1 {
+		t.Errorf("Expected %v edges in the MutualReachabilityMST, got %v", n-1, len(edges))
+	} else {
+		for _, e := range edges {
+			check("MutualReachabilityMST", e[0])
+			check("MutualReachabilityMST", e[1])
+		}
+	}
+
+	clustered := 0
+	for _, c := range vp.HierarchicalClusters(20) {
+		check("HierarchicalClusters", c.Center)
+		for _, item := range c.Items {
+			check("HierarchicalClusters", item)
+		}
+		clustered += len(c.Items)
+	}
+	if clustered != n {
+		t.Errorf("Expected %v items in clusters, got %v", n, clustered)
+	}
+
+	for _, item := range vp.InfluenceZone(Coordinate{X: 0.5, Y: 0.5}) {
+		check("InfluenceZone", item)
+	}
+
+	indices, _ := vp.TopOutliers(3, n+10)
+	if len(indices) != n {
+		t.Errorf("Expected %v outliers, got %v", n, len(indices))
+	}
+	for _, idx := range indices {
+		if vp.deleted[idx] {
+			t.Errorf("TopOutliers returned the replaced item %v", idx)
+		}
+	}
+
+	for idx := range vp.CoverageMap(nil, 5) {
+		if vp.deleted[idx] {
+			t.Errorf("CoverageMap returned the replaced item %v", idx)
+		}
+	}
+
+	for _, item := range vp.WeightedSample(Coordinate{}, 1000, rand.New(rand.NewSource(1))) {
+		check("WeightedSample", item)
+	}
+
+	if est := vp.EstimateIntrinsicDim(0, rand.New(rand.NewSource(1))); est.Samples > n {
+		t.Errorf("Expected at most %v samples, got %v", n, est.Samples)
+	}
+
+	if count := vp.CoveringNumber(0); count != n {
+		t.Errorf("Expected a covering number of %v for eps = 0, got %v", n, count)
+	}
+
+	if sizes := vp.SubtreeSizes(0); len(sizes) != 1 || sizes[0] != n {
+		t.Errorf("Expected a root subtree of %v items, got %v", n, sizes)
+	}
+	sum := 0
+	for _, size := range vp.SubtreeSizes(3) {
+		sum += size
+	}
+	if above := 1 + 2 + 4; sum < n-above || sum > n {
+		t.Errorf("Expected the subtrees at depth 3 to hold up to %v items, got %v", n, sum)
+	}
+
+	deleted := 0
+	vp.Walk(func(info NodeInfo) bool {
+		if info.Deleted != gone[info.Item.(Coordinate)] {
+			t.Errorf("Expected Deleted to be %v for %v", !info.Deleted, info.Item)
+		}
+		if info.Deleted {
+			deleted++
+		}
+		return true
+	})
+	if deleted != len(gone) {
+		t.Errorf("Expected Walk to mark %v nodes as deleted, got %v", len(gone), deleted)
+	}
+
+	if stats := vp.MemoryStats(nil); stats.Nodes != n+len(gone) {
+		t.Errorf("Expected MemoryStats to count %v nodes, got %v", n+len(gone), stats.Nodes)
+	}
+}
@@ -0,0 +1,38 @@
+package vptree
+
+import "errors"
+
+// ErrVersionMismatch is returned by SearchVersioned if the VP-tree was
+// modified after the expected version was read.
+var ErrVersionMismatch = errors.New("vptree: tree was modified since the expected version")
+
+// Version returns a number that changes whenever the structure of the tree
+// changes. Callers that cache anything derived from the tree, such as
+// thresholds or search results, can compare versions to tell whether their
+// cache is still valid.
+func (vp *VPTree) Version() uint64 {
+	return vp.version.Load()
+}
+
+// SearchVersioned is like Search, but fails with ErrVersionMismatch if the
+// tree's version is no longer expectedVersion when it is called. It only
+// detects modifications made before the call: like Search, it must not run
+// concurrently with Upsert or other methods that modify the tree.
+func (vp *VPTree) SearchVersioned(target interface{}, k int, expectedVersion uint64) (results []interface{}, distances []float64, err error) {
+	if vp.Version() != expectedVersion {
+		return nil, nil, ErrVersionMismatch
+	}
+
+	results, distances = vp.Search(target, k)
+	return
+}
+
+// mutated records a modification of the tree by the operation op, bumping
+// its version and calling the OnMutation hook. Every operation that changes
+// the tree's structure must call it.
+func (vp *VPTree) mutated(op string) {
+	vp.version.Add(1)
+	if vp.hooks.OnMutation != nil {
+		vp.hooks.OnMutation(op)
+	}
+}
@@ -0,0 +1,39 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure SearchVersioned searches as long as the version
+// matches, and fails once the tree was modified
+func TestSearchVersioned(t *testing.T) {
+	_, items := randomCoordinates(100)
+
+	var ops []string
+	vp := New(CoordinateMetric, items, WithInstrumentation(Hooks{
+		OnMutation: func(op string) { ops = append(ops, op) },
+	}))
+
+	v := vp.Version()
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	expectedResults, expectedDistances := vp.Search(q, 5)
+	results, distances, err := vp.SearchVersioned(q, 5, v)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+	vp.mutated("test")
+
+	if vp.Version() == v {
+		t.Errorf("Expected the version to change after a modification")
+	}
+	if len(ops) != 1 || ops[0] != "test" {
+		t.Errorf("Expected OnMutation to be called with \"test\", got %v", ops)
+	}
+	if _, _, err := vp.SearchVersioned(q, 5, v); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+}
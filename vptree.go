@@ -1,21 +1,31 @@
 package vptree
 
 import (
-	"container/heap"
+	"cmp"
+	"io"
 	"math"
+	"math/bits"
 	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type node struct {
 	Item      interface{}
+	Index     int
+	Size      int
 	Threshold float64
 	Left      *node
 	Right     *node
 }
 
 type heapItem struct {
-	Item interface{}
-	Dist float64
+	Item  interface{}
+	Index int
+	Dist  float64
 }
 
 // A Metric is a function that measures the distance between two provided
@@ -28,21 +38,190 @@ type heapItem struct {
 //	* d(x, z) <= d(x, y) + d(y, z) (triangle inequality)
 type Metric func(a, b interface{}) float64
 
+// A BoundedMetric computes the same distances as a Metric, but is told an
+// upper bound beyond which the exact distance does not matter. If the
+// distance is at most upper, DistanceBounded must return it exactly;
+// otherwise it may return any value greater than upper. This allows metrics
+// like the edit distance to abandon the computation early. See
+// WithBoundedMetric.
+type BoundedMetric interface {
+	DistanceBounded(a, b interface{}, upper float64) float64
+}
+
+// The BoundedMetricFunc type is an adapter to allow the use of ordinary
+// functions as BoundedMetrics.
+type BoundedMetricFunc func(a, b interface{}, upper float64) float64
+
+// DistanceBounded calls f(a, b, upper).
+func (f BoundedMetricFunc) DistanceBounded(a, b interface{}, upper float64) float64 {
+	return f(a, b, upper)
+}
+
+// An Option configures a VP-tree created by New.
+type Option func(*VPTree)
+
+// WithBoundedMetric makes searches compute distances using bm, passing the
+// largest distance that can still affect the result as the upper bound. bm
+// must agree with the metric passed to New up to that bound, which is still
+// used to build the tree. Results stay exact, since distances beyond the
+// bound are only ever used to reject items and subtrees.
+func WithBoundedMetric(bm BoundedMetric) Option {
+	return func(vp *VPTree) {
+		vp.bounded = bm
+	}
+}
+
 // A VPTree struct represents a Vantage-point tree. Vantage-point trees are
 // useful for nearest-neighbour searches in high-dimensional metric spaces.
 type VPTree struct {
 	root           *node
 	distanceMetric Metric
+
+	// bounded, if not nil, computes distances during searches. See
+	// WithBoundedMetric.
+	bounded BoundedMetric
+
+	// hooks are called to report on searches and builds. See
+	// WithInstrumentation.
+	hooks Hooks
+
+	// spill is the overlap factor of a spill tree, or 0 for a regular
+	// VP-tree. See NewSpillTree.
+	spill float64
+
+	// version counts the modifications of the tree. See Version.
+	version atomic.Uint64
+
+	// scratchMetric, if not nil, is the metric the tree was built with,
+	// and scratchPool holds its unused Scratches. See NewWithScratch.
+	scratchMetric ScratchMetric
+	scratchPool   sync.Pool
+
+	// arena holds the nodes, which are allocated all at once. If nodePool
+	// is not nil, the arena comes from there. See WithNodePool.
+	nodePool *NodePool
+	arena    []node
+
+	// largeKFraction is the fraction of the items from which on Search
+	// switches to SearchLargeK's strategy. See WithLargeKFraction.
+	largeKFraction float64
+
+	// planner, if not nil, decides how searches run. See WithAutoPlan.
+	planner *planner
+
+	// selector, if not nil, picks the vantage points during the build.
+	// See WithVantagePointSelector.
+	selector VantagePointSelector
+
+	// leafSize is the size up to which subtrees are left unpartitioned.
+	// See WithLeafSize.
+	leafSize int
+
+	// heapFactory, if not nil, creates the heaps of k-nearest-neighbour
+	// searches. See WithHeapFactory.
+	heapFactory HeapFactory
+
+	// equals, if not nil, identifies duplicates to drop during the build,
+	// and duplicates counts them. See WithDeduplication.
+	equals     func(a, b interface{}) bool
+	duplicates int
+
+	// internStrings makes equal string items share their memory. See
+	// WithStringInterning.
+	internStrings bool
+
+	// exactMedian makes the build split the items at the median distance
+	// rather than at the distance of an arbitrary item. See NewOnline.
+	exactMedian bool
+
+	// buildDists holds the distances to the vantage point while a node
+	// is built.
+	buildDists []float64
+
+	// richMetric, if not nil, is the metric the VP-tree was built with by
+	// NewRich.
+	richMetric RichMetric
+
+	// itemEncoder, if not nil, encodes the items for MarshalJSON. See
+	// WithItemEncoder.
+	itemEncoder ItemEncoder
+
+	// itemCodec, if not nil, encodes and decodes the items for
+	// MarshalBinary and UnmarshalBinary. See WithItemCodec.
+	itemCodec ItemCodec
+
+	// deleted holds the indices of the items Upsert replaced, and
+	// nextIndex the index of the next item it adds. upserts counts the
+	// upserts since the last rebuild, after rebuildAfter of which the tree
+	// is rebuilt. See Upsert.
+	deleted      map[int]bool
+	nextIndex    int
+	upserts      int
+	rebuildAfter int
+
+	// wal, if not nil, receives a record of every Upsert. walSeq is the
+	// sequence number of the last record, walOffset the length of the log
+	// and walErr the first error writing to it. See WithWAL.
+	wal       io.Writer
+	walSeq    uint64
+	walOffset int64
+	walErr    error
 }
 
 // New creates a new VP-tree using the metric and items provided. The metric
 // measures the distance between two items, so that the VP-tree can find the
 // nearest neighbour(s) of a target item.
-func New(metric Metric, items []interface{}) (t *VPTree) {
+func New(metric Metric, items []interface{}, opts ...Option) (t *VPTree) {
 	t = &VPTree{
 		distanceMetric: metric,
 	}
-	t.root = t.buildFromPoints(items)
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	// Build from a copy of the items, so that the caller's slice is left
+	// untouched, and remember where each item came from.
+	points := make([]interface{}, len(items))
+	copy(points, items)
+	if t.internStrings {
+		internStrings(points)
+	}
+	indices := make([]int, len(items))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	if t.nodePool != nil && len(items) > 0 {
+		t.arena = t.nodePool.get(len(items))
+	} else {
+		t.arena = make([]node, 0, len(items))
+	}
+
+	if t.hooks.OnBuildDone == nil {
+		t.root = t.buildFromPoints(points, indices, 0)
+		t.buildDists = nil
+		return
+	}
+
+	// Count the metric calls of the build only
+	start := time.Now()
+	calls := 0
+	t.distanceMetric = func(a, b interface{}) float64 {
+		calls++
+		return metric(a, b)
+	}
+	t.root = t.buildFromPoints(points, indices, 0)
+	t.buildDists = nil
+	t.distanceMetric = metric
+
+	t.hooks.OnBuildDone(BuildStats{
+		Items:       len(items) - t.duplicates,
+		Duplicates:  t.duplicates,
+		MetricCalls: calls,
+		Height:      height(t.root),
+		Duration:    time.Since(start),
+	})
 	return
 }
 
@@ -50,104 +229,809 @@ func New(metric Metric, items []interface{}) (t *VPTree) {
 // returns the up to k narest neighbours and the corresponding distances in
 // order of least distance to largest distance.
 func (vp *VPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
-	if k < 1 {
+	found := getHeapItems()
+	defer putHeapItems(found)
+
+	*found = vp.nearestInto(*found, target, k)
+	return itemsAndDistances(*found)
+}
+
+// SearchIndices is like Search, but instead of the neighbours themselves it
+// returns their indices in the items slice the VP-tree was built from.
+func (vp *VPTree) SearchIndices(target interface{}, k int) (indices []int, distances []float64) {
+	found := getHeapItems()
+	defer putHeapItems(found)
+
+	*found = vp.nearestInto(*found, target, k)
+	nearest := *found
+	if len(nearest) == 0 {
 		return
 	}
 
-	h := make(priorityQueue, 0, k)
+	indices = make([]int, len(nearest))
+	distances = make([]float64, len(nearest))
+	for i, hi := range nearest {
+		indices[i], distances[i] = hi.Index, hi.Dist
+	}
+
+	return
+}
+
+// KthNearestDistance returns the distance from target to its k-th nearest
+// neighbour, without collecting the neighbours themselves. It returns +Inf if
+// the VP-tree holds fewer than k items or k < 1.
+func (vp *VPTree) KthNearestDistance(target interface{}, k int) float64 {
+	if k < 1 {
+		return math.Inf(1)
+	}
+
+	q := vp.getKNNQuery(target, k)
+	defer putKNNQuery(q)
+	vp.runKNN(q)
+
+	if q.len() < k {
+		return math.Inf(1)
+	}
+
+	return q.tau
+}
+
+// nearest returns the k nearest neighbours of target in order of least
+// distance to largest distance.
+func (vp *VPTree) nearest(target interface{}, k int) []heapItem {
+	return vp.nearestInto(nil, target, k)
+}
+
+// nearestInto is like nearest, but stores the neighbours in found, growing it
+// if needed, and returns the resulting slice.
+func (vp *VPTree) nearestInto(found []heapItem, target interface{}, k int) []heapItem {
+	if k < 1 {
+		return found[:0]
+	}
+
+	q := vp.getNearestQuery(target, k)
+	defer putKNNQuery(q)
+	vp.runKNN(q)
+
+	return q.resultsInto(found)
+}
+
+// getNearestQuery returns the query Search runs for the k nearest neighbours
+// of target, which collects the candidates in a buffer for large k, see
+// WithLargeKFraction, and in a heap otherwise.
+func (vp *VPTree) getNearestQuery(target interface{}, k int) *knnQuery {
+	if vp.isLargeK(k) {
+		return vp.getBufferedQuery(target, k)
+	}
+	return vp.getKNNQuery(target, k)
+}
+
+// nearestSkipping is like nearest, but does not descend into subtrees for
+// which skip returns true. A nil skip function skips nothing.
+func (vp *VPTree) nearestSkipping(target interface{}, k int, skip func(n *node) bool) []heapItem {
+	return vp.nearestSkippingInto(nil, target, k, skip)
+}
+
+// nearestSkippingInto is like nearestSkipping, but stores the neighbours in
+// found like nearestInto.
+func (vp *VPTree) nearestSkippingInto(found []heapItem, target interface{}, k int, skip func(n *node) bool) []heapItem {
+	if k < 1 {
+		return found[:0]
+	}
+
+	q := vp.getKNNQuery(target, k)
+	defer putKNNQuery(q)
+	q.skip = skip
+	vp.runKNN(q)
+
+	return q.resultsInto(found)
+}
+
+// runKNN runs q against the whole tree. If the VP-tree has an OnSearchDone
+// hook and q isn't collecting statistics already, it collects them for the
+// hook.
+func (vp *VPTree) runKNN(q *knnQuery) {
+	if q.stats == nil && (vp.hooks.OnSearchDone != nil || vp.planner != nil) {
+		q.stats = &QueryStats{}
+		defer func() { q.stats = nil }()
+	}
+
+	// A scan would ignore the subtrees q skips, its budget and epsilon
+	approximate := q.skip != nil || q.maxVisits > 0 || q.epsilon > 0
+	if !approximate && vp.plan(q.stats, false, q.k) == PlanScan {
+		vp.scanKNN(q)
+	} else {
+		visited := 0
+		if q.stats != nil {
+			visited = q.stats.NodesVisited
+		}
+
+		vp.search(q, searchFrame{n: vp.root})
+
+		if vp.planner != nil {
+			vp.observe(false, q.stats.NodesVisited-visited)
+		}
+	}
+
+	if q.buffer != nil {
+		q.shrinkBuffer()
+	}
+
+	if q.stats != nil {
+		q.stats.Tau = math.Inf(1)
+		if q.len() == q.k {
+			q.stats.Tau = q.tau
+		}
+
+		if vp.hooks.OnSearchDone != nil {
+			vp.hooks.OnSearchDone(*q.stats)
+		}
+	}
+}
+
+// results empties the query's heap into a slice, in order of least distance
+// to largest distance.
+func (q *knnQuery) results() []heapItem {
+	return q.resultsInto(nil)
+}
 
-	tau := math.MaxFloat64
-	vp.search(vp.root, &tau, target, k, &h)
+// resultsInto is like results, but stores the items in the given slice,
+// growing it if needed, and returns the resulting slice.
+func (q *knnQuery) resultsInto(items []heapItem) []heapItem {
+	if q.buffer != nil {
+		q.shrinkBuffer()
+		slices.SortFunc(q.buffer, func(a, b heapItem) int {
+			return cmp.Compare(a.Dist, b.Dist)
+		})
+		return append(items[:0], q.buffer...)
+	}
 
-	for h.Len() > 0 {
-		hi := heap.Pop(&h)
-		results = append(results, hi.(*heapItem).Item)
-		distances = append(distances, hi.(*heapItem).Dist)
+	if q.custom != nil {
+		items = slices.Grow(items[:0], q.custom.Len())[:q.custom.Len()]
+		for i := len(items) - 1; i >= 0; i-- {
+			hi, _ := q.custom.PopMax()
+			items[i] = hi.(heapItem)
+		}
+		return items
 	}
 
-	// Reverse results and distances, because we popped them from the heap
-	// in large-to-small order
-	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
-		results[i], results[j] = results[j], results[i]
-		distances[i], distances[j] = distances[j], distances[i]
+	items = slices.Grow(items[:0], q.h.Len())[:q.h.Len()]
+	for i := len(items) - 1; i >= 0; i-- {
+		// The heap pops the items in large-to-small order
+		items[i] = q.h.popMax()
 	}
 
+	return items
+}
+
+// maxPooledHeapItems is the capacity up to which getHeapItems' slices are
+// returned to the pool, so that searches for many neighbours don't pin
+// large slices.
+const maxPooledHeapItems = 1024
+
+// heapItemPool recycles the slices Search and SearchIndices collect the
+// neighbours in before copying them out.
+var heapItemPool = sync.Pool{
+	New: func() interface{} { return new([]heapItem) },
+}
+
+// getHeapItems returns an empty slice of heap items from the pool.
+func getHeapItems() *[]heapItem {
+	return heapItemPool.Get().(*[]heapItem)
+}
+
+// putHeapItems clears found, so that it doesn't keep any items alive, and
+// returns it to the pool.
+func putHeapItems(found *[]heapItem) {
+	if cap(*found) > maxPooledHeapItems {
+		return
+	}
+	clear(*found)
+	*found = (*found)[:0]
+	heapItemPool.Put(found)
+}
+
+// SearchRadius searches the VP-tree for all items within distance radius of
+// target. It returns the items and the corresponding distances in order of
+// least distance to largest distance.
+func (vp *VPTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	vp.withinRadius(target, radius, func(found []heapItem) {
+		results = make([]interface{}, len(found))
+		distances = make([]float64, len(found))
+		for i, hi := range found {
+			results[i], distances[i] = hi.Item, hi.Dist
+		}
+	})
+
+	return
+}
+
+// SearchRadiusIndices is like SearchRadius, but returns the indices of the
+// items instead of the items themselves.
+func (vp *VPTree) SearchRadiusIndices(target interface{}, radius float64) (indices []int, distances []float64) {
+	vp.withinRadius(target, radius, func(found []heapItem) {
+		indices = make([]int, len(found))
+		distances = make([]float64, len(found))
+		for i, hi := range found {
+			indices[i], distances[i] = hi.Index, hi.Dist
+		}
+	})
+
 	return
 }
 
-func (vp *VPTree) buildFromPoints(items []interface{}) (n *node) {
+// withinRadius finds the items within radius of target and, if there are
+// any, passes them to collect ordered by increasing distance. The slice is
+// only valid during the call.
+func (vp *VPTree) withinRadius(target interface{}, radius float64, collect func(found []heapItem)) {
+	q := getRadiusQuery(target, radius)
+	defer putRadiusQuery(q)
+	if vp.hooks.OnSearchDone != nil || vp.planner != nil {
+		q.stats = &QueryStats{Tau: radius}
+	}
+
+	if vp.plan(q.stats, true, 0) == PlanScan {
+		vp.scanRadius(q)
+	} else {
+		vp.searchRadius(q, vp.root, 0)
+		if vp.planner != nil {
+			vp.observe(true, q.stats.NodesVisited)
+		}
+	}
+
+	if vp.hooks.OnSearchDone != nil {
+		vp.hooks.OnSearchDone(*q.stats)
+	}
+
+	found := q.found
+
+	if vp.spill > 0 {
+		// Spill trees may hold an item more than once
+		seen := make(map[int]bool, len(found))
+		unique := found[:0]
+		for _, hi := range found {
+			if !seen[hi.Index] {
+				seen[hi.Index] = true
+				unique = append(unique, hi)
+			}
+		}
+		found = unique
+	}
+
+	if len(found) == 0 {
+		return
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Dist < found[j].Dist
+	})
+
+	collect(found)
+}
+
+func (vp *VPTree) buildFromPoints(items []interface{}, indices []int, depth int) (n *node) {
 	if len(items) == 0 {
 		return nil
 	}
 
-	n = &node{}
+	if len(items) <= vp.leafSize {
+		return vp.buildLeaf(items, indices)
+	}
+
+	n, median, rest := vp.partition(items, indices, depth)
+	if rest > 0 {
+		n.Left = vp.buildFromPoints(items[:median], indices[:median], depth+1)
+		n.Right = vp.buildFromPoints(items[median:rest], indices[median:rest], depth+1)
+	}
 
-	// Take a random item out of the items slice and make it this node's item
-	idx := rand.Intn(len(items))
-	n.Item = items[idx]
-	items[idx], items = items[len(items)-1], items[:len(items)-1]
+	// Deduplication may have dropped items
+	n.Size = 1 + n.Left.size() + n.Right.size()
+	return
+}
 
-	if len(items) > 0 {
+// size returns the number of nodes in the subtree rooted at n.
+func (n *node) size() int {
+	if n == nil {
+		return 0
+	}
+	return n.Size
+}
+
+// partition creates the node for items at the given depth. It moves the
+// node's vantage point to the end of items, drops its duplicates if the
+// VP-tree deduplicates items, and splits the first rest of the remaining
+// items at median into those that belong into the left and the right
+// subtree.
+func (vp *VPTree) partition(items []interface{}, indices []int, depth int) (n *node, median, rest int) {
+	// swap exchanges two items, keeping track of their original indices
+	swap := func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+		indices[i], indices[j] = indices[j], indices[i]
+	}
+
+	n = vp.newNode()
+	n.Size = len(items)
+
+	// Take the vantage point out of the items slice and make it this
+	// node's item
+	var idx int
+	if vp.selector != nil {
+		idx = vp.selector(items, depth)
+	} else {
+		idx = rand.Intn(len(items))
+	}
+	swap(idx, len(items)-1)
+	n.Item, n.Index = items[len(items)-1], indices[len(items)-1]
+	items, indices = items[:len(items)-1], indices[:len(indices)-1]
+
+	if len(items) == 0 {
+		return
+	}
+
+	// Compute the distance of every item to the node's item once
+	if cap(vp.buildDists) < len(items) {
+		vp.buildDists = make([]float64, len(items))
+	}
+	dists := vp.buildDists[:len(items)]
+	for i, item := range items {
+		dists[i] = vp.distanceMetric(item, n.Item)
+	}
+
+	swap = func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+		indices[i], indices[j] = indices[j], indices[i]
+		dists[i], dists[j] = dists[j], dists[i]
+	}
+
+	rest = len(items)
+	if vp.equals != nil {
+		// Move the duplicates of the node's item behind the others
+		for i := 0; i < rest; {
+			if dists[i] < deduplicationEpsilon && vp.equals(items[i], n.Item) {
+				rest--
+				swap(i, rest)
+			} else {
+				i++
+			}
+		}
+		vp.duplicates += len(items) - rest
+	}
+
+	if rest > 0 {
 		// Now partition the items into two equal-sized sets, one
 		// closer to the node's item than the median, and one farther
 		// away.
-		median := len(items) / 2
-		pivotDist := vp.distanceMetric(items[median], n.Item)
-		items[median], items[len(items)-1] = items[len(items)-1], items[median]
+		median = rest / 2
+		if vp.exactMedian {
+			selectKth(dists[:rest], median, swap)
+		}
+		pivotDist := dists[median]
+		swap(median, rest-1)
 
 		storeIndex := 0
-		for i := 0; i < len(items)-1; i++ {
-			if vp.distanceMetric(items[i], n.Item) <= pivotDist {
-				items[storeIndex], items[i] = items[i], items[storeIndex]
+		for i := 0; i < rest-1; i++ {
+			if dists[i] <= pivotDist {
+				swap(storeIndex, i)
 				storeIndex++
 			}
 		}
-		items[len(items)-1], items[storeIndex] = items[storeIndex], items[len(items)-1]
+		swap(rest-1, storeIndex)
 		median = storeIndex
 
+		// Keep the pivot with the items at the same distance, so that
+		// duplicates stay together
+		if vp.equals != nil {
+			median++
+		}
+
 		n.Threshold = pivotDist
-		n.Left = vp.buildFromPoints(items[:median])
-		n.Right = vp.buildFromPoints(items[median:])
 	}
 	return
 }
 
-func (vp *VPTree) search(n *node, tau *float64, target interface{}, k int, h *priorityQueue) {
-	if n == nil {
-		return
+// A knnQuery holds the state of a k-nearest-neighbour search.
+type knnQuery struct {
+	target interface{}
+	k      int
+
+	// tau is the distance of the k-th nearest neighbour found so far,
+	// and h holds the up to k nearest neighbours found so far
+	tau float64
+	h   compactQueue
+
+	// skip, if not nil, excludes subtrees from the search
+	skip func(n *node) bool
+
+	// stats, if not nil, is updated as the search proceeds
+	stats *QueryStats
+
+	// maxVisits, if positive, limits the number of nodes visited, and
+	// subtrees are pruned as if tau were tau/(1+epsilon). See Searcher.
+	maxVisits int
+	epsilon   float64
+	visits    int
+
+	// tracing enables recording the search's decisions in trace. See
+	// SearchDebugTrace.
+	tracing bool
+	trace   []TraceEntry
+
+	// sharedTau, if not nil, holds the float64 bits of the smallest tau
+	// of the queries searching parts of the tree in parallel. See
+	// SearchParallel.
+	sharedTau *atomic.Uint64
+
+	// splitDepth, if positive, stops the search at that depth and
+	// collects the frames it would have searched there in frontier
+	splitDepth int32
+	frontier   []searchFrame
+
+	// scratch, if not nil, is passed to the VP-tree's ScratchMetric
+	scratch *Scratch
+
+	// buffer, if not nil, collects the candidates instead of h. See
+	// SearchLargeK.
+	buffer []heapItem
+
+	// custom, if not nil, collects the candidates instead of h. See
+	// WithHeapFactory.
+	custom BoundedHeap
+
+	// current is the node whose distance to the target is computed, so
+	// that SearchSafe can name the item the metric panicked on
+	current *node
+}
+
+// getKNNQuery is like the function getKNNQuery, but sets the query up to
+// use the VP-tree's HeapFactory, if it has one.
+func (vp *VPTree) getKNNQuery(target interface{}, k int) *knnQuery {
+	q := getKNNQuery(target, k, vp.root.size())
+	if vp.heapFactory != nil && vp.spill == 0 {
+		q.custom = vp.heapFactory(k)
+	}
+	return q
+}
+
+// len returns the number of candidates the query holds.
+func (q *knnQuery) len() int {
+	switch {
+	case q.custom != nil:
+		return q.custom.Len()
+	case q.buffer != nil:
+		return len(q.buffer)
+	default:
+		return q.h.Len()
+	}
+}
+
+// knnQueryPools recycle the heaps of k-NN searches. They are sorted into
+// capacity classes, the smallest power of two that is at least k, so that
+// searches for few neighbours don't hold on to large heaps.
+var knnQueryPools [bits.UintSize + 1]sync.Pool
+
+// getKNNQuery returns a query for the k nearest neighbours of target among n
+// items, reusing the memory of an earlier query if possible. The heap is
+// sized for at most n candidates, however large k is. Queries must be
+// returned with putKNNQuery once their results have been copied out.
+func getKNNQuery(target interface{}, k, n int) *knnQuery {
+	class := bits.Len(uint(max(min(k, n), 1) - 1))
+
+	q, _ := knnQueryPools[class].Get().(*knnQuery)
+	if q == nil {
+		q = &knnQuery{h: newCompactQueue(1 << class)}
 	}
 
-	dist := vp.distanceMetric(n.Item, target)
+	q.target, q.k, q.tau = target, k, math.MaxFloat64
+	return q
+}
+
+// putKNNQuery clears q, so that it doesn't keep any items alive, and returns
+// it to its pool.
+func putKNNQuery(q *knnQuery) {
+	class := bits.Len(uint(cap(q.h.dists) - 1))
+
+	q.h.reset()
+	*q = knnQuery{h: q.h}
+
+	knnQueryPools[class].Put(q)
+}
 
-	if dist < *tau {
-		if h.Len() == k {
-			heap.Pop(h)
+// add adds a candidate closer than tau to the query's heap, or to its buffer
+// if it has one.
+func (q *knnQuery) add(c heapItem) {
+	if q.buffer != nil {
+		q.addBuffered(c)
+	} else if q.custom != nil {
+		if q.custom.Len() == q.k {
+			q.custom.PopMax()
+			if q.stats != nil {
+				q.stats.HeapEvictions++
+			}
 		}
-		heap.Push(h, &heapItem{n.Item, dist})
-		if h.Len() == k {
-			*tau = h.Top().(*heapItem).Dist
+		q.custom.Push(c, c.Dist)
+		if q.custom.Len() == q.k {
+			_, q.tau = q.custom.PeekMax()
 		}
+	} else {
+		if q.h.Len() == q.k {
+			q.h.replaceTop(c)
+			if q.stats != nil {
+				q.stats.HeapEvictions++
+			}
+		} else {
+			q.h.push(c)
+		}
+		if q.h.Len() == q.k {
+			q.tau = q.h.dists[0]
+			if q.sharedTau != nil {
+				q.tau = q.publishTau(q.tau)
+			}
+		}
+	}
+
+	if q.tracing {
+		q.trace = append(q.trace, TraceEntry{c.Item, c.Dist, q.tau, TraceAdded})
 	}
+}
 
-	if n.Left == nil && n.Right == nil {
+// A searchFrame is a subtree waiting to be searched. Whether a k-NN search
+// still needs to visit it depends on tau, which may shrink while the
+// subtrees pushed after it are searched, so the check is done when the frame
+// is popped.
+type searchFrame struct {
+	n     *node
+	depth int32
+
+	// check, if not checkNone, compares the distance from the target to
+	// the parent's vantage point against the parent's threshold
+	check frameCheck
+	dist  float64
+	bound float64
+}
+
+// initialStackSize is the initial capacity of the search stack. It covers
+// most trees without growing.
+const initialStackSize = 64
+
+type frameCheck uint8
+
+const (
+	checkNone  frameCheck = iota
+	checkInner            // search if dist-tau <= bound
+	checkOuter            // search if dist+tau >= bound
+)
+
+// search runs q on the subtree of the start frame. It walks the tree with an
+// explicit stack, visiting the nodes in the same order a recursive
+// depth-first search would.
+func (vp *VPTree) search(q *knnQuery, start searchFrame) {
+	if vp.scratchMetric != nil && q.scratch == nil {
+		q.scratch = vp.getScratch()
+		defer func() {
+			vp.scratchPool.Put(q.scratch)
+			q.scratch = nil
+		}()
+	}
+
+	// The stack starts out in a local array, which keeps it off the heap
+	// unless the tree is unusually deep
+	var buf [initialStackSize]searchFrame
+	stack := append(buf[:0], start)
+
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if q.splitDepth > 0 && f.depth == q.splitDepth {
+			q.frontier = append(q.frontier, f)
+			continue
+		}
+
+		if q.sharedTau != nil {
+			q.tau = math.Min(q.tau, math.Float64frombits(q.sharedTau.Load()))
+		}
+
+		tau := q.tau
+		if q.epsilon > 0 {
+			tau /= 1 + q.epsilon
+		}
+
+		if (f.check == checkInner && f.dist-tau > f.bound) || (f.check == checkOuter && f.dist+tau < f.bound) {
+			if q.tracing && f.n != nil {
+				q.tracePrune(f.n, f.check == checkInner, f.dist)
+			}
+			continue
+		}
+
+		n := f.n
+		if n == nil || (q.skip != nil && q.skip(n)) {
+			continue
+		}
+
+		if q.maxVisits > 0 {
+			if q.visits == q.maxVisits {
+				return
+			}
+			q.visits++
+		}
+
+		// Beyond this bound, the distance only decides that the item
+		// is not a neighbour and that the left subtree can be skipped
+		upper := q.tau
+		if n.Left != nil || n.Right != nil {
+			if vp.spill > 0 {
+				upper = math.Max(upper, n.Threshold*(1+vp.spill))
+			} else {
+				upper += n.Threshold
+			}
+		}
+
+		q.current = n
+		dist := vp.distance(n.Item, q.target, upper, q.scratch)
+
+		if q.stats != nil {
+			q.stats.MetricCalls++
+			q.stats.NodesVisited++
+			q.stats.MaxDepth = max(q.stats.MaxDepth, int(f.depth))
+		}
+
+		if q.tracing {
+			q.trace = append(q.trace, TraceEntry{n.Item, dist, q.tau, TraceVisited})
+		}
+
+		if dist < q.tau && vp.live(n) && !(vp.spill > 0 && q.h.contains(n.Index)) {
+			q.add(heapItem{n.Item, n.Index, dist})
+		}
+
+		if n.Left == nil && n.Right == nil {
+			continue
+		}
+
+		// The child to search first is pushed last
+		left := searchFrame{n: n.Left, depth: f.depth + 1, check: checkInner, dist: dist, bound: n.Threshold}
+		right := searchFrame{n: n.Right, depth: f.depth + 1, check: checkOuter, dist: dist, bound: n.Threshold}
+
+		if vp.spill > 0 {
+			// Spill trees don't backtrack, see NewSpillTree
+			if dist >= n.Threshold*(1-vp.spill) {
+				stack = append(stack, searchFrame{n: n.Right, depth: f.depth + 1})
+			} else if q.tracing && n.Right != nil {
+				q.tracePrune(n.Right, false, dist)
+			}
+
+			if dist <= n.Threshold*(1+vp.spill) {
+				stack = append(stack, searchFrame{n: n.Left, depth: f.depth + 1})
+			} else if q.tracing && n.Left != nil {
+				q.tracePrune(n.Left, true, dist)
+			}
+		} else if dist < n.Threshold {
+			stack = append(stack, right, left)
+		} else {
+			stack = append(stack, left, right)
+		}
+	}
+}
+
+// radiusQueryPool recycles the result buffers of range searches.
+var radiusQueryPool sync.Pool
+
+// maxPooledRadiusResults is the largest result buffer kept for reuse, so
+// that a single huge range search doesn't pin its memory.
+const maxPooledRadiusResults = 1 << 16
+
+func getRadiusQuery(target interface{}, radius float64) *radiusQuery {
+	q, _ := radiusQueryPool.Get().(*radiusQuery)
+	if q == nil {
+		q = &radiusQuery{}
+	}
+
+	q.target, q.radius = target, radius
+	return q
+}
+
+func putRadiusQuery(q *radiusQuery) {
+	if cap(q.found) > maxPooledRadiusResults {
 		return
 	}
 
-	if dist < n.Threshold {
-		if dist-*tau <= n.Threshold {
-			vp.search(n.Left, tau, target, k, h)
+	clear(q.found[:cap(q.found)])
+	*q = radiusQuery{found: q.found[:0]}
+
+	radiusQueryPool.Put(q)
+}
+
+// A radiusQuery holds the state of a range search.
+type radiusQuery struct {
+	target interface{}
+	radius float64
+	found  []heapItem
+
+	// emit, if not nil, receives the items found instead of found
+	emit func(hi heapItem)
+
+	// stats, if not nil, is updated as the search proceeds
+	stats *QueryStats
+
+	// splitDepth, if positive, stops the search at that depth and
+	// collects the frames it would have searched there in frontier
+	splitDepth int32
+	frontier   []searchFrame
+
+	// scratch, if not nil, is passed to the VP-tree's ScratchMetric
+	scratch *Scratch
+}
+
+// searchRadius runs q on the subtree rooted at n, whose depth in the tree is
+// depth, visiting the nodes in depth-first order.
+func (vp *VPTree) searchRadius(q *radiusQuery, n *node, depth int) {
+	if vp.scratchMetric != nil && q.scratch == nil {
+		q.scratch = vp.getScratch()
+		defer func() {
+			vp.scratchPool.Put(q.scratch)
+			q.scratch = nil
+		}()
+	}
+
+	var buf [initialStackSize]searchFrame
+	stack := append(buf[:0], searchFrame{n: n, depth: int32(depth)})
+
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if q.splitDepth > 0 && f.depth == q.splitDepth {
+			q.frontier = append(q.frontier, f)
+			continue
 		}
 
-		if dist+*tau >= n.Threshold {
-			vp.search(n.Right, tau, target, k, h)
+		n := f.n
+		if n == nil {
+			continue
 		}
-	} else {
-		if dist+*tau >= n.Threshold {
-			vp.search(n.Right, tau, target, k, h)
+
+		upper := q.radius
+		if n.Left != nil || n.Right != nil {
+			upper += n.Threshold
 		}
 
-		if dist-*tau <= n.Threshold {
-			vp.search(n.Left, tau, target, k, h)
+		dist := vp.distance(n.Item, q.target, upper, q.scratch)
+
+		if q.stats != nil {
+			q.stats.MetricCalls++
+			q.stats.NodesVisited++
+			q.stats.MaxDepth = max(q.stats.MaxDepth, int(f.depth))
+		}
+
+		if dist <= q.radius && vp.live(n) {
+			if q.emit != nil {
+				q.emit(heapItem{n.Item, n.Index, dist})
+			} else {
+				q.found = append(q.found, heapItem{n.Item, n.Index, dist})
+			}
 		}
+
+		// The radius doesn't change, so the children can be checked
+		// right away. The left child is pushed last to be searched
+		// first.
+		if dist+q.radius >= n.Threshold {
+			stack = append(stack, searchFrame{n: n.Right, depth: f.depth + 1})
+		}
+
+		if dist-q.radius <= n.Threshold {
+			stack = append(stack, searchFrame{n: n.Left, depth: f.depth + 1})
+		}
+	}
+}
+
+// distance returns the distance between item and target. If the VP-tree has a
+// BoundedMetric, distances greater than upper may be inexact. If the VP-tree
+// has a ScratchMetric, it is passed scratch.
+func (vp *VPTree) distance(item, target interface{}, upper float64, scratch *Scratch) float64 {
+	if vp.bounded != nil {
+		return vp.bounded.DistanceBounded(item, target, upper)
+	}
+	if scratch != nil {
+		return vp.scratchMetric(item, target, scratch)
 	}
+	return vp.distanceMetric(item, target)
 }
@@ -49,7 +49,7 @@ func nearestNeighbours(target Coordinate, items []Coordinate, k int) (coords []C
 
 	// Push all items onto a heap
 	for _, v := range items {
-		heap.Push(pq, &heapItem{v, CoordinateMetric(v, target)})
+		heap.Push(pq, &heapItem{Item: v, Dist: CoordinateMetric(v, target)})
 	}
 
 	// Pop all but the k smallest items
@@ -184,3 +184,229 @@ func TestConcurrent(t *testing.T) {
 
 	wg.Wait()
 }
+
+// This test makes sure SearchIndices returns the positions of the nearest
+// neighbours in the original items slice
+func TestSearchIndices(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	vp := New(CoordinateMetric, items)
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	coords, distances1 := vp.Search(q, 10)
+	indices, distances2 := vp.SearchIndices(q, 10)
+
+	if len(indices) != len(coords) {
+		t.Fatalf("Expected %v indices, got %v", len(coords), len(indices))
+	}
+
+	for i, idx := range indices {
+		if items[idx] != coords[i] {
+			t.Errorf("Expected items[indices[%v]] to be %v, got %v", i, coords[i], items[idx])
+		}
+		if distances1[i] != distances2[i] {
+			t.Errorf("Expected distances2[%v] to be %v, got %v", i, distances1[i], distances2[i])
+		}
+	}
+}
+
+// This test makes sure KthNearestDistance agrees with Search
+func TestKthNearestDistance(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(20) + 1
+
+		_, distances := vp.Search(q, k)
+		if d := vp.KthNearestDistance(q, k); d != distances[k-1] {
+			t.Errorf("Expected distance %v to the %v-th nearest neighbour, got %v", distances[k-1], k, d)
+		}
+	}
+
+	if d := vp.KthNearestDistance(Coordinate{}, 1001); !math.IsInf(d, 1) {
+		t.Errorf("Expected +Inf for k > len(items), got %v", d)
+	}
+
+	if d := vp.KthNearestDistance(Coordinate{}, 0); !math.IsInf(d, 1) {
+		t.Errorf("Expected +Inf for k = 0, got %v", d)
+	}
+}
+
+// boundedCoordinateMetric is CoordinateMetric as a BoundedMetric that gives
+// up beyond the bound and records how often it did so
+type boundedCoordinateMetric struct {
+	calls     int
+	abandoned int
+}
+
+func (m *boundedCoordinateMetric) DistanceBounded(a, b interface{}, upper float64) float64 {
+	m.calls++
+
+	d := CoordinateMetric(a, b)
+	if d > upper {
+		m.abandoned++
+		return math.Inf(1)
+	}
+	return d
+}
+
+// This test makes sure searches with a BoundedMetric pass useful bounds and
+// still return exact results
+func TestBoundedMetric(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	bm := &boundedCoordinateMetric{}
+
+	vp := New(CoordinateMetric, items, WithBoundedMetric(bm))
+	reference := New(CoordinateMetric, items)
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		results, distances := vp.Search(q, 5)
+		expectedResults, expectedDistances := reference.Search(q, 5)
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+
+		results, distances = vp.SearchRadius(q, 0.05)
+		expectedResults, expectedDistances = reference.SearchRadius(q, 0.05)
+		compareCoordDistSets(t, results, toCoordinates(expectedResults), distances, expectedDistances)
+	}
+
+	if bm.calls == 0 || bm.abandoned == 0 {
+		t.Errorf("Expected the bounded metric to abandon some of its calls, got %v of %v", bm.abandoned, bm.calls)
+	}
+}
+
+func toCoordinates(items []interface{}) []Coordinate {
+	coords := make([]Coordinate, len(items))
+	for i, item := range items {
+		coords[i] = item.(Coordinate)
+	}
+	return coords
+}
+
+func BenchmarkSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	vp := New(CoordinateMetric, items)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vp.Search(Coordinate{X: rng.Float64(), Y: rng.Float64()}, 10)
+	}
+}
+
+func BenchmarkSearchRadius(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	vp := New(CoordinateMetric, items)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vp.SearchRadius(Coordinate{X: rng.Float64(), Y: rng.Float64()}, 0.01)
+	}
+}
+
+// This test makes sure a k far larger than the tree doesn't size the heap
+// after k
+func TestHugeK(t *testing.T) {
+	items, vpitems := randomCoordinates(10)
+	vp := New(CoordinateMetric, vpitems, WithLargeKFraction(math.Inf(1)))
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	expectedCoords, expectedDists := nearestNeighbours(q, items, 10)
+
+	coords, distances := vp.Search(q, math.MaxInt)
+	compareCoordDistSets(t, coords, expectedCoords, distances, expectedDists)
+
+	if d := vp.KthNearestDistance(q, math.MaxInt32); !math.IsInf(d, 1) {
+		t.Errorf("Expected +Inf for k beyond the tree, got %v", d)
+	}
+	if d := vp.KthNearestDistance(q, 10); d != expectedDists[9] {
+		t.Errorf("Expected the distance %v, got %v", expectedDists[9], d)
+	}
+}
+
+// This test makes sure pooled query state doesn't keep items alive
+func TestPooledQueriesCleared(t *testing.T) {
+	_, items := randomCoordinates(100)
+	vp := New(CoordinateMetric, items)
+
+	vp.Search(Coordinate{}, 5)
+	vp.SearchRadius(Coordinate{}, 0.5)
+
+	q := getKNNQuery(nil, 5, 100)
+	for _, item := range q.h.items[:cap(q.h.items)] {
+		if item != nil {
+			t.Errorf("Expected a cleared heap, found %v", item)
+		}
+	}
+
+	r := getRadiusQuery(nil, 0)
+	for _, hi := range r.found[:cap(r.found)] {
+		if hi.Item != nil {
+			t.Errorf("Expected cleared range results, found %v", hi.Item)
+		}
+	}
+}
+
+func BenchmarkSearchConcurrent(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	var items []interface{}
+	for i := 0; i < 100000; i++ {
+		items = append(items, Coordinate{X: rng.Float64(), Y: rng.Float64()})
+	}
+
+	vp := New(CoordinateMetric, items)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < b.N/8; i++ {
+				vp.Search(Coordinate{X: rng.Float64(), Y: rng.Float64()}, 10)
+			}
+			wg.Done()
+		}(int64(g))
+	}
+	wg.Wait()
+}
+
+// This test makes sure Search only allocates the slices it returns
+func TestSearchAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes sync.Pool drop the pooled queries")
+	}
+
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	var target interface{} = Coordinate{0.5, 0.5}
+	vp.Search(target, 10)
+
+	if allocs := testing.AllocsPerRun(100, func() { vp.Search(target, 10) }); allocs > 2 {
+		t.Errorf("Expected at most 2 allocations, got %v", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { vp.SearchIndices(target, 10) }); allocs > 2 {
+		t.Errorf("Expected at most 2 allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,177 @@
+// Package vptreetest provides a conformance harness that checks a VP-tree
+// built with a user-supplied metric and data set against a brute-force
+// search. It is meant to be called from the user's own tests:
+//
+//	func TestMyMetric(t *testing.T) {
+//		vptreetest.RunConformance(t, MyMetric, myItems, vptreetest.Config{Seed: 1})
+//	}
+package vptreetest
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+)
+
+// Config controls how thoroughly RunConformance exercises the VP-tree.
+type Config struct {
+	// Seed seeds the random choice of targets, k and radii, so that
+	// failures can be reproduced.
+	Seed int64
+
+	// Queries is the number of random queries per check. It defaults to
+	// 100.
+	Queries int
+
+	// MaxK is the largest k used for nearest-neighbour queries. It
+	// defaults to 20.
+	MaxK int
+
+	// Targets are the query points. If empty, the items themselves are
+	// used as targets.
+	Targets []interface{}
+
+	// Build builds the index under test. It defaults to vptree.New, but
+	// can return any vptree.Index, such as a vptree.MVPTree.
+	Build func(metric vptree.Metric, items []interface{}) vptree.Index
+}
+
+// RunConformance builds VP-trees, or the indexes cfg.Build returns, over
+// items using metric and checks the results of Search and SearchRadius
+// against a brute-force search. It also covers the edge cases k = 0,
+// k > len(items), the empty tree and data sets containing duplicate items.
+//
+// Since items need not be comparable, results are checked by their
+// distances: the returned distances must equal the brute-force distances,
+// and every returned item must actually be at the distance reported for it.
+func RunConformance(t *testing.T, metric vptree.Metric, items []interface{}, cfg Config) {
+	t.Helper()
+
+	if cfg.Queries <= 0 {
+		cfg.Queries = 100
+	}
+
+	if cfg.MaxK <= 0 {
+		cfg.MaxK = 20
+	}
+
+	if cfg.Build == nil {
+		cfg.Build = func(metric vptree.Metric, items []interface{}) vptree.Index {
+			return vptree.New(metric, items)
+		}
+	}
+
+	targets := cfg.Targets
+	if len(targets) == 0 {
+		targets = items
+	}
+
+	if len(targets) == 0 {
+		t.Fatal("vptreetest: need at least one item or target")
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	tree := cfg.Build(metric, items)
+
+	t.Run("Search", func(t *testing.T) {
+		for i := 0; i < cfg.Queries; i++ {
+			target := targets[rng.Intn(len(targets))]
+			k := rng.Intn(cfg.MaxK) + 1
+			checkSearch(t, tree, metric, items, target, k)
+		}
+	})
+
+	t.Run("SearchRadius", func(t *testing.T) {
+		if len(items) == 0 {
+			t.Skip("no items")
+		}
+
+		for i := 0; i < cfg.Queries; i++ {
+			target := targets[rng.Intn(len(targets))]
+			radius := metric(items[rng.Intn(len(items))], target)
+			checkSearchRadius(t, tree, metric, items, target, radius)
+		}
+	})
+
+	t.Run("EdgeCases", func(t *testing.T) {
+		target := targets[rng.Intn(len(targets))]
+
+		if results, distances := tree.Search(target, 0); len(results) != 0 || len(distances) != 0 {
+			t.Errorf("Search(%v, 0) returned %v results and %v distances, expected none", target, len(results), len(distances))
+		}
+
+		checkSearch(t, tree, metric, items, target, len(items)+1)
+
+		empty := cfg.Build(metric, nil)
+		if results, distances := empty.Search(target, 3); len(results) != 0 || len(distances) != 0 {
+			t.Errorf("Search(%v, 3) on an empty tree returned %v results and %v distances, expected none", target, len(results), len(distances))
+		}
+		if results, distances := empty.SearchRadius(target, 1); len(results) != 0 || len(distances) != 0 {
+			t.Errorf("SearchRadius(%v, 1) on an empty tree returned %v results and %v distances, expected none", target, len(results), len(distances))
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		doubled := make([]interface{}, 0, 2*len(items))
+		doubled = append(doubled, items...)
+		doubled = append(doubled, items...)
+		dupTree := cfg.Build(metric, doubled)
+
+		for i := 0; i < cfg.Queries; i++ {
+			target := targets[rng.Intn(len(targets))]
+			k := rng.Intn(cfg.MaxK) + 1
+			checkSearch(t, dupTree, metric, doubled, target, k)
+		}
+	})
+}
+
+// bruteForce returns the distances of all items to target in ascending order.
+func bruteForce(metric vptree.Metric, items []interface{}, target interface{}) []float64 {
+	distances := make([]float64, len(items))
+	for i, item := range items {
+		distances[i] = metric(item, target)
+	}
+	sort.Float64s(distances)
+	return distances
+}
+
+func checkSearch(t *testing.T, tree vptree.Index, metric vptree.Metric, items []interface{}, target interface{}, k int) {
+	t.Helper()
+
+	expected := bruteForce(metric, items, target)
+	if len(expected) > k {
+		expected = expected[:k]
+	}
+
+	results, distances := tree.Search(target, k)
+	checkResults(t, "Search", target, k, metric, results, distances, expected)
+}
+
+func checkSearchRadius(t *testing.T, tree vptree.Index, metric vptree.Metric, items []interface{}, target interface{}, radius float64) {
+	t.Helper()
+
+	var expected []float64
+	for _, d := range bruteForce(metric, items, target) {
+		if d <= radius {
+			expected = append(expected, d)
+		}
+	}
+
+	results, distances := tree.SearchRadius(target, radius)
+	checkResults(t, "SearchRadius", target, radius, metric, results, distances, expected)
+}
+
+func checkResults(t *testing.T, method string, target, param interface{}, metric vptree.Metric, results []interface{}, distances, expected []float64) {
+	t.Helper()
+
+	ok := len(results) == len(expected) && len(distances) == len(expected)
+	for i := 0; ok && i < len(expected); i++ {
+		ok = distances[i] == expected[i] && metric(results[i], target) == distances[i]
+	}
+
+	if !ok {
+		t.Errorf("%v(%v, %v):\n\texpected distances %v\n\tgot items %v\n\twith distances %v", method, target, param, expected, results, distances)
+	}
+}
@@ -0,0 +1,265 @@
+package vptree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+)
+
+// walHeaderSize is the size of the header of a log record: the length of
+// its payload and the CRC-32 checksum of the payload, both as little-endian
+// uint32s.
+const walHeaderSize = 8
+
+// walUpsert is the operation of a log record of Upsert. It is the only
+// mutation the log records.
+const walUpsert = 1
+
+// ErrNoWAL is returned by Checkpoint if the VP-tree has no write-ahead log.
+var ErrNoWAL = errors.New("vptree: no write-ahead log set")
+
+// WithWAL makes every Upsert append a record to w, a write-ahead log, before
+// it changes the tree, so that Recover can restore the tree after a crash
+// from a snapshot written by Checkpoint and the log. The items are encoded
+// with the ItemCodec set with WithItemCodec.
+//
+// Every record holds the length of its payload and its CRC-32 checksum,
+// followed by the payload: the record's sequence number, the operation, and
+// the items passed to Upsert. Upsert can't return errors, so the first
+// error encoding or writing a record is kept, see WALErr, and no further
+// records are written, since the log would have a gap.
+func WithWAL(w io.Writer) Option {
+	return func(vp *VPTree) {
+		vp.wal = w
+	}
+}
+
+// WALErr returns the first error writing the write-ahead log, or nil if
+// there was none.
+func (vp *VPTree) WALErr() error {
+	return vp.walErr
+}
+
+// WALOffset returns the number of bytes of the write-ahead log that hold
+// complete records, counted from the start of the log that Recover replayed,
+// or of the log set with WithWAL.
+func (vp *VPTree) WALOffset() int64 {
+	return vp.walOffset
+}
+
+// logUpsert appends the record of Upsert(old, new) to the write-ahead log.
+func (vp *VPTree) logUpsert(old, new interface{}) {
+	if vp.walErr != nil {
+		return
+	}
+
+	if vp.itemCodec == nil {
+		vp.walErr = ErrNoItemCodec
+		return
+	}
+
+	buf := make([]byte, walHeaderSize, 64)
+	buf = binary.LittleEndian.AppendUint64(buf, vp.walSeq+1)
+	buf = append(buf, walUpsert)
+
+	item, err := vp.itemCodec.AppendItem(nil, old)
+	if err != nil {
+		vp.walErr = fmt.Errorf("vptree: encoding item: %w", err)
+		return
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(item)))
+	buf = append(buf, item...)
+
+	if buf, err = vp.itemCodec.AppendItem(buf, new); err != nil {
+		vp.walErr = fmt.Errorf("vptree: encoding item: %w", err)
+		return
+	}
+
+	payload := buf[walHeaderSize:]
+	if len(payload) > math.MaxUint32 {
+		vp.walErr = errors.New("vptree: log record too large")
+		return
+	}
+	binary.LittleEndian.PutUint32(buf, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+
+	if _, err := vp.wal.Write(buf); err != nil {
+		vp.walErr = fmt.Errorf("vptree: writing log record %v: %w", vp.walSeq+1, err)
+		return
+	}
+
+	vp.walSeq++
+	vp.walOffset += int64(len(buf))
+}
+
+// Checkpoint writes a snapshot of the VP-tree to w, which Recover can
+// restore the tree from together with the write-ahead log, and returns the
+// offset in the log up to which the snapshot covers the records. The log
+// can be truncated to start at that offset, but doesn't have to be: the
+// snapshot holds the sequence number of the last record it covers, and
+// Recover skips the records up to it.
+//
+// The snapshot is the tree in the binary format of WriteTo, which holds the
+// indices of the items Upsert replaced, followed by the rest of the state of
+// Upsert: the sequence number, the index of the next item and the number of
+// upserts since the last rebuild.
+func (vp *VPTree) Checkpoint(w io.Writer) (walOffset int64, err error) {
+	if vp.wal == nil {
+		return 0, ErrNoWAL
+	}
+	if vp.walErr != nil {
+		return 0, vp.walErr
+	}
+
+	if _, err := vp.WriteTo(w); err != nil {
+		return 0, err
+	}
+
+	if vp.nextIndex == 0 {
+		vp.nextIndex = vp.itemCount()
+	}
+
+	buf := binary.LittleEndian.AppendUint64(nil, vp.walSeq)
+	buf = binary.AppendUvarint(buf, uint64(vp.nextIndex))
+	buf = binary.AppendUvarint(buf, uint64(vp.upserts))
+
+	if _, err := w.Write(buf); err != nil {
+		return 0, fmt.Errorf("vptree: writing snapshot: %w", err)
+	}
+
+	return vp.walOffset, nil
+}
+
+// Recover restores a VP-tree from a snapshot written by Checkpoint and the
+// write-ahead log, replaying the records the snapshot doesn't cover. A nil
+// snapshot starts from an empty tree, for logs without a checkpoint. The
+// metric has to be the one the tree was built with, codec decodes the
+// items, and opts are applied to the tree as by New; WithRebuildAfter
+// should be the same as before, so that the replayed upserts rebuild the
+// tree at the same points. A WithWAL among opts only receives the records
+// of the upserts after the recovery.
+//
+// Replay stops at the end of the log or at the first record that is
+// incomplete or fails its checksum, which is what a crash while writing the
+// last record leaves behind. WALOffset then returns the length of the
+// intact part of the log, to which it should be truncated before appending
+// to it again. Intact records that can't be decoded return an error.
+func Recover(snapshot, wal io.Reader, metric Metric, codec ItemCodec, opts ...Option) (*VPTree, error) {
+	vp := New(metric, nil, append(opts, WithItemCodec(codec))...)
+
+	logTo := vp.wal
+	vp.wal = nil
+
+	if snapshot != nil {
+		if err := vp.readSnapshot(bufio.NewReaderSize(snapshot, binaryBufferSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	covered := vp.walSeq
+	br := bufio.NewReaderSize(wal, binaryBufferSize)
+	var header [walHeaderSize]byte
+	var payload []byte
+
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, fmt.Errorf("vptree: reading log: %w", err)
+		}
+
+		length := binary.LittleEndian.Uint32(header[:])
+		var err error
+		if payload, err = readAtMost(br, payload[:0], int64(length)); err != nil {
+			return nil, fmt.Errorf("vptree: reading log: %w", err)
+		}
+		if uint32(len(payload)) != length || crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+			break
+		}
+
+		// The record is intact, so errors from here on are not
+		// caused by a crash
+		old, new, seq, err := vp.decodeUpsert(payload)
+		if err != nil {
+			return nil, fmt.Errorf("vptree: log record at offset %v: %w", vp.walOffset, err)
+		}
+
+		if seq > covered {
+			vp.Upsert(old, new)
+			vp.walSeq = seq
+		}
+		vp.walOffset += int64(walHeaderSize + len(payload))
+	}
+
+	vp.wal = logTo
+	return vp, nil
+}
+
+// readSnapshot reads a snapshot written by Checkpoint from r.
+func (vp *VPTree) readSnapshot(r *bufio.Reader) error {
+	if _, err := vp.ReadFrom(r); err != nil {
+		return err
+	}
+
+	d := binaryDecoder{r: r}
+	vp.walSeq = d.uint64()
+	nextIndex := d.uvarint()
+	upserts := d.uvarint()
+	if d.err != nil {
+		return fmt.Errorf("vptree: reading snapshot: %w", d.err)
+	}
+	if nextIndex > math.MaxInt || upserts > math.MaxInt {
+		return fmt.Errorf("%w: Upsert state out of range", ErrBinaryFormat)
+	}
+
+	vp.nextIndex, vp.upserts = int(nextIndex), int(upserts)
+	return nil
+}
+
+// decodeUpsert decodes the payload of a log record of Upsert.
+func (vp *VPTree) decodeUpsert(payload []byte) (old, new interface{}, seq uint64, err error) {
+	if len(payload) < 9 || payload[8] != walUpsert {
+		return nil, nil, 0, fmt.Errorf("%w: unknown log record", ErrBinaryFormat)
+	}
+	seq = binary.LittleEndian.Uint64(payload)
+
+	rest := payload[9:]
+	length, n := binary.Uvarint(rest)
+	if n <= 0 || length > uint64(len(rest)-n) {
+		return nil, nil, 0, fmt.Errorf("%w: malformed log record", ErrBinaryFormat)
+	}
+	rest = rest[n:]
+
+	if old, err = vp.itemCodec.DecodeItem(rest[:length]); err != nil {
+		return nil, nil, 0, fmt.Errorf("vptree: decoding item: %w", err)
+	}
+	if new, err = vp.itemCodec.DecodeItem(rest[length:]); err != nil {
+		return nil, nil, 0, fmt.Errorf("vptree: decoding item: %w", err)
+	}
+
+	return old, new, seq, nil
+}
+
+// readAtMost reads up to n bytes from r into buf, growing it only as the
+// bytes arrive, and returns them. Reaching the end of r is not an error.
+func readAtMost(r io.Reader, buf []byte, n int64) ([]byte, error) {
+	for int64(len(buf)) < n {
+		chunk := int(min(n-int64(len(buf)), binaryBufferSize))
+		buf = slices.Grow(buf, chunk)
+		got, err := io.ReadFull(r, buf[len(buf):len(buf)+chunk])
+		buf = buf[:len(buf)+got]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
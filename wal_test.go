@@ -0,0 +1,152 @@
+package vptree
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// walState is what a recovered tree has to agree on with the tree before
+// the crash: its items and the results of a few searches.
+type walState struct {
+	items   map[int]interface{}
+	indices [][]int
+	dists   [][]float64
+}
+
+func captureWALState(vp *VPTree, queries []interface{}) walState {
+	s := walState{items: make(map[int]interface{})}
+	vp.forEachItem(1, func(n *node) {
+		s.items[n.Index] = n.Item
+	})
+	for _, q := range queries {
+		indices, dists := vp.SearchIndices(q, 5)
+		s.indices = append(s.indices, indices)
+		s.dists = append(s.dists, dists)
+	}
+	return s
+}
+
+// This test crashes a tree at every record of its write-ahead log, and in
+// the middle of every record, and checks that the tree recovered from the
+// latest snapshot and the log matches the tree after the last complete
+// record
+func TestRecover(t *testing.T) {
+	_, vpitems := randomCoordinates(200)
+
+	var queries []interface{}
+	for i := 0; i < 5; i++ {
+		queries = append(queries, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	var log bytes.Buffer
+	opts := []Option{WithItemCodec(coordinateCodec{}), WithRebuildAfter(25)}
+	vp := New(CoordinateMetric, vpitems, append(opts, WithWAL(&log))...)
+
+	var snapshots [2]bytes.Buffer
+	if offset, err := vp.Checkpoint(&snapshots[0]); err != nil || offset != 0 {
+		t.Fatalf("Expected a checkpoint at offset 0, got %v, %v", offset, err)
+	}
+
+	items := append([]interface{}(nil), vpitems...)
+	offsets := []int64{0}
+	states := []walState{captureWALState(vp, queries)}
+
+	for i := 1; i <= 60; i++ {
+		j := rand.Intn(len(items))
+		moved := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		vp.Upsert(items[j], moved)
+		items[j] = moved
+
+		offsets = append(offsets, vp.WALOffset())
+		states = append(states, captureWALState(vp, queries))
+
+		if i == 30 {
+			if offset, err := vp.Checkpoint(&snapshots[1]); err != nil || offset != vp.WALOffset() {
+				t.Fatalf("Expected a checkpoint at offset %v, got %v, %v", vp.WALOffset(), offset, err)
+			}
+		}
+	}
+
+	if vp.WALErr() != nil {
+		t.Fatal(vp.WALErr())
+	}
+
+	for i, offset := range offsets {
+		crashes := []int64{offset}
+		if i+1 < len(offsets) {
+			// A torn record
+			crashes = append(crashes, offset+walHeaderSize+3, offsets[i+1]-1)
+		}
+
+		for _, crash := range crashes {
+			snapshot := &snapshots[0]
+			if i >= 30 {
+				snapshot = &snapshots[1]
+			}
+
+			recovered, err := Recover(bytes.NewReader(snapshot.Bytes()), bytes.NewReader(log.Bytes()[:crash]), CoordinateMetric, coordinateCodec{}, opts...)
+			if err != nil {
+				t.Fatalf("Crash at offset %v: %v", crash, err)
+			}
+
+			if recovered.WALOffset() != offset {
+				t.Errorf("Crash at offset %v: expected the intact log to end at %v, got %v", crash, offset, recovered.WALOffset())
+			}
+
+			if s := captureWALState(recovered, queries); !reflect.DeepEqual(s, states[i]) {
+				t.Fatalf("Crash at offset %v: the recovered tree differs from the tree after %v upserts", crash, i)
+			}
+		}
+	}
+
+	// A corrupt record stops the replay like a torn one
+	corrupt := append([]byte(nil), log.Bytes()...)
+	corrupt[offsets[40]+walHeaderSize+2] ^= 0xff
+	recovered, err := Recover(bytes.NewReader(snapshots[1].Bytes()), bytes.NewReader(corrupt), CoordinateMetric, coordinateCodec{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := captureWALState(recovered, queries); !reflect.DeepEqual(s, states[40]) {
+		t.Errorf("Expected the replay to stop before the corrupt record")
+	}
+
+	// Logging continues where the recovered log ends
+	var more bytes.Buffer
+	recovered, _ = Recover(bytes.NewReader(snapshots[1].Bytes()), bytes.NewReader(log.Bytes()), CoordinateMetric, coordinateCodec{}, append(opts, WithWAL(&more))...)
+	if more.Len() != 0 {
+		t.Errorf("Expected the replayed upserts not to be logged again")
+	}
+	recovered.Upsert(items[0], Coordinate{X: 2, Y: 2})
+	if recovered.WALOffset() != offsets[60]+int64(more.Len()) {
+		t.Errorf("Expected the offset to continue from the recovered log")
+	}
+}
+
+// This test makes sure errors writing the log are kept
+func TestWALErrors(t *testing.T) {
+	_, vpitems := randomCoordinates(50)
+
+	if _, err := New(CoordinateMetric, vpitems).Checkpoint(&bytes.Buffer{}); !errors.Is(err, ErrNoWAL) {
+		t.Errorf("Expected ErrNoWAL, got %v", err)
+	}
+
+	vp := New(CoordinateMetric, vpitems, WithItemCodec(coordinateCodec{}), WithWAL(&limitedWriter{limit: 10}))
+	vp.Upsert(vpitems[0], Coordinate{X: 2})
+	vp.Upsert(vpitems[1], Coordinate{X: 3})
+
+	if !errors.Is(vp.WALErr(), errLimit) || vp.WALOffset() != 0 {
+		t.Errorf("Expected the writer's error, got %v", vp.WALErr())
+	}
+	if _, err := vp.Checkpoint(&bytes.Buffer{}); !errors.Is(err, errLimit) {
+		t.Errorf("Expected Checkpoint to fail after a failed log write, got %v", err)
+	}
+
+	vp = New(CoordinateMetric, vpitems, WithWAL(&bytes.Buffer{}))
+	vp.Upsert(vpitems[0], Coordinate{X: 2})
+	if !errors.Is(vp.WALErr(), ErrNoItemCodec) {
+		t.Errorf("Expected ErrNoItemCodec, got %v", vp.WALErr())
+	}
+}
@@ -0,0 +1,66 @@
+package vptree
+
+// NodeInfo describes a node of a VP-tree, as passed to the callback of Walk.
+// It is a copy, so changing it does not change the tree.
+type NodeInfo struct {
+	// Item is the node's vantage point, and Index its index in the items
+	// the VP-tree was built from.
+	Item  interface{}
+	Index int
+
+	// Threshold is the median distance from the vantage point that
+	// separates the left subtree, holding the closer items, from the
+	// right one.
+	Threshold float64
+
+	// Depth is the node's depth, where the root is at depth 0.
+	Depth int
+
+	// Size is the number of nodes in the node's subtree, itself included.
+	Size int
+
+	// Leaf is whether the node has no children.
+	Leaf bool
+
+	// Deleted is whether Upsert replaced the node's item. The node still
+	// splits its subtree until the tree is rebuilt, but its item is no
+	// longer in the VP-tree.
+	Deleted bool
+}
+
+// Len returns the number of items in the VP-tree, not counting the items
+// Upsert replaced.
+func (vp *VPTree) Len() int {
+	switch {
+	case vp.root == nil:
+		return 0
+	case vp.spill > 0:
+		// Spill trees may hold an item in more than one node
+		return vp.itemCount() - len(vp.deleted)
+	default:
+		return vp.root.Size - len(vp.deleted)
+	}
+}
+
+// Walk calls fn for every node of the VP-tree in preorder: first a node,
+// then its left subtree and then its right subtree. If fn returns false, the
+// node's subtree is skipped. The order only depends on the tree, so walking
+// the same tree twice visits the nodes in the same order. The nodes of the
+// items Upsert replaced are visited as well, marked as Deleted.
+func (vp *VPTree) Walk(fn func(n NodeInfo) bool) {
+	vp.walk(vp.root, 0, fn)
+}
+
+func (vp *VPTree) walk(n *node, depth int, fn func(n NodeInfo) bool) {
+	if n == nil {
+		return
+	}
+
+	leaf := n.Left == nil && n.Right == nil
+	if !fn(NodeInfo{n.Item, n.Index, n.Threshold, depth, n.Size, leaf, !vp.live(n)}) || leaf {
+		return
+	}
+
+	vp.walk(n.Left, depth+1, fn)
+	vp.walk(n.Right, depth+1, fn)
+}
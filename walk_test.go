@@ -0,0 +1,88 @@
+package vptree
+
+import (
+	"slices"
+	"testing"
+)
+
+// This test walks a tree and checks the nodes against Len, LevelCounts and
+// the structure of the tree
+func TestWalk(t *testing.T) {
+	_, items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, items)
+
+	if vp.Len() != len(items) {
+		t.Errorf("Expected Len to be %v, got %v", len(items), vp.Len())
+	}
+
+	var counts []int
+	var order []int
+	seen := make(map[int]bool)
+	var stack []NodeInfo
+	vp.Walk(func(n NodeInfo) bool {
+		for len(counts) <= n.Depth {
+			counts = append(counts, 0)
+		}
+		counts[n.Depth]++
+		order = append(order, n.Index)
+		seen[n.Index] = true
+
+		if n.Item != items[n.Index] {
+			t.Errorf("Expected item %v at index %v, got %v", items[n.Index], n.Index, n.Item)
+		}
+
+		// In preorder, the parent of a node is the last node visited
+		// above it
+		for len(stack) > 0 && stack[len(stack)-1].Depth >= n.Depth {
+			stack = stack[:len(stack)-1]
+		}
+		if n.Depth > 0 && (len(stack) == 0 || stack[len(stack)-1].Depth != n.Depth-1) {
+			t.Errorf("Expected a parent for the node at depth %v", n.Depth)
+		}
+		stack = append(stack, n)
+
+		if n.Leaf != (n.Size == 1) {
+			t.Errorf("Expected only nodes of size 1 to be leaves, got size %v and leaf %v", n.Size, n.Leaf)
+		}
+		return true
+	})
+
+	if len(order) != vp.Len() || len(seen) != vp.Len() {
+		t.Errorf("Expected to visit %v distinct nodes, visited %v nodes and %v distinct ones", vp.Len(), len(order), len(seen))
+	}
+	if !slices.Equal(counts, vp.LevelCounts()) {
+		t.Errorf("Expected the depths to agree with LevelCounts %v, got %v", vp.LevelCounts(), counts)
+	}
+
+	var again []int
+	vp.Walk(func(n NodeInfo) bool {
+		again = append(again, n.Index)
+		return true
+	})
+	if !slices.Equal(order, again) {
+		t.Errorf("Expected walking the tree twice to visit the nodes in the same order")
+	}
+
+	// Skipping the subtrees below depth 2 leaves the first three levels,
+	// which need not be full, since the splits are not exactly even
+	visited := 0
+	vp.Walk(func(n NodeInfo) bool {
+		visited++
+		if n.Depth > 2 {
+			t.Errorf("Expected no nodes below depth 2, got one at depth %v", n.Depth)
+		}
+		return n.Depth < 2
+	})
+	if expected := counts[0] + counts[1] + counts[2]; visited != expected {
+		t.Errorf("Expected to visit %v nodes, visited %v", expected, visited)
+	}
+
+	empty := New(CoordinateMetric, nil)
+	empty.Walk(func(n NodeInfo) bool {
+		t.Errorf("Expected no nodes in an empty tree")
+		return true
+	})
+	if empty.Len() != 0 {
+		t.Errorf("Expected an empty tree to have Len 0, got %v", empty.Len())
+	}
+}